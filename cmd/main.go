@@ -22,8 +22,12 @@ import (
 	_ "github.com/lib/pq"
 
 	api "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/audit"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/auth"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/crawler"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/database"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/jobs"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/ratelimit"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/repositories"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/services"
 )
@@ -160,14 +164,65 @@ func main() {
 	if _, err := repositoriesService.CreateOrganisation(context.Background(), &models.Organisation{Uri: "https://developer.overheid.nl/", Label: "Developer overheid"}); err != nil {
 		fmt.Printf("[Developer-overheid-import] create org warning: %v\n", err)
 	}
-	if err := repositoriesService.PublishAllRepositoriesToTypesense(context.Background()); err != nil {
-		log.Fatalf("[typesense-sync] bulk publish failed: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "reindex" {
+		reindex(repositoriesService)
+		return
 	}
-	jobs.NewRepositoryActiveJob(repo).Start(context.Background())
+	scheduler := jobs.NewScheduler(repo)
+	if jobs.CrawlerEnabled() {
+		crawlJob := jobs.NewRepositoryCrawlJob(crawler.New(repo, repositoriesService, crawler.ConfigFromEnv()))
+		if err := scheduler.Register(jobs.RepositoryCrawlJobName, jobs.DefaultRepositoryCrawlSchedule, crawlJob.Run); err != nil {
+			log.Fatalf("failed to register job: %v", err)
+		}
+	}
+	if err := scheduler.Register(jobs.RepositoryActiveJobName, jobs.DefaultRepositoryActiveSchedule, jobs.NewRepositoryActiveJob(repo).Run); err != nil {
+		log.Fatalf("failed to register job: %v", err)
+	}
+	repositoriesService.SetJobScheduler(scheduler)
+	scheduler.Start(context.Background())
+	go func() {
+		if err := repositoriesService.EnsureSearchIndex(context.Background()); err != nil {
+			log.Printf("[typesense] creating the search index failed: %v", err)
+		}
+	}()
+	jobs.NewSearchIndexOutboxJob(repo).Start(context.Background())
 
 	// Start server
-	router := api.NewRouter(version, controller)
+	auditConfig, err := audit.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("[audit] invalid configuration: %v", err)
+	}
+	routerOpts := []api.RouterOption{api.WithAuditConfig(auditConfig)}
+	if auth.Enabled() {
+		authConfig, err := auth.ConfigFromEnv()
+		if err != nil {
+			log.Fatalf("[auth] invalid configuration: %v", err)
+		}
+		routerOpts = append(routerOpts, api.WithAuthenticator(auth.NewAuthenticator(authConfig)))
+	} else {
+		log.Println("[auth] authentication is disabled (ENABLE_AUTH=false)")
+	}
+	if ratelimit.Enabled() {
+		rateLimitConfig, err := ratelimit.ConfigFromEnv()
+		if err != nil {
+			log.Fatalf("[ratelimit] invalid configuration: %v", err)
+		}
+		routerOpts = append(routerOpts, api.WithRateLimiter(ratelimit.NewLimiter(rateLimitConfig)))
+	} else {
+		log.Println("[ratelimit] rate limiting is disabled (ENABLE_RATE_LIMIT=false)")
+	}
+	router := api.NewRouter(version, controller, routerOpts...)
 
 	log.Println("Server is running on port 1337")
 	log.Fatal(http.ListenAndServe(":1337", router))
 }
+
+// reindex bouwt de Typesense-index opnieuw op en stopt daarna; start met
+// `go run ./cmd reindex`.
+func reindex(service *services.RepositoryService) {
+	result, err := service.ReindexTypesense(auth.WithInternal(context.Background()))
+	if err != nil {
+		log.Fatalf("[typesense] reindex failed: %v", err)
+	}
+	log.Printf("[typesense] alias points at %s (%d documents, previous %q)", result.Collection, result.Documents, result.PreviousCollection)
+}
@@ -0,0 +1,228 @@
+// Package audit records every write request to the API in the audit log:
+// who sent it, which operation on which resource, a hash of the body and the
+// response status. Anonymous requests that are rejected are only counted.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/auth"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/operation"
+	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/gin-gonic/gin"
+)
+
+// ActorAnonymous is recorded for requests without an authenticated caller:
+// requests rejected by authentication, webhooks and servers that run without
+// authentication.
+const ActorAnonymous = "anonymous"
+
+const (
+	// EnvMaxBodySize is the largest request body in bytes the middleware
+	// reads to hash it.
+	EnvMaxBodySize = "AUDIT_MAX_BODY_SIZE"
+	// DefaultMaxBodySize fits the largest body an endpoint accepts, that of a
+	// bulk upsert.
+	DefaultMaxBodySize = 10 << 20
+
+	resourceKey = "audit.resource"
+
+	rejectionLogInterval = time.Minute
+)
+
+// Config configures the audit middleware.
+type Config struct {
+	// MaxBodySize is the largest request body that is read; larger bodies
+	// are answered with 413 before the request is handled. Zero means
+	// DefaultMaxBodySize.
+	MaxBodySize int64
+}
+
+// ConfigFromEnv reads the configuration from AUDIT_MAX_BODY_SIZE.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{MaxBodySize: DefaultMaxBodySize}
+	if raw := strings.TrimSpace(os.Getenv(EnvMaxBodySize)); raw != "" {
+		size, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || size <= 0 {
+			return Config{}, fmt.Errorf("audit: %s must be a positive number of bytes", EnvMaxBodySize)
+		}
+		cfg.MaxBodySize = size
+	}
+	return cfg, nil
+}
+
+// Recorder stores audit events.
+type Recorder interface {
+	RecordAuditEvent(ctx context.Context, event *models.AuditEvent) error
+}
+
+type resource struct {
+	resourceType string
+	id           string
+}
+
+// SetResource names the resource a request created or changed, for handlers
+// whose route does not carry the resource id.
+func SetResource(c *gin.Context, resourceType, id string) {
+	c.Set(resourceKey, resource{resourceType: resourceType, id: id})
+}
+
+// Middleware records an audit event for every POST, PUT, PATCH and DELETE
+// request once it has been handled. It must run before the authentication
+// middleware so that rejected requests are seen as well. Anonymous requests
+// rejected with 401, 403 or 413 are not stored but counted, so unauthenticated
+// callers cannot fill the log. A failure to record is logged and does not
+// change the response.
+func Middleware(recorder Recorder, lookup operation.Lookup, cfg Config) gin.HandlerFunc {
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DefaultMaxBodySize
+	}
+	rejections := &rejectionCounter{since: time.Now()}
+	return func(c *gin.Context) {
+		if !isWrite(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		bodyHash, err := hashBody(c, cfg.MaxBodySize)
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			abort(c, problem.New(http.StatusRequestEntityTooLarge, "Request body too large", problem.ErrorDetail{
+				In:     "body",
+				Code:   "too_large",
+				Detail: fmt.Sprintf("request body must not exceed %d bytes", tooLarge.Limit),
+			}))
+		case err != nil:
+			log.Printf("[audit] reading request body failed: %v", err)
+		}
+
+		if !c.IsAborted() {
+			c.Next()
+		}
+
+		event := &models.AuditEvent{
+			Actor:    ActorAnonymous,
+			Method:   c.Request.Method,
+			Path:     c.Request.URL.Path,
+			BodyHash: bodyHash,
+			Status:   c.Writer.Status(),
+		}
+		if principal, ok := auth.PrincipalFromGin(c); ok && principal.ClientID != "" {
+			event.Actor = principal.ClientID
+		}
+		if event.Actor == ActorAnonymous && rejected(event.Status) {
+			rejections.add()
+			return
+		}
+		if op, ok := lookup(c.Request.Method, c.FullPath()); ok {
+			event.OperationId = op.ID
+		}
+		event.ResourceType, event.ResourceId = resourceOf(c)
+
+		ctx := context.WithoutCancel(c.Request.Context())
+		if err := recorder.RecordAuditEvent(ctx, event); err != nil {
+			log.Printf("[audit] recording %s %s failed: %v", event.Method, event.Path, err)
+		}
+	}
+}
+
+// rejected reports whether status is one of the rejections that are counted
+// instead of stored for anonymous requests.
+func rejected(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestEntityTooLarge:
+		return true
+	}
+	return false
+}
+
+// rejectionCounter counts the rejected anonymous requests and logs the count
+// at most once per rejectionLogInterval.
+type rejectionCounter struct {
+	mu    sync.Mutex
+	count int
+	since time.Time
+}
+
+func (r *rejectionCounter) add() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.count++
+	now := time.Now()
+	if now.Sub(r.since) < rejectionLogInterval {
+		return
+	}
+	log.Printf("[audit] %d anonymous write requests rejected since %s", r.count, r.since.Format(time.RFC3339))
+	r.count = 0
+	r.since = now
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// hashBody returns the hex SHA-256 of the request body and puts the body back
+// for the handler. An empty body has no hash. A body larger than limit gives
+// an *http.MaxBytesError.
+func hashBody(c *gin.Context, limit int64) (string, error) {
+	r := c.Request
+	if r.Body == nil || r.Body == http.NoBody {
+		return "", nil
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, r.Body, limit))
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil || len(body) == 0 {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func abort(c *gin.Context, p problem.ProblemJSON) {
+	c.Abort()
+	c.Header("Content-Type", "application/problem+json")
+	c.JSON(p.Status, p)
+}
+
+// resourceOf returns the resource set with SetResource, or else the resource
+// type from the route (/v1/repositories/:id gives repositories) with the id
+// from its path parameter.
+func resourceOf(c *gin.Context) (string, string) {
+	if value, ok := c.Get(resourceKey); ok {
+		if res, ok := value.(resource); ok {
+			return res.resourceType, res.id
+		}
+	}
+
+	route := strings.TrimPrefix(c.FullPath(), "/")
+	segments := strings.Split(route, "/")
+	resourceType := ""
+	if len(segments) > 1 {
+		resourceType = segments[1]
+	}
+	for _, name := range []string{"id", "uri", "forge"} {
+		if id := c.Param(name); id != "" {
+			return resourceType, id
+		}
+	}
+	return resourceType, ""
+}
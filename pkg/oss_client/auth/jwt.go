@@ -0,0 +1,323 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errInvalidToken = errors.New("auth: invalid token")
+	// errKeySetUnavailable means the token could not be checked because the
+	// JWKS could not be loaded; the caller is not to blame.
+	errKeySetUnavailable = errors.New("auth: key set unavailable")
+	// errInvalidAPIKey means the X-Api-Key value is not a known key.
+	errInvalidAPIKey = errors.New("auth: unknown api key")
+)
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// audience accepts both the string and the array form of the aud claim.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+type jwtClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+	Scope     string   `json:"scope"`
+	ClientID  string   `json:"client_id"`
+	AZP       string   `json:"azp"`
+}
+
+// verifyToken checks signature, expiry, issuer and audience of a compact JWS
+// and returns the client credentials principal it describes.
+func (a *Authenticator) verifyToken(ctx context.Context, token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", errInvalidToken)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", errInvalidToken, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature encoding", errInvalidToken)
+	}
+	if a.keys == nil {
+		return nil, fmt.Errorf("%w: no JWKS configured", errInvalidToken)
+	}
+	key, err := a.keys.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", errInvalidToken, err)
+	}
+	now := a.now()
+	if claims.ExpiresAt == nil || now.After(unixTime(*claims.ExpiresAt).Add(a.cfg.Leeway)) {
+		return nil, fmt.Errorf("%w: expired", errInvalidToken)
+	}
+	if claims.NotBefore != nil && now.Add(a.cfg.Leeway).Before(unixTime(*claims.NotBefore)) {
+		return nil, fmt.Errorf("%w: not yet valid", errInvalidToken)
+	}
+	if a.cfg.Issuer != "" && claims.Issuer != a.cfg.Issuer {
+		return nil, fmt.Errorf("%w: issuer %q", errInvalidToken, claims.Issuer)
+	}
+	if a.cfg.Audience != "" && !slices.Contains(claims.Audience, a.cfg.Audience) {
+		return nil, fmt.Errorf("%w: audience", errInvalidToken)
+	}
+
+	clientID := claims.ClientID
+	if clientID == "" {
+		clientID = claims.AZP
+	}
+	if clientID == "" {
+		clientID = claims.Subject
+	}
+	return &Principal{
+		ClientID: clientID,
+		Scheme:   SchemeClientCredentials,
+		Scopes:   strings.Fields(claims.Scope),
+	}, nil
+}
+
+func decodeSegment(segment string, out any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+func unixTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signingInput, signature []byte) error {
+	var hash crypto.Hash
+	switch alg[len(alg)-min(len(alg), 3):] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("%w: unsupported alg %q", errInvalidToken, alg)
+	}
+	h := hash.New()
+	h.Write(signingInput)
+	digest := h.Sum(nil)
+
+	var err error
+	switch {
+	case strings.HasPrefix(alg, "RS"):
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: key does not match alg %s", errInvalidToken, alg)
+		}
+		err = rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+	case strings.HasPrefix(alg, "PS"):
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: key does not match alg %s", errInvalidToken, alg)
+		}
+		err = rsa.VerifyPSS(pub, hash, digest, signature, nil)
+	case strings.HasPrefix(alg, "ES"):
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: key does not match alg %s", errInvalidToken, alg)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("%w: signature length", errInvalidToken)
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			err = errors.New("ecdsa verification failed")
+		}
+	default:
+		return fmt.Errorf("%w: unsupported alg %q", errInvalidToken, alg)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: signature: %v", errInvalidToken, err)
+	}
+	return nil
+}
+
+// keySet caches the signing keys published at a JWKS url. Unknown key ids
+// trigger a refetch, at most once per minRefresh, so rotated keys are picked up.
+type keySet struct {
+	url        string
+	client     *http.Client
+	ttl        time.Duration
+	minRefresh time.Duration
+	now        func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func (s *keySet) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	stale := s.keys == nil || now.Sub(s.fetchedAt) > s.ttl
+	_, known := s.keys[kid]
+	if stale || (!known && now.Sub(s.fetchedAt) > s.minRefresh) {
+		if err := s.refresh(ctx); err != nil {
+			if s.keys == nil {
+				return nil, err
+			}
+			// Keep serving the cached keys when the JWKS is temporarily unreachable.
+		}
+	}
+
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown key id %q", errInvalidToken, kid)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (s *keySet) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errKeySetUnavailable, err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errKeySetUnavailable, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: GET %s returned %d", errKeySetUnavailable, s.url, resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("%w: %v", errKeySetUnavailable, err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	s.keys = keys
+	s.fetchedAt = s.now()
+	return nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("rsa exponent too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) > size || len(y) > size {
+			return nil, errors.New("ec coordinate too large")
+		}
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		copy(point[1+size-len(x):1+size], x)
+		copy(point[1+2*size-len(y):], y)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
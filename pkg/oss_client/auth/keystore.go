@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"strings"
+)
+
+// KeyStore resolves an X-Api-Key value to the key's principal. It returns nil
+// without error for unknown keys.
+type KeyStore interface {
+	LookupAPIKey(ctx context.Context, key string) (*Principal, error)
+}
+
+// StaticKeyStore holds a fixed set of API keys, indexed by their SHA-256 hash
+// so the plain keys are not kept in memory after construction.
+type StaticKeyStore struct {
+	keys map[[sha256.Size]byte]string
+}
+
+// NewStaticKeyStore maps key ids to API keys.
+func NewStaticKeyStore(keys map[string]string) *StaticKeyStore {
+	store := &StaticKeyStore{keys: make(map[[sha256.Size]byte]string, len(keys))}
+	for id, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			store.keys[sha256.Sum256([]byte(key))] = strings.TrimSpace(id)
+		}
+	}
+	return store
+}
+
+// ParseStaticKeyStore reads comma-separated id:key pairs, as used in AUTH_API_KEYS.
+func ParseStaticKeyStore(raw string) *StaticKeyStore {
+	keys := map[string]string{}
+	for _, entry := range strings.Split(raw, ",") {
+		id, key, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || strings.TrimSpace(id) == "" {
+			continue
+		}
+		keys[id] = key
+	}
+	return NewStaticKeyStore(keys)
+}
+
+func (s *StaticKeyStore) LookupAPIKey(_ context.Context, key string) (*Principal, error) {
+	id, ok := s.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, nil
+	}
+	return &Principal{ClientID: id, Scheme: SchemeAPIKey}, nil
+}
@@ -0,0 +1,284 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	httpclient "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/httpclient"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/operation"
+	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
+	"github.com/gin-gonic/gin"
+	"github.com/wI2L/fizz/openapi"
+)
+
+const (
+	EnvEnableAuth = "ENABLE_AUTH"
+	EnvJWKSURL    = "AUTH_JWKS_URL"
+	EnvIssuer     = "AUTH_ISSUER"
+	EnvAudience   = "AUTH_AUDIENCE"
+	EnvAPIKeys    = "AUTH_API_KEYS"
+	// EnvAdminClients lists the client ids that may write every organisation.
+	EnvAdminClients = "AUTH_ADMIN_CLIENTS"
+	// EnvClientOrganisations maps client ids to the organisation URIs they may
+	// write, as a JSON object of string arrays.
+	EnvClientOrganisations = "AUTH_CLIENT_ORGANISATIONS"
+
+	apiKeyHeader = "X-Api-Key"
+
+	defaultLeeway        = 30 * time.Second
+	jwksCacheTTL         = 15 * time.Minute
+	jwksMinRefreshPeriod = 30 * time.Second
+)
+
+// Config configures token and API key verification. Issuer and Audience are
+// only checked when set. Without a JWKSURL every bearer token is rejected.
+// AdminClients and ClientOrganisations decide which organisations a client may
+// write; clients in neither may not write organisation data.
+type Config struct {
+	JWKSURL             string
+	Issuer              string
+	Audience            string
+	APIKeys             KeyStore
+	AdminClients        []string
+	ClientOrganisations map[string][]string
+	HTTPClient          *http.Client
+	Leeway              time.Duration
+}
+
+// Enabled reports whether authentication is enforced. It is on unless
+// ENABLE_AUTH is set to false.
+func Enabled() bool {
+	return !strings.EqualFold(strings.TrimSpace(os.Getenv(EnvEnableAuth)), "false")
+}
+
+// ConfigFromEnv reads the authentication configuration from AUTH_* env vars.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		JWKSURL:  strings.TrimSpace(os.Getenv(EnvJWKSURL)),
+		Issuer:   strings.TrimSpace(os.Getenv(EnvIssuer)),
+		Audience: strings.TrimSpace(os.Getenv(EnvAudience)),
+		APIKeys:  ParseStaticKeyStore(os.Getenv(EnvAPIKeys)),
+	}
+	for _, id := range strings.Split(os.Getenv(EnvAdminClients), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			cfg.AdminClients = append(cfg.AdminClients, id)
+		}
+	}
+	if raw := strings.TrimSpace(os.Getenv(EnvClientOrganisations)); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg.ClientOrganisations); err != nil {
+			return Config{}, fmt.Errorf("auth: %s must be a JSON object of organisation URI arrays: %w", EnvClientOrganisations, err)
+		}
+	}
+	return cfg, nil
+}
+
+// Authenticator verifies the credentials of a request.
+type Authenticator struct {
+	cfg  Config
+	keys *keySet
+	now  func() time.Time
+}
+
+func NewAuthenticator(cfg Config) *Authenticator {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = httpclient.HTTPClient
+	}
+	if cfg.Leeway == 0 {
+		cfg.Leeway = defaultLeeway
+	}
+	a := &Authenticator{cfg: cfg, now: time.Now}
+	if cfg.JWKSURL != "" {
+		a.keys = &keySet{
+			url:        cfg.JWKSURL,
+			client:     cfg.HTTPClient,
+			ttl:        jwksCacheTTL,
+			minRefresh: jwksMinRefreshPeriod,
+			now:        func() time.Time { return a.now() },
+		}
+	}
+	return a
+}
+
+// RequirementLookup returns the security requirements of an operation, keyed
+// by HTTP method and gin route path. ok is false for unknown routes.
+type RequirementLookup func(method, fullPath string) (requirements []*openapi.SecurityRequirement, ok bool)
+
+// OperationRequirements returns the requirements declared with fizz.Security
+// on the operations of lookup.
+func OperationRequirements(lookup operation.Lookup) RequirementLookup {
+	return func(method, fullPath string) ([]*openapi.SecurityRequirement, bool) {
+		op, ok := lookup(method, fullPath)
+		if !ok {
+			return nil, false
+		}
+		return op.Security, true
+	}
+}
+
+// Middleware enforces the security requirements of each route. Routes without
+// requirements are public. Requirements are alternatives; all schemes and
+// scopes within one requirement must be satisfied.
+func (a *Authenticator) Middleware(lookup RequirementLookup) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requirements, ok := lookup(c.Request.Method, c.FullPath())
+		if !ok || optional(requirements) {
+			c.Next()
+			return
+		}
+
+		principals, err := a.authenticate(c)
+		if err != nil {
+			if errors.Is(err, errKeySetUnavailable) {
+				log.Printf("[auth] %v", err)
+				abort(c, problem.NewInternalServerError("Token could not be verified"))
+				return
+			}
+			c.Header("WWW-Authenticate", challenge(err))
+			abort(c, problem.NewUnauthorized("Invalid credentials"))
+			return
+		}
+		if len(principals) == 0 {
+			c.Header("WWW-Authenticate", "Bearer")
+			abort(c, problem.NewUnauthorized("Authentication required"))
+			return
+		}
+
+		for _, requirement := range requirements {
+			if principal := satisfies(*requirement, principals); principal != nil {
+				c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), principal))
+				c.Next()
+				return
+			}
+		}
+
+		scopes := requiredScopes(requirements)
+		c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, strings.Join(scopes, " ")))
+		details := make([]problem.ErrorDetail, 0, len(scopes))
+		for _, scope := range scopes {
+			details = append(details, problem.ErrorDetail{
+				In:       "header",
+				Location: "#/Authorization",
+				Code:     "scope",
+				Detail:   fmt.Sprintf("scope %s is required", scope),
+			})
+		}
+		abort(c, problem.NewForbidden("Insufficient scope", details...))
+	}
+}
+
+// authenticate verifies the bearer token and API key that were sent. It
+// returns no principals when the request carries no credentials.
+func (a *Authenticator) authenticate(c *gin.Context) ([]*Principal, error) {
+	var principals []*Principal
+	if header := strings.TrimSpace(c.GetHeader("Authorization")); header != "" {
+		scheme, token, _ := strings.Cut(header, " ")
+		if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return nil, fmt.Errorf("%w: unsupported authorization scheme", errInvalidToken)
+		}
+		principal, err := a.verifyToken(c.Request.Context(), strings.TrimSpace(token))
+		if err != nil {
+			return nil, err
+		}
+		principals = append(principals, a.grant(principal))
+	}
+	if key := strings.TrimSpace(c.GetHeader(apiKeyHeader)); key != "" {
+		if a.cfg.APIKeys == nil {
+			return nil, errInvalidAPIKey
+		}
+		principal, err := a.cfg.APIKeys.LookupAPIKey(c.Request.Context(), key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errKeySetUnavailable, err)
+		}
+		if principal == nil {
+			return nil, errInvalidAPIKey
+		}
+		principals = append(principals, a.grant(principal))
+	}
+	return principals, nil
+}
+
+// challenge returns the WWW-Authenticate value for rejected credentials. It
+// names the scheme of the credential that failed, so a client that sent an
+// unknown API key is not told to send a bearer token.
+func challenge(err error) string {
+	if errors.Is(err, errInvalidAPIKey) {
+		return fmt.Sprintf(`ApiKey header=%q, error="invalid_key"`, apiKeyHeader)
+	}
+	return `Bearer error="invalid_token"`
+}
+
+// grant adds the organisation permissions configured for the client.
+func (a *Authenticator) grant(p *Principal) *Principal {
+	p.Admin = slices.Contains(a.cfg.AdminClients, p.ClientID)
+	p.Organisations = a.cfg.ClientOrganisations[p.ClientID]
+	return p
+}
+
+// satisfies returns the principal that identifies the caller when the
+// requirement is met: the token principal when a token is involved, else the
+// API key principal.
+func satisfies(requirement openapi.SecurityRequirement, principals []*Principal) *Principal {
+	var identified *Principal
+	for scheme, scopes := range requirement {
+		var match *Principal
+		for _, p := range principals {
+			if p.Scheme != scheme {
+				continue
+			}
+			if scheme == SchemeClientCredentials && !p.HasScopes(scopes...) {
+				continue
+			}
+			match = p
+		}
+		if match == nil {
+			return nil
+		}
+		if identified == nil || match.Scheme == SchemeClientCredentials {
+			identified = match
+		}
+	}
+	return identified
+}
+
+// optional reports whether the route can be called anonymously: it declares
+// no requirements or an empty one.
+func optional(requirements []*openapi.SecurityRequirement) bool {
+	if len(requirements) == 0 {
+		return true
+	}
+	for _, requirement := range requirements {
+		if requirement == nil || len(*requirement) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func requiredScopes(requirements []*openapi.SecurityRequirement) []string {
+	seen := map[string]bool{}
+	var scopes []string
+	for _, requirement := range requirements {
+		for _, scope := range (*requirement)[SchemeClientCredentials] {
+			if !seen[scope] {
+				seen[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+func abort(c *gin.Context, p problem.ProblemJSON) {
+	c.Abort()
+	c.Header("Content-Type", "application/problem+json")
+	c.JSON(p.Status, p)
+}
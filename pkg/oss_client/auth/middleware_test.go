@@ -0,0 +1,354 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wI2L/fizz/openapi"
+)
+
+type testKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return testKeys{rsa: rsaKey, ec: ecKey}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// serveJWKS publishes the public keys as kid "rsa" and "ec" and counts fetches.
+func serveJWKS(t *testing.T, keys testKeys, fetches *atomic.Int32) *httptest.Server {
+	t.Helper()
+	ecBytes, err := keys.ec.PublicKey.Bytes()
+	require.NoError(t, err)
+	set := map[string]any{"keys": []map[string]string{
+		{
+			"kty": "RSA", "kid": "rsa", "use": "sig", "alg": "RS256",
+			"n": b64(keys.rsa.N.Bytes()),
+			"e": b64(big.NewInt(int64(keys.rsa.E)).Bytes()),
+		},
+		{
+			"kty": "EC", "kid": "ec", "crv": "P-256",
+			"x": b64(ecBytes[1:33]),
+			"y": b64(ecBytes[33:]),
+		},
+	}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches != nil {
+			fetches.Add(1)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func signToken(t *testing.T, keys testKeys, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	input := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, keys.rsa, crypto.SHA256, digest[:])
+	case "PS256":
+		signature, err = rsa.SignPSS(rand.Reader, keys.rsa, crypto.SHA256, digest[:], nil)
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, keys.ec, digest[:])
+		if err == nil {
+			signature = make([]byte, 64)
+			r.FillBytes(signature[:32])
+			s.FillBytes(signature[32:])
+		}
+	default:
+		t.Fatalf("unsupported alg %s", alg)
+	}
+	require.NoError(t, err)
+	return input + "." + b64(signature)
+}
+
+func validClaims(scope string) map[string]any {
+	return map[string]any{
+		"iss":       "https://auth.example.org",
+		"aud":       []string{"oss-register"},
+		"exp":       time.Now().Add(time.Hour).Unix(),
+		"client_id": "gemeente-a",
+		"scope":     scope,
+	}
+}
+
+// newTestRouter mounts the middleware with fixed requirements per route.
+func newTestRouter(t *testing.T, cfg auth.Config) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	requirements := map[string][]*openapi.SecurityRequirement{
+		"GET /v1/repositories/{id}": {
+			{"apiKey": {}},
+			{"clientCredentials": {"repositories:read"}},
+		},
+		"POST /v1/repositories": {
+			{"clientCredentials": {"repositories:write"}},
+		},
+		"POST /v1/webhooks/{forge}": {},
+	}
+	lookup := func(method, fullPath string) ([]*openapi.SecurityRequirement, bool) {
+		path := strings.ReplaceAll(strings.ReplaceAll(fullPath, ":id", "{id}"), ":forge", "{forge}")
+		reqs, ok := requirements[method+" "+path]
+		return reqs, ok
+	}
+
+	r := gin.New()
+	r.Use(auth.NewAuthenticator(cfg).Middleware(lookup))
+	handler := func(c *gin.Context) {
+		principal, ok := auth.PrincipalFromGin(c)
+		if !ok {
+			c.String(http.StatusOK, "anonymous")
+			return
+		}
+		c.String(http.StatusOK, principal.Scheme+":"+principal.ClientID)
+	}
+	r.GET("/v1/repositories/:id", handler)
+	r.POST("/v1/repositories", handler)
+	r.POST("/v1/webhooks/:forge", handler)
+	return r
+}
+
+func do(r http.Handler, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	keys := newTestKeys(t)
+	jwks := serveJWKS(t, keys, nil)
+	r := newTestRouter(t, auth.Config{
+		JWKSURL:  jwks.URL,
+		Issuer:   "https://auth.example.org",
+		Audience: "oss-register",
+		APIKeys:  auth.NewStaticKeyStore(map[string]string{"portal": "secret-key"}),
+	})
+	bearer := func(token string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + token}
+	}
+
+	t.Run("routes without requirements are public", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/v1/webhooks/github", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "anonymous", w.Body.String())
+	})
+
+	t.Run("missing credentials return 401", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/v1/repositories", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+		assert.Contains(t, w.Body.String(), "Authentication required")
+	})
+
+	for _, alg := range []string{"RS256", "PS256", "ES256"} {
+		t.Run("token with scope is accepted ("+alg+")", func(t *testing.T) {
+			kid := "rsa"
+			if alg == "ES256" {
+				kid = "ec"
+			}
+			token := signToken(t, keys, alg, kid, validClaims("repositories:read repositories:write"))
+			w := do(r, http.MethodPost, "/v1/repositories", bearer(token))
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "clientCredentials:gemeente-a", w.Body.String())
+		})
+	}
+
+	t.Run("token without scope returns 403", func(t *testing.T) {
+		token := signToken(t, keys, "RS256", "rsa", validClaims("repositories:read"))
+		w := do(r, http.MethodPost, "/v1/repositories", bearer(token))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `scope="repositories:write"`)
+
+		var body struct {
+			Status int    `json:"status"`
+			Title  string `json:"title"`
+			Errors []struct {
+				Detail string `json:"detail"`
+			} `json:"errors"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, http.StatusForbidden, body.Status)
+		assert.Equal(t, "Insufficient scope", body.Title)
+		require.Len(t, body.Errors, 1)
+		assert.Equal(t, "scope repositories:write is required", body.Errors[0].Detail)
+	})
+
+	t.Run("api key satisfies the apiKey alternative", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/v1/repositories/1", map[string]string{"X-Api-Key": "secret-key"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "apiKey:portal", w.Body.String())
+	})
+
+	t.Run("api key does not grant write scopes", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/v1/repositories", map[string]string{"X-Api-Key": "secret-key"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("unknown api key returns 401", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/v1/repositories/1", map[string]string{"X-Api-Key": "wrong"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid credentials")
+		assert.Equal(t, `ApiKey header="X-Api-Key", error="invalid_key"`, w.Header().Get("WWW-Authenticate"))
+	})
+
+	invalid := map[string]func() string{
+		"expired": func() string {
+			claims := validClaims("repositories:write")
+			claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			return signToken(t, keys, "RS256", "rsa", claims)
+		},
+		"wrong issuer": func() string {
+			claims := validClaims("repositories:write")
+			claims["iss"] = "https://evil.example.org"
+			return signToken(t, keys, "RS256", "rsa", claims)
+		},
+		"wrong audience": func() string {
+			claims := validClaims("repositories:write")
+			claims["aud"] = "other-api"
+			return signToken(t, keys, "RS256", "rsa", claims)
+		},
+		"tampered payload": func() string {
+			token := signToken(t, keys, "RS256", "rsa", validClaims("repositories:read"))
+			parts := strings.Split(token, ".")
+			payload, _ := json.Marshal(validClaims("repositories:write"))
+			return parts[0] + "." + b64(payload) + "." + parts[2]
+		},
+		"alg none": func() string {
+			header, _ := json.Marshal(map[string]string{"alg": "none", "kid": "rsa"})
+			payload, _ := json.Marshal(validClaims("repositories:write"))
+			return b64(header) + "." + b64(payload) + "."
+		},
+		"unknown kid": func() string {
+			return signToken(t, keys, "RS256", "other", validClaims("repositories:write"))
+		},
+		"key of other type": func() string {
+			return signToken(t, keys, "RS256", "ec", validClaims("repositories:write"))
+		},
+	}
+	for name, token := range invalid {
+		t.Run("invalid token: "+name, func(t *testing.T) {
+			w := do(r, http.MethodPost, "/v1/repositories", bearer(token()))
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Contains(t, w.Header().Get("WWW-Authenticate"), "invalid_token")
+		})
+	}
+
+	t.Run("basic authorization is rejected", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/v1/repositories", map[string]string{"Authorization": "Basic Zm9vOmJhcg=="})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestMiddlewareCachesKeySet(t *testing.T) {
+	keys := newTestKeys(t)
+	var fetches atomic.Int32
+	jwks := serveJWKS(t, keys, &fetches)
+	r := newTestRouter(t, auth.Config{JWKSURL: jwks.URL})
+
+	token := signToken(t, keys, "RS256", "rsa", validClaims("repositories:write"))
+	for range 3 {
+		w := do(r, http.MethodPost, "/v1/repositories", map[string]string{"Authorization": "Bearer " + token})
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Equal(t, int32(1), fetches.Load())
+}
+
+func TestMiddlewareReportsUnavailableKeySet(t *testing.T) {
+	keys := newTestKeys(t)
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(jwks.Close)
+	r := newTestRouter(t, auth.Config{JWKSURL: jwks.URL})
+
+	token := signToken(t, keys, "RS256", "rsa", validClaims("repositories:write"))
+	w := do(r, http.MethodPost, "/v1/repositories", map[string]string{"Authorization": "Bearer " + token})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+}
+
+func TestParseStaticKeyStore(t *testing.T) {
+	store := auth.ParseStaticKeyStore(" portal:key-1 , cli:key-2,invalid,:no-id")
+
+	principal, err := store.LookupAPIKey(t.Context(), "key-2")
+	require.NoError(t, err)
+	require.NotNil(t, principal)
+	assert.Equal(t, "cli", principal.ClientID)
+	assert.Equal(t, auth.SchemeAPIKey, principal.Scheme)
+
+	principal, err = store.LookupAPIKey(t.Context(), "no-id")
+	require.NoError(t, err)
+	assert.Nil(t, principal)
+}
+
+func TestConfigFromEnvReadsOrganisationPermissions(t *testing.T) {
+	t.Setenv(auth.EnvAdminClients, "beheer, ,ops")
+	t.Setenv(auth.EnvClientOrganisations, `{"gemeente-a": ["https://example.org/organisations/a/"]}`)
+
+	cfg, err := auth.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"beheer", "ops"}, cfg.AdminClients)
+
+	gemeente := &auth.Principal{ClientID: "gemeente-a", Organisations: cfg.ClientOrganisations["gemeente-a"]}
+	assert.True(t, gemeente.CanWriteOrganisation("https://example.org/organisations/a"))
+	assert.False(t, gemeente.CanWriteOrganisation("https://example.org/organisations/b"))
+	assert.False(t, gemeente.CanWriteOrganisation(""))
+	assert.True(t, (&auth.Principal{Admin: true}).CanWriteOrganisation("https://example.org/organisations/b"))
+	assert.True(t, auth.CanWriteOrganisation(t.Context(), "https://example.org/organisations/b"))
+
+	t.Setenv(auth.EnvClientOrganisations, "gemeente-a=https://example.org")
+	_, err = auth.ConfigFromEnv()
+	assert.Error(t, err)
+}
+
+func TestIsAdminDeniesRequestsWithoutCaller(t *testing.T) {
+	assert.False(t, auth.IsAdmin(t.Context()))
+	assert.False(t, auth.IsAdmin(auth.WithPrincipal(t.Context(), &auth.Principal{ClientID: "gemeente-a"})))
+	assert.True(t, auth.IsAdmin(auth.WithPrincipal(t.Context(), &auth.Principal{ClientID: "beheer", Admin: true})))
+	assert.True(t, auth.IsAdmin(auth.WithInternal(t.Context())))
+}
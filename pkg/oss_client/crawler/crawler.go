@@ -0,0 +1,259 @@
+// Package crawler enumerates the repositories of registered git organisations
+// through the GitHub, GitLab and Gitea APIs and upserts them into the register.
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	httpclient "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/httpclient"
+	util "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/util"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/repositories"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/services"
+)
+
+const (
+	EnvGitHubAPIURL = "CRAWLER_GITHUB_API_URL"
+	EnvGitHubToken  = "CRAWLER_GITHUB_TOKEN"
+	EnvGitLabToken  = "CRAWLER_GITLAB_TOKEN"
+	EnvGitLabHosts  = "CRAWLER_GITLAB_HOSTS"
+	EnvGiteaToken   = "CRAWLER_GITEA_TOKEN"
+	EnvGiteaHosts   = "CRAWLER_GITEA_HOSTS"
+
+	DefaultGitHubAPIURL = "https://api.github.com"
+
+	gitOrganisationsPerPage = 100
+)
+
+var (
+	defaultGitLabHosts = []string{"gitlab.com"}
+	defaultGiteaHosts  = []string{"codeberg.org", "gitea.com"}
+)
+
+// Config selects and authenticates the forge APIs. Hosts are compared with the
+// host (and port) of a git organisation url; github.com always uses GitHubAPIURL.
+type Config struct {
+	GitHubAPIURL string
+	GitHubToken  string
+	GitLabHosts  []string
+	GitLabToken  string
+	GiteaHosts   []string
+	GiteaToken   string
+	HTTPClient   *http.Client
+}
+
+// ConfigFromEnv reads the crawler configuration from CRAWLER_* env vars.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		GitHubAPIURL: DefaultGitHubAPIURL,
+		GitHubToken:  strings.TrimSpace(os.Getenv(EnvGitHubToken)),
+		GitLabHosts:  defaultGitLabHosts,
+		GitLabToken:  strings.TrimSpace(os.Getenv(EnvGitLabToken)),
+		GiteaHosts:   defaultGiteaHosts,
+		GiteaToken:   strings.TrimSpace(os.Getenv(EnvGiteaToken)),
+	}
+	if v := strings.TrimSpace(os.Getenv(EnvGitHubAPIURL)); v != "" {
+		cfg.GitHubAPIURL = v
+	}
+	if hosts := splitHosts(os.Getenv(EnvGitLabHosts)); len(hosts) > 0 {
+		cfg.GitLabHosts = hosts
+	}
+	if hosts := splitHosts(os.Getenv(EnvGiteaHosts)); len(hosts) > 0 {
+		cfg.GiteaHosts = hosts
+	}
+	return cfg
+}
+
+func splitHosts(raw string) []string {
+	var hosts []string
+	for _, h := range strings.Split(raw, ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// Result summarises a crawl. Skipped counts repositories that were deleted
+// from the register and are therefore not recreated.
+type Result struct {
+	GitOrganisations int
+	Created          int
+	Updated          int
+	Skipped          int
+	Failed           int
+}
+
+func (r *Result) add(other Result) {
+	r.GitOrganisations += other.GitOrganisations
+	r.Created += other.Created
+	r.Updated += other.Updated
+	r.Skipped += other.Skipped
+	r.Failed += other.Failed
+}
+
+// Crawler upserts the repositories of every git organisation through the
+// RepositoryService, so validation, revisions and Typesense indexing apply.
+type Crawler struct {
+	repo    repositories.RepositoriesRepository
+	service *services.RepositoryService
+	cfg     Config
+	now     func() time.Time
+}
+
+func New(repo repositories.RepositoriesRepository, service *services.RepositoryService, cfg Config) *Crawler {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = httpclient.HTTPClient
+	}
+	if cfg.GitHubAPIURL == "" {
+		cfg.GitHubAPIURL = DefaultGitHubAPIURL
+	}
+	return &Crawler{
+		repo:    repo,
+		service: service,
+		cfg:     cfg,
+		now:     time.Now,
+	}
+}
+
+// Run crawls all git organisations. A failing git organisation is logged and
+// does not stop the others; the errors are returned joined.
+func (c *Crawler) Run(ctx context.Context) (Result, error) {
+	var total Result
+	var errs []error
+	for page := 1; ; page++ {
+		gitOrgs, pagination, err := c.repo.GetGitOrganisations(ctx, page, gitOrganisationsPerPage, nil, nil)
+		if err != nil {
+			return total, err
+		}
+		for i := range gitOrgs {
+			result, err := c.CrawlGitOrganisation(ctx, &gitOrgs[i])
+			total.add(result)
+			if err != nil {
+				log.Printf("[crawler] git organisation=%s failed: %v", gitOrgs[i].Url, err)
+				errs = append(errs, err)
+			}
+		}
+		if page >= pagination.TotalPages {
+			break
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+// CrawlGitOrganisation enumerates the repositories of one git organisation and
+// upserts them under the organisation the git organisation belongs to.
+func (c *Crawler) CrawlGitOrganisation(ctx context.Context, gitOrg *models.GitOrganisatie) (Result, error) {
+	result := Result{GitOrganisations: 1}
+	if gitOrg.OrganisationID == nil || *gitOrg.OrganisationID == "" {
+		return result, fmt.Errorf("crawler: git organisation %s has no organisation", gitOrg.Url)
+	}
+
+	forge, owner, err := c.forgeFor(gitOrg.Url)
+	if err != nil {
+		return result, err
+	}
+
+	remote, err := forge.ListRepositories(ctx, owner)
+	if err != nil {
+		return result, fmt.Errorf("crawler: listing %s: %w", gitOrg.Url, err)
+	}
+
+	crawledAt := c.now().UTC()
+	inputs := make([]models.RepositoryInput, 0, len(remote))
+	for _, r := range remote {
+		input := models.RepositoryInput{
+			Url:             stringPtr(r.URL),
+			OrganisationUri: stringPtr(*gitOrg.OrganisationID),
+			IsFork:          boolPtr(r.IsFork),
+			Archived:        boolPtr(r.Archived),
+			Name:            stringPtr(r.Name),
+			LastActivityAt:  r.LastActivityAt,
+			LastCrawledAt:   crawledAt,
+		}
+		if r.Description != "" {
+			input.ShortDescription = stringPtr(r.Description)
+		}
+		// Empty repositories have no default branch.
+		if r.DefaultBranch != "" {
+			publicCodeURL, err := forge.PublicCodeURL(ctx, r)
+			if err != nil {
+				return result, fmt.Errorf("crawler: publiccode lookup for %s: %w", r.URL, err)
+			}
+			if publicCodeURL != "" {
+				input.PublicCodeUrl = stringPtr(publicCodeURL)
+			}
+		}
+		inputs = append(inputs, input)
+	}
+	if len(inputs) == 0 {
+		return result, nil
+	}
+
+	response, err := c.service.UpsertRepositories(util.WithActor(ctx, util.ActorCrawler), inputs)
+	if err != nil {
+		return result, err
+	}
+	result.Created = response.Created
+	result.Updated = response.Updated
+	for _, item := range response.Results {
+		if item.Status != models.BulkStatusFailed {
+			continue
+		}
+		if len(item.Errors) > 0 && item.Errors[0].Code == "gone" {
+			result.Skipped++
+			continue
+		}
+		result.Failed++
+		log.Printf("[crawler] repository=%s failed: %v", item.Url, item.Errors)
+	}
+	return result, nil
+}
+
+// forgeFor picks the forge client for a git organisation url and returns the
+// owner path on that forge.
+func (c *Crawler) forgeFor(gitOrgURL string) (Forge, string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(gitOrgURL))
+	if err != nil || parsed.Host == "" {
+		return nil, "", fmt.Errorf("crawler: invalid git organisation url %q", gitOrgURL)
+	}
+	owner := strings.TrimSuffix(strings.Trim(parsed.Path, "/"), ".git")
+	if owner == "" {
+		return nil, "", fmt.Errorf("crawler: git organisation url %q has no owner", gitOrgURL)
+	}
+	host := strings.ToLower(parsed.Host)
+	base := parsed.Scheme + "://" + parsed.Host
+
+	switch {
+	case host == "github.com" || host == "www.github.com":
+		owner, _, _ = strings.Cut(owner, "/")
+		return newGitHubForge(c.cfg.HTTPClient, strings.TrimSuffix(c.cfg.GitHubAPIURL, "/"), c.cfg.GitHubToken), owner, nil
+	case containsHost(c.cfg.GitLabHosts, host):
+		return newGitLabForge(c.cfg.HTTPClient, base+"/api/v4", c.cfg.GitLabToken), owner, nil
+	case containsHost(c.cfg.GiteaHosts, host):
+		owner, _, _ = strings.Cut(owner, "/")
+		return newGiteaForge(c.cfg.HTTPClient, base+"/api/v1", c.cfg.GiteaToken), owner, nil
+	}
+	return nil, "", fmt.Errorf("crawler: no forge configured for host %s", host)
+}
+
+func containsHost(hosts []string, host string) bool {
+	for _, h := range hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func stringPtr(s string) *string { return &s }
+
+func boolPtr(b bool) *bool { return &b }
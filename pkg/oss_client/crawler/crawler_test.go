@@ -0,0 +1,264 @@
+package crawler_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/crawler"
+	util "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/util"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/repositories"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const publicCodeYAML = `publiccodeYmlVersion: "0.4"
+name: Acme App
+url: https://github.com/acme/app
+`
+
+type acceptPublicCode struct{}
+
+func (acceptPublicCode) ValidatePublicCode(string) error { return nil }
+
+type fixture struct {
+	repo    repositories.RepositoriesRepository
+	service *services.RepositoryService
+	orgURI  string
+}
+
+func setup(t *testing.T) *fixture {
+	t.Helper()
+	t.Setenv("ENABLE_TYPESENSE", "false")
+	util.SetPublicCodeValidatorForTest(t, acceptPublicCode{})
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Organisation{}, &models.Repository{}, &models.GitOrganisatie{}, &models.RepositoryRevision{}, &models.AuditEvent{}, &models.SearchIndexOperation{}, &models.JobRun{}))
+
+	repo := repositories.NewRepositoriesRepository(db)
+	org := &models.Organisation{Uri: "https://www.example.org", Label: "Example"}
+	require.NoError(t, repo.SaveOrganisatie(org))
+	return &fixture{repo: repo, service: services.NewRepositoryService(repo), orgURI: org.Uri}
+}
+
+func (f *fixture) addGitOrganisation(t *testing.T, id, gitOrgURL string) *models.GitOrganisatie {
+	t.Helper()
+	gitOrg := &models.GitOrganisatie{Id: id, Url: gitOrgURL, OrganisationID: &f.orgURI}
+	require.NoError(t, f.repo.SaveGitOrganisatie(context.Background(), gitOrg))
+	return gitOrg
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// newGitHubStandIn serves the subset of the GitHub REST API the crawler uses.
+func newGitHubStandIn(t *testing.T) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/acme/repos", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer gh-token", r.Header.Get("Authorization"))
+		assert.Equal(t, "public", r.URL.Query().Get("type"))
+		writeJSON(w, []map[string]any{
+			{"name": "app", "full_name": "acme/app", "html_url": "https://github.com/acme/app", "description": "The app", "default_branch": "main", "pushed_at": "2026-10-01T10:00:00Z"},
+			{"name": "fork", "full_name": "acme/fork", "html_url": "https://github.com/acme/fork", "default_branch": "develop", "fork": true, "archived": true, "pushed_at": "2026-09-01T10:00:00Z"},
+			{"name": "empty", "full_name": "acme/empty", "html_url": "https://github.com/acme/empty", "pushed_at": "2026-08-01T10:00:00Z"},
+			{"name": "secret", "full_name": "acme/secret", "html_url": "https://github.com/acme/secret", "private": true, "default_branch": "main"},
+		})
+	})
+	mux.HandleFunc("/repos/acme/app/contents/publiccode.yml", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "main", r.URL.Query().Get("ref"))
+		writeJSON(w, map[string]any{"download_url": server.URL + "/raw/acme/app/main/publiccode.yml"})
+	})
+	mux.HandleFunc("/repos/acme/fork/contents/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "develop", r.URL.Query().Get("ref"))
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/raw/acme/app/main/publiccode.yml", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(publicCodeYAML))
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCrawlGitOrganisation_GitHub(t *testing.T) {
+	f := setup(t)
+	server := newGitHubStandIn(t)
+	ctx := context.Background()
+
+	// A repository deleted from the register is not recreated by the crawler.
+	deleted := &models.Repository{Id: "deleted-1", Url: "https://github.com/acme/empty", OrganisationID: &f.orgURI}
+	require.NoError(t, f.repo.SaveRepository(ctx, deleted))
+	require.NoError(t, f.repo.DeleteRepository(ctx, deleted.Id))
+
+	c := crawler.New(f.repo, f.service, crawler.Config{GitHubAPIURL: server.URL, GitHubToken: "gh-token"})
+	result, err := c.CrawlGitOrganisation(ctx, f.addGitOrganisation(t, "git-1", "https://github.com/acme"))
+	require.NoError(t, err)
+	assert.Equal(t, crawler.Result{GitOrganisations: 1, Created: 2, Skipped: 1}, result)
+
+	app, err := f.repo.FindRepositoryByURL(ctx, "https://github.com/acme/app")
+	require.NoError(t, err)
+	require.NotNil(t, app)
+	assert.Equal(t, server.URL+"/raw/acme/app/main/publiccode.yml", app.PublicCodeUrl)
+	assert.Equal(t, "Acme App", app.Name)
+	assert.False(t, app.IsFork)
+	assert.True(t, app.Active)
+	assert.Equal(t, f.orgURI, *app.OrganisationID)
+	assert.True(t, app.LastActivityAt.Equal(time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)))
+	assert.WithinDuration(t, time.Now(), app.LastCrawledAt, time.Minute)
+
+	revisions, _, err := f.repo.GetRepositoryRevisions(ctx, app.Id, 1, 10)
+	require.NoError(t, err)
+	require.NotEmpty(t, revisions)
+	assert.Equal(t, util.ActorCrawler, revisions[0].Actor)
+
+	fork, err := f.repo.FindRepositoryByURL(ctx, "https://github.com/acme/fork")
+	require.NoError(t, err)
+	require.NotNil(t, fork)
+	assert.Empty(t, fork.PublicCodeUrl)
+	assert.True(t, fork.IsFork)
+	assert.True(t, fork.Archived)
+
+	secret, err := f.repo.FindRepositoryByURL(ctx, "https://github.com/acme/secret")
+	require.NoError(t, err)
+	assert.Nil(t, secret)
+}
+
+func TestCrawlGitOrganisation_GitLabIncludesSubgroupsAndPaginates(t *testing.T) {
+	f := setup(t)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.EscapedPath()
+		switch {
+		case path == "/api/v4/groups/acme%2Fplatform/projects":
+			assert.Equal(t, "gl-token", r.Header.Get("PRIVATE-TOKEN"))
+			assert.Equal(t, "true", r.URL.Query().Get("include_subgroups"))
+			if r.URL.Query().Get("page") != "1" {
+				writeJSON(w, []any{})
+				return
+			}
+			projects := make([]map[string]any, 0, 100)
+			for i := 0; i < 100; i++ {
+				projects = append(projects, map[string]any{
+					"id": i + 1, "name": "project", "web_url": fmt.Sprintf("%s/acme/platform/project-%d", server.URL, i+1),
+					"default_branch": "main", "last_activity_at": "2026-10-01T10:00:00Z",
+				})
+			}
+			projects[0]["forked_from_project"] = map[string]any{"id": 999}
+			writeJSON(w, projects)
+		case path == "/api/v4/projects/1/repository/files/publiccode.yml":
+			writeJSON(w, map[string]any{"file_name": "publiccode.yml"})
+		case strings.HasPrefix(path, "/api/v4/projects/"):
+			http.NotFound(w, r)
+		case path == "/acme/platform/project-1/-/raw/main/publiccode.yml":
+			_, _ = w.Write([]byte(publicCodeYAML))
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	c := crawler.New(f.repo, f.service, crawler.Config{GitLabHosts: []string{host}, GitLabToken: "gl-token"})
+	result, err := c.CrawlGitOrganisation(context.Background(), f.addGitOrganisation(t, "git-1", server.URL+"/acme/platform"))
+	require.NoError(t, err)
+	assert.Equal(t, 100, result.Created)
+
+	first, err := f.repo.FindRepositoryByURL(context.Background(), server.URL+"/acme/platform/project-1")
+	require.NoError(t, err)
+	require.NotNil(t, first)
+	assert.True(t, first.IsFork)
+	assert.Equal(t, server.URL+"/acme/platform/project-1/-/raw/main/publiccode.yml", first.PublicCodeUrl)
+}
+
+func TestCrawlGitOrganisation_GiteaFallsBackToUser(t *testing.T) {
+	f := setup(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/orgs/jane/repos":
+			http.NotFound(w, r)
+		case "/api/v1/users/jane/repos":
+			writeJSON(w, []map[string]any{
+				{"name": "tool", "full_name": "jane/tool", "html_url": "https://codeberg.example/jane/tool", "default_branch": "main", "updated_at": "2026-10-02T10:00:00Z"},
+			})
+		case "/api/v1/repos/jane/tool/contents/publiccode.yml":
+			http.NotFound(w, r)
+		case "/api/v1/repos/jane/tool/contents/publiccode.yaml":
+			writeJSON(w, map[string]any{"download_url": "https://codeberg.example/jane/tool/raw/branch/main/publiccode.yaml"})
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	existing := &models.Repository{Id: "tool-1", Url: "https://codeberg.example/jane/tool", OrganisationID: &f.orgURI}
+	require.NoError(t, f.repo.SaveRepository(context.Background(), existing))
+
+	c := crawler.New(f.repo, f.service, crawler.Config{GiteaHosts: []string{host}})
+	result, err := c.CrawlGitOrganisation(context.Background(), f.addGitOrganisation(t, "git-1", server.URL+"/jane"))
+	require.NoError(t, err)
+	assert.Equal(t, crawler.Result{GitOrganisations: 1, Updated: 1}, result)
+
+	tool, err := f.repo.FindRepositoryByURL(context.Background(), existing.Url)
+	require.NoError(t, err)
+	assert.Equal(t, existing.Id, tool.Id)
+	assert.Equal(t, "https://codeberg.example/jane/tool/raw/branch/main/publiccode.yaml", tool.PublicCodeUrl)
+	assert.True(t, tool.LastActivityAt.Equal(time.Date(2026, 10, 2, 10, 0, 0, 0, time.UTC)))
+}
+
+func TestRun_ContinuesAfterFailingGitOrganisation(t *testing.T) {
+	f := setup(t)
+	server := newGitHubStandIn(t)
+	f.addGitOrganisation(t, "git-1", "https://unknown.example/acme")
+	f.addGitOrganisation(t, "git-2", "https://github.com/acme")
+
+	c := crawler.New(f.repo, f.service, crawler.Config{GitHubAPIURL: server.URL, GitHubToken: "gh-token"})
+	result, err := c.Run(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no forge configured for host unknown.example")
+	assert.Equal(t, 2, result.GitOrganisations)
+	assert.Equal(t, 3, result.Created)
+}
+
+func TestRun_ReportsForgeErrors(t *testing.T) {
+	f := setup(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "rate limited", http.StatusForbidden)
+	}))
+	t.Cleanup(server.Close)
+	f.addGitOrganisation(t, "git-1", "https://github.com/acme")
+
+	c := crawler.New(f.repo, f.service, crawler.Config{GitHubAPIURL: server.URL})
+	_, err := c.Run(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "returned 403")
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv(crawler.EnvGitHubAPIURL, "https://github.example/api/v3")
+	t.Setenv(crawler.EnvGitHubToken, " gh ")
+	t.Setenv(crawler.EnvGitLabHosts, "gitlab.com, GitLab.Example.org ,")
+	t.Setenv(crawler.EnvGiteaHosts, "")
+
+	cfg := crawler.ConfigFromEnv()
+	assert.Equal(t, "https://github.example/api/v3", cfg.GitHubAPIURL)
+	assert.Equal(t, "gh", cfg.GitHubToken)
+	assert.Equal(t, []string{"gitlab.com", "gitlab.example.org"}, cfg.GitLabHosts)
+	assert.Equal(t, []string{"codeberg.org", "gitea.com"}, cfg.GiteaHosts)
+}
@@ -0,0 +1,97 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// publicCodeFiles are the file names looked up on the default branch, in order.
+var publicCodeFiles = []string{"publiccode.yml", "publiccode.yaml"}
+
+// errNotFound is returned by getJSON when the forge answers 404.
+var errNotFound = errors.New("crawler: not found")
+
+// Repository is a public repository as reported by a forge API.
+type Repository struct {
+	Name           string
+	URL            string
+	Description    string
+	DefaultBranch  string
+	IsFork         bool
+	Archived       bool
+	LastActivityAt time.Time
+
+	// ref identifies the repository in forge API paths: owner/name on GitHub
+	// and Gitea, the numeric project id on GitLab.
+	ref string
+}
+
+// Forge enumerates repositories of an organisation on a single forge.
+type Forge interface {
+	// ListRepositories returns the public repositories of the owner, which can
+	// be an organisation, group or user.
+	ListRepositories(ctx context.Context, owner string) ([]Repository, error)
+	// PublicCodeURL returns the raw url of publiccode.yml on the default branch,
+	// or an empty string when the repository has none.
+	PublicCodeURL(ctx context.Context, repo Repository) (string, error)
+}
+
+// apiClient performs authenticated JSON requests against a forge API.
+type apiClient struct {
+	httpClient *http.Client
+	baseURL    string
+	header     string
+	token      string
+}
+
+func (c *apiClient) getJSON(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		value := c.token
+		if c.header == "Authorization" {
+			value = "Bearer " + c.token
+		}
+		req.Header.Set(c.header, value)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("crawler: GET %s returned %d: %s", path, resp.StatusCode, body)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// listPages calls fetch with increasing page numbers until a page holds fewer
+// than perPage items.
+func listPages(perPage int, fetch func(page int) (int, error)) error {
+	for page := 1; ; page++ {
+		n, err := fetch(page)
+		if err != nil {
+			return err
+		}
+		if n < perPage {
+			return nil
+		}
+	}
+}
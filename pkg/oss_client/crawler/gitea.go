@@ -0,0 +1,77 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const giteaPerPage = 50
+
+// giteaForge talks to the Gitea (and Forgejo) API v1.
+type giteaForge struct {
+	api apiClient
+}
+
+func newGiteaForge(httpClient *http.Client, baseURL, token string) *giteaForge {
+	return &giteaForge{api: apiClient{
+		httpClient: httpClient,
+		baseURL:    baseURL,
+		header:     "Authorization",
+		token:      token,
+	}}
+}
+
+type giteaRepository struct {
+	Name          string    `json:"name"`
+	FullName      string    `json:"full_name"`
+	HTMLURL       string    `json:"html_url"`
+	Description   string    `json:"description"`
+	DefaultBranch string    `json:"default_branch"`
+	Private       bool      `json:"private"`
+	Fork          bool      `json:"fork"`
+	Archived      bool      `json:"archived"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (f *giteaForge) ListRepositories(ctx context.Context, owner string) ([]Repository, error) {
+	repos, err := f.list(ctx, "/orgs/"+url.PathEscape(owner)+"/repos")
+	if errors.Is(err, errNotFound) {
+		repos, err = f.list(ctx, "/users/"+url.PathEscape(owner)+"/repos")
+	}
+	return repos, err
+}
+
+func (f *giteaForge) list(ctx context.Context, path string) ([]Repository, error) {
+	var out []Repository
+	err := listPages(giteaPerPage, func(page int) (int, error) {
+		var batch []giteaRepository
+		if err := f.api.getJSON(ctx, fmt.Sprintf("%s?limit=%d&page=%d", path, giteaPerPage, page), &batch); err != nil {
+			return 0, err
+		}
+		for _, r := range batch {
+			if r.Private {
+				continue
+			}
+			out = append(out, Repository{
+				Name:           r.Name,
+				URL:            r.HTMLURL,
+				Description:    r.Description,
+				DefaultBranch:  r.DefaultBranch,
+				IsFork:         r.Fork,
+				Archived:       r.Archived,
+				LastActivityAt: r.UpdatedAt,
+				ref:            r.FullName,
+			})
+		}
+		return len(batch), nil
+	})
+	return out, err
+}
+
+func (f *giteaForge) PublicCodeURL(ctx context.Context, repo Repository) (string, error) {
+	return contentsDownloadURL(ctx, &f.api, "/repos/"+repo.ref, repo.DefaultBranch)
+}
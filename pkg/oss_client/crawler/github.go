@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const githubPerPage = 100
+
+// githubForge talks to the GitHub REST API.
+type githubForge struct {
+	api apiClient
+}
+
+func newGitHubForge(httpClient *http.Client, baseURL, token string) *githubForge {
+	return &githubForge{api: apiClient{
+		httpClient: httpClient,
+		baseURL:    baseURL,
+		header:     "Authorization",
+		token:      token,
+	}}
+}
+
+type githubRepository struct {
+	Name          string    `json:"name"`
+	FullName      string    `json:"full_name"`
+	HTMLURL       string    `json:"html_url"`
+	Description   string    `json:"description"`
+	DefaultBranch string    `json:"default_branch"`
+	Private       bool      `json:"private"`
+	Fork          bool      `json:"fork"`
+	Archived      bool      `json:"archived"`
+	PushedAt      time.Time `json:"pushed_at"`
+}
+
+type contentsResponse struct {
+	DownloadURL string `json:"download_url"`
+}
+
+func (f *githubForge) ListRepositories(ctx context.Context, owner string) ([]Repository, error) {
+	repos, err := f.list(ctx, "/orgs/"+url.PathEscape(owner)+"/repos?type=public")
+	if errors.Is(err, errNotFound) {
+		repos, err = f.list(ctx, "/users/"+url.PathEscape(owner)+"/repos?type=owner")
+	}
+	return repos, err
+}
+
+func (f *githubForge) list(ctx context.Context, path string) ([]Repository, error) {
+	var out []Repository
+	err := listPages(githubPerPage, func(page int) (int, error) {
+		var batch []githubRepository
+		if err := f.api.getJSON(ctx, fmt.Sprintf("%s&per_page=%d&page=%d", path, githubPerPage, page), &batch); err != nil {
+			return 0, err
+		}
+		for _, r := range batch {
+			if r.Private {
+				continue
+			}
+			out = append(out, Repository{
+				Name:           r.Name,
+				URL:            r.HTMLURL,
+				Description:    r.Description,
+				DefaultBranch:  r.DefaultBranch,
+				IsFork:         r.Fork,
+				Archived:       r.Archived,
+				LastActivityAt: r.PushedAt,
+				ref:            r.FullName,
+			})
+		}
+		return len(batch), nil
+	})
+	return out, err
+}
+
+func (f *githubForge) PublicCodeURL(ctx context.Context, repo Repository) (string, error) {
+	return contentsDownloadURL(ctx, &f.api, "/repos/"+repo.ref, repo.DefaultBranch)
+}
+
+// contentsDownloadURL looks up publiccode.yml through the contents API that
+// GitHub and Gitea share.
+func contentsDownloadURL(ctx context.Context, api *apiClient, repoPath, branch string) (string, error) {
+	for _, name := range publicCodeFiles {
+		var content contentsResponse
+		path := repoPath + "/contents/" + name + "?ref=" + url.QueryEscape(branch)
+		err := api.getJSON(ctx, path, &content)
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return content.DownloadURL, nil
+	}
+	return "", nil
+}
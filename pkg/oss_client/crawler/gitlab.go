@@ -0,0 +1,88 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const gitlabPerPage = 100
+
+// gitlabForge talks to the GitLab REST API v4.
+type gitlabForge struct {
+	api apiClient
+}
+
+func newGitLabForge(httpClient *http.Client, baseURL, token string) *gitlabForge {
+	return &gitlabForge{api: apiClient{
+		httpClient: httpClient,
+		baseURL:    baseURL,
+		header:     "PRIVATE-TOKEN",
+		token:      token,
+	}}
+}
+
+type gitlabProject struct {
+	ID                int       `json:"id"`
+	Name              string    `json:"name"`
+	WebURL            string    `json:"web_url"`
+	Description       string    `json:"description"`
+	DefaultBranch     string    `json:"default_branch"`
+	Archived          bool      `json:"archived"`
+	ForkedFromProject *struct{} `json:"forked_from_project"`
+	LastActivityAt    time.Time `json:"last_activity_at"`
+}
+
+func (f *gitlabForge) ListRepositories(ctx context.Context, owner string) ([]Repository, error) {
+	repos, err := f.list(ctx, "/groups/"+url.PathEscape(owner)+"/projects?include_subgroups=true&visibility=public")
+	if errors.Is(err, errNotFound) {
+		repos, err = f.list(ctx, "/users/"+url.PathEscape(owner)+"/projects?visibility=public")
+	}
+	return repos, err
+}
+
+func (f *gitlabForge) list(ctx context.Context, path string) ([]Repository, error) {
+	var out []Repository
+	err := listPages(gitlabPerPage, func(page int) (int, error) {
+		var batch []gitlabProject
+		if err := f.api.getJSON(ctx, fmt.Sprintf("%s&per_page=%d&page=%d", path, gitlabPerPage, page), &batch); err != nil {
+			return 0, err
+		}
+		for _, p := range batch {
+			out = append(out, Repository{
+				Name:           p.Name,
+				URL:            p.WebURL,
+				Description:    p.Description,
+				DefaultBranch:  p.DefaultBranch,
+				IsFork:         p.ForkedFromProject != nil,
+				Archived:       p.Archived,
+				LastActivityAt: p.LastActivityAt,
+				ref:            strconv.Itoa(p.ID),
+			})
+		}
+		return len(batch), nil
+	})
+	return out, err
+}
+
+// PublicCodeURL checks the files API and returns the raw web url, which is
+// readable without a token for public projects.
+func (f *gitlabForge) PublicCodeURL(ctx context.Context, repo Repository) (string, error) {
+	for _, name := range publicCodeFiles {
+		path := "/projects/" + repo.ref + "/repository/files/" + url.PathEscape(name) + "?ref=" + url.QueryEscape(repo.DefaultBranch)
+		err := f.api.getJSON(ctx, path, nil)
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(repo.URL, "/") + "/-/raw/" + repo.DefaultBranch + "/" + name, nil
+	}
+	return "", nil
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
 	commondatabase "github.com/developer-overheid-nl/don-register-common/database"
@@ -37,6 +38,27 @@ func Connect(connStr string) (*gorm.DB, error) {
 	if err := migrateRepositorySchemaColumns(db); err != nil {
 		return nil, err
 	}
+	if err := migrateRepositoryRevisionTable(db); err != nil {
+		return nil, err
+	}
+	if err := migrateGitOrganisationColumns(db); err != nil {
+		return nil, err
+	}
+	if err := migrateAuditEventTable(db); err != nil {
+		return nil, err
+	}
+	if err := migrateSearchIndexOperationTable(db); err != nil {
+		return nil, err
+	}
+	if err := migrateJobRunTable(db); err != nil {
+		return nil, err
+	}
+	if err := migrateRepositoryFilterIndexes(db); err != nil {
+		return nil, err
+	}
+	if err := migrateRepositorySearchVector(db); err != nil {
+		return nil, err
+	}
 
 	// if err := db.AutoMigrate(
 	// 	&models.Repository{},
@@ -84,6 +106,152 @@ func migrateRepositorySchemaColumns(db *gorm.DB) error {
 		return fmt.Errorf("failed to backfill column archived: %w", err)
 	}
 
+	if !m.HasColumn(&models.Repository{}, "deleted_at") {
+		if err := m.AddColumn(&models.Repository{}, "DeletedAt"); err != nil {
+			return fmt.Errorf("failed to add column deleted_at: %w", err)
+		}
+	}
+	if !m.HasIndex(&models.Repository{}, "DeletedAt") {
+		if err := m.CreateIndex(&models.Repository{}, "DeletedAt"); err != nil {
+			return fmt.Errorf("failed to create index on deleted_at: %w", err)
+		}
+	}
+
+	if !m.HasColumn(&models.Repository{}, "version") {
+		if err := m.AddColumn(&models.Repository{}, "Version"); err != nil {
+			return fmt.Errorf("failed to add column version: %w", err)
+		}
+	}
+	if err := db.Model(&models.Repository{}).
+		Where("version IS NULL OR version < ?", 1).
+		Update("version", 1).Error; err != nil {
+		return fmt.Errorf("failed to backfill column version: %w", err)
+	}
+
+	return nil
+}
+
+// migrateRepositoryRevisionTable creates the table holding repository revisions.
+func migrateRepositoryRevisionTable(db *gorm.DB) error {
+	m := db.Migrator()
+	if m.HasTable(&models.RepositoryRevision{}) {
+		return nil
+	}
+	if err := m.CreateTable(&models.RepositoryRevision{}); err != nil {
+		return fmt.Errorf("failed to create table repository_revisions: %w", err)
+	}
+	return nil
+}
+
+// migrateAuditEventTable creates the table holding the audit log.
+func migrateAuditEventTable(db *gorm.DB) error {
+	m := db.Migrator()
+	if m.HasTable(&models.AuditEvent{}) {
+		return nil
+	}
+	if err := m.CreateTable(&models.AuditEvent{}); err != nil {
+		return fmt.Errorf("failed to create table audit_events: %w", err)
+	}
+	return nil
+}
+
+// migrateSearchIndexOperationTable creates the outbox of Typesense operations.
+func migrateSearchIndexOperationTable(db *gorm.DB) error {
+	m := db.Migrator()
+	if m.HasTable(&models.SearchIndexOperation{}) {
+		return nil
+	}
+	if err := m.CreateTable(&models.SearchIndexOperation{}); err != nil {
+		return fmt.Errorf("failed to create table search_index_operations: %w", err)
+	}
+	return nil
+}
+
+// migrateJobRunTable creates the table with the runs of scheduled jobs.
+func migrateJobRunTable(db *gorm.DB) error {
+	m := db.Migrator()
+	if m.HasTable(&models.JobRun{}) {
+		return nil
+	}
+	if err := m.CreateTable(&models.JobRun{}); err != nil {
+		return fmt.Errorf("failed to create table job_runs: %w", err)
+	}
+	return nil
+}
+
+// repositoryFilterIndexes back the publiccode.yml filters of
+// repositories.repositoryFilter. The expressions must match the ones used
+// there for PostgreSQL to pick them.
+var repositoryFilterIndexes = []struct{ name, definition string }{
+	{"idx_repositories_public_code_data", "USING GIN ((public_code_data::jsonb) jsonb_path_ops)"},
+	{"idx_repositories_software_type", "(((public_code_data::jsonb) #>> '{softwareType}'))"},
+	{"idx_repositories_development_status", "(((public_code_data::jsonb) #>> '{developmentStatus}'))"},
+	{"idx_repositories_maintenance_type", "(((public_code_data::jsonb) #>> '{maintenance,type}'))"},
+	{"idx_repositories_license", "(((public_code_data::jsonb) #>> '{legal,license}'))"},
+	{"idx_repositories_last_activity_at", "(last_activity_at)"},
+}
+
+// migrateRepositoryFilterIndexes creates the jsonb indexes used when filtering
+// repositories. They only exist on PostgreSQL.
+func migrateRepositoryFilterIndexes(db *gorm.DB) error {
+	if db.Dialector.Name() != "postgres" || !db.Migrator().HasTable(&models.Repository{}) {
+		return nil
+	}
+	for _, index := range repositoryFilterIndexes {
+		if err := db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON repositories %s", index.name, index.definition)).Error; err != nil {
+			return fmt.Errorf("failed to create index %s: %w", index.name, err)
+		}
+	}
+	return nil
+}
+
+// repositorySearchWeights are the fields of the full-text search vector of a
+// repository, from most to least relevant. The organisation label ranks below
+// them and is matched at query time by repositories.textSearch.
+var repositorySearchWeights = []struct{ weight, text string }{
+	{"A", "COALESCE(name, '')"},
+	{"B", "COALESCE(short_description, '')"},
+	{"C", "COALESCE(long_description, '')"},
+	{"D", "COALESCE(jsonb_path_query_array(NULLIF(public_code_data, '')::jsonb, '$.description.*.features[*]'), '[]'::jsonb)"},
+}
+
+// migrateRepositorySearchVector adds the generated column search_vector,
+// weighting every field with both the Dutch and the English dictionary, and
+// its GIN index. It only exists on PostgreSQL.
+func migrateRepositorySearchVector(db *gorm.DB) error {
+	m := db.Migrator()
+	if db.Dialector.Name() != "postgres" || !m.HasTable(&models.Repository{}) {
+		return nil
+	}
+	if !m.HasColumn(&models.Repository{}, "search_vector") {
+		var parts []string
+		for _, field := range repositorySearchWeights {
+			for _, config := range []string{"dutch", "english"} {
+				parts = append(parts, fmt.Sprintf("setweight(to_tsvector('%s', %s), '%s')", config, field.text, field.weight))
+			}
+		}
+		if err := db.Exec("ALTER TABLE repositories ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (" + strings.Join(parts, " || ") + ") STORED").Error; err != nil {
+			return fmt.Errorf("failed to add column search_vector: %w", err)
+		}
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_repositories_search_vector ON repositories USING GIN (search_vector)").Error; err != nil {
+		return fmt.Errorf("failed to create index idx_repositories_search_vector: %w", err)
+	}
+	return nil
+}
+
+// migrateGitOrganisationColumns adds git organisation columns introduced after
+// the initial production schema was created.
+func migrateGitOrganisationColumns(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&models.GitOrganisatie{}) {
+		return nil
+	}
+	if !m.HasColumn(&models.GitOrganisatie{}, "webhook_secret") {
+		if err := m.AddColumn(&models.GitOrganisatie{}, "WebhookSecret"); err != nil {
+			return fmt.Errorf("failed to add column webhook_secret: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -18,6 +18,8 @@ func TestMigrateRepositorySchemaColumnsAddsRepositoryStatusColumns(t *testing.T)
 	require.True(t, m.HasColumn(&models.Repository{}, "is_fork"))
 	require.True(t, m.HasColumn(&models.Repository{}, "fork_based_on_urls"))
 	require.True(t, m.HasColumn(&models.Repository{}, "archived"))
+	require.True(t, m.HasColumn(&models.Repository{}, "deleted_at"))
+	require.True(t, m.HasIndex(&models.Repository{}, "DeletedAt"))
 }
 
 func TestMigrateRepositorySchemaColumnsBackfillsForkFlag(t *testing.T) {
@@ -51,6 +53,47 @@ func TestMigrateRepositorySchemaColumnsSkipsMissingRepositoryTable(t *testing.T)
 	require.NoError(t, migrateRepositorySchemaColumns(db))
 }
 
+func TestMigrateRepositoryRevisionTableCreatesTable(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	require.NoError(t, migrateRepositoryRevisionTable(db))
+	require.True(t, db.Migrator().HasTable(&models.RepositoryRevision{}))
+
+	require.NoError(t, migrateRepositoryRevisionTable(db))
+}
+
+func TestMigrateAuditEventTableCreatesTable(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	require.NoError(t, migrateAuditEventTable(db))
+	require.True(t, db.Migrator().HasTable(&models.AuditEvent{}))
+
+	require.NoError(t, migrateAuditEventTable(db))
+}
+
+func TestMigrateSearchIndexOperationTableCreatesTable(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	require.NoError(t, migrateSearchIndexOperationTable(db))
+	require.True(t, db.Migrator().HasTable(&models.SearchIndexOperation{}))
+
+	require.NoError(t, migrateSearchIndexOperationTable(db))
+}
+
+func TestMigrateJobRunTableCreatesTable(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	require.NoError(t, migrateJobRunTable(db))
+	require.True(t, db.Migrator().HasTable(&models.JobRun{}))
+	require.True(t, db.Migrator().HasColumn(&models.JobRun{}, "counters"))
+
+	require.NoError(t, migrateJobRunTable(db))
+}
+
 func TestMigrateRepositoryTimestampColumnsRenamesLegacyColumns(t *testing.T) {
 	db := openLegacyTimestampRepositoryDB(t)
 
@@ -121,3 +164,36 @@ func openCurrentTimestampRepositoryDB(t *testing.T) *gorm.DB {
 
 	return db
 }
+
+func TestMigrateGitOrganisationColumnsAddsWebhookSecret(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Exec(`CREATE TABLE git_organisaties (id TEXT PRIMARY KEY, organisation_id TEXT, url TEXT)`).Error)
+
+	require.NoError(t, migrateGitOrganisationColumns(db))
+	require.True(t, db.Migrator().HasColumn(&models.GitOrganisatie{}, "webhook_secret"))
+
+	require.NoError(t, migrateGitOrganisationColumns(db))
+}
+
+func TestMigrateGitOrganisationColumnsSkipsMissingTable(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	require.NoError(t, migrateGitOrganisationColumns(db))
+	require.False(t, db.Migrator().HasTable(&models.GitOrganisatie{}))
+}
+
+func TestMigrateRepositoryFilterIndexesSkipsSQLite(t *testing.T) {
+	db := openLegacyRepositoryDB(t)
+
+	require.NoError(t, migrateRepositoryFilterIndexes(db))
+	require.False(t, db.Migrator().HasIndex(&models.Repository{}, "idx_repositories_public_code_data"))
+}
+
+func TestMigrateRepositorySearchVectorSkipsSQLite(t *testing.T) {
+	db := openLegacyRepositoryDB(t)
+
+	require.NoError(t, migrateRepositorySearchVector(db))
+	require.False(t, db.Migrator().HasColumn(&models.Repository{}, "search_vector"))
+}
@@ -1,6 +1,15 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/audit"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/auth"
 	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/util"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
@@ -8,6 +17,18 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// maxRequestBodySize is the largest body a write endpoint that reads its
+	// body directly accepts.
+	maxRequestBodySize = 1 << 20
+	// maxBulkBodySize is the largest body of a bulk upsert, enough for its
+	// maximum of 1000 items.
+	maxBulkBodySize = 10 << 20
+	// maxWebhookBodySize is the largest webhook payload accepted; forges send
+	// a few MB at most.
+	maxWebhookBodySize = 5 << 20
+)
+
 // OSSController binds HTTP requests to the OSSController
 type OSSController struct {
 	Service *services.RepositoryService
@@ -26,11 +47,31 @@ func (c *OSSController) ListRepositorys(ctx *gin.Context, p *models.ListReposito
 	if err != nil {
 		return nil, err
 	}
-	util.SetPaginationHeaders(ctx.Request, ctx.Header, pagination)
+	if p.Cursor != "" {
+		lastID := ""
+		if len(repos) > 0 {
+			lastID = repos[len(repos)-1].Id
+		}
+		setCursorPaginationHeaders(ctx, pagination, lastID)
+	} else {
+		util.SetPaginationHeaders(ctx.Request, ctx.Header, pagination)
+	}
 
 	return repos, nil
 }
 
+// ListRepositoryHistory handles GET /repositories/:id/history
+func (c *OSSController) ListRepositoryHistory(ctx *gin.Context, p *models.ListRepositoryHistoryParams) ([]models.RepositoryRevision, error) {
+	p.Page, p.PerPage = normalizePagination(p.Page, p.PerPage)
+	p.BaseURL = ctx.FullPath()
+	revisions, pagination, err := c.Service.ListRepositoryHistory(ctx.Request.Context(), p)
+	if err != nil {
+		return nil, err
+	}
+	util.SetPaginationHeaders(ctx.Request, ctx.Header, pagination)
+	return revisions, nil
+}
+
 // SearchRepositorys handles GET /repositories/_search
 func (c *OSSController) SearchRepositorys(ctx *gin.Context, p *models.ListRepositorysSearchParams) ([]models.RepositorySummary, error) {
 	p.Page, p.PerPage = normalizePagination(p.Page, p.PerPage)
@@ -44,7 +85,7 @@ func (c *OSSController) SearchRepositorys(ctx *gin.Context, p *models.ListReposi
 }
 
 // RetrieveRepository handles GET /Repositorys/:id
-func (c *OSSController) RetrieveRepository(ctx *gin.Context, params *models.RepositoryParams) (*models.RepositoryDetail, error) {
+func (c *OSSController) RetrieveRepository(ctx *gin.Context, params *models.RetrieveRepositoryParams) (*models.RepositoryDetail, error) {
 	Repository, err := c.Service.RetrieveRepository(ctx.Request.Context(), params.Id)
 	if err != nil {
 		return nil, err
@@ -52,22 +93,34 @@ func (c *OSSController) RetrieveRepository(ctx *gin.Context, params *models.Repo
 	if Repository == nil {
 		return nil, problem.NewNotFound("Resource does not exist")
 	}
+	etag := util.RepositoryETag(Repository.Version)
+	ctx.Header("ETag", etag)
+	if params.IfNoneMatch != "" && util.ETagMatches(params.IfNoneMatch, etag) {
+		ctx.Status(http.StatusNotModified)
+		ctx.Writer.WriteHeaderNow()
+		return nil, nil
+	}
 	return Repository, nil
 }
 
 // CreateRepository handles POST /Repositorys
 func (c *OSSController) CreateRepository(ctx *gin.Context, body *models.RepositoryInput) (*models.RepositoryDetail, error) {
-	created, err := c.Service.CreateRepository(ctx.Request.Context(), *body)
+	created, err := c.Service.CreateRepository(actorContext(ctx), *body)
 	if err != nil {
 		return nil, err
 	}
+	audit.SetResource(ctx, "repositories", created.Id)
+	ctx.Header("ETag", util.RepositoryETag(created.Version))
 	return created, nil
 }
 
 // ListOrganisations handles GET /organisations
 func (c *OSSController) ListOrganisations(ctx *gin.Context, p *models.ListOrganisationsParams) ([]models.OrganisationSummary, error) {
+	// Zonder perPage blijft de volledige lijst (maximaal 100) de standaard.
+	if p.PerPage < 1 {
+		p.PerPage = 100
+	}
 	p.Page, p.PerPage = normalizePagination(p.Page, p.PerPage)
-	p.PerPage = 100
 	p.BaseURL = ctx.FullPath()
 	orgs, pagination, err := c.Service.ListOrganisations(ctx.Request.Context(), p)
 	if err != nil {
@@ -84,9 +137,25 @@ func (c *OSSController) CreateOrganisation(ctx *gin.Context, body *models.Organi
 	if err != nil {
 		return nil, err
 	}
+	audit.SetResource(ctx, "organisations", created.Uri)
 	return created, nil
 }
 
+// RetrieveOrganisation handles GET /organisations/:uri
+func (c *OSSController) RetrieveOrganisation(ctx *gin.Context, p *models.OrganisationParams) (*models.OrganisationDetail, error) {
+	return c.Service.RetrieveOrganisation(ctx.Request.Context(), p.Uri)
+}
+
+// UpdateOrganisation handles PUT /organisations/:uri
+func (c *OSSController) UpdateOrganisation(ctx *gin.Context, req *models.UpdateOrganisationRequest) (*models.OrganisationDetail, error) {
+	return c.Service.UpdateOrganisation(ctx.Request.Context(), req.Uri, req.Label)
+}
+
+// DeleteOrganisation handles DELETE /organisations/:uri
+func (c *OSSController) DeleteOrganisation(ctx *gin.Context, p *models.OrganisationParams) error {
+	return c.Service.DeleteOrganisation(ctx.Request.Context(), p.Uri)
+}
+
 // ListGitOrganisations handles GET /GitRepositorys
 func (c *OSSController) ListGitOrganisations(ctx *gin.Context, p *models.ListGitOrganisationsParams) ([]models.GitOrganisatieSummary, error) {
 	p.Page, p.PerPage = normalizePagination(p.Page, p.PerPage)
@@ -95,7 +164,15 @@ func (c *OSSController) ListGitOrganisations(ctx *gin.Context, p *models.ListGit
 	if err != nil {
 		return nil, err
 	}
-	util.SetPaginationHeaders(ctx.Request, ctx.Header, pagination)
+	if p.Cursor != "" {
+		lastID := ""
+		if len(gitOrganisations) > 0 {
+			lastID = gitOrganisations[len(gitOrganisations)-1].Id
+		}
+		setCursorPaginationHeaders(ctx, pagination, lastID)
+	} else {
+		util.SetPaginationHeaders(ctx.Request, ctx.Header, pagination)
+	}
 
 	return gitOrganisations, nil
 }
@@ -106,23 +183,209 @@ func (c *OSSController) CreateGitOrganisation(ctx *gin.Context, body *models.Git
 	if err != nil {
 		return nil, err
 	}
+	audit.SetResource(ctx, "git-organisations", created.Id)
 	return created, nil
 }
 
+// RetrieveGitOrganisation handles GET /git-organisations/:id
+func (c *OSSController) RetrieveGitOrganisation(ctx *gin.Context, p *models.GitOrganisationParams) (*models.GitOrganisatie, error) {
+	return c.Service.RetrieveGitOrganisation(ctx.Request.Context(), p.Id)
+}
+
+// UpdateGitOrganisation handles PUT /git-organisations/:id
+func (c *OSSController) UpdateGitOrganisation(ctx *gin.Context, req *models.UpdateGitOrganisationRequest) (*models.GitOrganisatie, error) {
+	return c.Service.UpdateGitOrganisation(ctx.Request.Context(), req.Id, req.GitOrganisationInput)
+}
+
+// DeleteGitOrganisation handles DELETE /git-organisations/:id
+func (c *OSSController) DeleteGitOrganisation(ctx *gin.Context, p *models.GitOrganisationParams) error {
+	return c.Service.DeleteGitOrganisation(ctx.Request.Context(), p.Id)
+}
+
+// ListGitOrganisationRepositories handles GET /git-organisations/:id/repositories
+func (c *OSSController) ListGitOrganisationRepositories(ctx *gin.Context, p *models.ListGitOrganisationRepositoriesParams) ([]models.RepositorySummary, error) {
+	p.Page, p.PerPage = normalizePagination(p.Page, p.PerPage)
+	p.BaseURL = ctx.FullPath()
+	repositories, pagination, err := c.Service.ListGitOrganisationRepositories(ctx.Request.Context(), p)
+	if err != nil {
+		return nil, err
+	}
+	util.SetPaginationHeaders(ctx.Request, ctx.Header, pagination)
+	return repositories, nil
+}
+
 // UpdateRepository handles PUT /repositories/:id
 func (c *OSSController) UpdateRepository(ctx *gin.Context, req *models.UpdateRepositoryRequest) (*models.RepositoryDetail, error) {
-	updated, err := c.Service.UpdateRepository(ctx.Request.Context(), req.Id, req.RepositoryInput)
+	updated, err := c.Service.UpdateRepository(actorContext(ctx), req.Id, req.IfMatch, req.RepositoryInput)
 	if err != nil {
 		return nil, err
 	}
+	ctx.Header("ETag", util.RepositoryETag(updated.Version))
 	return updated, nil
 }
 
+// PatchRepository handles PATCH /repositories/:id with a JSON Merge Patch body.
+// The body is read directly so that omitted fields and explicit nulls stay distinguishable.
+func (c *OSSController) PatchRepository(ctx *gin.Context) (*models.RepositoryDetail, error) {
+	mediaType, _, err := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+	if err != nil || (mediaType != "application/merge-patch+json" && mediaType != "application/json") {
+		return nil, problem.New(http.StatusUnsupportedMediaType, "Content-Type must be application/merge-patch+json")
+	}
+
+	body, err := readBody(ctx, maxRequestBodySize)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := c.Service.PatchRepository(actorContext(ctx), ctx.Param("id"), ctx.GetHeader("If-Match"), body)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Header("ETag", util.RepositoryETag(patched.Version))
+	return patched, nil
+}
+
+// BulkUpsertRepositories handles POST /repositories/_bulk with a JSON array or NDJSON body.
+func (c *OSSController) BulkUpsertRepositories(ctx *gin.Context) (*models.BulkRepositoryResponse, error) {
+	mediaType, _, err := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+	ndjson := mediaType == "application/x-ndjson" || mediaType == "application/ndjson"
+	if err != nil || (mediaType != "application/json" && !ndjson) {
+		return nil, problem.New(http.StatusUnsupportedMediaType, "Content-Type must be application/json or application/x-ndjson")
+	}
+
+	body := http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBulkBodySize)
+	result, err := c.Service.BulkUpsertRepositories(actorContext(ctx), body, ndjson)
+	if err != nil {
+		return nil, bodyTooLarge(err)
+	}
+	return result, nil
+}
+
+// HandleWebhook handles POST /webhooks/:forge. The body is read directly because
+// the signature is computed over the raw bytes.
+func (c *OSSController) HandleWebhook(ctx *gin.Context) (*models.WebhookResult, error) {
+	mediaType, _, err := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return nil, problem.New(http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+	}
+
+	body, err := readBody(ctx, maxWebhookBodySize)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Service.HandleWebhook(ctx.Request.Context(), ctx.Param("forge"), ctx.Request.Header, body)
+	if err != nil {
+		return nil, err
+	}
+	if result.RepositoryId != "" {
+		audit.SetResource(ctx, "repositories", result.RepositoryId)
+	}
+	return result, nil
+}
+
+// DeleteRepository handles DELETE /repositories/:id
+func (c *OSSController) DeleteRepository(ctx *gin.Context, params *models.RepositoryParams) error {
+	return c.Service.DeleteRepository(actorContext(ctx), params.Id)
+}
+
 // ListRepositoryFilters handles GET /repositories/filters
 func (c *OSSController) ListRepositoryFilters(ctx *gin.Context, p *models.RepositoryFiltersParams) ([]models.FilterGroup, error) {
 	return c.Service.GetRepositoryFilters(ctx.Request.Context(), p)
 }
 
+// ListAuditEvents handles GET /audit-events
+func (c *OSSController) ListAuditEvents(ctx *gin.Context, p *models.ListAuditEventsParams) ([]models.AuditEvent, error) {
+	p.Page, p.PerPage = normalizePagination(p.Page, p.PerPage)
+	p.BaseURL = ctx.FullPath()
+	events, pagination, err := c.Service.ListAuditEvents(ctx.Request.Context(), p)
+	if err != nil {
+		return nil, err
+	}
+	util.SetPaginationHeaders(ctx.Request, ctx.Header, pagination)
+	return events, nil
+}
+
+// ListSearchIndexOperations handles GET /search-index-operations
+func (c *OSSController) ListSearchIndexOperations(ctx *gin.Context, p *models.ListSearchIndexOperationsParams) ([]models.SearchIndexOperation, error) {
+	p.Page, p.PerPage = normalizePagination(p.Page, p.PerPage)
+	p.BaseURL = ctx.FullPath()
+	operations, pagination, err := c.Service.ListSearchIndexOperations(ctx.Request.Context(), p)
+	if err != nil {
+		return nil, err
+	}
+	util.SetPaginationHeaders(ctx.Request, ctx.Header, pagination)
+	return operations, nil
+}
+
+// ReindexSearchIndex handles POST /search-index/reindex. The reindex is not
+// cancelled when the client disconnects, so that it never stops halfway and
+// leaves a collection behind.
+func (c *OSSController) ReindexSearchIndex(ctx *gin.Context) (*models.SearchIndexReindex, error) {
+	return c.Service.ReindexTypesense(context.WithoutCancel(ctx.Request.Context()))
+}
+
+// RetrieveSearchCuration handles GET /search-index/curation
+func (c *OSSController) RetrieveSearchCuration(ctx *gin.Context) (*models.SearchCuration, error) {
+	return c.Service.SearchCuration(ctx.Request.Context())
+}
+
+// ApplySearchCuration handles POST /search-index/curation
+func (c *OSSController) ApplySearchCuration(ctx *gin.Context) (*models.SearchCuration, error) {
+	return c.Service.ApplySearchCuration(ctx.Request.Context())
+}
+
+// ListJobs handles GET /jobs
+func (c *OSSController) ListJobs(ctx *gin.Context) ([]models.Job, error) {
+	return c.Service.ListJobs(ctx.Request.Context())
+}
+
+// RunJob handles POST /jobs/{name}/run
+func (c *OSSController) RunJob(ctx *gin.Context, p *models.JobParams) (*models.JobRun, error) {
+	return c.Service.RunJob(ctx.Request.Context(), p.Name)
+}
+
+// readBody reads the request body up to limit bytes; a larger body is
+// answered with 413.
+func readBody(ctx *gin.Context, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, limit))
+	if err != nil {
+		return nil, bodyTooLarge(err)
+	}
+	return body, nil
+}
+
+// bodyTooLarge turns the error of reading past http.MaxBytesReader into a 413
+// problem and returns other errors unchanged.
+func bodyTooLarge(err error) error {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return err
+	}
+	return problem.New(http.StatusRequestEntityTooLarge, "Request body too large", problem.ErrorDetail{
+		In:     "body",
+		Code:   "too_large",
+		Detail: fmt.Sprintf("request body must not exceed %d bytes", tooLarge.Limit),
+	})
+}
+
+// actorContext records the authenticated client as actor, or ActorAPI when
+// authentication is disabled.
+func actorContext(ctx *gin.Context) context.Context {
+	if principal, ok := auth.PrincipalFromGin(ctx); ok && principal.ClientID != "" {
+		return util.WithActor(ctx.Request.Context(), principal.ClientID)
+	}
+	return util.WithActor(ctx.Request.Context(), util.ActorAPI)
+}
+
+// setCursorPaginationHeaders sets the headers of a cursor page whose last item
+// has id lastID.
+func setCursorPaginationHeaders(ctx *gin.Context, pagination models.Pagination, lastID string) {
+	nextCursor := ""
+	if pagination.HasMore && lastID != "" {
+		nextCursor = util.EncodeCursor(lastID)
+	}
+	util.SetCursorPaginationHeaders(ctx.Request, ctx.Header, pagination, nextCursor)
+}
+
 func normalizePagination(page, perPage int) (int, int) {
 	if page < 1 {
 		page = 1
@@ -6,12 +6,17 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/handler"
 	httpclient "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/httpclient"
+	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/repositories"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/services"
+	commonpagination "github.com/developer-overheid-nl/don-register-common/pagination"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -20,15 +25,27 @@ import (
 type serviceStubRepo struct {
 	listFunc            func(ctx context.Context, page, perPage int, p *models.RepositoryFiltersParams) ([]models.Repository, models.Pagination, error)
 	retrieveFunc        func(ctx context.Context, id string) (*models.Repository, error)
+	byIDsFunc           func(ctx context.Context, ids []string) ([]models.Repository, error)
+	indexOpsFunc        func(ctx context.Context, page, perPage int, status string) ([]models.SearchIndexOperation, models.Pagination, error)
 	searchFunc          func(ctx context.Context, page, perPage int, organisation *string, query string) ([]models.Repository, models.Pagination, error)
 	saveRepositoryFunc  func(ctx context.Context, repository *models.Repository) error
-	getOrgFunc          func(ctx context.Context, page, perPage int) ([]models.Organisation, models.Pagination, error)
-	gitOrgListFunc      func(ctx context.Context, page, perPage int, organisation *string) ([]models.GitOrganisatie, models.Pagination, error)
+	deleteRepoFunc      func(ctx context.Context, id string) error
+	findRepoByURLFunc   func(ctx context.Context, url string) (*models.Repository, error)
+	revisionsFunc       func(ctx context.Context, repositoryID string, page, perPage int) ([]models.RepositoryRevision, models.Pagination, error)
+	getOrgFunc          func(ctx context.Context, page, perPage int, p *models.OrganisationFilters) ([]models.Organisation, models.Pagination, error)
+	gitOrgListFunc      func(ctx context.Context, page, perPage int, organisation, after *string) ([]models.GitOrganisatie, models.Pagination, error)
 	saveOrgFunc         func(org *models.Organisation) error
 	findOrgFunc         func(ctx context.Context, uri string) (*models.Organisation, error)
 	findGitOrgByURLFunc func(ctx context.Context, url string) (*models.GitOrganisatie, error)
 	saveGitOrgFunc      func(ctx context.Context, gitOrg *models.GitOrganisatie) error
 	filterCountsFunc    func(ctx context.Context, p *models.RepositoryFiltersParams) (*models.RepositoryFilterCounts, error)
+	saveAuditEventFunc  func(ctx context.Context, event *models.AuditEvent) error
+	auditEventsFunc     func(ctx context.Context, page, perPage int, filter models.AuditEventFilter) ([]models.AuditEvent, models.Pagination, error)
+	orgRefsFunc         func(ctx context.Context, uri string) (*models.OrganisationReferences, error)
+	deleteOrgFunc       func(ctx context.Context, uri string) error
+	getGitOrgFunc       func(ctx context.Context, id string) (*models.GitOrganisatie, error)
+	deleteGitOrgFunc    func(ctx context.Context, id string) error
+	gitOrgReposFunc     func(ctx context.Context, gitOrganisationURL string, page, perPage int) ([]models.Repository, models.Pagination, error)
 }
 
 func (s *serviceStubRepo) GetRepositorys(ctx context.Context, page, perPage int, p *models.RepositoryFiltersParams) ([]models.Repository, models.Pagination, error) {
@@ -38,6 +55,32 @@ func (s *serviceStubRepo) GetRepositorys(ctx context.Context, page, perPage int,
 	return nil, models.Pagination{}, nil
 }
 
+func (s *serviceStubRepo) ClaimDueSearchIndexOperations(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]models.SearchIndexOperation, error) {
+	return nil, nil
+}
+
+func (s *serviceStubRepo) SaveSearchIndexOperation(ctx context.Context, operation *models.SearchIndexOperation) error {
+	return nil
+}
+
+func (s *serviceStubRepo) CompleteSearchIndexOperation(ctx context.Context, operation *models.SearchIndexOperation) error {
+	return nil
+}
+
+func (s *serviceStubRepo) GetSearchIndexOperations(ctx context.Context, page, perPage int, status string) ([]models.SearchIndexOperation, models.Pagination, error) {
+	if s.indexOpsFunc != nil {
+		return s.indexOpsFunc(ctx, page, perPage, status)
+	}
+	return nil, models.Pagination{}, nil
+}
+
+func (s *serviceStubRepo) GetRepositoriesByIDs(ctx context.Context, ids []string) ([]models.Repository, error) {
+	if s.byIDsFunc != nil {
+		return s.byIDsFunc(ctx, ids)
+	}
+	return nil, nil
+}
+
 func (s *serviceStubRepo) GetRepositoryByID(ctx context.Context, id string) (*models.Repository, error) {
 	if s.retrieveFunc != nil {
 		return s.retrieveFunc(ctx, id)
@@ -45,6 +88,17 @@ func (s *serviceStubRepo) GetRepositoryByID(ctx context.Context, id string) (*mo
 	return nil, nil
 }
 
+func (s *serviceStubRepo) FindRepositoryByURL(ctx context.Context, url string) (*models.Repository, error) {
+	if s.findRepoByURLFunc != nil {
+		return s.findRepoByURLFunc(ctx, url)
+	}
+	return nil, nil
+}
+
+func (s *serviceStubRepo) FindRepositoryByNormalizedURL(ctx context.Context, url string) (*models.Repository, error) {
+	return s.FindRepositoryByURL(ctx, url)
+}
+
 func (s *serviceStubRepo) SearchRepositorys(ctx context.Context, page, perPage int, organisation *string, query string) ([]models.Repository, models.Pagination, error) {
 	if s.searchFunc != nil {
 		return s.searchFunc(ctx, page, perPage, organisation, query)
@@ -59,6 +113,20 @@ func (s *serviceStubRepo) SaveRepository(ctx context.Context, repository *models
 	return nil
 }
 
+func (s *serviceStubRepo) DeleteRepository(ctx context.Context, id string) error {
+	if s.deleteRepoFunc != nil {
+		return s.deleteRepoFunc(ctx, id)
+	}
+	return nil
+}
+
+func (s *serviceStubRepo) GetRepositoryRevisions(ctx context.Context, repositoryID string, page, perPage int) ([]models.RepositoryRevision, models.Pagination, error) {
+	if s.revisionsFunc != nil {
+		return s.revisionsFunc(ctx, repositoryID, page, perPage)
+	}
+	return nil, models.Pagination{}, nil
+}
+
 func (s *serviceStubRepo) SaveOrganisatie(org *models.Organisation) error {
 	if s.saveOrgFunc != nil {
 		return s.saveOrgFunc(org)
@@ -70,16 +138,16 @@ func (s *serviceStubRepo) AllRepositorys(ctx context.Context) ([]models.Reposito
 	return nil, nil
 }
 
-func (s *serviceStubRepo) GetOrganisations(ctx context.Context, page, perPage int) ([]models.Organisation, models.Pagination, error) {
+func (s *serviceStubRepo) GetOrganisations(ctx context.Context, page, perPage int, p *models.OrganisationFilters) ([]models.Organisation, models.Pagination, error) {
 	if s.getOrgFunc != nil {
-		return s.getOrgFunc(ctx, page, perPage)
+		return s.getOrgFunc(ctx, page, perPage, p)
 	}
 	return nil, models.Pagination{}, nil
 }
 
-func (s *serviceStubRepo) GetGitOrganisations(ctx context.Context, page, perPage int, organisation *string) ([]models.GitOrganisatie, models.Pagination, error) {
+func (s *serviceStubRepo) GetGitOrganisations(ctx context.Context, page, perPage int, organisation, after *string) ([]models.GitOrganisatie, models.Pagination, error) {
 	if s.gitOrgListFunc != nil {
-		return s.gitOrgListFunc(ctx, page, perPage, organisation)
+		return s.gitOrgListFunc(ctx, page, perPage, organisation, after)
 	}
 	return nil, models.Pagination{}, nil
 }
@@ -91,6 +159,20 @@ func (s *serviceStubRepo) FindOrganisationByURI(ctx context.Context, uri string)
 	return nil, nil
 }
 
+func (s *serviceStubRepo) CountOrganisationReferences(ctx context.Context, uri string) (*models.OrganisationReferences, error) {
+	if s.orgRefsFunc != nil {
+		return s.orgRefsFunc(ctx, uri)
+	}
+	return &models.OrganisationReferences{}, nil
+}
+
+func (s *serviceStubRepo) DeleteOrganisation(ctx context.Context, uri string) error {
+	if s.deleteOrgFunc != nil {
+		return s.deleteOrgFunc(ctx, uri)
+	}
+	return nil
+}
+
 func (s *serviceStubRepo) FindGitOrganisationByURL(ctx context.Context, url string) (*models.GitOrganisatie, error) {
 	if s.findGitOrgByURLFunc != nil {
 		return s.findGitOrgByURLFunc(ctx, url)
@@ -98,6 +180,27 @@ func (s *serviceStubRepo) FindGitOrganisationByURL(ctx context.Context, url stri
 	return nil, nil
 }
 
+func (s *serviceStubRepo) GetGitOrganisationByID(ctx context.Context, id string) (*models.GitOrganisatie, error) {
+	if s.getGitOrgFunc != nil {
+		return s.getGitOrgFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (s *serviceStubRepo) DeleteGitOrganisation(ctx context.Context, id string) error {
+	if s.deleteGitOrgFunc != nil {
+		return s.deleteGitOrgFunc(ctx, id)
+	}
+	return nil
+}
+
+func (s *serviceStubRepo) GetGitOrganisationRepositories(ctx context.Context, gitOrganisationURL string, page, perPage int) ([]models.Repository, models.Pagination, error) {
+	if s.gitOrgReposFunc != nil {
+		return s.gitOrgReposFunc(ctx, gitOrganisationURL, page, perPage)
+	}
+	return nil, models.Pagination{}, nil
+}
+
 func (s *serviceStubRepo) SaveGitOrganisatie(ctx context.Context, gitOrg *models.GitOrganisatie) error {
 	if s.saveGitOrgFunc != nil {
 		return s.saveGitOrgFunc(ctx, gitOrg)
@@ -112,6 +215,48 @@ func (s *serviceStubRepo) GetRepositoryFilterCounts(ctx context.Context, p *mode
 	return &models.RepositoryFilterCounts{}, nil
 }
 
+func (s *serviceStubRepo) SaveAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	if s.saveAuditEventFunc != nil {
+		return s.saveAuditEventFunc(ctx, event)
+	}
+	return nil
+}
+
+func (s *serviceStubRepo) GetAuditEvents(ctx context.Context, page, perPage int, filter models.AuditEventFilter) ([]models.AuditEvent, models.Pagination, error) {
+	if s.auditEventsFunc != nil {
+		return s.auditEventsFunc(ctx, page, perPage, filter)
+	}
+	return nil, models.Pagination{}, nil
+}
+
+func (s *serviceStubRepo) SaveJobRun(_ context.Context, _ *models.JobRun) error {
+	return nil
+}
+
+func (s *serviceStubRepo) FailRunningJobRuns(_ context.Context, _ string, _ time.Time, _ string) (int64, error) {
+	return 0, nil
+}
+
+func (s *serviceStubRepo) GetGitOrganisationsByHost(_ context.Context, _ string) ([]models.GitOrganisatie, error) {
+	return nil, nil
+}
+
+func (s *serviceStubRepo) EnqueueOrganisationSearchIndexOperations(_ context.Context, _ string) (int, error) {
+	return 0, nil
+}
+
+func (s *serviceStubRepo) TryLock(_ context.Context, _ string) (func(), bool, error) {
+	return func() {}, true, nil
+}
+
+func (s *serviceStubRepo) GetLatestJobRun(_ context.Context, _ string) (*models.JobRun, error) {
+	return nil, nil
+}
+
+func (s *serviceStubRepo) Transaction(ctx context.Context, fn func(repo repositories.RepositoriesRepository) error) error {
+	return fn(s)
+}
+
 func TestListRepositorys_HandlerSetsHeaders(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	repo := &serviceStubRepo{
@@ -119,7 +264,7 @@ func TestListRepositorys_HandlerSetsHeaders(t *testing.T) {
 			org := &models.Organisation{Uri: "org-1", Label: "Org 1"}
 			return []models.Repository{
 				{Id: "repo-1", Name: "Repo One", Organisation: org},
-			}, models.Pagination{TotalRecords: 1, TotalPages: 1, CurrentPage: 1, RecordsPerPage: 10}, nil
+			}, models.Pagination{Pagination: commonpagination.Pagination{TotalRecords: 1, TotalPages: 1, CurrentPage: 1, RecordsPerPage: 10}}, nil
 		},
 	}
 	ctrl := handler.NewOSSController(services.NewRepositoryService(repo))
@@ -146,7 +291,7 @@ func TestRetrieveRepository_NotFound(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/v1/repositories/missing", nil)
 	ctx.Request = req
 
-	resp, err := ctrl.RetrieveRepository(ctx, &models.RepositoryParams{Id: "missing"})
+	resp, err := ctrl.RetrieveRepository(ctx, &models.RetrieveRepositoryParams{RepositoryParams: models.RepositoryParams{Id: "missing"}})
 	assert.Nil(t, resp)
 	assert.Error(t, err)
 }
@@ -159,7 +304,7 @@ func TestSearchRepositorys_UsesService(t *testing.T) {
 			assert.Equal(t, 20, perPage)
 			assert.Nil(t, organisation)
 			assert.Equal(t, "repo", query)
-			return []models.Repository{{Id: "repo-2", Organisation: &models.Organisation{Uri: "org-1"}}}, models.Pagination{TotalRecords: 1}, nil
+			return []models.Repository{{Id: "repo-2", Organisation: &models.Organisation{Uri: "org-1"}}}, models.Pagination{Pagination: commonpagination.Pagination{TotalRecords: 1}}, nil
 		},
 	}
 	ctrl := handler.NewOSSController(services.NewRepositoryService(repo))
@@ -178,13 +323,13 @@ func TestSearchRepositorys_UsesService(t *testing.T) {
 func TestListOrganisations_SetsHeader(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	repo := &serviceStubRepo{
-		getOrgFunc: func(ctx context.Context, page, perPage int) ([]models.Organisation, models.Pagination, error) {
-			return []models.Organisation{{Uri: "org-1", Label: "Org 1"}}, models.Pagination{
+		getOrgFunc: func(ctx context.Context, page, perPage int, p *models.OrganisationFilters) ([]models.Organisation, models.Pagination, error) {
+			return []models.Organisation{{Uri: "org-1", Label: "Org 1"}}, models.Pagination{Pagination: commonpagination.Pagination{
 				TotalRecords:   1,
 				TotalPages:     1,
 				CurrentPage:    1,
 				RecordsPerPage: 10,
-			}, nil
+			}}, nil
 		},
 	}
 	ctrl := handler.NewOSSController(services.NewRepositoryService(repo))
@@ -200,6 +345,30 @@ func TestListOrganisations_SetsHeader(t *testing.T) {
 	assert.Equal(t, "1", w.Header().Get("Total-Count"))
 }
 
+func TestListOrganisations_HonoursPerPage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var gotPerPage int
+	repo := &serviceStubRepo{
+		getOrgFunc: func(ctx context.Context, page, perPage int, p *models.OrganisationFilters) ([]models.Organisation, models.Pagination, error) {
+			gotPerPage = perPage
+			return nil, models.Pagination{}, nil
+		},
+	}
+	ctrl := handler.NewOSSController(services.NewRepositoryService(repo))
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/v1/organisations", nil)
+
+	_, err := ctrl.ListOrganisations(ctx, &models.ListOrganisationsParams{PerPage: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 10, gotPerPage)
+
+	_, err = ctrl.ListOrganisations(ctx, &models.ListOrganisationsParams{})
+	require.NoError(t, err)
+	assert.Equal(t, 100, gotPerPage)
+}
+
 func TestCreateOrganisation_DelegatesToService(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	tooiURI := "https://identifier.overheid.nl/tooi/id/oorg/oorg10111"
@@ -297,13 +466,13 @@ func TestCreateRepository_DelegatesToService(t *testing.T) {
 func TestListGitOrganisations_SetsHeader(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	repo := &serviceStubRepo{
-		gitOrgListFunc: func(ctx context.Context, page, perPage int, organisation *string) ([]models.GitOrganisatie, models.Pagination, error) {
-			return []models.GitOrganisatie{{Id: "git-1", Url: "https://github.com/example"}}, models.Pagination{
+		gitOrgListFunc: func(ctx context.Context, page, perPage int, organisation, after *string) ([]models.GitOrganisatie, models.Pagination, error) {
+			return []models.GitOrganisatie{{Id: "git-1", Url: "https://github.com/example"}}, models.Pagination{Pagination: commonpagination.Pagination{
 				TotalRecords:   1,
 				TotalPages:     1,
 				CurrentPage:    1,
 				RecordsPerPage: 20,
-			}, nil
+			}}, nil
 		},
 	}
 	ctrl := handler.NewOSSController(services.NewRepositoryService(repo))
@@ -396,3 +565,59 @@ func TestListRepositoryFilters_DelegatesToService(t *testing.T) {
 	require.NotNil(t, groups[0].Count)
 	assert.Equal(t, 3, *groups[0].Count)
 }
+
+func TestPatchRepository_RejectsOversizedBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &serviceStubRepo{
+		retrieveFunc: func(ctx context.Context, id string) (*models.Repository, error) {
+			t.Fatalf("the repository should not be loaded for an oversized body")
+			return nil, nil
+		},
+	}
+	ctrl := handler.NewOSSController(services.NewRepositoryService(repo))
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	body := `{"name":"` + strings.Repeat("a", 1<<20) + `"}`
+	ctx.Request = httptest.NewRequest(http.MethodPatch, "/v1/repositories/repo-1", strings.NewReader(body))
+	ctx.Request.Header.Set("Content-Type", "application/merge-patch+json")
+	ctx.Params = gin.Params{{Key: "id", Value: "repo-1"}}
+
+	_, err := ctrl.PatchRepository(ctx)
+	var p problem.ProblemJSON
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, p.Status)
+}
+
+func TestBulkUpsertRepositories_RejectsOversizedBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := handler.NewOSSController(services.NewRepositoryService(&serviceStubRepo{}))
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	body := `[{"name":"` + strings.Repeat("a", 10<<20) + `"}]`
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/repositories/_bulk", strings.NewReader(body))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+
+	_, err := ctrl.BulkUpsertRepositories(ctx)
+	var p problem.ProblemJSON
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, p.Status)
+}
+
+func TestHandleWebhook_RejectsOversizedBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := handler.NewOSSController(services.NewRepositoryService(&serviceStubRepo{}))
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	body := `{"repository":"` + strings.Repeat("a", 5<<20) + `"}`
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/webhooks/github", strings.NewReader(body))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	ctx.Params = gin.Params{{Key: "forge", Value: "github"}}
+
+	_, err := ctrl.HandleWebhook(ctx)
+	var p problem.ProblemJSON
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, p.Status)
+}
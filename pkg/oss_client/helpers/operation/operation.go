@@ -0,0 +1,56 @@
+// Package operation looks up the OpenAPI operation that fizz generated for a
+// gin route, so middleware can use the operation id and security declared on it.
+package operation
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/wI2L/fizz/openapi"
+)
+
+// Lookup returns the operation for an HTTP method and gin route path
+// (/v1/repositories/:id). ok is false for routes unknown to the generator.
+type Lookup func(method, fullPath string) (op *openapi.Operation, ok bool)
+
+// Index reads the operations from the generated OpenAPI document. The document
+// is read on first use, after all routes have been registered.
+func Index(gen *openapi.Generator) Lookup {
+	var (
+		once  sync.Once
+		index map[string]*openapi.Operation
+	)
+	return func(method, fullPath string) (*openapi.Operation, bool) {
+		once.Do(func() {
+			index = map[string]*openapi.Operation{}
+			for path, item := range gen.API().Paths {
+				for m, op := range map[string]*openapi.Operation{
+					http.MethodGet:    item.GET,
+					http.MethodPut:    item.PUT,
+					http.MethodPost:   item.POST,
+					http.MethodPatch:  item.PATCH,
+					http.MethodDelete: item.DELETE,
+				} {
+					if op != nil {
+						index[m+" "+path] = op
+					}
+				}
+			}
+		})
+		op, ok := index[method+" "+OpenAPIPath(fullPath)]
+		return op, ok
+	}
+}
+
+// OpenAPIPath turns a gin route path (/v1/repositories/:id) into its OpenAPI
+// form (/v1/repositories/{id}).
+func OpenAPIPath(fullPath string) string {
+	segments := strings.Split(fullPath, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
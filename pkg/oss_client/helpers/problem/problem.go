@@ -27,6 +27,18 @@ func NewInternalServerError(title string) ProblemJSON {
 	return New(http.StatusInternalServerError, title)
 }
 
-func NewForbidden(title string) ProblemJSON {
-	return New(http.StatusForbidden, title)
+func NewUnauthorized(title string) ProblemJSON {
+	return New(http.StatusUnauthorized, title)
+}
+
+func NewForbidden(title string, details ...ErrorDetail) ProblemJSON {
+	return New(http.StatusForbidden, title, details...)
+}
+
+func NewGone(title string) ProblemJSON {
+	return New(http.StatusGone, title)
+}
+
+func NewPreconditionFailed(title string) ProblemJSON {
+	return New(http.StatusPreconditionFailed, title)
 }
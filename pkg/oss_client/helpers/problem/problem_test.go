@@ -56,6 +56,12 @@ func TestProblemConstructors(t *testing.T) {
 			status: http.StatusForbidden,
 			title:  "denied",
 		},
+		{
+			name:   "gone",
+			got:    problem.NewGone("deleted"),
+			status: http.StatusGone,
+			title:  "deleted",
+		},
 	}
 
 	for _, tt := range tests {
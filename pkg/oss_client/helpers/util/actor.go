@@ -0,0 +1,34 @@
+package util
+
+import (
+	"context"
+	"strings"
+)
+
+const (
+	// ActorSystem is used for changes made by background jobs.
+	ActorSystem = "system"
+	// ActorAPI is used for changes made through the HTTP API.
+	ActorAPI = "api"
+	// ActorCrawler is used for changes made by the repository crawler.
+	ActorCrawler = "crawler"
+	// ActorWebhook is used for changes triggered by forge webhooks.
+	ActorWebhook = "webhook"
+)
+
+type actorContextKey struct{}
+
+// WithActor stores who performs a change so repository revisions can record it.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, strings.TrimSpace(actor))
+}
+
+// ActorFromContext returns the actor stored by WithActor, or ActorSystem when absent.
+func ActorFromContext(ctx context.Context) string {
+	if ctx != nil {
+		if actor, ok := ctx.Value(actorContextKey{}).(string); ok && actor != "" {
+			return actor
+		}
+	}
+	return ActorSystem
+}
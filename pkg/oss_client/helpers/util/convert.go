@@ -1,6 +1,7 @@
 package util
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -11,12 +12,23 @@ import (
 	"os/exec"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
 	"github.com/google/uuid"
 	publiccode "github.com/italia/publiccode-parser-go/v5"
 )
 
+const (
+	// publicCodeFetchTimeout bounds fetching a publiccode.yml from a forge.
+	publicCodeFetchTimeout = 10 * time.Second
+	// publicCodeValidationTimeout bounds a single don-checker run.
+	publicCodeValidationTimeout = time.Minute
+	// maxPublicCodeSize caps the publiccode.yml body that is read.
+	maxPublicCodeSize = 1 << 20
+)
+
 // PublicCodeValidator validates publiccode.yml input before it is parsed.
 type PublicCodeValidator interface {
 	ValidatePublicCode(input string) error
@@ -76,10 +88,13 @@ func (donCheckerPublicCodeValidator) ValidatePublicCode(input string) error {
 		defer cleanup()
 	}
 
-	cmd := exec.Command("don-checker", publicCodeValidationArgs(inputArg)...)
+	ctx, cancel := context.WithTimeout(context.Background(), publicCodeValidationTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "don-checker", publicCodeValidationArgs(inputArg)...)
 	output, err := cmd.CombinedOutput()
 	if isExecutableNotFound(err) {
-		cmd = exec.Command("npx", append([]string{"--yes", "@developer-overheid-nl/don-checker@latest"}, publicCodeValidationArgs(inputArg)...)...)
+		cmd = exec.CommandContext(ctx, "npx", append([]string{"--yes", "@developer-overheid-nl/don-checker@latest"}, publicCodeValidationArgs(inputArg)...)...)
 		output, err = cmd.CombinedOutput()
 	}
 	if err != nil {
@@ -140,6 +155,7 @@ func ToRepositoryDetail(repo *models.Repository) *models.RepositoryDetail {
 		RepositorySummary: ToRepositorySummary(repo),
 		PublicCode:        repo.PublicCode,
 		LongDescription:   repo.LongDescription,
+		Version:           repo.Version,
 	}
 	return detail
 }
@@ -153,6 +169,12 @@ func ToGitOrganisatieSummary(gitOrg *models.GitOrganisatie) models.GitOrganisati
 }
 
 func ApplyRepositoryInput(target *models.Repository, input *models.RepositoryInput) *models.Repository {
+	return ApplyRepositoryInputContext(context.Background(), target, input)
+}
+
+// ApplyRepositoryInputContext is ApplyRepositoryInput with the publiccode.yml
+// fetch bound to ctx and to publicCodeFetchTimeout.
+func ApplyRepositoryInputContext(ctx context.Context, target *models.Repository, input *models.RepositoryInput) *models.Repository {
 	if target == nil {
 		target = &models.Repository{
 			Id: uuid.NewString(),
@@ -203,17 +225,8 @@ func ApplyRepositoryInput(target *models.Repository, input *models.RepositoryInp
 	if publicCodeRaw != "" {
 		content := publicCodeRaw
 		if isLikelyURL(publicCodeRaw) {
-			if resp, err := http.Get(publicCodeRaw); err == nil && resp != nil {
-				defer func() {
-					if err := resp.Body.Close(); err != nil {
-						_ = err
-					}
-				}()
-				if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
-					if body, err := io.ReadAll(resp.Body); err == nil {
-						content = string(body)
-					}
-				}
+			if body, ok := fetchPublicCode(ctx, publicCodeRaw); ok {
+				content = body
 			}
 		}
 
@@ -257,6 +270,36 @@ func ApplyRepositoryInput(target *models.Repository, input *models.RepositoryInp
 	return target
 }
 
+// fetchPublicCode downloads a publiccode.yml. ok is false when the request
+// fails, times out or does not return 2xx.
+func fetchPublicCode(ctx context.Context, rawURL string) (string, bool) {
+	ctx, cancel := context.WithTimeout(ctx, publicCodeFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", false
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("fetching publiccode %s failed: %v", rawURL, err)
+		return "", false
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			_ = err
+		}
+	}()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return "", false
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPublicCodeSize))
+	if err != nil {
+		return "", false
+	}
+	return string(body), true
+}
+
 func repositoryNameFromURL(rawURL string) string {
 	parsed, err := url.Parse(strings.TrimSpace(rawURL))
 	if err != nil {
@@ -322,7 +365,14 @@ type parsedPublicCodeYAML struct {
 	BasedOnURLs      []string
 }
 
+// publicCodeParseMu serialises parsing: the publiccode parser sets package
+// level state and is not safe for concurrent use.
+var publicCodeParseMu sync.Mutex
+
 func parsePublicCodeYAML(raw string) parsedPublicCodeYAML {
+	publicCodeParseMu.Lock()
+	defer publicCodeParseMu.Unlock()
+
 	parser, err := publiccode.NewParser(publiccode.ParserConfig{
 		DisableExternalChecks: true,
 	})
@@ -2,6 +2,7 @@ package util_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"log"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/util"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	commonpagination "github.com/developer-overheid-nl/don-register-common/pagination"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -94,14 +96,14 @@ func TestSetPaginationHeadersBuildsHTTPSLinkHeader(t *testing.T) {
 	next := 3
 	headers := http.Header{}
 
-	util.SetPaginationHeaders(req, headers.Set, models.Pagination{
+	util.SetPaginationHeaders(req, headers.Set, models.Pagination{Pagination: commonpagination.Pagination{
 		Previous:       &previous,
 		Next:           &next,
 		CurrentPage:    2,
 		RecordsPerPage: 25,
 		TotalPages:     4,
 		TotalRecords:   88,
-	})
+	}})
 
 	assert.Equal(t, "88", headers.Get("Total-Count"))
 	assert.Equal(t, "4", headers.Get("Total-Pages"))
@@ -125,6 +127,25 @@ func TestSetPaginationHeadersOmitsLinksWhenThereAreNoPages(t *testing.T) {
 	assert.Empty(t, headers.Get("Link"))
 }
 
+func TestRepositoryETagAndMatching(t *testing.T) {
+	etag := util.RepositoryETag(3)
+	assert.Equal(t, `"3"`, etag)
+
+	assert.True(t, util.ETagMatches(`"3"`, etag))
+	assert.True(t, util.ETagMatches(`W/"3"`, etag))
+	assert.True(t, util.ETagMatches(`"1", "3"`, etag))
+	assert.True(t, util.ETagMatches("*", etag))
+	assert.False(t, util.ETagMatches(`"2"`, etag))
+	assert.False(t, util.ETagMatches("3", etag))
+
+	assert.True(t, util.ETagMatchesStrong(`"3"`, etag))
+	assert.True(t, util.ETagMatchesStrong(`"1", "3"`, etag))
+	assert.True(t, util.ETagMatchesStrong("*", etag))
+	assert.False(t, util.ETagMatchesStrong(`W/"3"`, etag))
+	assert.False(t, util.ETagMatchesStrong(`"3"`, `W/"3"`))
+	assert.False(t, util.ETagMatchesStrong(`"2"`, etag))
+}
+
 func TestApplyRepositoryInputKeepsExistingTimestampsWhenZero(t *testing.T) {
 	created := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
 	lastCrawled := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
@@ -605,6 +626,32 @@ func strPtr(val string) *string {
 	return &val
 }
 
+func TestApplyRepositoryInputContextStopsFetchingWhenContextIsDone(t *testing.T) {
+	disablePublicCodeValidation(t)
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	repo := util.ApplyRepositoryInputContext(ctx, nil, &models.RepositoryInput{
+		Url:           strPtr("https://example.org/org/slow-forge"),
+		PublicCodeUrl: strPtr(srv.URL + "/publiccode.yml"),
+	})
+	assert.Less(t, time.Since(started), 5*time.Second)
+	assert.Equal(t, "slow-forge", repo.Name)
+	assert.Nil(t, repo.PublicCode)
+}
+
 func disablePublicCodeValidation(t *testing.T) {
 	t.Helper()
 	util.SetPublicCodeValidatorForTest(t, &fakePublicCodeValidator{})
@@ -0,0 +1,81 @@
+package util
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+)
+
+// CursorStart starts cursor pagination at the first item.
+const CursorStart = "*"
+
+// ErrInvalidCursor is returned by DecodeCursor for cursors that were not
+// produced by EncodeCursor.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type cursorPayload struct {
+	After string `json:"after"`
+}
+
+// EncodeCursor returns the opaque cursor for the items after the one with id.
+func EncodeCursor(id string) string {
+	payload, _ := json.Marshal(cursorPayload{After: id})
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// DecodeCursor returns the id after which the page starts, or "" for
+// CursorStart.
+func DecodeCursor(cursor string) (string, error) {
+	cursor = strings.TrimSpace(cursor)
+	if cursor == CursorStart {
+		return "", nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", ErrInvalidCursor
+	}
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil || payload.After == "" {
+		return "", ErrInvalidCursor
+	}
+	return payload.After, nil
+}
+
+// SetCursorPaginationHeaders is SetPaginationHeaders for cursor pagination:
+// Next-Cursor holds the cursor of the next page, and Link points to the first
+// and the next page. Page numbers do not apply and are left out.
+func SetCursorPaginationHeaders(r *http.Request, setHeader func(key, val string), p models.Pagination, nextCursor string) {
+	setHeader("Total-Count", strconv.Itoa(p.TotalRecords))
+	setHeader("Per-Page", strconv.Itoa(p.RecordsPerPage))
+	if nextCursor != "" {
+		setHeader("Next-Cursor", nextCursor)
+	}
+	if r == nil || r.URL == nil {
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	makeURL := func(cursor string) string {
+		u := *r.URL
+		q := u.Query()
+		q.Del("page")
+		q.Set("cursor", cursor)
+		q.Set("perPage", strconv.Itoa(p.RecordsPerPage))
+		u.RawQuery = q.Encode()
+		return fmt.Sprintf("%s://%s%s", scheme, r.Host, u.RequestURI())
+	}
+	links := []string{fmt.Sprintf("<%s>; rel=\"first\"", makeURL(CursorStart))}
+	if nextCursor != "" {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"next\"", makeURL(nextCursor)))
+	}
+	setHeader("Link", strings.Join(links, ", "))
+}
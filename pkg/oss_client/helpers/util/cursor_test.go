@@ -0,0 +1,42 @@
+package util_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/util"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	commonpagination "github.com/developer-overheid-nl/don-register-common/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	after, err := util.DecodeCursor(util.EncodeCursor("repo-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "repo-1", after)
+
+	after, err = util.DecodeCursor(util.CursorStart)
+	require.NoError(t, err)
+	assert.Empty(t, after)
+
+	for _, invalid := range []string{"repo-1", "e30", "!!"} {
+		_, err := util.DecodeCursor(invalid)
+		assert.ErrorIs(t, err, util.ErrInvalidCursor, invalid)
+	}
+}
+
+func TestSetCursorPaginationHeadersLinksNextCursor(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://api.example.org/v1/git-organisations?cursor=%2A&page=3&perPage=2", nil)
+	headers := http.Header{}
+	next := util.EncodeCursor("git-2")
+
+	util.SetCursorPaginationHeaders(req, headers.Set, models.Pagination{Pagination: commonpagination.Pagination{RecordsPerPage: 2, TotalRecords: 5}}, next)
+
+	assert.Equal(t, "5", headers.Get("Total-Count"))
+	assert.Equal(t, next, headers.Get("Next-Cursor"))
+	assert.Empty(t, headers.Get("Current-Page"))
+	assert.Equal(t, `<http://api.example.org/v1/git-organisations?cursor=%2A&perPage=2>; rel="first", `+
+		`<http://api.example.org/v1/git-organisations?cursor=`+next+`&perPage=2>; rel="next"`, headers.Get("Link"))
+}
@@ -63,6 +63,54 @@ func classifyRepositoryForkType(repoURL string, isFork bool, publicCodeURL strin
 	return ""
 }
 
+// RepositoryURLUnder reports whether repoURL lies below gitOrganisationURL, for
+// example https://github.com/org/repo below https://github.com/org. Both urls
+// are compared in their normalised form, so scheme, case and a trailing .git
+// do not matter.
+func RepositoryURLUnder(repoURL, gitOrganisationURL string) bool {
+	repo := normalizeRepositoryReference(repoURL)
+	org := normalizeRepositoryReference(gitOrganisationURL)
+	if repo == "" || org == "" {
+		return false
+	}
+	return strings.HasPrefix(repo, org+"/")
+}
+
+// SameRepositoryURL reports whether a and b point at the same repository when
+// compared in their normalised form, as in RepositoryURLUnder.
+func SameRepositoryURL(a, b string) bool {
+	normalized := normalizeRepositoryReference(a)
+	return normalized != "" && normalized == normalizeRepositoryReference(b)
+}
+
+// GitOrganisationPathPattern returns the lower-case path of a git organisation
+// url, for a coarse LIKE pre-filter in SQL before RepositoryURLUnder is applied.
+func GitOrganisationPathPattern(gitOrganisationURL string) string {
+	normalized := normalizeRepositoryReference(gitOrganisationURL)
+	if _, path, ok := strings.Cut(normalized, "/"); ok {
+		return "/" + path + "/"
+	}
+	return normalized
+}
+
+// RepositoryURLPrefixes returns the lower-case http and https url prefixes of
+// the repositories below a git organisation, for a LIKE match in SQL. It
+// returns nil for an invalid url.
+func RepositoryURLPrefixes(gitOrganisationURL string) []string {
+	normalized := normalizeRepositoryReference(gitOrganisationURL)
+	if normalized == "" {
+		return nil
+	}
+	return []string{"https://" + normalized + "/", "http://" + normalized + "/"}
+}
+
+// RepositoryURLHost returns the lower-case host of a repository url, with its
+// port when that is not the default, or "" for an invalid url.
+func RepositoryURLHost(repoURL string) string {
+	host, _, _ := strings.Cut(normalizeRepositoryReference(repoURL), "/")
+	return host
+}
+
 func normalizeRepositoryReference(raw string) string {
 	trimmed := strings.TrimSpace(raw)
 	if trimmed == "" {
@@ -67,3 +67,51 @@ func TestDetectRepositoryForkType(t *testing.T) {
 		})
 	}
 }
+
+func TestRepositoryURLUnder(t *testing.T) {
+	testCases := map[string]struct {
+		repoURL  string
+		orgURL   string
+		expected bool
+	}{
+		"repository below organisation":        {"https://github.com/developer-overheid-nl/don-site", "https://github.com/developer-overheid-nl", true},
+		"case, scheme and .git are normalised": {"http://GitHub.com/Developer-Overheid-NL/don-site.git", "https://github.com/developer-overheid-nl/", true},
+		"default port is ignored":              {"https://github.com:443/developer-overheid-nl/don-site", "https://github.com/developer-overheid-nl", true},
+		"organisation itself is not below":     {"https://github.com/developer-overheid-nl", "https://github.com/developer-overheid-nl", false},
+		"prefix of another organisation":       {"https://github.com/developer-overheid-nl-archive/don-site", "https://github.com/developer-overheid-nl", false},
+		"other host":                           {"https://gitlab.com/developer-overheid-nl/don-site", "https://github.com/developer-overheid-nl", false},
+		"invalid url":                          {"not a url", "https://github.com/developer-overheid-nl", false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, util.RepositoryURLUnder(tc.repoURL, tc.orgURL))
+		})
+	}
+}
+
+func TestSameRepositoryURL(t *testing.T) {
+	assert.True(t, util.SameRepositoryURL("https://github.com/acme/app", "http://GitHub.com/Acme/App.git/"))
+	assert.False(t, util.SameRepositoryURL("https://github.com/acme/app", "https://github.com/acme/app-other"))
+	assert.False(t, util.SameRepositoryURL("", ""))
+}
+
+func TestRepositoryURLPrefixes(t *testing.T) {
+	assert.Equal(t, []string{"https://github.com/developer-overheid-nl/", "http://github.com/developer-overheid-nl/"},
+		util.RepositoryURLPrefixes("https://GitHub.com/Developer-Overheid-NL/"))
+	assert.Equal(t, []string{"https://git.example.org:8443/group/", "http://git.example.org:8443/group/"},
+		util.RepositoryURLPrefixes("https://git.example.org:8443/group"))
+	assert.Nil(t, util.RepositoryURLPrefixes("not a url"))
+}
+
+func TestRepositoryURLHost(t *testing.T) {
+	assert.Equal(t, "github.com", util.RepositoryURLHost("https://GitHub.com:443/acme/repo"))
+	assert.Equal(t, "git.example.org:8443", util.RepositoryURLHost("https://git.example.org:8443/group/repo"))
+	assert.Empty(t, util.RepositoryURLHost("not a url"))
+}
+
+func TestGitOrganisationPathPattern(t *testing.T) {
+	assert.Equal(t, "/developer-overheid-nl/", util.GitOrganisationPathPattern("https://GitHub.com/Developer-Overheid-NL/"))
+	assert.Equal(t, "/group/subgroup/", util.GitOrganisationPathPattern("https://gitlab.com/group/subgroup"))
+	assert.Equal(t, "git.example.org", util.GitOrganisationPathPattern("https://git.example.org"))
+}
@@ -2,11 +2,51 @@ package util
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
 	commonpagination "github.com/developer-overheid-nl/don-register-common/pagination"
 )
 
 func SetPaginationHeaders(r *http.Request, setHeader func(key, val string), p models.Pagination) {
-	commonpagination.SetHeaders(r, setHeader, p)
+	commonpagination.SetHeaders(r, setHeader, p.Pagination)
+}
+
+// RepositoryETag formats a repository version as a strong entity tag.
+func RepositoryETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// ETagMatches reports whether an If-None-Match header value matches the given
+// entity tag using the weak comparison: weak tags are compared on their opaque
+// value.
+func ETagMatches(header, etag string) bool {
+	return etagMatches(header, etag, false)
+}
+
+// ETagMatchesStrong reports whether an If-Match header value matches the given
+// entity tag using the strong comparison of RFC 9110 section 8.8.3.2: weak
+// tags never match.
+func ETagMatchesStrong(header, etag string) bool {
+	return etagMatches(header, etag, true)
+}
+
+func etagMatches(header, etag string, strong bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strong {
+			if !strings.HasPrefix(candidate, "W/") && !strings.HasPrefix(etag, "W/") && candidate == etag {
+				return true
+			}
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
 }
@@ -3,27 +3,55 @@ package oss_client_test
 import (
 	"bytes"
 	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	oss_client "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/audit"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/auth"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/handler"
 	httpclient "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/httpclient"
+	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/ratelimit"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/repositories"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/services"
 	"github.com/gin-gonic/gin"
+	"github.com/loopfz/gadgeto/tonic"
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+// init mirrors the error hook from cmd/main.go so problem responses keep their status.
+func init() {
+	tonic.SetErrorHook(func(c *gin.Context, err error) (int, interface{}) {
+		var apiErr problem.ProblemJSON
+		if errors.As(err, &apiErr) {
+			c.Header("Content-Type", "application/problem+json")
+			return apiErr.Status, apiErr
+		}
+		return tonic.DefaultErrorHook(c, err)
+	})
+}
+
 type integrationEnv struct {
 	server  *httptest.Server
 	repo    repositories.RepositoriesRepository
@@ -31,7 +59,7 @@ type integrationEnv struct {
 	client  *http.Client
 }
 
-func newIntegrationEnv(t *testing.T) *integrationEnv {
+func newIntegrationEnv(t *testing.T, opts ...oss_client.RouterOption) *integrationEnv {
 	t.Helper()
 
 	gin.SetMode(gin.TestMode)
@@ -41,12 +69,12 @@ func newIntegrationEnv(t *testing.T) *integrationEnv {
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&models.Organisation{}, &models.Repository{}, &models.GitOrganisatie{}))
+	require.NoError(t, db.AutoMigrate(&models.Organisation{}, &models.Repository{}, &models.GitOrganisatie{}, &models.RepositoryRevision{}, &models.AuditEvent{}, &models.SearchIndexOperation{}, &models.JobRun{}))
 
 	repo := repositories.NewRepositoriesRepository(db)
 	svc := services.NewRepositoryService(repo)
 	controller := handler.NewOSSController(svc)
-	router := oss_client.NewRouter("test-version", controller)
+	router := oss_client.NewRouter("test-version", controller, opts...)
 
 	server := httptest.NewServer(router)
 	t.Cleanup(func() { server.Close() })
@@ -99,6 +127,16 @@ func (e *integrationEnv) doJSONRequestWithHeaders(t *testing.T, method, path str
 	return resp
 }
 
+func (e *integrationEnv) doRawRequest(t *testing.T, method, path, contentType, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, e.server.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := e.client.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
 func decodeBody[T any](t *testing.T, resp *http.Response) T {
 	t.Helper()
 	defer func() {
@@ -419,6 +457,72 @@ func TestRepositoriesEndpoints(t *testing.T) {
 		require.Equal(t, "repo-2", body[0].Id)
 	})
 
+	t.Run("organisation detail, update and delete", func(t *testing.T) {
+		path := "/v1/organisations/" + url.PathEscape(org.Uri)
+		resp := env.doRequest(t, http.MethodGet, path)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		detail := decodeBody[models.OrganisationDetail](t, resp)
+		require.Equal(t, org.Uri, detail.Uri)
+		require.Positive(t, detail.RepositoryCount)
+
+		resp = env.doJSONRequest(t, http.MethodPut, path, map[string]string{"label": "Integration Org renamed"})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		detail = decodeBody[models.OrganisationDetail](t, resp)
+		require.Equal(t, "Integration Org renamed", detail.Label)
+
+		resp = env.doRequest(t, http.MethodDelete, path)
+		require.Equal(t, http.StatusConflict, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+
+		unused := &models.Organisation{Uri: "https://example.org/organisations/unused", Label: "Unused"}
+		require.NoError(t, env.repo.SaveOrganisatie(unused))
+		unusedPath := "/v1/organisations/" + url.PathEscape(unused.Uri)
+		resp = env.doRequest(t, http.MethodDelete, unusedPath)
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+
+		resp = env.doRequest(t, http.MethodGet, unusedPath)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+	})
+
+	t.Run("git organisation detail, repositories, update and delete", func(t *testing.T) {
+		resp := env.doJSONRequest(t, http.MethodPost, "/v1/git-organisations", map[string]string{
+			"url":             "https://example.org/repos",
+			"organisationUri": org.Uri,
+		})
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		gitOrg := decodeBody[models.GitOrganisatie](t, resp)
+
+		resp = env.doRequest(t, http.MethodGet, "/v1/git-organisations/"+gitOrg.Id)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+
+		resp = env.doRequest(t, http.MethodGet, "/v1/git-organisations/"+gitOrg.Id+"/repositories")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		repos := decodeBody[[]models.RepositorySummary](t, resp)
+		require.NotEmpty(t, repos)
+		for _, repo := range repos {
+			require.True(t, strings.HasPrefix(repo.Url, "https://example.org/repos/"), repo.Url)
+		}
+
+		resp = env.doJSONRequest(t, http.MethodPut, "/v1/git-organisations/"+gitOrg.Id, map[string]string{
+			"url":             "https://example.org/other-repos",
+			"organisationUri": org.Uri,
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		updated := decodeBody[models.GitOrganisatie](t, resp)
+		require.Equal(t, "https://example.org/other-repos", updated.Url)
+
+		resp = env.doRequest(t, http.MethodDelete, "/v1/git-organisations/"+gitOrg.Id)
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+
+		resp = env.doRequest(t, http.MethodGet, "/v1/git-organisations/"+gitOrg.Id)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+	})
+
 	t.Run("list organisations", func(t *testing.T) {
 		resp := env.doRequest(t, http.MethodGet, "/v1/organisations")
 		require.Equal(t, http.StatusOK, resp.StatusCode)
@@ -454,6 +558,222 @@ func TestRepositoriesEndpoints(t *testing.T) {
 		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
 	})
 
+	t.Run("repository etag supports conditional requests", func(t *testing.T) {
+		resp := env.doRequest(t, http.MethodGet, "/v1/repositories/repo-1")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		etag := resp.Header.Get("ETag")
+		require.NotEmpty(t, etag)
+		require.NoError(t, resp.Body.Close())
+
+		req, err := http.NewRequest(http.MethodGet, env.server.URL+"/v1/repositories/repo-1", nil)
+		require.NoError(t, err)
+		req.Header.Set("If-None-Match", etag)
+		resp, err = env.client.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotModified, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+
+		resp = env.doJSONRequestWithHeaders(t, http.MethodPut, "/v1/repositories/repo-1", map[string]any{
+			"url":             repoModel.Url,
+			"organisationUri": org.Uri,
+			"name":            "Integration Repo",
+		}, map[string]string{"If-Match": `"999"`})
+		require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+
+		resp = env.doJSONRequestWithHeaders(t, http.MethodPut, "/v1/repositories/repo-1", map[string]any{
+			"url":             repoModel.Url,
+			"organisationUri": org.Uri,
+			"name":            "Integration Repo",
+		}, map[string]string{"If-Match": etag})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		newETag := resp.Header.Get("ETag")
+		require.NotEmpty(t, newETag)
+		require.NotEqual(t, etag, newETag)
+		require.NoError(t, resp.Body.Close())
+
+		resp = env.doJSONRequestWithHeaders(t, http.MethodPut, "/v1/repositories/repo-1", map[string]any{
+			"url":             repoModel.Url,
+			"organisationUri": org.Uri,
+			"name":            "Integration Repo",
+		}, map[string]string{"If-Match": etag})
+		require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+	})
+
+	t.Run("patch repository updates only supplied fields", func(t *testing.T) {
+		patched := &models.Repository{
+			Id:               "repo-to-patch",
+			Name:             "Repo to patch",
+			ShortDescription: "Blijft staan",
+			OrganisationID:   &org.Uri,
+			Url:              "https://example.org/repos/repo-to-patch",
+			Active:           true,
+		}
+		require.NoError(t, env.repo.SaveRepository(ctx, patched))
+
+		resp := env.doRawRequest(t, http.MethodPatch, "/v1/repositories/repo-to-patch", "application/merge-patch+json", `{"archived":true}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body := decodeBody[models.RepositoryDetail](t, resp)
+		require.True(t, body.Archived)
+		require.Equal(t, "Repo to patch", body.Name)
+		require.Equal(t, "Blijft staan", body.ShortDescription)
+
+		stored, err := env.repo.GetRepositoryByID(ctx, "repo-to-patch")
+		require.NoError(t, err)
+		require.True(t, stored.Archived)
+		require.Equal(t, "Blijft staan", stored.ShortDescription)
+
+		resp = env.doRawRequest(t, http.MethodPatch, "/v1/repositories/repo-to-patch", "application/merge-patch+json", `{"url":"notaurl"}`)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+
+		resp = env.doRawRequest(t, http.MethodPatch, "/v1/repositories/repo-to-patch", "text/plain", `{"archived":false}`)
+		require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+
+		resp = env.doRawRequest(t, http.MethodPatch, "/v1/repositories/does-not-exist", "application/merge-patch+json", `{"archived":true}`)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+	})
+
+	t.Run("bulk upsert creates and updates repositories in one request", func(t *testing.T) {
+		body := `[
+			{"url": "https://example.org/repos/bulk-new", "organisationUri": "` + org.Uri + `", "name": "Bulk new"},
+			{"url": "https://example.org/repos/repo-to-patch", "organisationUri": "` + org.Uri + `", "name": "Renamed by bulk"},
+			{"url": "notaurl", "organisationUri": "` + org.Uri + `"}
+		]`
+		resp := env.doRawRequest(t, http.MethodPost, "/v1/repositories/_bulk", "application/json", body)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		result := decodeBody[models.BulkRepositoryResponse](t, resp)
+		require.Equal(t, 1, result.Created)
+		require.Equal(t, 1, result.Updated)
+		require.Equal(t, 1, result.Failed)
+		require.Equal(t, "repo-to-patch", result.Results[1].Id)
+		require.Equal(t, models.BulkStatusFailed, result.Results[2].Status)
+
+		created, err := env.repo.FindRepositoryByURL(ctx, "https://example.org/repos/bulk-new")
+		require.NoError(t, err)
+		require.NotNil(t, created)
+		require.Equal(t, result.Results[0].Id, created.Id)
+
+		updated, err := env.repo.GetRepositoryByID(ctx, "repo-to-patch")
+		require.NoError(t, err)
+		require.Equal(t, "Renamed by bulk", updated.Name)
+
+		ndjson := `{"url": "https://example.org/repos/bulk-ndjson", "organisationUri": "` + org.Uri + `"}` + "\n"
+		resp = env.doRawRequest(t, http.MethodPost, "/v1/repositories/_bulk", "application/x-ndjson", ndjson)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		result = decodeBody[models.BulkRepositoryResponse](t, resp)
+		require.Equal(t, 1, result.Created)
+
+		resp = env.doRawRequest(t, http.MethodPost, "/v1/repositories/_bulk", "text/plain", ndjson)
+		require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+	})
+
+	t.Run("repository history lists revisions", func(t *testing.T) {
+		resp := env.doRawRequest(t, http.MethodPatch, "/v1/repositories/repo-1", "application/merge-patch+json", `{"name":"Integration Repo renamed"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+
+		resp = env.doRequest(t, http.MethodGet, "/v1/repositories/repo-1/history?perPage=1")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NotEmpty(t, resp.Header.Get("Total-Count"))
+
+		body := decodeBody[[]models.RepositoryRevision](t, resp)
+		require.Len(t, body, 1)
+		require.Equal(t, "api", body[0].Actor)
+		require.Equal(t, []models.RepositoryFieldChange{
+			{Field: "name", OldValue: "Integration Repo", NewValue: "Integration Repo renamed"},
+		}, body[0].Changes)
+
+		resp = env.doRequest(t, http.MethodGet, "/v1/repositories/does-not-exist/history")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+	})
+
+	t.Run("webhook refreshes repository", func(t *testing.T) {
+		resp := env.doJSONRequest(t, http.MethodPost, "/v1/git-organisations", map[string]string{
+			"url":             "https://example.org/repos",
+			"organisationUri": org.Uri,
+			"webhookSecret":   "s3cret",
+		})
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		gitOrg := decodeBody[map[string]any](t, resp)
+		require.NotContains(t, gitOrg, "webhookSecret")
+
+		payload := `{"ref":"refs/heads/main","repository":{"html_url":"https://example.org/repos/repo-to-patch","archived":false}}`
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write([]byte(payload))
+		send := func(signature string) *http.Response {
+			req, err := http.NewRequest(http.MethodPost, env.server.URL+"/v1/webhooks/github", strings.NewReader(payload))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-GitHub-Event", "push")
+			req.Header.Set("X-Hub-Signature-256", signature)
+			resp, err := env.client.Do(req)
+			require.NoError(t, err)
+			return resp
+		}
+
+		resp = send("sha256=" + hex.EncodeToString(mac.Sum(nil)))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		result := decodeBody[models.WebhookResult](t, resp)
+		require.Equal(t, models.WebhookStatusRefreshed, result.Status)
+		require.Equal(t, "repo-to-patch", result.RepositoryId)
+
+		stored, err := env.repo.GetRepositoryByID(ctx, "repo-to-patch")
+		require.NoError(t, err)
+		require.False(t, stored.Archived)
+		require.WithinDuration(t, time.Now(), stored.LastActivityAt, time.Minute)
+
+		resp = send("sha256=00")
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+		require.NoError(t, resp.Body.Close())
+
+		resp = env.doRawRequest(t, http.MethodPost, "/v1/webhooks/bitbucket", "application/json", payload)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+
+		resp = env.doRawRequest(t, http.MethodPost, "/v1/webhooks/github", "application/x-www-form-urlencoded", "payload="+payload)
+		require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+	})
+
+	t.Run("delete repository leaves tombstone", func(t *testing.T) {
+		tombstone := &models.Repository{
+			Id:             "repo-to-delete",
+			Name:           "Repo to delete",
+			OrganisationID: &org.Uri,
+			Url:            "https://example.org/repos/repo-to-delete",
+			Active:         true,
+		}
+		require.NoError(t, env.repo.SaveRepository(ctx, tombstone))
+
+		resp := env.doRequest(t, http.MethodDelete, "/v1/repositories/repo-to-delete")
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+
+		resp = env.doRequest(t, http.MethodGet, "/v1/repositories/repo-to-delete")
+		require.Equal(t, http.StatusGone, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+
+		resp = env.doRequest(t, http.MethodDelete, "/v1/repositories/repo-to-delete")
+		require.Equal(t, http.StatusGone, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+
+		resp = env.doRequest(t, http.MethodDelete, "/v1/repositories/does-not-exist")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+
+		resp = env.doRequest(t, http.MethodGet, "/v1/repositories?q=delete")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body := decodeBody[[]models.RepositorySummary](t, resp)
+		require.Empty(t, body)
+	})
+
 	t.Run("method not allowed returns 405 with RFC7807 envelope", func(t *testing.T) {
 		// Send a PATCH request to an existing route that only supports GET
 		resp := env.doRequest(t, http.MethodPatch, "/v1/repositories")
@@ -472,6 +792,360 @@ func TestRepositoriesEndpoints(t *testing.T) {
 	})
 }
 
+// signRS256 signs claims with key as a compact JWS with kid "test".
+func signRS256(t *testing.T, key *rsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+	enc := base64.RawURLEncoding
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	input := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return input + "." + enc.EncodeToString(signature)
+}
+
+// newAuthIntegrationEnv starts the API with authentication against a local
+// JWKS. The returned function builds an Authorization header for a client.
+func TestAdminRoutesAreClosedWithoutAuthentication(t *testing.T) {
+	env := newIntegrationEnv(t)
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/v1/audit-events"},
+		{http.MethodGet, "/v1/search-index-operations"},
+		{http.MethodPost, "/v1/search-index/reindex"},
+		{http.MethodGet, "/v1/search-index/curation"},
+		{http.MethodPost, "/v1/search-index/curation"},
+		{http.MethodGet, "/v1/jobs"},
+		{http.MethodPost, "/v1/jobs/repository-active/run"},
+	} {
+		resp := env.doJSONRequest(t, route.method, route.path, nil)
+		require.Equal(t, http.StatusForbidden, resp.StatusCode, route.path)
+		body := decodeBody[problem.ProblemJSON](t, resp)
+		require.Equal(t, "Admin access required", body.Title, route.path)
+	}
+}
+
+func newAuthIntegrationEnv(t *testing.T, cfg auth.Config) (*integrationEnv, func(clientID, scope string) map[string]string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	t.Cleanup(jwks.Close)
+
+	cfg.JWKSURL = jwks.URL
+	env := newIntegrationEnv(t, oss_client.WithAuthenticator(auth.NewAuthenticator(cfg)))
+	bearer := func(clientID, scope string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + signRS256(t, key, map[string]any{
+			"client_id": clientID,
+			"scope":     scope,
+			"exp":       time.Now().Add(time.Hour).Unix(),
+		})}
+	}
+	return env, bearer
+}
+
+func TestAuthenticationEnforcesRouteSecurity(t *testing.T) {
+	org := &models.Organisation{Uri: "https://example.org/organisations/auth", Label: "Auth Org"}
+	env, bearer := newAuthIntegrationEnv(t, auth.Config{
+		APIKeys:             auth.NewStaticKeyStore(map[string]string{"portal": "read-key"}),
+		ClientOrganisations: map[string][]string{"gemeente-a": {org.Uri}},
+	})
+	require.NoError(t, env.repo.SaveOrganisatie(org))
+
+	token := func(scope string) map[string]string {
+		return bearer("gemeente-a", scope)
+	}
+	input := map[string]any{
+		"url":             "https://example.org/repos/auth-repo",
+		"name":            "Auth Repo",
+		"organisationUri": org.Uri,
+	}
+
+	resp := env.doJSONRequest(t, http.MethodPost, "/v1/repositories", input)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+	require.NoError(t, resp.Body.Close())
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodPost, "/v1/repositories", input, map[string]string{"X-Api-Key": "read-key"})
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	body := decodeBody[problem.ProblemJSON](t, resp)
+	require.Equal(t, "Insufficient scope", body.Title)
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodPost, "/v1/repositories", input, token("repositories:read"))
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodPost, "/v1/repositories", input, token("repositories:write"))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	created := decodeBody[models.Repository](t, resp)
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodGet, "/v1/repositories/"+created.Id+"/history", nil, map[string]string{"X-Api-Key": "read-key"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	history := decodeBody[[]models.RepositoryRevision](t, resp)
+	require.NotEmpty(t, history)
+	require.Equal(t, "gemeente-a", history[0].Actor)
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodGet, "/v1/repositories", nil, token("repositories:read"))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	resp = env.doRequest(t, http.MethodGet, "/v1/repositories")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	// Webhooks carry their own signature and need no credentials.
+	resp = env.doRawRequest(t, http.MethodPost, "/v1/webhooks/bitbucket", "application/json", "{}")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+}
+
+func TestOrganisationScopedWritePermissions(t *testing.T) {
+	own := &models.Organisation{Uri: "https://example.org/organisations/gemeente-a", Label: "Gemeente A"}
+	other := &models.Organisation{Uri: "https://example.org/organisations/gemeente-b", Label: "Gemeente B"}
+	env, bearer := newAuthIntegrationEnv(t, auth.Config{
+		AdminClients:        []string{"beheer"},
+		ClientOrganisations: map[string][]string{"gemeente-a": {own.Uri}},
+	})
+	require.NoError(t, env.repo.SaveOrganisatie(own))
+	require.NoError(t, env.repo.SaveOrganisatie(other))
+
+	const scopes = "repositories:write gitOrganisations:write"
+	gemeente := bearer("gemeente-a", scopes)
+	admin := bearer("beheer", scopes)
+	unmapped := bearer("onbekend", scopes)
+	repository := func(name string, org *models.Organisation) map[string]any {
+		return map[string]any{
+			"url":             "https://example.org/repos/" + name,
+			"name":            name,
+			"organisationUri": org.Uri,
+		}
+	}
+
+	resp := env.doJSONRequestWithHeaders(t, http.MethodPost, "/v1/repositories", repository("own", own), gemeente)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	created := decodeBody[models.Repository](t, resp)
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodPost, "/v1/repositories", repository("other", other), gemeente)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	body := decodeBody[problem.ProblemJSON](t, resp)
+	require.Equal(t, "Organisation not permitted", body.Title)
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodPost, "/v1/repositories", repository("unmapped", own), unmapped)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	// Moving a repository to another organisation is not allowed either.
+	resp = env.doJSONRequestWithHeaders(t, http.MethodPut, "/v1/repositories/"+created.Id, repository("own", other), gemeente)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodPost, "/v1/repositories", repository("by-admin", other), admin)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	byAdmin := decodeBody[models.Repository](t, resp)
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodPut, "/v1/repositories/"+byAdmin.Id, repository("by-admin", other), gemeente)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	gitOrg := func(org *models.Organisation) map[string]string {
+		return map[string]string{"url": "https://example.org/git/" + org.Label, "organisationUri": org.Uri}
+	}
+	resp = env.doJSONRequestWithHeaders(t, http.MethodPost, "/v1/git-organisations", gitOrg(own), gemeente)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodPost, "/v1/git-organisations", gitOrg(other), gemeente)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+}
+
+func TestAuditLogRecordsWriteRequests(t *testing.T) {
+	org := &models.Organisation{Uri: "https://example.org/organisations/audit", Label: "Audit Org"}
+	env, bearer := newAuthIntegrationEnv(t, auth.Config{
+		AdminClients:        []string{"beheer"},
+		ClientOrganisations: map[string][]string{"gemeente-a": {org.Uri}},
+	})
+	require.NoError(t, env.repo.SaveOrganisatie(org))
+
+	gemeente := bearer("gemeente-a", "repositories:write")
+	admin := bearer("beheer", auth.ScopeAdmin)
+	input := map[string]any{
+		"url":             "https://example.org/repos/audited",
+		"name":            "Audited",
+		"organisationUri": org.Uri,
+	}
+	var encoded bytes.Buffer
+	require.NoError(t, json.NewEncoder(&encoded).Encode(input))
+	sum := sha256.Sum256(encoded.Bytes())
+
+	resp := env.doJSONRequest(t, http.MethodPost, "/v1/repositories", input)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodPost, "/v1/repositories", input, gemeente)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	created := decodeBody[models.Repository](t, resp)
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodDelete, "/v1/repositories/"+created.Id, nil, gemeente)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodGet, "/v1/audit-events", nil, gemeente)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	body := decodeBody[problem.ProblemJSON](t, resp)
+	require.Equal(t, "Insufficient scope", body.Title)
+
+	// The admin scope alone is not enough: the client must be an admin client.
+	resp = env.doJSONRequestWithHeaders(t, http.MethodGet, "/v1/audit-events", nil, bearer("gemeente-a", auth.ScopeAdmin))
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	body = decodeBody[problem.ProblemJSON](t, resp)
+	require.Equal(t, "Admin access required", body.Title)
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodGet, "/v1/audit-events", nil, bearer("beheer", ""))
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodGet, "/v1/audit-events?actor=gemeente-a", nil, admin)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "2", resp.Header.Get("Total-Count"))
+	events := decodeBody[[]models.AuditEvent](t, resp)
+	require.Len(t, events, 2)
+	require.Equal(t, "deleteRepository", events[0].OperationId)
+	require.Equal(t, created.Id, events[0].ResourceId)
+	require.Empty(t, events[0].BodyHash)
+	require.Equal(t, "createRepository", events[1].OperationId)
+	require.Equal(t, "repositories", events[1].ResourceType)
+	require.Equal(t, created.Id, events[1].ResourceId)
+	require.Equal(t, hex.EncodeToString(sum[:]), events[1].BodyHash)
+	require.Equal(t, http.StatusCreated, events[1].Status)
+	require.Equal(t, models.AuditOutcomeSuccess, events[1].Outcome)
+
+	// Rejected anonymous requests are counted, not stored.
+	resp = env.doJSONRequestWithHeaders(t, http.MethodGet, "/v1/audit-events?actor=anonymous", nil, admin)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, decodeBody[[]models.AuditEvent](t, resp))
+
+	from := url.QueryEscape(time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	resp = env.doJSONRequestWithHeaders(t, http.MethodGet, "/v1/audit-events?from="+from, nil, admin)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, decodeBody[[]models.AuditEvent](t, resp))
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodGet, "/v1/audit-events?from=yesterday", nil, admin)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+}
+
+func TestAuditRejectsOversizedBodyBeforeAuthentication(t *testing.T) {
+	env, bearer := newAuthIntegrationEnv(t, auth.Config{AdminClients: []string{"beheer"}})
+
+	body := `{"name":"` + strings.Repeat("a", audit.DefaultMaxBodySize) + `"}`
+	req, err := http.NewRequest(http.MethodPost, env.server.URL+"/v1/repositories/_bulk", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := env.client.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	require.Equal(t, "Request body too large", decodeBody[problem.ProblemJSON](t, resp).Title)
+
+	resp = env.doJSONRequestWithHeaders(t, http.MethodGet, "/v1/audit-events", nil, bearer("beheer", auth.ScopeAdmin))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, decodeBody[[]models.AuditEvent](t, resp))
+}
+
+func TestRateLimitingPerOperation(t *testing.T) {
+	env := newIntegrationEnv(t, oss_client.WithRateLimiter(ratelimit.NewLimiter(ratelimit.Config{
+		Operations: map[string]ratelimit.Limit{"listRepositories": {Requests: 2, Period: time.Minute}},
+	})))
+
+	for remaining := 1; remaining >= 0; remaining-- {
+		resp := env.doRequest(t, http.MethodGet, "/v1/repositories")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "2", resp.Header.Get(ratelimit.HeaderLimit))
+		require.Equal(t, strconv.Itoa(remaining), resp.Header.Get(ratelimit.HeaderRemaining))
+		require.NoError(t, resp.Body.Close())
+	}
+
+	resp := env.doRequest(t, http.MethodGet, "/v1/repositories")
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+	require.NotEmpty(t, resp.Header.Get("Retry-After"))
+	require.NoError(t, resp.Body.Close())
+
+	// X-Forwarded-For is ignored without trusted proxies, so it does not
+	// give the client a new bucket.
+	req, err := http.NewRequest(http.MethodGet, env.server.URL+"/v1/repositories", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	resp, err = env.client.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	// Operations without a limit are not limited.
+	resp = env.doRequest(t, http.MethodGet, "/v1/organisations")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, resp.Header.Get(ratelimit.HeaderLimit))
+	require.NoError(t, resp.Body.Close())
+}
+
+func TestRepositoriesCursorPaginationIsStableUnderInserts(t *testing.T) {
+	env := newIntegrationEnv(t)
+	ctx := context.Background()
+	save := func(id string) {
+		require.NoError(t, env.repo.SaveRepository(ctx, &models.Repository{
+			Id:            id,
+			Name:          "Repo " + id,
+			Url:           "https://example.org/" + id,
+			PublicCodeUrl: "https://example.org/" + id + "/publiccode.yml",
+			Active:        true,
+		}))
+	}
+	for _, id := range []string{"repo-b", "repo-d", "repo-f", "repo-h", "repo-j"} {
+		save(id)
+	}
+
+	var ids []string
+	path := "/v1/repositories?perPage=2&cursor=*"
+	for page := 0; path != ""; page++ {
+		require.Less(t, page, 5)
+		resp := env.doRequest(t, http.MethodGet, path)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		for _, repo := range decodeBody[[]models.RepositorySummary](t, resp) {
+			ids = append(ids, repo.Id)
+		}
+		path = ""
+		if next := resp.Header.Get("Next-Cursor"); next != "" {
+			require.Contains(t, resp.Header.Get("Link"), `rel="next"`)
+			path = "/v1/repositories?perPage=2&cursor=" + next
+		}
+		if page == 0 {
+			// Inserted before the cursor: offsets would shift and repeat repo-d.
+			save("repo-a")
+			save("repo-c")
+		}
+	}
+	require.Equal(t, []string{"repo-b", "repo-d", "repo-f", "repo-h", "repo-j"}, ids)
+
+	resp := env.doRequest(t, http.MethodGet, "/v1/repositories?cursor=bogus")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	resp = env.doRequest(t, http.MethodGet, "/v1/repositories?cursor=*&sort=name")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+}
+
 func TestAPIVersionMiddlewareSetsHeader(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleNext(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.Local)
+	}
+	cases := []struct {
+		spec string
+		now  time.Time
+		want time.Time
+	}{
+		// 17 October 2026 is a Saturday.
+		{"0 13 * * *", at(17, 12, 30), at(17, 13, 0)},
+		{"0 13 * * *", at(17, 13, 0), at(18, 13, 0)},
+		{"*/15 * * * *", at(17, 12, 31), at(17, 12, 45)},
+		{"0-30/10 9 * * *", at(17, 9, 25), at(17, 9, 30)},
+		{"0 9 * * 1-5", at(17, 12, 0), at(19, 9, 0)},
+		{"0 9 * * 7", at(17, 12, 0), at(18, 9, 0)},
+		{"0 0 1,15 * *", at(2, 0, 0), at(15, 0, 0)},
+		{"0 0 20 * 1", at(17, 12, 0), at(19, 0, 0)},
+		{"@daily", at(17, 12, 0), at(18, 0, 0)},
+		{"30 6 1 1 *", at(17, 12, 0), time.Date(2027, 1, 1, 6, 30, 0, 0, time.Local)},
+	}
+	for _, tc := range cases {
+		schedule, err := ParseSchedule(tc.spec)
+		require.NoError(t, err, tc.spec)
+		assert.Equal(t, tc.want, schedule.Next(tc.now), tc.spec)
+	}
+}
+
+func TestParseScheduleRejectsInvalidExpressions(t *testing.T) {
+	for _, spec := range []string{"", "0 13 * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := ParseSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -3,33 +3,58 @@ package jobs
 import (
 	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	httpclient "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/httpclient"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/repositories"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 type activeJobRepoStub struct {
-	allErr  error
-	saveErr error
-	all     []models.Repository
-	saved   []models.Repository
+	allErr   error
+	saveErr  error
+	saveFunc func(r *models.Repository) error
+	all      []models.Repository
+	saved    []models.Repository
 }
 
 func (s *activeJobRepoStub) AllRepositorys(_ context.Context) ([]models.Repository, error) {
-	return s.all, s.allErr
+	return append([]models.Repository(nil), s.all...), s.allErr
 }
 
 func (s *activeJobRepoStub) SaveRepository(_ context.Context, r *models.Repository) error {
 	if s.saveErr != nil {
 		return s.saveErr
 	}
+	if s.saveFunc != nil {
+		if err := s.saveFunc(r); err != nil {
+			return err
+		}
+	}
 	s.saved = append(s.saved, *r)
+	for i := range s.all {
+		if s.all[i].Id == r.Id {
+			s.all[i] = *r
+		}
+	}
+	return nil
+}
+
+func (s *activeJobRepoStub) DeleteRepository(_ context.Context, _ string) error {
 	return nil
 }
 
+func (s *activeJobRepoStub) GetRepositoryRevisions(_ context.Context, _ string, _, _ int) ([]models.RepositoryRevision, models.Pagination, error) {
+	return nil, models.Pagination{}, nil
+}
+
 func (s *activeJobRepoStub) GetRepositorys(_ context.Context, _, _ int, _ *models.RepositoryFiltersParams) ([]models.Repository, models.Pagination, error) {
 	return nil, models.Pagination{}, nil
 }
@@ -37,7 +62,40 @@ func (s *activeJobRepoStub) SearchRepositorys(_ context.Context, _, _ int, _ *st
 	return nil, models.Pagination{}, nil
 }
 
-func (s *activeJobRepoStub) GetRepositoryByID(_ context.Context, _ string) (*models.Repository, error) {
+func (s *activeJobRepoStub) ClaimDueSearchIndexOperations(_ context.Context, _ time.Time, _ int, _ time.Duration) ([]models.SearchIndexOperation, error) {
+	return nil, nil
+}
+
+func (s *activeJobRepoStub) SaveSearchIndexOperation(_ context.Context, _ *models.SearchIndexOperation) error {
+	return nil
+}
+
+func (s *activeJobRepoStub) CompleteSearchIndexOperation(_ context.Context, _ *models.SearchIndexOperation) error {
+	return nil
+}
+
+func (s *activeJobRepoStub) GetSearchIndexOperations(_ context.Context, _, _ int, _ string) ([]models.SearchIndexOperation, models.Pagination, error) {
+	return nil, models.Pagination{}, nil
+}
+
+func (s *activeJobRepoStub) GetRepositoriesByIDs(_ context.Context, _ []string) ([]models.Repository, error) {
+	return nil, nil
+}
+
+func (s *activeJobRepoStub) GetRepositoryByID(_ context.Context, id string) (*models.Repository, error) {
+	for _, r := range s.all {
+		if r.Id == id {
+			return &r, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *activeJobRepoStub) FindRepositoryByURL(_ context.Context, _ string) (*models.Repository, error) {
+	return nil, nil
+}
+
+func (s *activeJobRepoStub) FindRepositoryByNormalizedURL(_ context.Context, _ string) (*models.Repository, error) {
 	return nil, nil
 }
 
@@ -45,7 +103,7 @@ func (s *activeJobRepoStub) SaveOrganisatie(_ *models.Organisation) error {
 	return nil
 }
 
-func (s *activeJobRepoStub) GetOrganisations(_ context.Context, _, _ int) ([]models.Organisation, models.Pagination, error) {
+func (s *activeJobRepoStub) GetOrganisations(_ context.Context, _, _ int, _ *models.OrganisationFilters) ([]models.Organisation, models.Pagination, error) {
 	return nil, models.Pagination{}, nil
 }
 
@@ -53,7 +111,15 @@ func (s *activeJobRepoStub) FindOrganisationByURI(_ context.Context, _ string) (
 	return nil, nil
 }
 
-func (s *activeJobRepoStub) GetGitOrganisations(_ context.Context, _, _ int, _ *string) ([]models.GitOrganisatie, models.Pagination, error) {
+func (s *activeJobRepoStub) CountOrganisationReferences(_ context.Context, _ string) (*models.OrganisationReferences, error) {
+	return &models.OrganisationReferences{}, nil
+}
+
+func (s *activeJobRepoStub) DeleteOrganisation(_ context.Context, _ string) error {
+	return nil
+}
+
+func (s *activeJobRepoStub) GetGitOrganisations(_ context.Context, _, _ int, _, _ *string) ([]models.GitOrganisatie, models.Pagination, error) {
 	return nil, models.Pagination{}, nil
 }
 
@@ -61,6 +127,18 @@ func (s *activeJobRepoStub) FindGitOrganisationByURL(_ context.Context, _ string
 	return nil, nil
 }
 
+func (s *activeJobRepoStub) GetGitOrganisationByID(_ context.Context, _ string) (*models.GitOrganisatie, error) {
+	return nil, nil
+}
+
+func (s *activeJobRepoStub) DeleteGitOrganisation(_ context.Context, _ string) error {
+	return nil
+}
+
+func (s *activeJobRepoStub) GetGitOrganisationRepositories(_ context.Context, _ string, _, _ int) ([]models.Repository, models.Pagination, error) {
+	return nil, models.Pagination{}, nil
+}
+
 func (s *activeJobRepoStub) SaveGitOrganisatie(_ context.Context, _ *models.GitOrganisatie) error {
 	return nil
 }
@@ -69,14 +147,40 @@ func (s *activeJobRepoStub) GetRepositoryFilterCounts(_ context.Context, _ *mode
 	return &models.RepositoryFilterCounts{}, nil
 }
 
-func TestNextRunAtSameDayBeforeHour(t *testing.T) {
-	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.Local)
-	assert.Equal(t, time.Date(2024, 5, 1, 13, 0, 0, 0, time.Local), nextRunAt(now, 13))
+func (s *activeJobRepoStub) SaveAuditEvent(_ context.Context, _ *models.AuditEvent) error {
+	return nil
+}
+
+func (s *activeJobRepoStub) GetAuditEvents(_ context.Context, _, _ int, _ models.AuditEventFilter) ([]models.AuditEvent, models.Pagination, error) {
+	return nil, models.Pagination{}, nil
+}
+
+func (s *activeJobRepoStub) SaveJobRun(_ context.Context, _ *models.JobRun) error {
+	return nil
+}
+
+func (s *activeJobRepoStub) FailRunningJobRuns(_ context.Context, _ string, _ time.Time, _ string) (int64, error) {
+	return 0, nil
+}
+
+func (s *activeJobRepoStub) GetGitOrganisationsByHost(_ context.Context, _ string) ([]models.GitOrganisatie, error) {
+	return nil, nil
+}
+
+func (s *activeJobRepoStub) EnqueueOrganisationSearchIndexOperations(_ context.Context, _ string) (int, error) {
+	return 0, nil
+}
+
+func (s *activeJobRepoStub) TryLock(_ context.Context, _ string) (func(), bool, error) {
+	return func() {}, true, nil
+}
+
+func (s *activeJobRepoStub) GetLatestJobRun(_ context.Context, _ string) (*models.JobRun, error) {
+	return nil, nil
 }
 
-func TestNextRunAtNextDayAfterHour(t *testing.T) {
-	now := time.Date(2024, 5, 1, 13, 30, 0, 0, time.Local)
-	assert.Equal(t, time.Date(2024, 5, 2, 13, 0, 0, 0, time.Local), nextRunAt(now, 13))
+func (s *activeJobRepoStub) Transaction(_ context.Context, fn func(repo repositories.RepositoriesRepository) error) error {
+	return fn(s)
 }
 
 func TestRefreshRepositoryActiveFlagsUpdatesOnlyChangedRepositories(t *testing.T) {
@@ -91,8 +195,9 @@ func TestRefreshRepositoryActiveFlagsUpdatesOnlyChangedRepositories(t *testing.T
 	}
 	job := &RepositoryActiveJob{repo: repo, staleAfter: time.Hour}
 
-	err := job.refreshRepositoryActiveFlags(context.Background(), cutoff)
+	counters, err := job.refreshRepositoryActiveFlags(context.Background(), cutoff)
 	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"repositories": 4, "updated": 2, "skipped": 0, "removedDocuments": 0}, counters)
 	require.Len(t, repo.saved, 2)
 	assert.Equal(t, "recent-inactive", repo.saved[0].Id)
 	assert.True(t, repo.saved[0].Active)
@@ -100,12 +205,94 @@ func TestRefreshRepositoryActiveFlagsUpdatesOnlyChangedRepositories(t *testing.T
 	assert.False(t, repo.saved[1].Active)
 }
 
+func TestRefreshRepositoryActiveFlagsReconcilesTypesense(t *testing.T) {
+	cutoff := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := &activeJobRepoStub{
+		all: []models.Repository{
+			{Id: "recent-inactive", LastCrawledAt: cutoff.Add(time.Minute), Active: false},
+			{Id: "old-active", LastCrawledAt: cutoff.Add(-time.Minute), Active: true},
+			{Id: "recent-active", LastCrawledAt: cutoff, Active: true},
+		},
+	}
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, "/documents/export") {
+			// created is saved and indexed while the job runs.
+			repo.all = append(repo.all, models.Repository{Id: "created", LastCrawledAt: time.Now(), Active: true})
+			_, _ = w.Write([]byte(`{"id":"recent-active","tags":["oss-register","repository","repository-id:recent-active"]}` + "\n" +
+				`{"id":"old-active","tags":["oss-register","repository","repository-id:old-active"]}` + "\n" +
+				`{"id":"created","tags":["oss-register","repository","repository-id:created"]}` + "\n" +
+				`{"id":"orphan","tags":["oss-register","repository","repository-id:orphan"]}` + "\n"))
+			return
+		}
+		_, _ = w.Write([]byte(`{"success":true}`))
+	}))
+	defer server.Close()
+	t.Setenv("TYPESENSE_ENDPOINT", server.URL)
+	t.Setenv("TYPESENSE_API_KEY", "secret")
+	t.Setenv("TYPESENSE_COLLECTION", "oss-register")
+	t.Setenv("ENABLE_TYPESENSE", "true")
+	prevClient := httpclient.HTTPClient
+	httpclient.HTTPClient = server.Client()
+	t.Cleanup(func() { httpclient.HTTPClient = prevClient })
+
+	job := &RepositoryActiveJob{repo: repo, staleAfter: time.Hour}
+
+	counters, err := job.refreshRepositoryActiveFlags(context.Background(), cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, 2, counters["removedDocuments"])
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"GET /collections/oss-register/documents/export",
+		"DELETE /collections/oss-register/documents/old-active",
+		"DELETE /collections/oss-register/documents/orphan",
+	}, requests)
+}
+
+func TestRefreshRepositoryActiveFlagsRetriesOrSkipsVersionConflicts(t *testing.T) {
+	cutoff := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := &activeJobRepoStub{
+		all: []models.Repository{
+			{Id: "retried", LastCrawledAt: cutoff.Add(time.Minute), Version: 1},
+			{Id: "contended", LastCrawledAt: cutoff.Add(time.Minute), Version: 1},
+			{Id: "later", LastCrawledAt: cutoff.Add(time.Minute), Version: 1},
+		},
+	}
+	repo.saveFunc = func(r *models.Repository) error {
+		switch {
+		case r.Id == "contended":
+			return repositories.ErrVersionConflict
+		case r.Id == "retried" && r.Version == 1:
+			// Another writer saved the repository after the job loaded it.
+			repo.all[0].Version = 2
+			return repositories.ErrVersionConflict
+		}
+		return nil
+	}
+	job := &RepositoryActiveJob{repo: repo, staleAfter: time.Hour}
+
+	counters, err := job.refreshRepositoryActiveFlags(context.Background(), cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"repositories": 3, "updated": 2, "skipped": 1, "removedDocuments": 0}, counters)
+	require.Len(t, repo.saved, 2)
+	assert.Equal(t, "retried", repo.saved[0].Id)
+	assert.Equal(t, 2, repo.saved[0].Version)
+	assert.True(t, repo.saved[0].Active)
+	assert.Equal(t, "later", repo.saved[1].Id)
+}
+
 func TestRefreshRepositoryActiveFlagsPropagatesErrors(t *testing.T) {
 	expected := errors.New("database unavailable")
 	repo := &activeJobRepoStub{allErr: expected}
 	job := &RepositoryActiveJob{repo: repo}
 
-	err := job.refreshRepositoryActiveFlags(context.Background(), time.Now())
+	_, err := job.refreshRepositoryActiveFlags(context.Background(), time.Now())
 	assert.ErrorIs(t, err, expected)
 
 	repo = &activeJobRepoStub{
@@ -114,11 +301,11 @@ func TestRefreshRepositoryActiveFlagsPropagatesErrors(t *testing.T) {
 	}
 	job = &RepositoryActiveJob{repo: repo}
 
-	err = job.refreshRepositoryActiveFlags(context.Background(), time.Now().Add(-time.Hour))
+	_, err = job.refreshRepositoryActiveFlags(context.Background(), time.Now().Add(-time.Hour))
 	assert.ErrorIs(t, err, expected)
 }
 
-func TestRunOnceRefreshesRepositoryActiveFlags(t *testing.T) {
+func TestRunRefreshesRepositoryActiveFlags(t *testing.T) {
 	repo := &activeJobRepoStub{
 		all: []models.Repository{
 			{Id: "stale", LastCrawledAt: time.Now().UTC().Add(-2 * time.Hour), Active: true},
@@ -129,20 +316,22 @@ func TestRunOnceRefreshesRepositoryActiveFlags(t *testing.T) {
 		staleAfter: time.Hour,
 	}
 
-	job.runOnce(context.Background())
+	counters, err := job.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, counters["updated"])
 
 	require.Len(t, repo.saved, 1)
 	assert.Equal(t, "stale", repo.saved[0].Id)
 	assert.False(t, repo.saved[0].Active)
 }
 
-func TestRunOnceLogsRefreshErrors(t *testing.T) {
+func TestRunReturnsRefreshErrors(t *testing.T) {
+	expected := errors.New("database unavailable")
 	job := &RepositoryActiveJob{
-		repo:       &activeJobRepoStub{allErr: errors.New("database unavailable")},
+		repo:       &activeJobRepoStub{allErr: expected},
 		staleAfter: time.Hour,
 	}
 
-	assert.NotPanics(t, func() {
-		job.runOnce(context.Background())
-	})
+	_, err := job.Run(context.Background())
+	assert.ErrorIs(t, err, expected)
 }
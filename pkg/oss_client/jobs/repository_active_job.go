@@ -2,24 +2,31 @@ package jobs
 
 import (
 	"context"
+	"errors"
 	"log"
 	"os"
 	"strconv"
 	"time"
 
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/repositories"
+	typesense "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/services/typesense"
 )
 
 const (
 	DefaultRepositoryActiveStaleAfterHours = 48
 	DefaultRepositoryActiveStaleAfter      = DefaultRepositoryActiveStaleAfterHours * time.Hour
 	EnvCrawlStaleAfterHours                = "CRAWL_STALE_AFTER_HOURS"
+
+	RepositoryActiveJobName         = "repository-active"
+	DefaultRepositoryActiveSchedule = "0 13 * * *"
 )
 
+// RepositoryActiveJob marks repositories that were not crawled within
+// staleAfter as inactive, and crawled ones as active again.
 type RepositoryActiveJob struct {
 	repo       repositories.RepositoriesRepository
 	staleAfter time.Duration
-	runAtHour  int
 }
 
 func staleAfterFromEnv() time.Duration {
@@ -37,7 +44,6 @@ func NewRepositoryActiveJob(repo repositories.RepositoriesRepository) *Repositor
 	return &RepositoryActiveJob{
 		repo:       repo,
 		staleAfter: staleAfterFromEnv(),
-		runAtHour:  13,
 	}
 }
 
@@ -45,56 +51,80 @@ func (j *RepositoryActiveJob) StaleAfter() time.Duration {
 	return j.staleAfter
 }
 
-func (j *RepositoryActiveJob) Start(ctx context.Context) {
-	go func() {
-		for {
-			wait := time.Until(nextRunAt(time.Now(), j.runAtHour))
-			timer := time.NewTimer(wait)
-			select {
-			case <-timer.C:
-				j.runOnce(ctx)
-			case <-ctx.Done():
-				timer.Stop()
-				return
-			}
-		}
-	}()
-}
-
-func (j *RepositoryActiveJob) runOnce(ctx context.Context) {
+// Run refreshes the active flags once; register it with a Scheduler.
+func (j *RepositoryActiveJob) Run(ctx context.Context) (map[string]int, error) {
 	cutoff := time.Now().UTC().Add(-j.staleAfter)
-	if err := j.refreshRepositoryActiveFlags(ctx, cutoff); err != nil {
-		log.Printf("repository active job failed: %v", err)
-	}
+	return j.refreshRepositoryActiveFlags(ctx, cutoff)
 }
 
-func nextRunAt(now time.Time, hour int) time.Time {
-	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
-	if now.After(next) {
-		next = next.AddDate(0, 0, 1)
-	}
-	return next
-}
-
-func (j *RepositoryActiveJob) refreshRepositoryActiveFlags(ctx context.Context, cutoff time.Time) error {
+// refreshRepositoryActiveFlags updates the active flag of every repository.
+// A repository that keeps being changed by other writers is skipped and left
+// for the next run.
+func (j *RepositoryActiveJob) refreshRepositoryActiveFlags(ctx context.Context, cutoff time.Time) (map[string]int, error) {
 	repos, err := j.repo.AllRepositorys(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	updated := 0
+	updated, skipped := 0, 0
 	for i := range repos {
-		active := !repos[i].LastCrawledAt.Before(cutoff)
-		if repos[i].Active == active {
-			continue
-		}
-		repos[i].Active = active
-		if err := j.repo.SaveRepository(ctx, &repos[i]); err != nil {
-			return err
+		saved, err := j.refreshRepository(ctx, &repos[i], cutoff)
+		switch {
+		case errors.Is(err, repositories.ErrVersionConflict):
+			log.Printf("repository active job skipped %s: %v", repos[i].Id, err)
+			skipped++
+		case err != nil:
+			return map[string]int{"repositories": len(repos), "updated": updated, "skipped": skipped}, err
+		case saved:
+			updated++
 		}
-		updated++
 	}
 
 	log.Printf("repository active job updated %d repositories", updated)
-	return nil
+	removed := j.reconcileTypesense(ctx)
+	return map[string]int{"repositories": len(repos), "updated": updated, "skipped": skipped, "removedDocuments": removed}, nil
+}
+
+// refreshRepository saves the active flag of repository when it changed and
+// reports whether it did. When the repository was saved by another writer
+// since it was loaded, it is reloaded and checked once more.
+func (j *RepositoryActiveJob) refreshRepository(ctx context.Context, repository *models.Repository, cutoff time.Time) (bool, error) {
+	for attempt := 0; ; attempt++ {
+		active := !repository.LastCrawledAt.Before(cutoff)
+		if repository.Active == active {
+			return false, nil
+		}
+		repository.Active = active
+		err := j.repo.SaveRepository(ctx, repository)
+		if !errors.Is(err, repositories.ErrVersionConflict) || attempt > 0 {
+			return err == nil, err
+		}
+		current, err := j.repo.GetRepositoryByID(ctx, repository.Id)
+		if err != nil {
+			return false, err
+		}
+		if current == nil || current.DeletedAt != nil {
+			return false, nil
+		}
+		*repository = *current
+	}
+}
+
+// reconcileTypesense removes stale documents and returns how many. The
+// repositories whose active flag changed are synced through the outbox
+// written by SaveRepository. The repositories are loaded by Reconcile after
+// it exported the documents, so that repositories created during the run are
+// not mistaken for stale ones.
+func (j *RepositoryActiveJob) reconcileTypesense(ctx context.Context) int {
+	if !typesense.Enabled() {
+		return 0
+	}
+	removed, err := typesense.Reconcile(ctx, j.repo.AllRepositorys)
+	if err != nil {
+		log.Printf("[typesense] reconcile failed: %v", err)
+	}
+	if removed > 0 {
+		log.Printf("[typesense] reconcile removed %d stale documents", removed)
+	}
+	return removed
 }
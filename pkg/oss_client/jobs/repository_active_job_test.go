@@ -7,6 +7,7 @@ import (
 
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/jobs"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/repositories"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -25,6 +26,14 @@ func (s *stubRepositoriesRepo) SaveRepository(_ context.Context, r *models.Repos
 	return nil
 }
 
+func (s *stubRepositoriesRepo) DeleteRepository(_ context.Context, _ string) error {
+	return nil
+}
+
+func (s *stubRepositoriesRepo) GetRepositoryRevisions(_ context.Context, _ string, _, _ int) ([]models.RepositoryRevision, models.Pagination, error) {
+	return nil, models.Pagination{}, nil
+}
+
 func (s *stubRepositoriesRepo) GetRepositorys(_ context.Context, _, _ int, _ *models.RepositoryFiltersParams) ([]models.Repository, models.Pagination, error) {
 	return nil, models.Pagination{}, nil
 }
@@ -32,19 +41,47 @@ func (s *stubRepositoriesRepo) SearchRepositorys(_ context.Context, _, _ int, _
 	return nil, models.Pagination{}, nil
 }
 
+func (s *stubRepositoriesRepo) ClaimDueSearchIndexOperations(_ context.Context, _ time.Time, _ int, _ time.Duration) ([]models.SearchIndexOperation, error) {
+	return nil, nil
+}
+
+func (s *stubRepositoriesRepo) SaveSearchIndexOperation(_ context.Context, _ *models.SearchIndexOperation) error {
+	return nil
+}
+
+func (s *stubRepositoriesRepo) CompleteSearchIndexOperation(_ context.Context, _ *models.SearchIndexOperation) error {
+	return nil
+}
+
+func (s *stubRepositoriesRepo) GetSearchIndexOperations(_ context.Context, _, _ int, _ string) ([]models.SearchIndexOperation, models.Pagination, error) {
+	return nil, models.Pagination{}, nil
+}
+
+func (s *stubRepositoriesRepo) GetRepositoriesByIDs(_ context.Context, _ []string) ([]models.Repository, error) {
+	return nil, nil
+}
+
 func (s *stubRepositoriesRepo) GetRepositoryByID(_ context.Context, _ string) (*models.Repository, error) {
 	return nil, nil
 }
 
+func (s *stubRepositoriesRepo) FindRepositoryByURL(_ context.Context, _ string) (*models.Repository, error) {
+	return nil, nil
+}
+
+func (s *stubRepositoriesRepo) FindRepositoryByNormalizedURL(_ context.Context, _ string) (*models.Repository, error) {
+	return nil, nil
+}
+
 func (s *stubRepositoriesRepo) SaveOrganisatie(_ *models.Organisation) error {
 	return nil
 }
 
-func (s *stubRepositoriesRepo) GetOrganisations(_ context.Context, _, _ int) ([]models.Organisation, models.Pagination, error) {
+func (s *stubRepositoriesRepo) GetOrganisations(_ context.Context, _, _ int, _ *models.OrganisationFilters) ([]models.Organisation, models.Pagination, error) {
 	return nil, models.Pagination{}, nil
 }
 
-func (s *stubRepositoriesRepo) GetGitOrganisations(_ context.Context, _, _ int, _ *string) ([]models.GitOrganisatie, models.Pagination, error) {
+func (s *stubRepositoriesRepo) GetGitOrganisations(_ context.Context, _, _ int, _, _ *string) ([]models.GitOrganisatie, models.Pagination, error) {
 	return nil, models.Pagination{}, nil
 }
 
@@ -52,10 +89,30 @@ func (s *stubRepositoriesRepo) FindOrganisationByURI(_ context.Context, _ string
 	return nil, nil
 }
 
+func (s *stubRepositoriesRepo) CountOrganisationReferences(_ context.Context, _ string) (*models.OrganisationReferences, error) {
+	return &models.OrganisationReferences{}, nil
+}
+
+func (s *stubRepositoriesRepo) DeleteOrganisation(_ context.Context, _ string) error {
+	return nil
+}
+
 func (s *stubRepositoriesRepo) FindGitOrganisationByURL(_ context.Context, _ string) (*models.GitOrganisatie, error) {
 	return nil, nil
 }
 
+func (s *stubRepositoriesRepo) GetGitOrganisationByID(_ context.Context, _ string) (*models.GitOrganisatie, error) {
+	return nil, nil
+}
+
+func (s *stubRepositoriesRepo) DeleteGitOrganisation(_ context.Context, _ string) error {
+	return nil
+}
+
+func (s *stubRepositoriesRepo) GetGitOrganisationRepositories(_ context.Context, _ string, _, _ int) ([]models.Repository, models.Pagination, error) {
+	return nil, models.Pagination{}, nil
+}
+
 func (s *stubRepositoriesRepo) SaveGitOrganisatie(_ context.Context, _ *models.GitOrganisatie) error {
 	return nil
 }
@@ -64,6 +121,42 @@ func (s *stubRepositoriesRepo) GetRepositoryFilterCounts(_ context.Context, _ *m
 	return &models.RepositoryFilterCounts{}, nil
 }
 
+func (s *stubRepositoriesRepo) SaveAuditEvent(_ context.Context, _ *models.AuditEvent) error {
+	return nil
+}
+
+func (s *stubRepositoriesRepo) GetAuditEvents(_ context.Context, _, _ int, _ models.AuditEventFilter) ([]models.AuditEvent, models.Pagination, error) {
+	return nil, models.Pagination{}, nil
+}
+
+func (s *stubRepositoriesRepo) SaveJobRun(_ context.Context, _ *models.JobRun) error {
+	return nil
+}
+
+func (s *stubRepositoriesRepo) FailRunningJobRuns(_ context.Context, _ string, _ time.Time, _ string) (int64, error) {
+	return 0, nil
+}
+
+func (s *stubRepositoriesRepo) GetGitOrganisationsByHost(_ context.Context, _ string) ([]models.GitOrganisatie, error) {
+	return nil, nil
+}
+
+func (s *stubRepositoriesRepo) EnqueueOrganisationSearchIndexOperations(_ context.Context, _ string) (int, error) {
+	return 0, nil
+}
+
+func (s *stubRepositoriesRepo) TryLock(_ context.Context, _ string) (func(), bool, error) {
+	return func() {}, true, nil
+}
+
+func (s *stubRepositoriesRepo) GetLatestJobRun(_ context.Context, _ string) (*models.JobRun, error) {
+	return nil, nil
+}
+
+func (s *stubRepositoriesRepo) Transaction(_ context.Context, fn func(repo repositories.RepositoriesRepository) error) error {
+	return fn(s)
+}
+
 func TestNewRepositoryActiveJob_DefaultStaleAfter(t *testing.T) {
 	t.Setenv(jobs.EnvCrawlStaleAfterHours, "")
 	repo := &stubRepositoriesRepo{}
@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/crawler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type crawlerStub struct {
+	runs   int
+	result crawler.Result
+	err    error
+}
+
+func (s *crawlerStub) Run(_ context.Context) (crawler.Result, error) {
+	s.runs++
+	return s.result, s.err
+}
+
+func TestDefaultCrawlScheduleRunsBeforeActiveJob(t *testing.T) {
+	crawl, err := ParseSchedule(DefaultRepositoryCrawlSchedule)
+	require.NoError(t, err)
+	active, err := ParseSchedule(DefaultRepositoryActiveSchedule)
+	require.NoError(t, err)
+
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.Local)
+	assert.True(t, crawl.Next(now).Before(active.Next(now)))
+}
+
+func TestCrawlJobRunReturnsCounters(t *testing.T) {
+	stub := &crawlerStub{result: crawler.Result{GitOrganisations: 2, Created: 3}}
+	job := &RepositoryCrawlJob{crawler: stub}
+
+	counters, err := job.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, stub.runs)
+	assert.Equal(t, 2, counters["gitOrganisations"])
+	assert.Equal(t, 3, counters["created"])
+}
+
+func TestCrawlJobRunReturnsErrors(t *testing.T) {
+	expected := errors.New("forge unavailable")
+	job := &RepositoryCrawlJob{crawler: &crawlerStub{err: expected}}
+
+	_, err := job.Run(context.Background())
+	assert.ErrorIs(t, err, expected)
+}
+
+func TestCrawlerEnabled(t *testing.T) {
+	t.Setenv(EnvEnableCrawler, "")
+	assert.False(t, CrawlerEnabled())
+
+	t.Setenv(EnvEnableCrawler, " TRUE ")
+	assert.True(t, CrawlerEnabled())
+}
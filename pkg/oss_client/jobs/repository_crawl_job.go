@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/crawler"
+)
+
+const (
+	EnvEnableCrawler = "ENABLE_CRAWLER"
+
+	RepositoryCrawlJobName = "repository-crawl"
+	// DefaultRepositoryCrawlSchedule runs the crawler an hour before the
+	// RepositoryActiveJob so freshly crawled repositories stay active.
+	DefaultRepositoryCrawlSchedule = "0 12 * * *"
+)
+
+type repositoryCrawler interface {
+	Run(ctx context.Context) (crawler.Result, error)
+}
+
+// RepositoryCrawlJob runs the built-in crawler.
+type RepositoryCrawlJob struct {
+	crawler repositoryCrawler
+}
+
+// CrawlerEnabled reports whether ENABLE_CRAWLER is set to true.
+func CrawlerEnabled() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv(EnvEnableCrawler)), "true")
+}
+
+func NewRepositoryCrawlJob(c *crawler.Crawler) *RepositoryCrawlJob {
+	return &RepositoryCrawlJob{crawler: c}
+}
+
+// Run crawls once; register it with a Scheduler.
+func (j *RepositoryCrawlJob) Run(ctx context.Context) (map[string]int, error) {
+	result, err := j.crawler.Run(ctx)
+	log.Printf("repository crawl job crawled %d git organisations: %d created, %d updated, %d skipped, %d failed",
+		result.GitOrganisations, result.Created, result.Updated, result.Skipped, result.Failed)
+	return map[string]int{
+		"gitOrganisations": result.GitOrganisations,
+		"created":          result.Created,
+		"updated":          result.Updated,
+		"skipped":          result.Skipped,
+		"failed":           result.Failed,
+	}, err
+}
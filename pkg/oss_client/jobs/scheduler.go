@@ -0,0 +1,311 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/repositories"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/services"
+	"github.com/google/uuid"
+)
+
+// EnvSchedulePrefix prefixes the variables that override the cron expression
+// of a job: JOB_SCHEDULE_REPOSITORY_ACTIVE for repository-active. The value
+// off disables the schedule; the job can still be run manually.
+const EnvSchedulePrefix = "JOB_SCHEDULE_"
+
+// errRanElsewhere skips a scheduled run that another instance already started.
+var errRanElsewhere = errors.New("already ran on another instance")
+
+// RunFunc runs a job once and returns counters describing what it did.
+type RunFunc func(ctx context.Context) (map[string]int, error)
+
+type scheduledJob struct {
+	name     string
+	schedule *Schedule
+	run      RunFunc
+}
+
+// Scheduler runs registered jobs on their cron schedule and records every
+// run in the job_runs table. Schedules use the server's local time zone. A
+// job never runs twice at the same time, also not across instances that
+// share the database: a run holds a database lock on the job, and a run that
+// is due while the job is busy, or that another instance already started, is
+// skipped.
+type Scheduler struct {
+	repo repositories.RepositoriesRepository
+	now  func() time.Time
+
+	mu    sync.Mutex
+	ctx   context.Context
+	jobs  map[string]*scheduledJob
+	order []string
+	// running holds the release of the database lock of every job that runs
+	// in this instance.
+	running map[string]func()
+}
+
+func NewScheduler(repo repositories.RepositoriesRepository) *Scheduler {
+	return &Scheduler{
+		repo:    repo,
+		now:     time.Now,
+		ctx:     context.Background(),
+		jobs:    map[string]*scheduledJob{},
+		running: map[string]func(){},
+	}
+}
+
+// jobLock is the name of the database lock a run of the job holds.
+func jobLock(name string) string {
+	return "job:" + name
+}
+
+// ScheduleEnv returns the variable that overrides the schedule of the job.
+func ScheduleEnv(name string) string {
+	return EnvSchedulePrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Register adds a job that runs on defaultSchedule, or on the cron expression
+// in ScheduleEnv(name) when set. It returns an error for an invalid
+// expression or a name that is already registered.
+func (s *Scheduler) Register(name, defaultSchedule string, run RunFunc) error {
+	spec := defaultSchedule
+	if v := strings.TrimSpace(os.Getenv(ScheduleEnv(name))); v != "" {
+		spec = v
+	}
+
+	job := &scheduledJob{name: name, run: run}
+	if !strings.EqualFold(spec, "off") {
+		schedule, err := ParseSchedule(spec)
+		if err != nil {
+			return fmt.Errorf("job %s: %w", name, err)
+		}
+		job.schedule = schedule
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("job %s is already registered", name)
+	}
+	s.jobs[name] = job
+	s.order = append(s.order, name)
+	return nil
+}
+
+// Start runs every scheduled job on its schedule until ctx is done. Manual
+// runs started with Trigger also stop with ctx. Runs left running by a
+// previous process are marked failed first.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	jobs := make([]*scheduledJob, 0, len(s.order))
+	for _, name := range s.order {
+		jobs = append(jobs, s.jobs[name])
+	}
+	s.mu.Unlock()
+
+	for _, job := range jobs {
+		s.failInterruptedRuns(ctx, job.name)
+	}
+	for _, job := range jobs {
+		if job.schedule == nil {
+			log.Printf("job %s has no schedule; run it manually", job.name)
+			continue
+		}
+		go s.loop(ctx, job)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, job *scheduledJob) {
+	for {
+		next := job.schedule.Next(s.now())
+		if next.IsZero() {
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			run, err := s.begin(ctx, job, models.JobTriggerSchedule, next)
+			if err != nil {
+				log.Printf("job %s skipped: %v", job.name, err)
+				continue
+			}
+			s.execute(ctx, job, run)
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// Trigger starts the job now, outside its schedule, and returns the run while
+// the job continues in the background.
+func (s *Scheduler) Trigger(name string) (*models.JobRun, error) {
+	s.mu.Lock()
+	job, ok := s.jobs[name]
+	ctx := s.ctx
+	s.mu.Unlock()
+	if !ok {
+		return nil, services.ErrJobNotFound
+	}
+
+	run, err := s.begin(ctx, job, models.JobTriggerManual, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	started := *run
+	go s.execute(ctx, job, run)
+	return &started, nil
+}
+
+// Jobs returns the registered jobs with their next and last run.
+func (s *Scheduler) Jobs(ctx context.Context) ([]models.Job, error) {
+	s.mu.Lock()
+	out := make([]models.Job, 0, len(s.order))
+	for _, name := range s.order {
+		job := s.jobs[name]
+		_, running := s.running[name]
+		item := models.Job{Name: name, Running: running}
+		if job.schedule != nil {
+			item.Schedule = job.schedule.String()
+			if next := job.schedule.Next(s.now()); !next.IsZero() {
+				item.NextRunAt = &next
+			}
+		}
+		out = append(out, item)
+	}
+	s.mu.Unlock()
+
+	for i := range out {
+		lastRun, err := s.repo.GetLatestJobRun(ctx, out[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		out[i].LastRun = lastRun
+	}
+	return out, nil
+}
+
+// failInterruptedRuns marks the runs of the job that are still recorded as
+// running as failed. Only an instance that holds the job's lock can be
+// running it, so while the lock is free those runs were cut short by a
+// restart.
+func (s *Scheduler) failInterruptedRuns(ctx context.Context, name string) {
+	unlock, ok, err := s.repo.TryLock(ctx, jobLock(name))
+	if err != nil {
+		log.Printf("job %s: lock to clean up interrupted runs: %v", name, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	failed, err := s.repo.FailRunningJobRuns(ctx, name, s.now().UTC(), "interrupted: the server stopped during the run")
+	if err != nil {
+		log.Printf("job %s: mark interrupted runs failed: %v", name, err)
+		return
+	}
+	if failed > 0 {
+		log.Printf("job %s: marked %d interrupted runs failed", name, failed)
+	}
+}
+
+// begin takes the job's lock and records the start of a run. due is the time
+// a scheduled run was planned for; it is skipped when another instance
+// started the job since then.
+func (s *Scheduler) begin(ctx context.Context, job *scheduledJob, trigger string, due time.Time) (*models.JobRun, error) {
+	s.mu.Lock()
+	if _, ok := s.running[job.name]; ok {
+		s.mu.Unlock()
+		return nil, services.ErrJobRunning
+	}
+	s.running[job.name] = func() {}
+	s.mu.Unlock()
+
+	unlock, ok, err := s.repo.TryLock(ctx, jobLock(job.name))
+	if err != nil {
+		s.finish(job.name)
+		return nil, fmt.Errorf("lock job %s: %w", job.name, err)
+	}
+	if !ok {
+		s.finish(job.name)
+		return nil, services.ErrJobRunning
+	}
+	s.mu.Lock()
+	s.running[job.name] = unlock
+	s.mu.Unlock()
+
+	if !due.IsZero() {
+		last, err := s.repo.GetLatestJobRun(ctx, job.name)
+		if err != nil {
+			s.finish(job.name)
+			return nil, fmt.Errorf("read last run of job %s: %w", job.name, err)
+		}
+		if last != nil && !last.StartedAt.Before(due) {
+			s.finish(job.name)
+			return nil, errRanElsewhere
+		}
+	}
+
+	run := &models.JobRun{
+		Id:        uuid.NewString(),
+		JobName:   job.name,
+		Trigger:   trigger,
+		Status:    models.JobRunRunning,
+		StartedAt: s.now().UTC(),
+	}
+	if err := s.repo.SaveJobRun(ctx, run); err != nil {
+		s.finish(job.name)
+		return nil, fmt.Errorf("record start of job %s: %w", job.name, err)
+	}
+	return run, nil
+}
+
+// execute runs the job and records its outcome. A panic fails the run
+// instead of the server.
+func (s *Scheduler) execute(ctx context.Context, job *scheduledJob, run *models.JobRun) {
+	defer s.finish(job.name)
+
+	counters, err := func() (counters map[string]int, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return job.run(ctx)
+	}()
+
+	finished := s.now().UTC()
+	run.FinishedAt = &finished
+	run.DurationMs = finished.Sub(run.StartedAt).Milliseconds()
+	run.Counters = counters
+	run.Status = models.JobRunSucceeded
+	if err != nil {
+		run.Status = models.JobRunFailed
+		run.Error = err.Error()
+		log.Printf("job %s failed after %s: %v", job.name, finished.Sub(run.StartedAt), err)
+	}
+	if saveErr := s.repo.SaveJobRun(ctx, run); saveErr != nil {
+		log.Printf("job %s: record run %s: %v", job.name, run.Id, saveErr)
+	}
+}
+
+// finish releases the job's lock.
+func (s *Scheduler) finish(name string) {
+	s.mu.Lock()
+	unlock := s.running[name]
+	delete(s.running, name)
+	s.mu.Unlock()
+	if unlock != nil {
+		unlock()
+	}
+}
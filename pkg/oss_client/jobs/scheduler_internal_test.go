@@ -0,0 +1,206 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jobRunRepoStub struct {
+	activeJobRepoStub
+	mu    sync.Mutex
+	runs  map[string]models.JobRun
+	locks map[string]bool
+	done  chan models.JobRun
+}
+
+func newJobRunRepoStub() *jobRunRepoStub {
+	return &jobRunRepoStub{runs: map[string]models.JobRun{}, locks: map[string]bool{}, done: make(chan models.JobRun, 10)}
+}
+
+func (s *jobRunRepoStub) TryLock(_ context.Context, name string) (func(), bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locks[name] {
+		return nil, false, nil
+	}
+	s.locks[name] = true
+	return func() {
+		s.mu.Lock()
+		delete(s.locks, name)
+		s.mu.Unlock()
+	}, true, nil
+}
+
+func (s *jobRunRepoStub) FailRunningJobRuns(_ context.Context, name string, finishedAt time.Time, reason string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[name]
+	if !ok || run.Status != models.JobRunRunning {
+		return 0, nil
+	}
+	run.Status = models.JobRunFailed
+	run.FinishedAt = &finishedAt
+	run.Error = reason
+	s.runs[name] = run
+	return 1, nil
+}
+
+func (s *jobRunRepoStub) SaveJobRun(_ context.Context, run *models.JobRun) error {
+	s.mu.Lock()
+	s.runs[run.JobName] = *run
+	s.mu.Unlock()
+	if run.Status != models.JobRunRunning {
+		s.done <- *run
+	}
+	return nil
+}
+
+func (s *jobRunRepoStub) GetLatestJobRun(_ context.Context, name string) (*models.JobRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[name]
+	if !ok {
+		return nil, nil
+	}
+	return &run, nil
+}
+
+func TestSchedulerRegisterReadsScheduleFromEnv(t *testing.T) {
+	t.Setenv("JOB_SCHEDULE_REPOSITORY_ACTIVE", "*/30 * * * *")
+	t.Setenv("JOB_SCHEDULE_REPOSITORY_CRAWL", "off")
+
+	now := time.Date(2026, 10, 17, 12, 10, 0, 0, time.Local)
+	scheduler := NewScheduler(newJobRunRepoStub())
+	scheduler.now = func() time.Time { return now }
+	noop := func(context.Context) (map[string]int, error) { return nil, nil }
+	require.NoError(t, scheduler.Register(RepositoryActiveJobName, DefaultRepositoryActiveSchedule, noop))
+	require.NoError(t, scheduler.Register(RepositoryCrawlJobName, DefaultRepositoryCrawlSchedule, noop))
+	require.Error(t, scheduler.Register(RepositoryCrawlJobName, DefaultRepositoryCrawlSchedule, noop))
+
+	t.Setenv("JOB_SCHEDULE_BROKEN", "every day")
+	require.Error(t, scheduler.Register("broken", "0 0 * * *", noop))
+
+	jobs, err := scheduler.Jobs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "*/30 * * * *", jobs[0].Schedule)
+	require.NotNil(t, jobs[0].NextRunAt)
+	assert.Equal(t, time.Date(2026, 10, 17, 12, 30, 0, 0, time.Local), *jobs[0].NextRunAt)
+	assert.Empty(t, jobs[1].Schedule)
+	assert.Nil(t, jobs[1].NextRunAt)
+}
+
+func TestSchedulerTriggerRecordsRun(t *testing.T) {
+	repo := newJobRunRepoStub()
+	scheduler := NewScheduler(repo)
+	release := make(chan struct{})
+	require.NoError(t, scheduler.Register("example", "off", func(context.Context) (map[string]int, error) {
+		<-release
+		return map[string]int{"updated": 3}, nil
+	}))
+
+	run, err := scheduler.Trigger("example")
+	require.NoError(t, err)
+	assert.Equal(t, models.JobRunRunning, run.Status)
+	assert.Equal(t, models.JobTriggerManual, run.Trigger)
+
+	_, err = scheduler.Trigger("example")
+	assert.ErrorIs(t, err, services.ErrJobRunning)
+	jobs, err := scheduler.Jobs(context.Background())
+	require.NoError(t, err)
+	assert.True(t, jobs[0].Running)
+
+	close(release)
+	finished := <-repo.done
+	assert.Equal(t, run.Id, finished.Id)
+	assert.Equal(t, models.JobRunSucceeded, finished.Status)
+	assert.Equal(t, map[string]int{"updated": 3}, finished.Counters)
+	require.NotNil(t, finished.FinishedAt)
+
+	_, err = scheduler.Trigger("unknown")
+	assert.ErrorIs(t, err, services.ErrJobNotFound)
+}
+
+func TestSchedulerRecordsFailuresAndPanics(t *testing.T) {
+	repo := newJobRunRepoStub()
+	scheduler := NewScheduler(repo)
+	require.NoError(t, scheduler.Register("failing", "off", func(context.Context) (map[string]int, error) {
+		return map[string]int{"updated": 1}, errors.New("database unavailable")
+	}))
+	require.NoError(t, scheduler.Register("panicking", "off", func(context.Context) (map[string]int, error) {
+		panic("boom")
+	}))
+
+	_, err := scheduler.Trigger("failing")
+	require.NoError(t, err)
+	failed := <-repo.done
+	assert.Equal(t, models.JobRunFailed, failed.Status)
+	assert.Equal(t, "database unavailable", failed.Error)
+	assert.Equal(t, 1, failed.Counters["updated"])
+
+	_, err = scheduler.Trigger("panicking")
+	require.NoError(t, err)
+	panicked := <-repo.done
+	assert.Equal(t, models.JobRunFailed, panicked.Status)
+	assert.Equal(t, "panic: boom", panicked.Error)
+
+	jobs, err := scheduler.Jobs(context.Background())
+	require.NoError(t, err)
+	assert.False(t, jobs[0].Running)
+	assert.Equal(t, failed.Id, jobs[0].LastRun.Id)
+}
+
+func TestSchedulerFailsInterruptedRunsOnStart(t *testing.T) {
+	repo := newJobRunRepoStub()
+	repo.runs["interrupted"] = models.JobRun{Id: "run-1", JobName: "interrupted", Status: models.JobRunRunning}
+	repo.runs["elsewhere"] = models.JobRun{Id: "run-2", JobName: "elsewhere", Status: models.JobRunRunning}
+	repo.locks[jobLock("elsewhere")] = true
+	scheduler := NewScheduler(repo)
+	noop := func(context.Context) (map[string]int, error) { return nil, nil }
+	require.NoError(t, scheduler.Register("interrupted", "off", noop))
+	require.NoError(t, scheduler.Register("elsewhere", "off", noop))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	scheduler.Start(ctx)
+
+	assert.Equal(t, models.JobRunFailed, repo.runs["interrupted"].Status)
+	assert.Contains(t, repo.runs["interrupted"].Error, "interrupted")
+	assert.Equal(t, models.JobRunRunning, repo.runs["elsewhere"].Status, "a run another instance holds the lock for is left alone")
+}
+
+func TestSchedulerSkipsRunsOfOtherInstances(t *testing.T) {
+	repo := newJobRunRepoStub()
+	scheduler := NewScheduler(repo)
+	ran := 0
+	require.NoError(t, scheduler.Register("example", "0 * * * *", func(context.Context) (map[string]int, error) {
+		ran++
+		return nil, nil
+	}))
+	job := scheduler.jobs["example"]
+
+	repo.locks[jobLock("example")] = true
+	_, err := scheduler.Trigger("example")
+	assert.ErrorIs(t, err, services.ErrJobRunning)
+	delete(repo.locks, jobLock("example"))
+
+	due := time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local)
+	repo.runs["example"] = models.JobRun{Id: "run-1", JobName: "example", Status: models.JobRunSucceeded, StartedAt: due.Add(time.Second)}
+	_, err = scheduler.begin(context.Background(), job, models.JobTriggerSchedule, due)
+	assert.ErrorIs(t, err, errRanElsewhere)
+
+	run, err := scheduler.begin(context.Background(), job, models.JobTriggerSchedule, due.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, repo.locks[jobLock("example")], "a run holds the job's lock")
+	scheduler.execute(context.Background(), job, run)
+	assert.Equal(t, 1, ran)
+	assert.Empty(t, repo.locks)
+}
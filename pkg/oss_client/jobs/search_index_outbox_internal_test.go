@@ -0,0 +1,165 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/repositories"
+	typesense "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/services/typesense"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type outboxRepoStub struct {
+	activeJobRepoStub
+	due          []models.SearchIndexOperation
+	repositories map[string]*models.Repository
+	completed    []string
+	failed       []models.SearchIndexOperation
+}
+
+func (s *outboxRepoStub) ClaimDueSearchIndexOperations(_ context.Context, _ time.Time, limit int, _ time.Duration) ([]models.SearchIndexOperation, error) {
+	n := min(limit, len(s.due))
+	due := s.due[:n]
+	s.due = s.due[n:]
+	return due, nil
+}
+
+func (s *outboxRepoStub) GetRepositoryByID(_ context.Context, id string) (*models.Repository, error) {
+	return s.repositories[id], nil
+}
+
+func (s *outboxRepoStub) CompleteSearchIndexOperation(_ context.Context, operation *models.SearchIndexOperation) error {
+	s.completed = append(s.completed, operation.Id)
+	return nil
+}
+
+func (s *outboxRepoStub) SaveSearchIndexOperation(_ context.Context, operation *models.SearchIndexOperation) error {
+	s.failed = append(s.failed, *operation)
+	return nil
+}
+
+func newTestOutboxJob(repo *outboxRepoStub, sync func(context.Context, *models.Repository) error) *SearchIndexOutboxJob {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	return &SearchIndexOutboxJob{
+		repo:        repo,
+		batchSize:   2,
+		maxAttempts: 3,
+		now:         func() time.Time { return now },
+		sync:        sync,
+	}
+}
+
+func TestSearchIndexOutboxJobCompletesSyncedOperations(t *testing.T) {
+	repo := &outboxRepoStub{
+		due: []models.SearchIndexOperation{
+			{Id: "op-1", RepositoryID: "repo-1"},
+			{Id: "op-2", RepositoryID: "repo-2"},
+			{Id: "op-3", RepositoryID: "gone"},
+		},
+		repositories: map[string]*models.Repository{
+			"repo-1": {Id: "repo-1", Active: true},
+			"repo-2": {Id: "repo-2", Active: false},
+		},
+	}
+	var synced []models.Repository
+	job := newTestOutboxJob(repo, func(_ context.Context, repository *models.Repository) error {
+		synced = append(synced, *repository)
+		return nil
+	})
+
+	require.NoError(t, job.drain(context.Background()))
+	assert.Equal(t, []string{"op-1", "op-2", "op-3"}, repo.completed)
+	require.Len(t, synced, 3)
+	assert.True(t, synced[0].Active)
+	assert.Equal(t, "gone", synced[2].Id)
+	assert.False(t, synced[2].Active)
+}
+
+func TestSearchIndexOutboxJobRetriesWithBackoffAndGivesUp(t *testing.T) {
+	repo := &outboxRepoStub{
+		due: []models.SearchIndexOperation{
+			{Id: "op-1", RepositoryID: "repo-1", Status: models.SearchIndexOperationPending},
+			{Id: "op-2", RepositoryID: "repo-2", Status: models.SearchIndexOperationPending, Attempts: 2},
+		},
+		repositories: map[string]*models.Repository{
+			"repo-1": {Id: "repo-1", Active: true},
+			"repo-2": {Id: "repo-2", Active: true},
+		},
+	}
+	job := newTestOutboxJob(repo, func(context.Context, *models.Repository) error {
+		return errors.New("typesense: request failed")
+	})
+
+	require.NoError(t, job.drain(context.Background()))
+	assert.Empty(t, repo.completed)
+	require.Len(t, repo.failed, 2)
+
+	retried := repo.failed[0]
+	assert.Equal(t, models.SearchIndexOperationPending, retried.Status)
+	assert.Equal(t, 1, retried.Attempts)
+	assert.Equal(t, job.now().Add(30*time.Second), retried.NextAttemptAt)
+	assert.Equal(t, "typesense: request failed", retried.LastError)
+
+	dead := repo.failed[1]
+	assert.Equal(t, models.SearchIndexOperationFailed, dead.Status)
+	assert.Equal(t, 3, dead.Attempts)
+}
+
+func TestSearchIndexOutboxJobDropsOperationsWhenIndexingDisabled(t *testing.T) {
+	repo := &outboxRepoStub{due: []models.SearchIndexOperation{{Id: "op-1", RepositoryID: "repo-1"}}}
+	job := newTestOutboxJob(repo, func(context.Context, *models.Repository) error {
+		return typesense.ErrDisabled
+	})
+
+	require.NoError(t, job.drain(context.Background()))
+	assert.Equal(t, []string{"op-1"}, repo.completed)
+	assert.Empty(t, repo.failed)
+}
+
+func TestSearchIndexOutboxJobKeepsWritesMadeDuringSync(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Organisation{}, &models.Repository{}, &models.RepositoryRevision{}, &models.SearchIndexOperation{}))
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	repository := &models.Repository{Id: "repo-1", Name: "Repo One", Url: "https://example.org/repos/repo-1", Active: true}
+	require.NoError(t, repo.SaveRepository(ctx, repository))
+
+	for _, outcome := range []error{nil, errors.New("typesense: request failed")} {
+		var synced []string
+		job := NewSearchIndexOutboxJob(repo)
+		job.sync = func(_ context.Context, indexed *models.Repository) error {
+			synced = append(synced, indexed.Name)
+			if len(synced) == 1 {
+				// A write lands while the first sync is in flight.
+				repository.Name = "Repo One renamed"
+				require.NoError(t, repo.SaveRepository(ctx, repository))
+			}
+			return outcome
+		}
+		due, err := repo.ClaimDueSearchIndexOperations(ctx, time.Now(), 10, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+
+		require.NoError(t, job.handle(ctx, &due[0]))
+		require.NoError(t, job.drain(ctx))
+		assert.Equal(t, []string{"Repo One", "Repo One renamed"}, synced)
+
+		repository.Name = "Repo One"
+		require.NoError(t, repo.SaveRepository(ctx, repository))
+	}
+}
+
+func TestSearchIndexRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, searchIndexRetryDelay(1))
+	assert.Equal(t, time.Minute, searchIndexRetryDelay(2))
+	assert.Equal(t, 4*time.Minute, searchIndexRetryDelay(4))
+	assert.Equal(t, time.Hour, searchIndexRetryDelay(20))
+}
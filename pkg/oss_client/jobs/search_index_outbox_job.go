@@ -0,0 +1,140 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/repositories"
+	typesense "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/services/typesense"
+)
+
+const (
+	DefaultSearchIndexOutboxInterval    = 5 * time.Second
+	DefaultSearchIndexOutboxBatchSize   = 50
+	DefaultSearchIndexOutboxMaxAttempts = 10
+
+	searchIndexRetryBase   = 30 * time.Second
+	searchIndexRetryMax    = time.Hour
+	searchIndexSyncTimeout = 10 * time.Second
+)
+
+// SearchIndexOutboxJob drains the outbox of search index operations that
+// SaveRepository and DeleteRepository write. Operations are claimed so
+// replicas don't handle the same one. Failed operations are retried with
+// exponential backoff and marked failed after maxAttempts.
+type SearchIndexOutboxJob struct {
+	repo        repositories.RepositoriesRepository
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	now         func() time.Time
+	sync        func(ctx context.Context, repository *models.Repository) error
+}
+
+func NewSearchIndexOutboxJob(repo repositories.RepositoriesRepository) *SearchIndexOutboxJob {
+	return &SearchIndexOutboxJob{
+		repo:        repo,
+		interval:    DefaultSearchIndexOutboxInterval,
+		batchSize:   DefaultSearchIndexOutboxBatchSize,
+		maxAttempts: DefaultSearchIndexOutboxMaxAttempts,
+		now:         time.Now,
+		sync:        typesense.SyncRepository,
+	}
+}
+
+func (j *SearchIndexOutboxJob) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				j.runOnce(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (j *SearchIndexOutboxJob) runOnce(ctx context.Context) {
+	if err := j.drain(ctx); err != nil {
+		log.Printf("[typesense] outbox run failed: %v", err)
+	}
+}
+
+// drain handles the due operations, one batch at a time, until none are left.
+func (j *SearchIndexOutboxJob) drain(ctx context.Context) error {
+	for ctx.Err() == nil {
+		operations, err := j.repo.ClaimDueSearchIndexOperations(ctx, j.now(), j.batchSize, j.claimLease())
+		if err != nil {
+			return err
+		}
+		for i := range operations {
+			if err := j.handle(ctx, &operations[i]); err != nil {
+				return err
+			}
+		}
+		if len(operations) < j.batchSize {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+// claimLease is how long a claimed batch stays with this worker: long enough
+// to sync every operation in it up to the timeout.
+func (j *SearchIndexOutboxJob) claimLease() time.Duration {
+	return time.Duration(j.batchSize)*searchIndexSyncTimeout + time.Minute
+}
+
+// handle syncs the repository of operation. A disabled index counts as done:
+// there is nothing to keep in sync. Errors are only returned for the outbox
+// itself; sync failures are recorded on the operation. A write to the
+// repository during the sync keeps the operation due, whatever the outcome.
+func (j *SearchIndexOutboxJob) handle(ctx context.Context, operation *models.SearchIndexOperation) error {
+	syncErr := j.syncRepository(ctx, operation.RepositoryID)
+	if syncErr == nil || errors.Is(syncErr, typesense.ErrDisabled) {
+		return j.repo.CompleteSearchIndexOperation(ctx, operation)
+	}
+
+	now := j.now().UTC()
+	operation.Attempts++
+	operation.LastError = syncErr.Error()
+	if operation.Attempts >= j.maxAttempts {
+		operation.Status = models.SearchIndexOperationFailed
+		log.Printf("[typesense] giving up on repository=%s after %d attempts: %v", operation.RepositoryID, operation.Attempts, syncErr)
+	} else {
+		operation.NextAttemptAt = now.Add(searchIndexRetryDelay(operation.Attempts))
+		log.Printf("[typesense] indexing failed for repository=%s (attempt %d), retry at %s: %v",
+			operation.RepositoryID, operation.Attempts, operation.NextAttemptAt.Format(time.RFC3339), syncErr)
+	}
+	return j.repo.SaveSearchIndexOperation(ctx, operation)
+}
+
+func (j *SearchIndexOutboxJob) syncRepository(ctx context.Context, repositoryID string) error {
+	repository, err := j.repo.GetRepositoryByID(ctx, repositoryID)
+	if err != nil {
+		return err
+	}
+	if repository == nil {
+		// Not stored (anymore): only the document can be removed.
+		repository = &models.Repository{Id: repositoryID}
+	}
+	itemCtx, cancel := context.WithTimeout(ctx, searchIndexSyncTimeout)
+	defer cancel()
+	return j.sync(itemCtx, repository)
+}
+
+// searchIndexRetryDelay is the wait after the given number of failed attempts:
+// 30s, 1m, 2m, ... up to an hour.
+func searchIndexRetryDelay(attempts int) time.Duration {
+	delay := searchIndexRetryBase
+	for i := 1; i < attempts && delay < searchIndexRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, searchIndexRetryMax)
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditEvent legt één schrijvend verzoek vast: wie het deed, welke operatie op
+// welke resource, een hash van het request body en de uitkomst.
+type AuditEvent struct {
+	Id           string    `json:"id" gorm:"column:id;primaryKey"`
+	OccurredAt   time.Time `json:"occurredAt" gorm:"column:occurred_at;index"`
+	Actor        string    `json:"actor" gorm:"column:actor;index"`
+	OperationId  string    `json:"operationId" gorm:"column:operation_id"`
+	Method       string    `json:"method" gorm:"column:method"`
+	Path         string    `json:"path" gorm:"column:path"`
+	ResourceType string    `json:"resourceType" gorm:"column:resource_type;index"`
+	ResourceId   string    `json:"resourceId,omitempty" gorm:"column:resource_id;index"`
+	BodyHash     string    `json:"bodyHash,omitempty" gorm:"column:body_hash"`
+	Status       int       `json:"status" gorm:"column:status"`
+	Outcome      string    `json:"outcome" gorm:"column:outcome"`
+}
+
+type ListAuditEventsParams struct {
+	Page         int     `query:"page" validate:"omitempty,min=1"`
+	PerPage      int     `query:"perPage" validate:"omitempty,min=1,max=100"`
+	Actor        *string `query:"actor"`
+	ResourceType *string `query:"resourceType"`
+	ResourceId   *string `query:"resourceId"`
+	From         *string `query:"from"`
+	To           *string `query:"to"`
+	BaseURL      string
+}
+
+// AuditEventFilter bevat de gevalideerde filters voor de audit log.
+type AuditEventFilter struct {
+	Actor        string
+	ResourceType string
+	ResourceId   string
+	From         *time.Time
+	To           *time.Time
+}
@@ -1,8 +1,9 @@
 package models
 
 type GitOrganisationInput struct {
-	Url             string `json:"url" binding:"required,url"`
-	OrganisationUri string `json:"organisationUri" binding:"required,url"`
+	Url             string  `json:"url" binding:"required,url"`
+	OrganisationUri string  `json:"organisationUri" binding:"required,url"`
+	WebhookSecret   *string `json:"webhookSecret,omitempty"`
 }
 
 type GitOrganisatie struct {
@@ -10,6 +11,7 @@ type GitOrganisatie struct {
 	Organisation   *Organisation `json:"organisation,omitempty" gorm:"foreignKey:OrganisationID;references:Uri"`
 	OrganisationID *string       `json:"organisationId,omitempty" gorm:"column:organisation_id"`
 	Url            string        `json:"url" gorm:"column:url;uniqueIndex"`
+	WebhookSecret  string        `json:"-" gorm:"column:webhook_secret"`
 }
 
 type GitOrganisatieSummary struct {
@@ -22,5 +24,22 @@ type ListGitOrganisationsParams struct {
 	Page         int     `query:"page" validate:"omitempty,min=1"`
 	PerPage      int     `query:"perPage" validate:"omitempty,min=1,max=100"`
 	Organisation *string `query:"organisation"`
+	Cursor       string  `query:"cursor"`
 	BaseURL      string
 }
+
+type GitOrganisationParams struct {
+	Id string `path:"id"`
+}
+
+type UpdateGitOrganisationRequest struct {
+	GitOrganisationParams
+	GitOrganisationInput
+}
+
+type ListGitOrganisationRepositoriesParams struct {
+	GitOrganisationParams
+	Page    int `query:"page" validate:"omitempty,min=1"`
+	PerPage int `query:"perPage" validate:"omitempty,min=1,max=100"`
+	BaseURL string
+}
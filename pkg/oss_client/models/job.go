@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+const (
+	JobRunRunning   = "running"
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual"
+)
+
+// JobRun is één uitvoering van een achtergrondjob met de duur, de uitkomst en
+// de tellers die de job teruggaf.
+type JobRun struct {
+	Id         string         `json:"id" gorm:"column:id;primaryKey"`
+	JobName    string         `json:"job" gorm:"column:job_name;index"`
+	Trigger    string         `json:"trigger" gorm:"column:trigger"`
+	Status     string         `json:"status" gorm:"column:status"`
+	StartedAt  time.Time      `json:"startedAt" gorm:"column:started_at;index"`
+	FinishedAt *time.Time     `json:"finishedAt,omitempty" gorm:"column:finished_at"`
+	DurationMs int64          `json:"durationMs" gorm:"column:duration_ms"`
+	Counters   map[string]int `json:"counters,omitempty" gorm:"column:counters;serializer:json"`
+	Error      string         `json:"error,omitempty" gorm:"column:error"`
+}
+
+// Job beschrijft een geregistreerde achtergrondjob met zijn schema en de
+// laatste uitvoering. Schedule en NextRunAt ontbreken als de job alleen
+// handmatig draait. NextRunAt is berekend in de lokale tijdzone van de server,
+// net als het schema zelf. Running geldt alleen voor deze instantie.
+type Job struct {
+	Name      string     `json:"name"`
+	Schedule  string     `json:"schedule,omitempty"`
+	NextRunAt *time.Time `json:"nextRunAt,omitempty"`
+	Running   bool       `json:"running"`
+	LastRun   *JobRun    `json:"lastRun,omitempty"`
+}
+
+type JobParams struct {
+	Name string `path:"name"`
+}
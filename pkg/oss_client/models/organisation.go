@@ -10,9 +10,64 @@ type Organisation struct {
 	Label string `gorm:"column:label" json:"label"`
 }
 
+// Sorteervolgordes voor de organisatielijst; een "-" ervoor keert de volgorde om.
+const (
+	OrganisationSortLabel           = "label"
+	OrganisationSortRepositoryCount = "repositoryCount"
+)
+
 type ListOrganisationsParams struct {
-	Page         int     `query:"page" validate:"omitempty,min=1"`
-	PerPage      int     `query:"perPage" validate:"omitempty,min=1,max=100"`
-	Organisation *string `query:"organisation"`
-	BaseURL      string
+	Page                  int     `query:"page" validate:"omitempty,min=1"`
+	PerPage               int     `query:"perPage" validate:"omitempty,min=1,max=100"`
+	Organisation          *string `query:"organisation"`
+	Query                 string  `query:"q"`
+	HasActiveRepositories *bool   `query:"hasActiveRepositories"`
+	Sort                  string  `query:"sort"`
+	BaseURL               string
+}
+
+func (p *ListOrganisationsParams) OrganisationFilters() *OrganisationFilters {
+	if p == nil {
+		return &OrganisationFilters{}
+	}
+	return &OrganisationFilters{
+		Organisation:          p.Organisation,
+		Query:                 p.Query,
+		HasActiveRepositories: p.HasActiveRepositories,
+		Sort:                  p.Sort,
+	}
+}
+
+// OrganisationFilters bevat de filters en sortering voor GetOrganisations.
+type OrganisationFilters struct {
+	Organisation          *string
+	Query                 string
+	HasActiveRepositories *bool
+	Sort                  string
+}
+
+// OrganisationDetail is een organisatie met het aantal repositories en git
+// organisaties dat ernaar verwijst.
+type OrganisationDetail struct {
+	Uri                  string `json:"uri"`
+	Label                string `json:"label"`
+	RepositoryCount      int    `json:"repositoryCount"`
+	GitOrganisationCount int    `json:"gitOrganisationCount"`
+}
+
+// OrganisationReferences telt de rijen die naar een organisatie verwijzen.
+// Verwijderde repositories blijven als tombstone naar de organisatie verwijzen.
+type OrganisationReferences struct {
+	Repositories        int
+	DeletedRepositories int
+	GitOrganisations    int
+}
+
+type OrganisationParams struct {
+	Uri string `path:"uri"`
+}
+
+type UpdateOrganisationRequest struct {
+	OrganisationParams
+	Label string `json:"label" binding:"required"`
 }
@@ -21,6 +21,7 @@ type RepositoryDetail struct {
 	RepositorySummary
 	PublicCode      *PublicCode `json:"publicCode,omitempty"`
 	LongDescription string      `json:"longDescription,omitempty"`
+	Version         int         `json:"-" gorm:"-"`
 }
 
 type Repository struct {
@@ -40,6 +41,8 @@ type Repository struct {
 	LastCrawledAt    time.Time     `json:"lastCrawledAt" gorm:"column:last_crawled_at"`
 	LastActivityAt   time.Time     `json:"lastActivityAt,omitempty" gorm:"column:last_activity_at"`
 	Active           bool          `json:"-" gorm:"column:active"`
+	DeletedAt        *time.Time    `json:"-" gorm:"column:deleted_at;index"`
+	Version          int           `json:"-" gorm:"column:version;not null;default:1"`
 }
 
 type RepositoryInput struct {
@@ -59,7 +62,13 @@ type RepositoryParams struct {
 	Id string `path:"id"`
 }
 
+type RetrieveRepositoryParams struct {
+	RepositoryParams
+	IfNoneMatch string `header:"If-None-Match"`
+}
+
 type UpdateRepositoryRequest struct {
 	RepositoryParams
 	RepositoryInput
+	IfMatch string `header:"If-Match" json:"-"`
 }
@@ -0,0 +1,25 @@
+package models
+
+import commonproblem "github.com/developer-overheid-nl/don-register-common/problem"
+
+const (
+	BulkStatusCreated = "created"
+	BulkStatusUpdated = "updated"
+	BulkStatusFailed  = "failed"
+)
+
+// BulkRepositoryResult beschrijft de uitkomst van één item uit een bulk upsert.
+type BulkRepositoryResult struct {
+	Index  int                         `json:"index"`
+	Id     string                      `json:"id,omitempty"`
+	Url    string                      `json:"url,omitempty"`
+	Status string                      `json:"status"`
+	Errors []commonproblem.ErrorDetail `json:"errors,omitempty"`
+}
+
+type BulkRepositoryResponse struct {
+	Created int                    `json:"created"`
+	Updated int                    `json:"updated"`
+	Failed  int                    `json:"failed"`
+	Results []BulkRepositoryResult `json:"results"`
+}
@@ -13,6 +13,19 @@ type ListRepositorysSearchParams struct {
 	BaseURL      string
 }
 
+// Sorteervolgordes voor de repositorylijst; een "-" ervoor keert de volgorde
+// om. Zonder sort komen repositories met een publiccode.yml eerst, en bij een
+// zoekterm q de beste treffers.
+const (
+	RepositorySortName           = "name"
+	RepositorySortLastActivityAt = "lastActivityAt"
+	RepositorySortCreatedAt      = "createdAt"
+	RepositorySortLastCrawledAt  = "lastCrawledAt"
+	RepositorySortOrganisation   = "organisation"
+	// RepositorySortRelevance kan alleen samen met q en alleen aflopend.
+	RepositorySortRelevance = "relevance"
+)
+
 type ListRepositorysParams struct {
 	Page               int      `query:"page" validate:"omitempty,min=1"`
 	PerPage            int      `query:"perPage" validate:"omitempty,min=1,max=100"`
@@ -27,6 +40,8 @@ type ListRepositorysParams struct {
 	MaintenanceType    []string `query:"maintenanceType"`
 	License            []string `query:"license"`
 	Platforms          []string `query:"platforms"`
+	Sort               string   `query:"sort"`
+	Cursor             string   `query:"cursor"`
 	BaseURL            string
 }
 
@@ -46,10 +61,21 @@ func (p *ListRepositorysParams) RepositoryFilters() *RepositoryFiltersParams {
 		MaintenanceType:    append([]string(nil), p.MaintenanceType...),
 		License:            append([]string(nil), p.License...),
 		Platforms:          append([]string(nil), p.Platforms...),
+		Sort:               p.Sort,
 	}
 }
 
-type Pagination = commonpagination.Pagination
+// Pagination describes a page of results. Cursor pages have no page numbers;
+// for them HasMore reports whether items follow the page.
+type Pagination struct {
+	commonpagination.Pagination
+	HasMore bool
+}
+
+// NewPagination describes page page of perPage items out of totalRecords.
+func NewPagination(page, perPage, totalRecords int) Pagination {
+	return Pagination{Pagination: commonpagination.New(page, perPage, totalRecords)}
+}
 
 type FilterOption = commonfilters.FilterOption
 
@@ -84,4 +110,9 @@ type RepositoryFiltersParams struct {
 	MaintenanceType    []string `query:"maintenanceType"`
 	License            []string `query:"license"`
 	Platforms          []string `query:"platforms"`
+	// Sort en After worden alleen door de repositorylijst gebruikt en zijn geen
+	// query parameters van het filterendpoint. Met After (ook leeg) pagineert
+	// de lijst op id: alleen repositories met een id na After.
+	Sort  string
+	After *string
 }
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// RepositoryRevision legt vast welke velden van een repository bij een save zijn gewijzigd.
+type RepositoryRevision struct {
+	Id           string                  `json:"id" gorm:"column:id;primaryKey"`
+	RepositoryID string                  `json:"repositoryId" gorm:"column:repository_id;index"`
+	Version      int                     `json:"version" gorm:"column:version"`
+	Actor        string                  `json:"actor" gorm:"column:actor"`
+	CreatedAt    time.Time               `json:"createdAt" gorm:"column:created_at;index"`
+	Changes      []RepositoryFieldChange `json:"changes" gorm:"column:changes;serializer:json"`
+}
+
+// RepositoryFieldChange bevat de oude en nieuwe waarde van één gewijzigd veld.
+// Velden uit publiccode.yml worden als pad genoteerd, bijvoorbeeld publicCode.legal.license.
+type RepositoryFieldChange struct {
+	Field    string `json:"field"`
+	OldValue any    `json:"oldValue"`
+	NewValue any    `json:"newValue"`
+}
+
+type ListRepositoryHistoryParams struct {
+	RepositoryParams
+	Page    int `query:"page" validate:"omitempty,min=1"`
+	PerPage int `query:"perPage" validate:"omitempty,min=1,max=100"`
+	BaseURL string
+}
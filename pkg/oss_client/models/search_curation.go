@@ -0,0 +1,25 @@
+package models
+
+// SearchCuration bevat de synoniemen en vastgepinde resultaten die naar de
+// Typesense-collectie worden gestuurd.
+type SearchCuration struct {
+	Synonyms []SearchSynonym `json:"synonyms"`
+	Pins     []SearchPin     `json:"pins"`
+}
+
+// SearchSynonym is een groep termen die bij het zoeken als gelijk gelden,
+// zoals "gemeente" en "municipality". Met Root gelden de termen alleen als
+// synoniem van Root en niet andersom.
+type SearchSynonym struct {
+	Id       string   `json:"id"`
+	Root     string   `json:"root,omitempty"`
+	Synonyms []string `json:"synonyms"`
+}
+
+// SearchPin zet repositories bovenaan de resultaten van een zoekopdracht, in
+// de opgegeven volgorde.
+type SearchPin struct {
+	Id           string   `json:"id"`
+	Query        string   `json:"query"`
+	Repositories []string `json:"repositories"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+const (
+	SearchIndexOperationPending = "pending"
+	SearchIndexOperationFailed  = "failed"
+)
+
+// SearchIndexOperation is een openstaande synchronisatie van een repository
+// naar Typesense. Hij wordt in dezelfde transactie als de repository opgeslagen
+// en door de outbox worker afgehandeld; na te veel mislukte pogingen blijft hij
+// met status failed staan.
+type SearchIndexOperation struct {
+	Id            string    `json:"id" gorm:"column:id;primaryKey"`
+	RepositoryID  string    `json:"repositoryId" gorm:"column:repository_id;index"`
+	Status        string    `json:"status" gorm:"column:status;index"`
+	Attempts      int       `json:"attempts" gorm:"column:attempts"`
+	NextAttemptAt time.Time `json:"nextAttemptAt" gorm:"column:next_attempt_at;index"`
+	LastError     string    `json:"lastError,omitempty" gorm:"column:last_error"`
+	CreatedAt     time.Time `json:"createdAt" gorm:"column:created_at"`
+	UpdatedAt     time.Time `json:"updatedAt" gorm:"column:updated_at"`
+}
+
+type ListSearchIndexOperationsParams struct {
+	Page    int     `query:"page" validate:"omitempty,min=1"`
+	PerPage int     `query:"perPage" validate:"omitempty,min=1,max=100"`
+	Status  *string `query:"status"`
+	BaseURL string
+}
+
+// SearchIndexReindex is het resultaat van een herindexering van Typesense.
+type SearchIndexReindex struct {
+	Collection         string    `json:"collection"`
+	PreviousCollection string    `json:"previousCollection,omitempty"`
+	Documents          int       `json:"documents"`
+	Resynced           int       `json:"resynced"`
+	StartedAt          time.Time `json:"startedAt"`
+	FinishedAt         time.Time `json:"finishedAt"`
+}
@@ -0,0 +1,18 @@
+package models
+
+const (
+	WebhookForgeGitHub = "github"
+	WebhookForgeGitLab = "gitlab"
+	WebhookForgeGitea  = "gitea"
+
+	WebhookStatusRefreshed = "refreshed"
+	WebhookStatusIgnored   = "ignored"
+)
+
+// WebhookResult beschrijft wat er met een binnengekomen webhook is gedaan.
+type WebhookResult struct {
+	Status       string `json:"status"`
+	Event        string `json:"event"`
+	RepositoryId string `json:"repositoryId,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
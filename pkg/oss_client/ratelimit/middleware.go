@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/auth"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/operation"
+	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// Response headers, following the IETF RateLimit header fields draft.
+const (
+	HeaderLimit     = "RateLimit-Limit"
+	HeaderRemaining = "RateLimit-Remaining"
+	HeaderReset     = "RateLimit-Reset"
+)
+
+// Middleware limits each client per operation and reports the state of its
+// bucket in RateLimit-* headers. It must run after the authentication
+// middleware so that authenticated clients are keyed on their id instead of
+// their IP address. Routes unknown to lookup are not limited.
+func (l *Limiter) Middleware(lookup operation.Lookup) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		op, ok := lookup(c.Request.Method, c.FullPath())
+		if !ok {
+			c.Next()
+			return
+		}
+		result, limited := l.Allow(op.ID, clientKey(c))
+		if !limited {
+			c.Next()
+			return
+		}
+
+		c.Header(HeaderLimit, strconv.Itoa(result.Limit))
+		c.Header(HeaderRemaining, strconv.Itoa(result.Remaining))
+		c.Header(HeaderReset, seconds(result.Reset))
+		if !result.Allowed {
+			c.Header("Retry-After", seconds(result.RetryAfter))
+			c.Abort()
+			p := problem.New(http.StatusTooManyRequests, "Too many requests")
+			c.Header("Content-Type", "application/problem+json")
+			c.JSON(p.Status, p)
+			return
+		}
+		c.Next()
+	}
+}
+
+// clientKey identifies the caller: the client id of the token or the id of the
+// API key, or else the IP address.
+func clientKey(c *gin.Context) string {
+	if principal, ok := auth.PrincipalFromGin(c); ok && principal.ClientID != "" {
+		return principal.Scheme + ":" + principal.ClientID
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// seconds rounds d up to whole seconds.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
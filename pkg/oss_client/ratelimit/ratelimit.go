@@ -0,0 +1,264 @@
+// Package ratelimit limits the request rate per client with token buckets. A
+// client is identified by its API key id or OAuth client id, or by its IP
+// address when the request is not authenticated. Limits are configured per
+// operation id.
+package ratelimit
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EnvEnableRateLimit = "ENABLE_RATE_LIMIT"
+	// EnvDefault is the limit of operations without a limit of their own, as
+	// <requests>/<period>, for example 300/1m.
+	EnvDefault = "RATE_LIMIT_DEFAULT"
+	// EnvOperations sets limits per operation id as comma separated
+	// <operationId>=<requests>/<period> pairs.
+	EnvOperations = "RATE_LIMIT_OPERATIONS"
+	// EnvMaxBuckets caps the number of buckets kept in memory.
+	EnvMaxBuckets = "RATE_LIMIT_MAX_BUCKETS"
+
+	// DefaultMaxBuckets is the bucket cap when none is configured.
+	DefaultMaxBuckets = 100_000
+
+	sweepInterval = 10 * time.Minute
+)
+
+// Limit allows Requests requests per Period. Up to Requests requests may be
+// sent at once; after that tokens are refilled evenly over the period. A zero
+// Limit is unlimited.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (l Limit) unlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+// ratePerSecond is the refill rate of the bucket.
+func (l Limit) ratePerSecond() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// ParseLimit parses <requests>/<period>, for example 60/1m. "off" and 0 are
+// unlimited.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "off") || s == "0" {
+		return Limit{}, nil
+	}
+	rawRequests, rawPeriod, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("ratelimit: limit %q must be <requests>/<period>", s)
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(rawRequests))
+	if err != nil || requests < 0 {
+		return Limit{}, fmt.Errorf("ratelimit: invalid request count in %q", s)
+	}
+	period, err := time.ParseDuration(strings.TrimSpace(rawPeriod))
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("ratelimit: invalid period in %q", s)
+	}
+	return Limit{Requests: requests, Period: period}, nil
+}
+
+// Config holds the limit per operation id. Operations without an entry share
+// one bucket per client limited by Default. At most MaxBuckets buckets are
+// kept; beyond that the least recently used bucket is dropped.
+type Config struct {
+	Default    Limit
+	Operations map[string]Limit
+	MaxBuckets int
+}
+
+// DefaultConfig limits the list endpoints that load every repository harder
+// than the rest of the API.
+func DefaultConfig() Config {
+	return Config{
+		Default: Limit{Requests: 300, Period: time.Minute},
+		Operations: map[string]Limit{
+			"listRepositories":      {Requests: 60, Period: time.Minute},
+			"listRepositoryFilters": {Requests: 60, Period: time.Minute},
+		},
+	}
+}
+
+// Enabled reports whether rate limiting is enforced. It is on unless
+// ENABLE_RATE_LIMIT is set to false.
+func Enabled() bool {
+	return !strings.EqualFold(strings.TrimSpace(os.Getenv(EnvEnableRateLimit)), "false")
+}
+
+// ConfigFromEnv reads the limits from RATE_LIMIT_* env vars on top of
+// DefaultConfig.
+func ConfigFromEnv() (Config, error) {
+	cfg := DefaultConfig()
+	if raw := strings.TrimSpace(os.Getenv(EnvDefault)); raw != "" {
+		limit, err := ParseLimit(raw)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.Default = limit
+	}
+	for _, pair := range strings.Split(os.Getenv(EnvOperations), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		operationID, raw, ok := strings.Cut(pair, "=")
+		operationID = strings.TrimSpace(operationID)
+		if !ok || operationID == "" {
+			return Config{}, fmt.Errorf("ratelimit: %s entry %q must be <operationId>=<requests>/<period>", EnvOperations, pair)
+		}
+		limit, err := ParseLimit(raw)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.Operations[operationID] = limit
+	}
+	if raw := strings.TrimSpace(os.Getenv(EnvMaxBuckets)); raw != "" {
+		maxBuckets, err := strconv.Atoi(raw)
+		if err != nil || maxBuckets <= 0 {
+			return Config{}, fmt.Errorf("ratelimit: %s must be a positive number", EnvMaxBuckets)
+		}
+		cfg.MaxBuckets = maxBuckets
+	}
+	return cfg, nil
+}
+
+// Result describes the state of a bucket after a request.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed. It is zero
+	// for allowed requests.
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps a token bucket per operation and client in memory. The
+// buckets are also kept in a list ordered from most to least recently used.
+type Limiter struct {
+	cfg Config
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*list.Element
+	recent    *list.List
+	lastSweep time.Time
+}
+
+func NewLimiter(cfg Config) *Limiter {
+	if cfg.Operations == nil {
+		cfg.Operations = map[string]Limit{}
+	}
+	if cfg.MaxBuckets <= 0 {
+		cfg.MaxBuckets = DefaultMaxBuckets
+	}
+	return &Limiter{cfg: cfg, now: time.Now, buckets: map[string]*list.Element{}, recent: list.New()}
+}
+
+// limitFor returns the limit of an operation and the bucket name it counts
+// against.
+func (l *Limiter) limitFor(operationID string) (Limit, string) {
+	if limit, ok := l.cfg.Operations[operationID]; ok {
+		return limit, operationID
+	}
+	return l.cfg.Default, ""
+}
+
+// Allow takes a token from the bucket of the client for the operation. ok is
+// false when the operation is unlimited.
+func (l *Limiter) Allow(operationID, client string) (result Result, ok bool) {
+	limit, name := l.limitFor(operationID)
+	if limit.unlimited() {
+		return Result{}, false
+	}
+	rate := limit.ratePerSecond()
+	capacity := float64(limit.Requests)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	b := l.bucket(name+"\x00"+client, capacity, now)
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	result = Result{Limit: limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = secondsToDuration((capacity - b.tokens) / rate)
+	return result, true
+}
+
+// bucket returns the bucket for key and marks it as most recently used. A new
+// bucket starts full; when the limiter is at MaxBuckets the least recently
+// used bucket makes room for it.
+func (l *Limiter) bucket(key string, capacity float64, now time.Time) *bucket {
+	if elem, ok := l.buckets[key]; ok {
+		l.recent.MoveToFront(elem)
+		return elem.Value.(*bucket)
+	}
+	for len(l.buckets) >= l.cfg.MaxBuckets {
+		l.remove(l.recent.Back())
+	}
+	b := &bucket{key: key, tokens: capacity, last: now}
+	l.buckets[key] = l.recent.PushFront(b)
+	return b
+}
+
+func (l *Limiter) remove(elem *list.Element) {
+	l.recent.Remove(elem)
+	delete(l.buckets, elem.Value.(*bucket).key)
+}
+
+// sweep drops buckets that have been idle long enough to be full again, so
+// clients that stopped sending requests do not use memory. The idle buckets
+// are at the back of the list.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	idle := l.longestPeriod()
+	for elem := l.recent.Back(); elem != nil && now.Sub(elem.Value.(*bucket).last) > idle; elem = l.recent.Back() {
+		l.remove(elem)
+	}
+}
+
+func (l *Limiter) longestPeriod() time.Duration {
+	longest := l.cfg.Default.Period
+	for _, limit := range l.cfg.Operations {
+		if limit.Period > longest {
+			longest = limit.Period
+		}
+	}
+	return longest
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
@@ -0,0 +1,205 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wI2L/fizz/openapi"
+)
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestLimiter(cfg Config) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)}
+	l := NewLimiter(cfg)
+	l.now = clock.Now
+	return l, clock
+}
+
+func TestLimiterRefillsBucketOverPeriod(t *testing.T) {
+	l, clock := newTestLimiter(Config{Default: Limit{Requests: 3, Period: 3 * time.Second}})
+
+	for i := 2; i >= 0; i-- {
+		result, ok := l.Allow("listOrganisations", "ip:1.2.3.4")
+		require.True(t, ok)
+		require.True(t, result.Allowed)
+		assert.Equal(t, 3, result.Limit)
+		assert.Equal(t, i, result.Remaining)
+	}
+
+	result, _ := l.Allow("listOrganisations", "ip:1.2.3.4")
+	require.False(t, result.Allowed)
+	assert.Equal(t, time.Second, result.RetryAfter)
+	assert.Equal(t, 3*time.Second, result.Reset)
+
+	// Other clients have their own bucket.
+	result, _ = l.Allow("listOrganisations", "ip:5.6.7.8")
+	assert.True(t, result.Allowed)
+
+	clock.now = clock.now.Add(time.Second)
+	result, _ = l.Allow("listOrganisations", "ip:1.2.3.4")
+	require.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	clock.now = clock.now.Add(time.Minute)
+	result, _ = l.Allow("listOrganisations", "ip:1.2.3.4")
+	require.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining)
+}
+
+func TestLimiterEvictsLeastRecentlyUsedBucket(t *testing.T) {
+	l, clock := newTestLimiter(Config{Default: Limit{Requests: 1, Period: time.Hour}, MaxBuckets: 2})
+
+	for _, client := range []string{"ip:1.1.1.1", "ip:2.2.2.2"} {
+		result, _ := l.Allow("listOrganisations", client)
+		require.True(t, result.Allowed)
+	}
+	clock.now = clock.now.Add(time.Second)
+	result, _ := l.Allow("listOrganisations", "ip:1.1.1.1")
+	require.False(t, result.Allowed)
+
+	// A third client evicts 2.2.2.2, which was used least recently.
+	result, _ = l.Allow("listOrganisations", "ip:3.3.3.3")
+	require.True(t, result.Allowed)
+	assert.Len(t, l.buckets, 2)
+
+	result, _ = l.Allow("listOrganisations", "ip:1.1.1.1")
+	assert.False(t, result.Allowed)
+	result, _ = l.Allow("listOrganisations", "ip:2.2.2.2")
+	assert.True(t, result.Allowed)
+}
+
+func TestLimiterUsesOperationLimits(t *testing.T) {
+	l, _ := newTestLimiter(Config{
+		Default: Limit{Requests: 10, Period: time.Minute},
+		Operations: map[string]Limit{
+			"listRepositories": {Requests: 1, Period: time.Minute},
+			"receiveWebhook":   {},
+		},
+	})
+
+	result, _ := l.Allow("listRepositories", "clientCredentials:portal")
+	require.True(t, result.Allowed)
+	result, _ = l.Allow("listRepositories", "clientCredentials:portal")
+	require.False(t, result.Allowed)
+
+	// Operations without a limit of their own share the default bucket.
+	result, _ = l.Allow("listOrganisations", "clientCredentials:portal")
+	require.True(t, result.Allowed)
+	assert.Equal(t, 9, result.Remaining)
+	result, _ = l.Allow("getOrganisation", "clientCredentials:portal")
+	assert.Equal(t, 8, result.Remaining)
+
+	_, ok := l.Allow("receiveWebhook", "ip:1.2.3.4")
+	assert.False(t, ok)
+}
+
+func TestMiddlewareSetsHeadersAndRejects(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l, _ := newTestLimiter(Config{
+		Default:    Limit{Requests: 100, Period: time.Minute},
+		Operations: map[string]Limit{"listRepositories": {Requests: 2, Period: time.Minute}},
+	})
+	lookup := func(method, fullPath string) (*openapi.Operation, bool) {
+		if method == http.MethodGet && fullPath == "/v1/repositories" {
+			return &openapi.Operation{ID: "listRepositories"}, true
+		}
+		return nil, false
+	}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if id := c.GetHeader("X-Client"); id != "" {
+			c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), &auth.Principal{ClientID: id, Scheme: auth.SchemeAPIKey}))
+		}
+	})
+	r.Use(l.Middleware(lookup))
+	r.GET("/v1/repositories", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	get := func(path, client string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if client != "" {
+			req.Header.Set("X-Client", client)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/v1/repositories", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get(HeaderLimit))
+	assert.Equal(t, "1", rec.Header().Get(HeaderRemaining))
+	assert.Equal(t, "30", rec.Header().Get(HeaderReset))
+
+	require.Equal(t, http.StatusOK, get("/v1/repositories", "").Code)
+
+	rec = get("/v1/repositories", "")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "0", rec.Header().Get(HeaderRemaining))
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), "Too many requests")
+
+	// The same IP with an API key counts against the key.
+	rec = get("/v1/repositories", "portal")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get(HeaderRemaining))
+
+	rec = get("/health", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get(HeaderLimit))
+}
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit(" 60/1m ")
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Requests: 60, Period: time.Minute}, limit)
+
+	limit, err = ParseLimit("off")
+	require.NoError(t, err)
+	assert.True(t, limit.unlimited())
+
+	for _, invalid := range []string{"60", "x/1m", "60/minute", "60/0s", "-1/1m"} {
+		_, err := ParseLimit(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv(EnvDefault, "100/1m")
+	t.Setenv(EnvOperations, "listRepositories=10/1s, searchRepositories=off")
+
+	cfg, err := ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Requests: 100, Period: time.Minute}, cfg.Default)
+	assert.Equal(t, Limit{Requests: 10, Period: time.Second}, cfg.Operations["listRepositories"])
+	assert.True(t, cfg.Operations["searchRepositories"].unlimited())
+	assert.Equal(t, DefaultConfig().Operations["listRepositoryFilters"], cfg.Operations["listRepositoryFilters"])
+
+	assert.Zero(t, cfg.MaxBuckets)
+
+	t.Setenv(EnvMaxBuckets, "500")
+	cfg, err = ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, 500, cfg.MaxBuckets)
+
+	t.Setenv(EnvMaxBuckets, "0")
+	_, err = ConfigFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv(EnvMaxBuckets, "")
+	t.Setenv(EnvOperations, "listRepositories")
+	_, err = ConfigFromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+)
+
+// SaveAuditEvent stores one audit event.
+func (r *repositoriesRepository) SaveAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+// GetAuditEvents returns the audit events matching filter, newest first.
+func (r *repositoriesRepository) GetAuditEvents(ctx context.Context, page, perPage int, filter models.AuditEventFilter) ([]models.AuditEvent, models.Pagination, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage <= 0 {
+		perPage = 20
+	}
+
+	db := r.db.WithContext(ctx).Model(&models.AuditEvent{})
+	if filter.Actor != "" {
+		db = db.Where("actor = ?", filter.Actor)
+	}
+	if filter.ResourceType != "" {
+		db = db.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.ResourceId != "" {
+		db = db.Where("resource_id = ?", filter.ResourceId)
+	}
+	if filter.From != nil {
+		db = db.Where("occurred_at >= ?", filter.From.UTC())
+	}
+	if filter.To != nil {
+		db = db.Where("occurred_at < ?", filter.To.UTC())
+	}
+
+	var totalRecords int64
+	if err := db.Count(&totalRecords).Error; err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	var events []models.AuditEvent
+	if err := db.Order("occurred_at DESC").
+		Order("id").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&events).Error; err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	return events, models.NewPagination(page, perPage, int(totalRecords)), nil
+}
@@ -1,11 +1,15 @@
 package repositories
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
 )
 
 var orgID = "https://example.org"
@@ -51,77 +55,130 @@ func withShortDescription(description string) func(*models.Repository) {
 	return func(r *models.Repository) { r.ShortDescription = description }
 }
 
+// filterDB stores repos in an in-memory database, so that the filters below
+// run the SQL generated by repositoryFilter.
+func filterDB(t *testing.T, repos ...models.Repository) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Organisation{}, &models.Repository{}))
+	for i, repo := range repos {
+		repo.Id = fmt.Sprintf("repo-%d", i+1)
+		require.NoError(t, db.Create(&repo).Error)
+	}
+	return db
+}
+
+func filteredRepositories(t *testing.T, db *gorm.DB, p *models.RepositoryFiltersParams, exclude string) (*repositoryFilter, *gorm.DB) {
+	t.Helper()
+	filter, err := compileRepositoryFilters(db, p)
+	require.NoError(t, err)
+	return filter, filter.apply(db.Model(&models.Repository{}), exclude)
+}
+
+func repoMatchesFilters(t *testing.T, repo models.Repository, p *models.RepositoryFiltersParams, exclude string) bool {
+	t.Helper()
+	_, query := filteredRepositories(t, filterDB(t, repo), p, exclude)
+	count, err := countRepositories(query)
+	require.NoError(t, err)
+	return count == 1
+}
+
+func countByField(t *testing.T, repos []models.Repository, p *models.RepositoryFiltersParams, exclude, field string) []models.FilterCount {
+	t.Helper()
+	filter, query := filteredRepositories(t, filterDB(t, repos...), p, exclude)
+	result, err := filter.countByField(query, publicCodeFields[field])
+	require.NoError(t, err)
+	return result
+}
+
+func countByArrayField(t *testing.T, repos []models.Repository, p *models.RepositoryFiltersParams, exclude, field string) []models.FilterCount {
+	t.Helper()
+	filter, query := filteredRepositories(t, filterDB(t, repos...), p, exclude)
+	result, err := filter.countByArrayField(query, publicCodeFields[field])
+	require.NoError(t, err)
+	return result
+}
+
+func countRepos(t *testing.T, repos []models.Repository, p *models.RepositoryFiltersParams, exclude string) int {
+	t.Helper()
+	_, query := filteredRepositories(t, filterDB(t, repos...), p, exclude)
+	count, err := countRepositories(query.Where(hasPublicCodeSQL))
+	require.NoError(t, err)
+	return count
+}
+
 // repoMatchesFilters
 
 func TestRepoMatchesFilters_NoFilters(t *testing.T) {
 	repo := makeRepo()
-	assert.True(t, repoMatchesFilters(repo, &models.RepositoryFiltersParams{}, ""))
+	assert.True(t, repoMatchesFilters(t, repo, &models.RepositoryFiltersParams{}, ""))
 }
 
 func TestRepoMatchesFilters_PublicCode_Match(t *testing.T) {
 	repo := makeRepo(withPublicCodeUrl("https://example.org/publiccode.yml"))
 	p := &models.RepositoryFiltersParams{PublicCode: boolPtr(true)}
-	assert.True(t, repoMatchesFilters(repo, p, ""))
+	assert.True(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_PublicCode_NoMatch(t *testing.T) {
 	repo := makeRepo(withPublicCodeUrl(""))
 	p := &models.RepositoryFiltersParams{PublicCode: boolPtr(true)}
-	assert.False(t, repoMatchesFilters(repo, p, ""))
+	assert.False(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_PublicCodeFalse_MatchesWithoutPublicCode(t *testing.T) {
 	repo := makeRepo(withPublicCodeUrl(""))
 	p := &models.RepositoryFiltersParams{PublicCode: boolPtr(false)}
-	assert.True(t, repoMatchesFilters(repo, p, ""))
+	assert.True(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_PublicCodeFalse_DoesNotMatchWithPublicCode(t *testing.T) {
 	repo := makeRepo()
 	p := &models.RepositoryFiltersParams{PublicCode: boolPtr(false)}
-	assert.False(t, repoMatchesFilters(repo, p, ""))
+	assert.False(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_PublicCode_Excluded(t *testing.T) {
 	repo := makeRepo()
 	p := &models.RepositoryFiltersParams{PublicCode: boolPtr(true)}
-	assert.True(t, repoMatchesFilters(repo, p, "publiccode"))
+	assert.True(t, repoMatchesFilters(t, repo, p, "publiccode"))
 }
 
 func TestRepoMatchesFilters_Organisation_Match(t *testing.T) {
 	repo := makeRepo(withOrg("https://example.org"))
 	p := &models.RepositoryFiltersParams{Organisation: ptr("https://example.org")}
-	assert.True(t, repoMatchesFilters(repo, p, ""))
+	assert.True(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_Organisation_NoMatch(t *testing.T) {
 	repo := makeRepo(withOrg("https://other.org"))
 	p := &models.RepositoryFiltersParams{Organisation: ptr("https://example.org")}
-	assert.False(t, repoMatchesFilters(repo, p, ""))
+	assert.False(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_Query_MatchesRepositoryText(t *testing.T) {
 	repo := makeRepo(withName("Open Forms"), withShortDescription("Digital form handling"))
 	p := &models.RepositoryFiltersParams{Query: "forms"}
-	assert.True(t, repoMatchesFilters(repo, p, ""))
+	assert.True(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_Query_NoMatch(t *testing.T) {
 	repo := makeRepo(withName("Open Forms"), withShortDescription("Digital form handling"))
 	p := &models.RepositoryFiltersParams{Query: "catalogus"}
-	assert.False(t, repoMatchesFilters(repo, p, ""))
+	assert.False(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_Query_MatchesPublicCodeURL(t *testing.T) {
 	repo := makeRepo(withPublicCode(&models.PublicCode{Url: "https://git.example.org/open-forms"}))
 	p := &models.RepositoryFiltersParams{Query: "open-forms"}
-	assert.True(t, repoMatchesFilters(repo, p, ""))
+	assert.True(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_Query_MatchesPublicCodeLandingURL(t *testing.T) {
 	repo := makeRepo(withPublicCode(&models.PublicCode{LandingUrl: "https://forms.example.org"}))
 	p := &models.RepositoryFiltersParams{Query: "forms.example.org"}
-	assert.True(t, repoMatchesFilters(repo, p, ""))
+	assert.True(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_Query_DoesNotMatchRepositoryURLWithoutPublicCode(t *testing.T) {
@@ -130,49 +187,49 @@ func TestRepoMatchesFilters_Query_DoesNotMatchRepositoryURLWithoutPublicCode(t *
 		func(r *models.Repository) { r.Url = "https://git.example.org/open-forms" },
 	)
 	p := &models.RepositoryFiltersParams{Query: "open-forms"}
-	assert.False(t, repoMatchesFilters(repo, p, ""))
+	assert.False(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_LastActivityAfter_Match(t *testing.T) {
 	repo := makeRepo(withLastActivity(time.Now()))
 	p := &models.RepositoryFiltersParams{LastActivityAfter: ptr("2020-01-01")}
-	assert.True(t, repoMatchesFilters(repo, p, ""))
+	assert.True(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_LastActivityAfter_NoMatch(t *testing.T) {
 	repo := makeRepo(withLastActivity(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)))
 	p := &models.RepositoryFiltersParams{LastActivityAfter: ptr("2020-01-01")}
-	assert.False(t, repoMatchesFilters(repo, p, ""))
+	assert.False(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_SoftwareType_Match(t *testing.T) {
 	repo := makeRepo(withPublicCode(&models.PublicCode{SoftwareType: "library"}))
 	p := &models.RepositoryFiltersParams{SoftwareType: []string{"library"}}
-	assert.True(t, repoMatchesFilters(repo, p, ""))
+	assert.True(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_SoftwareType_NoMatch(t *testing.T) {
 	repo := makeRepo(withPublicCode(&models.PublicCode{SoftwareType: "addon"}))
 	p := &models.RepositoryFiltersParams{SoftwareType: []string{"library"}}
-	assert.False(t, repoMatchesFilters(repo, p, ""))
+	assert.False(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_SoftwareType_NilPublicCode(t *testing.T) {
 	repo := makeRepo()
 	p := &models.RepositoryFiltersParams{SoftwareType: []string{"library"}}
-	assert.False(t, repoMatchesFilters(repo, p, ""))
+	assert.False(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_Platforms_AllMatch(t *testing.T) {
 	repo := makeRepo(withPublicCode(&models.PublicCode{Platforms: []string{"web", "linux"}}))
 	p := &models.RepositoryFiltersParams{Platforms: []string{"web", "linux"}}
-	assert.True(t, repoMatchesFilters(repo, p, ""))
+	assert.True(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_Platforms_PartialMatch(t *testing.T) {
 	repo := makeRepo(withPublicCode(&models.PublicCode{Platforms: []string{"web"}}))
 	p := &models.RepositoryFiltersParams{Platforms: []string{"web", "linux"}}
-	assert.False(t, repoMatchesFilters(repo, p, ""))
+	assert.False(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_AvailableLanguages_Match(t *testing.T) {
@@ -180,7 +237,7 @@ func TestRepoMatchesFilters_AvailableLanguages_Match(t *testing.T) {
 		Localisation: &models.PublicCodeLocalisation{AvailableLanguages: []string{"nl", "en"}},
 	}))
 	p := &models.RepositoryFiltersParams{AvailableLanguages: []string{"nl"}}
-	assert.True(t, repoMatchesFilters(repo, p, ""))
+	assert.True(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_License_Match(t *testing.T) {
@@ -188,7 +245,7 @@ func TestRepoMatchesFilters_License_Match(t *testing.T) {
 		Legal: &models.PublicCodeLegal{License: "MIT"},
 	}))
 	p := &models.RepositoryFiltersParams{License: []string{"MIT"}}
-	assert.True(t, repoMatchesFilters(repo, p, ""))
+	assert.True(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_MaintenanceType_Match(t *testing.T) {
@@ -196,7 +253,7 @@ func TestRepoMatchesFilters_MaintenanceType_Match(t *testing.T) {
 		Maintenance: &models.PublicCodeMaintenance{Type: "internal"},
 	}))
 	p := &models.RepositoryFiltersParams{MaintenanceType: []string{"internal"}}
-	assert.True(t, repoMatchesFilters(repo, p, ""))
+	assert.True(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 func TestRepoMatchesFilters_MultipleFilters_AllApply(t *testing.T) {
@@ -212,7 +269,7 @@ func TestRepoMatchesFilters_MultipleFilters_AllApply(t *testing.T) {
 		SoftwareType: []string{"library"},
 		Platforms:    []string{"web"},
 	}
-	assert.True(t, repoMatchesFilters(repo, p, ""))
+	assert.True(t, repoMatchesFilters(t, repo, p, ""))
 }
 
 // countByField
@@ -225,12 +282,7 @@ func TestCountByField_CountsCorrectly(t *testing.T) {
 		makeRepo(),
 	}
 	p := &models.RepositoryFiltersParams{}
-	result := countByField(repos, p, "", func(r models.Repository) string {
-		if r.PublicCode == nil {
-			return ""
-		}
-		return r.PublicCode.SoftwareType
-	})
+	result := countByField(t, repos, p, "", "softwareType")
 
 	assert.Len(t, result, 2)
 	assert.Equal(t, "addon", result[0].Value)
@@ -247,12 +299,7 @@ func TestCountByField_RespectsOtherFilters(t *testing.T) {
 		{OrganisationID: &org2, Active: true, PublicCodeUrl: "https://org2.nl/publiccode.yml", PublicCode: &models.PublicCode{SoftwareType: "addon"}},
 	}
 	p := &models.RepositoryFiltersParams{Organisation: &org1}
-	result := countByField(repos, p, "softwareType", func(r models.Repository) string {
-		if r.PublicCode == nil {
-			return ""
-		}
-		return r.PublicCode.SoftwareType
-	})
+	result := countByField(t, repos, p, "softwareType", "softwareType")
 
 	assert.Len(t, result, 1)
 	assert.Equal(t, "library", result[0].Value)
@@ -264,12 +311,7 @@ func TestCountByField_RespectsQueryFilter(t *testing.T) {
 		makeRepo(withName("Catalogus"), withPublicCode(&models.PublicCode{SoftwareType: "addon"})),
 	}
 	p := &models.RepositoryFiltersParams{Query: "forms"}
-	result := countByField(repos, p, "softwareType", func(r models.Repository) string {
-		if r.PublicCode == nil {
-			return ""
-		}
-		return r.PublicCode.SoftwareType
-	})
+	result := countByField(t, repos, p, "softwareType", "softwareType")
 
 	assert.Len(t, result, 1)
 	assert.Equal(t, "library", result[0].Value)
@@ -285,12 +327,7 @@ func TestCountByArrayField_CountsEachValue(t *testing.T) {
 		makeRepo(withPublicCode(&models.PublicCode{Platforms: []string{"linux"}})),
 	}
 	p := &models.RepositoryFiltersParams{}
-	result := countByArrayField(repos, p, "", func(r models.Repository) []string {
-		if r.PublicCode == nil {
-			return nil
-		}
-		return r.PublicCode.Platforms
-	})
+	result := countByArrayField(t, repos, p, "", "platforms")
 
 	counts := make(map[string]int)
 	for _, fc := range result {
@@ -309,8 +346,6 @@ func TestCountRepos_Toggle(t *testing.T) {
 		makeRepo(withPublicCodeUrl("https://other.org/publiccode.yml")),
 	}
 	p := &models.RepositoryFiltersParams{}
-	count := countRepos(repos, p, "publiccode", func(r models.Repository) bool {
-		return r.PublicCodeUrl != ""
-	})
+	count := countRepos(t, repos, p, "publiccode")
 	assert.Equal(t, 2, count)
 }
@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"gorm.io/gorm"
+)
+
+// SaveJobRun creates or updates a job run.
+func (r *repositoriesRepository) SaveJobRun(ctx context.Context, run *models.JobRun) error {
+	return r.db.WithContext(ctx).Save(run).Error
+}
+
+// GetLatestJobRun returns the most recently started run of the job, or nil
+// when it never ran.
+func (r *repositoriesRepository) GetLatestJobRun(ctx context.Context, jobName string) (*models.JobRun, error) {
+	var run models.JobRun
+	err := r.db.WithContext(ctx).
+		Where("job_name = ?", jobName).
+		Order("started_at DESC").
+		Order("id").
+		First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// FailRunningJobRuns marks the runs of the job that are still running as
+// failed with reason and returns how many there were.
+func (r *repositoriesRepository) FailRunningJobRuns(ctx context.Context, jobName string, finishedAt time.Time, reason string) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.JobRun{}).
+		Where("job_name = ? AND status = ?", jobName, models.JobRunRunning).
+		Updates(map[string]any{
+			"status":      models.JobRunFailed,
+			"finished_at": finishedAt,
+			"error":       reason,
+		})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// localLocks stands in for advisory locks on databases without them, such as
+// the SQLite used in tests. It only covers this process.
+var localLocks = struct {
+	sync.Mutex
+	held map[string]bool
+}{held: map[string]bool{}}
+
+// TryLock takes the lock called name for every process that shares the
+// database and reports whether it got it; unlock releases it. On Postgres it
+// is a session advisory lock on a dedicated connection, so a process that
+// dies releases its locks with its connection.
+func (r *repositoriesRepository) TryLock(ctx context.Context, name string) (unlock func(), ok bool, err error) {
+	if r.db.Dialector.Name() != "postgres" {
+		localLocks.Lock()
+		defer localLocks.Unlock()
+		if localLocks.held[name] {
+			return nil, false, nil
+		}
+		localLocks.held[name] = true
+		return func() {
+			localLocks.Lock()
+			defer localLocks.Unlock()
+			delete(localLocks.held, name)
+		}, true, nil
+	}
+
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", name).Scan(&ok); err != nil || !ok {
+		_ = conn.Close()
+		return nil, false, err
+	}
+	return func() {
+		// The caller's context may be done by now; the lock must still go.
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", name); err != nil {
+			log.Printf("[lock] release %s: %v", name, err)
+		}
+		if err := conn.Close(); err != nil {
+			log.Printf("[lock] close connection of %s: %v", name, err)
+		}
+	}, true, nil
+}
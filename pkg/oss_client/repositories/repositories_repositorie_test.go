@@ -2,9 +2,12 @@ package repositories_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	util "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/util"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/repositories"
 	"github.com/stretchr/testify/assert"
@@ -17,7 +20,7 @@ func setupDB(t *testing.T) *gorm.DB {
 	t.Helper()
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&models.Organisation{}, &models.Repository{}, &models.GitOrganisatie{}))
+	require.NoError(t, db.AutoMigrate(&models.Organisation{}, &models.Repository{}, &models.GitOrganisatie{}, &models.RepositoryRevision{}, &models.AuditEvent{}, &models.SearchIndexOperation{}, &models.JobRun{}))
 	return db
 }
 
@@ -49,6 +52,248 @@ func TestRepositoriesRepository_SaveAndRetrieve(t *testing.T) {
 	assert.Equal(t, "Org 1", got.Organisation.Label)
 }
 
+func TestRepositoriesRepository_GetRepositoriesByIDsKeepsOrderAndSkipsDeleted(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	org := &models.Organisation{Uri: "org-1", Label: "Org 1"}
+	require.NoError(t, repo.SaveOrganisatie(org))
+	for _, id := range []string{"repo-1", "repo-2", "repo-3"} {
+		require.NoError(t, repo.SaveRepository(ctx, &models.Repository{
+			Id:             id,
+			Name:           id,
+			OrganisationID: &org.Uri,
+			Url:            "https://example.org/repos/" + id,
+			Active:         true,
+		}))
+	}
+	require.NoError(t, repo.DeleteRepository(ctx, "repo-2"))
+
+	got, err := repo.GetRepositoriesByIDs(ctx, []string{"repo-3", "missing", "repo-2", "repo-1"})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "repo-3", got[0].Id)
+	assert.Equal(t, "repo-1", got[1].Id)
+	require.NotNil(t, got[0].Organisation)
+	assert.Equal(t, "Org 1", got[0].Organisation.Label)
+
+	got, err = repo.GetRepositoriesByIDs(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestRepositoriesRepository_WritesSearchIndexOperationsWithRepository(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	repository := &models.Repository{Id: "repo-1", Name: "Repo One", Url: "https://example.org/repos/repo-1", Active: true}
+	require.NoError(t, repo.SaveRepository(ctx, repository))
+	repository.Name = "Repo One renamed"
+	require.NoError(t, repo.SaveRepository(ctx, repository))
+
+	due, err := repo.ClaimDueSearchIndexOperations(ctx, time.Now().Add(time.Second), 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, due, 1, "a pending operation is reused")
+	assert.Equal(t, "repo-1", due[0].RepositoryID)
+	assert.Equal(t, models.SearchIndexOperationPending, due[0].Status)
+	claimed, err := repo.ClaimDueSearchIndexOperations(ctx, time.Now().Add(time.Second), 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, claimed, "a claimed operation is not handed out twice")
+
+	due[0].Attempts = 1
+	due[0].NextAttemptAt = time.Now().UTC().Add(time.Hour)
+	due[0].LastError = "typesense unavailable"
+	require.NoError(t, repo.SaveSearchIndexOperation(ctx, &due[0]))
+	later, err := repo.ClaimDueSearchIndexOperations(ctx, time.Now().Add(2*time.Minute), 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, later)
+
+	require.NoError(t, repo.DeleteRepository(ctx, "repo-1"))
+	due, err = repo.ClaimDueSearchIndexOperations(ctx, time.Now().Add(time.Second), 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, due, 1, "deleting makes the pending operation due again")
+	assert.Equal(t, 1, due[0].Attempts)
+
+	require.NoError(t, repo.CompleteSearchIndexOperation(ctx, &due[0]))
+	operations, pagination, err := repo.GetSearchIndexOperations(ctx, 1, 10, "")
+	require.NoError(t, err)
+	assert.Empty(t, operations)
+	assert.Equal(t, 0, pagination.TotalRecords)
+}
+
+func TestRepositoriesRepository_EnqueueOrganisationSearchIndexOperations(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	org := &models.Organisation{Uri: "https://example.org/org", Label: "Org"}
+	require.NoError(t, repo.SaveOrganisatie(org))
+	for _, repository := range []*models.Repository{
+		{Id: "repo-1", Url: "https://example.org/repos/repo-1", OrganisationID: &org.Uri, Active: true},
+		{Id: "repo-2", Url: "https://example.org/repos/repo-2", OrganisationID: &org.Uri, Active: false},
+		{Id: "repo-3", Url: "https://example.org/repos/repo-3", OrganisationID: &org.Uri, Active: true},
+		{Id: "repo-4", Url: "https://example.org/repos/repo-4", Active: true},
+	} {
+		require.NoError(t, repo.SaveRepository(ctx, repository))
+	}
+	require.NoError(t, repo.DeleteRepository(ctx, "repo-3"))
+	_, err := repo.ClaimDueSearchIndexOperations(ctx, time.Now().Add(time.Second), 10, time.Minute)
+	require.NoError(t, err)
+
+	var enqueued int
+	require.NoError(t, repo.Transaction(ctx, func(tx repositories.RepositoriesRepository) error {
+		org.Label = "Org renamed"
+		if err := tx.SaveOrganisatie(org); err != nil {
+			return err
+		}
+		enqueued, err = tx.EnqueueOrganisationSearchIndexOperations(ctx, org.Uri)
+		return err
+	}))
+	assert.Equal(t, 1, enqueued)
+
+	operations, _, err := repo.GetSearchIndexOperations(ctx, 1, 10, models.SearchIndexOperationPending)
+	require.NoError(t, err)
+	pending := 0
+	for _, operation := range operations {
+		if operation.RepositoryID == "repo-1" && !operation.NextAttemptAt.After(time.Now()) {
+			pending++
+		}
+	}
+	assert.Equal(t, 1, pending, "the active repository of the organisation is due again")
+}
+
+func TestRepositoriesRepository_KeepsSearchIndexOperationsWrittenDuringHandling(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	repository := &models.Repository{Id: "repo-1", Name: "Repo One", Url: "https://example.org/repos/repo-1", Active: true}
+	require.NoError(t, repo.SaveRepository(ctx, repository))
+
+	due, err := repo.ClaimDueSearchIndexOperations(ctx, time.Now(), 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	repository.Name = "Repo One renamed"
+	require.NoError(t, repo.SaveRepository(ctx, repository))
+
+	require.NoError(t, repo.CompleteSearchIndexOperation(ctx, &due[0]))
+	due[0].Attempts = 1
+	due[0].NextAttemptAt = time.Now().UTC().Add(time.Hour)
+	require.NoError(t, repo.SaveSearchIndexOperation(ctx, &due[0]))
+
+	again, err := repo.ClaimDueSearchIndexOperations(ctx, time.Now().Add(time.Second), 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, again, 1, "the write made while handling stays due")
+	assert.Equal(t, due[0].Id, again[0].Id)
+	assert.Equal(t, 0, again[0].Attempts)
+}
+
+func TestRepositoriesRepository_TryLock(t *testing.T) {
+	repo := repositories.NewRepositoriesRepository(setupDB(t))
+	ctx := context.Background()
+
+	unlock, ok, err := repo.TryLock(ctx, "reindex")
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, ok, err = repo.TryLock(ctx, "reindex")
+	require.NoError(t, err)
+	assert.False(t, ok, "a held lock is not handed out twice")
+	other, ok, err := repo.TryLock(ctx, "other")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	other()
+
+	unlock()
+	unlock, ok, err = repo.TryLock(ctx, "reindex")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	unlock()
+}
+
+func TestRepositoriesRepository_SaveRepositoryRollsBackWithoutOutbox(t *testing.T) {
+	db := setupDB(t)
+	require.NoError(t, db.Migrator().DropTable(&models.SearchIndexOperation{}))
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	err := repo.SaveRepository(ctx, &models.Repository{Id: "repo-1", Url: "https://example.org/repos/repo-1", Active: true})
+	require.Error(t, err)
+
+	got, err := repo.GetRepositoryByID(ctx, "repo-1")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestRepositoriesRepository_GetSearchIndexOperationsFiltersOnStatus(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, status := range []string{models.SearchIndexOperationPending, models.SearchIndexOperationFailed, models.SearchIndexOperationPending} {
+		require.NoError(t, db.Create(&models.SearchIndexOperation{
+			Id:           fmt.Sprintf("op-%d", i),
+			RepositoryID: fmt.Sprintf("repo-%d", i),
+			Status:       status,
+			CreatedAt:    created.Add(time.Duration(i) * time.Minute),
+		}).Error)
+	}
+
+	pending, pagination, err := repo.GetSearchIndexOperations(ctx, 1, 10, models.SearchIndexOperationPending)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, "op-0", pending[0].Id)
+	assert.Equal(t, "op-2", pending[1].Id)
+	assert.Equal(t, 2, pagination.TotalRecords)
+
+	all, _, err := repo.GetSearchIndexOperations(ctx, 1, 10, "")
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+}
+
+func TestRepositoriesRepository_JobRuns(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	latest, err := repo.GetLatestJobRun(ctx, "repository-active")
+	require.NoError(t, err)
+	assert.Nil(t, latest)
+
+	started := time.Date(2026, 10, 17, 13, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.SaveJobRun(ctx, &models.JobRun{Id: "run-1", JobName: "repository-active", Status: models.JobRunSucceeded, StartedAt: started}))
+	require.NoError(t, repo.SaveJobRun(ctx, &models.JobRun{Id: "run-2", JobName: "repository-crawl", Status: models.JobRunSucceeded, StartedAt: started.Add(time.Hour)}))
+	run := &models.JobRun{Id: "run-3", JobName: "repository-active", Status: models.JobRunRunning, StartedAt: started.Add(24 * time.Hour)}
+	require.NoError(t, repo.SaveJobRun(ctx, run))
+
+	finished := run.StartedAt.Add(time.Second)
+	run.Status = models.JobRunFailed
+	run.FinishedAt = &finished
+	run.Counters = map[string]int{"updated": 2}
+	run.Error = "database unavailable"
+	require.NoError(t, repo.SaveJobRun(ctx, run))
+
+	latest, err = repo.GetLatestJobRun(ctx, "repository-active")
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+	assert.Equal(t, "run-3", latest.Id)
+	assert.Equal(t, models.JobRunFailed, latest.Status)
+	assert.Equal(t, map[string]int{"updated": 2}, latest.Counters)
+	assert.Equal(t, "database unavailable", latest.Error)
+
+	require.NoError(t, repo.SaveJobRun(ctx, &models.JobRun{Id: "run-4", JobName: "repository-crawl", Status: models.JobRunRunning, StartedAt: started.Add(48 * time.Hour)}))
+	failed, err := repo.FailRunningJobRuns(ctx, "repository-crawl", started.Add(49*time.Hour), "interrupted")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), failed)
+	latest, err = repo.GetLatestJobRun(ctx, "repository-crawl")
+	require.NoError(t, err)
+	assert.Equal(t, models.JobRunFailed, latest.Status)
+	assert.Equal(t, "interrupted", latest.Error)
+	require.NotNil(t, latest.FinishedAt)
+}
+
 func TestRepositoriesRepository_SaveRepositoryUpdatesExistingByURL(t *testing.T) {
 	db := setupDB(t)
 	repo := repositories.NewRepositoriesRepository(db)
@@ -82,6 +327,32 @@ func TestRepositoriesRepository_SaveRepositoryUpdatesExistingByURL(t *testing.T)
 	assert.Equal(t, "Replacement", got.Name)
 }
 
+func TestRepositoriesRepository_FindRepositoryByNormalizedURL(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	org := &models.Organisation{Uri: "org-1", Label: "Org 1"}
+	require.NoError(t, repo.SaveOrganisatie(org))
+	for id, url := range map[string]string{
+		"repo-app":   "https://github.com/Acme/App",
+		"repo-other": "https://github.com/acme/app-other",
+	} {
+		require.NoError(t, repo.SaveRepository(ctx, &models.Repository{Id: id, OrganisationID: &org.Uri, Url: url, Active: true}))
+	}
+
+	for _, url := range []string{"https://github.com/Acme/App", "https://github.com/acme/app/", "http://GitHub.com/acme/app.git"} {
+		got, err := repo.FindRepositoryByNormalizedURL(ctx, url)
+		require.NoError(t, err)
+		require.NotNil(t, got, url)
+		assert.Equal(t, "repo-app", got.Id, url)
+	}
+
+	got, err := repo.FindRepositoryByNormalizedURL(ctx, "https://github.com/acme/unknown")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
 func TestRepositoriesRepository_GetRepositoriesOrganisationFilter(t *testing.T) {
 	db := setupDB(t)
 	repo := repositories.NewRepositoriesRepository(db)
@@ -311,6 +582,54 @@ func TestRepositoriesRepository_GetRepositoriesPaginatesFilteredResults(t *testi
 	assert.Equal(t, 3, pagination.TotalRecords)
 }
 
+func TestRepositoriesRepository_GetRepositoriesSorts(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	require.NoError(t, repo.SaveOrganisatie(&models.Organisation{Uri: "org-a", Label: "Amsterdam"}))
+	require.NoError(t, repo.SaveOrganisatie(&models.Organisation{Uri: "org-z", Label: "Zwolle"}))
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, r := range []struct {
+		id, name, org string
+		days          int
+	}{
+		{"repo-1", "Beta", "org-z", 2},
+		{"repo-2", "Alpha", "org-a", 1},
+		{"repo-3", "Gamma", "", 3},
+	} {
+		repository := &models.Repository{Id: r.id, Name: r.name, PublicCodeUrl: "https://example.org/" + r.id + "/publiccode.yml", Active: true}
+		if r.org != "" {
+			repository.OrganisationID = &r.org
+		}
+		require.NoError(t, repo.SaveRepository(ctx, repository))
+		day := base.AddDate(0, 0, r.days)
+		require.NoError(t, db.Model(&models.Repository{}).Where("id = ?", r.id).Updates(map[string]any{
+			"created_at":       day,
+			"last_activity_at": day,
+			"last_crawled_at":  base.AddDate(0, 0, -r.days),
+		}).Error)
+	}
+
+	for sort, expected := range map[string][]string{
+		"name":            {"Alpha", "Beta", "Gamma"},
+		"-name":           {"Gamma", "Beta", "Alpha"},
+		"-lastActivityAt": {"Gamma", "Beta", "Alpha"},
+		"createdAt":       {"Alpha", "Beta", "Gamma"},
+		"-lastCrawledAt":  {"Alpha", "Beta", "Gamma"},
+		"organisation":    {"Alpha", "Beta", "Gamma"},
+		"-organisation":   {"Beta", "Alpha", "Gamma"},
+	} {
+		results, _, err := repo.GetRepositorys(ctx, 1, 10, &models.RepositoryFiltersParams{Sort: sort})
+		require.NoError(t, err)
+		names := make([]string, len(results))
+		for i, result := range results {
+			names[i] = result.Name
+		}
+		assert.Equal(t, expected, names, sort)
+	}
+}
+
 func TestRepositoriesRepository_GetRepositoriesLastActivityAfterFilter(t *testing.T) {
 	db := setupDB(t)
 	repo := repositories.NewRepositoriesRepository(db)
@@ -515,7 +834,7 @@ func TestRepositoriesRepository_GetOrganisationsOrdersAndPaginates(t *testing.T)
 	require.NoError(t, repo.SaveOrganisatie(&models.Organisation{Uri: "org-a", Label: "Alpha"}))
 	require.NoError(t, repo.SaveOrganisatie(&models.Organisation{Uri: "org-c", Label: "Gamma"}))
 
-	results, pagination, err := repo.GetOrganisations(context.Background(), 1, 2)
+	results, pagination, err := repo.GetOrganisations(context.Background(), 1, 2, nil)
 	require.NoError(t, err)
 	require.Len(t, results, 2)
 	assert.Equal(t, []string{"Alpha", "Beta"}, []string{results[0].Label, results[1].Label})
@@ -525,6 +844,66 @@ func TestRepositoriesRepository_GetOrganisationsOrdersAndPaginates(t *testing.T)
 	assert.Equal(t, 2, *pagination.Next)
 }
 
+func TestRepositoriesRepository_GetOrganisationsFiltersAndSorts(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	orgs := []*models.Organisation{
+		{Uri: "https://example.org/alpha", Label: "Alpha"},
+		{Uri: "https://example.org/beta", Label: "Beta"},
+		{Uri: "https://example.org/gamma", Label: "Gamma 100%"},
+	}
+	for _, org := range orgs {
+		require.NoError(t, repo.SaveOrganisatie(org))
+	}
+	save := func(id string, org *models.Organisation, active bool) {
+		require.NoError(t, repo.SaveRepository(ctx, &models.Repository{
+			Id:             id,
+			OrganisationID: &org.Uri,
+			Url:            "https://example.org/repos/" + id,
+			Active:         active,
+		}))
+		if !active {
+			require.NoError(t, db.Model(&models.Repository{}).Where("id = ?", id).Update("active", false).Error)
+		}
+	}
+	save("beta-1", orgs[1], true)
+	save("beta-2", orgs[1], true)
+	save("alpha-1", orgs[0], true)
+	save("gamma-inactive", orgs[2], false)
+	save("alpha-deleted", orgs[0], true)
+	require.NoError(t, repo.DeleteRepository(ctx, "alpha-deleted"))
+
+	labels := func(p *models.OrganisationFilters) []string {
+		t.Helper()
+		results, _, err := repo.GetOrganisations(ctx, 1, 10, p)
+		require.NoError(t, err)
+		out := make([]string, len(results))
+		for i, org := range results {
+			out[i] = org.Label
+		}
+		return out
+	}
+
+	active := true
+	inactive := false
+	uri := orgs[1].Uri
+	assert.Equal(t, []string{"Gamma 100%", "Beta", "Alpha"}, labels(&models.OrganisationFilters{Sort: "-label"}))
+	assert.Equal(t, []string{"Beta", "Alpha", "Gamma 100%"}, labels(&models.OrganisationFilters{Sort: "-repositoryCount"}))
+	assert.Equal(t, []string{"Gamma 100%", "Alpha", "Beta"}, labels(&models.OrganisationFilters{Sort: "repositoryCount"}))
+	assert.Equal(t, []string{"Alpha", "Beta"}, labels(&models.OrganisationFilters{HasActiveRepositories: &active}))
+	assert.Equal(t, []string{"Gamma 100%"}, labels(&models.OrganisationFilters{HasActiveRepositories: &inactive}))
+	assert.Equal(t, []string{"Beta"}, labels(&models.OrganisationFilters{Query: "BET"}))
+	assert.Equal(t, []string{"Gamma 100%"}, labels(&models.OrganisationFilters{Query: "100%"}))
+	assert.Equal(t, []string{"Alpha"}, labels(&models.OrganisationFilters{Query: "example.org/alp"}))
+	assert.Equal(t, []string{"Beta"}, labels(&models.OrganisationFilters{Organisation: &uri}))
+
+	_, pagination, err := repo.GetOrganisations(ctx, 1, 1, &models.OrganisationFilters{HasActiveRepositories: &active})
+	require.NoError(t, err)
+	assert.Equal(t, 2, pagination.TotalRecords)
+}
+
 func TestRepositoriesRepository_GitOrganisationsCRUD(t *testing.T) {
 	db := setupDB(t)
 	repo := repositories.NewRepositoriesRepository(db)
@@ -548,11 +927,92 @@ func TestRepositoriesRepository_GitOrganisationsCRUD(t *testing.T) {
 	require.NoError(t, err)
 	assert.Nil(t, missing)
 
-	results, pagination, err := repo.GetGitOrganisations(ctx, 1, 10, &org2.Uri)
+	results, pagination, err := repo.GetGitOrganisations(ctx, 1, 10, &org2.Uri, nil)
 	require.NoError(t, err)
 	require.Len(t, results, 1)
 	assert.Equal(t, "git-2", results[0].Id)
 	assert.Equal(t, 1, pagination.TotalRecords)
+
+	require.NoError(t, repo.SaveGitOrganisatie(ctx, &models.GitOrganisatie{Id: "git-3", OrganisationID: &org1.Uri, Url: "https://gitlab.com/org-1"}))
+	onHost, err := repo.GetGitOrganisationsByHost(ctx, "github.com")
+	require.NoError(t, err)
+	require.Len(t, onHost, 2)
+	assert.Equal(t, "git-1", onHost[0].Id)
+	assert.Equal(t, "git-2", onHost[1].Id)
+}
+
+func TestRepositoriesRepository_GitOrganisationDetailDeleteAndRepositories(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	org := &models.Organisation{Uri: "org-1", Label: "Org 1"}
+	require.NoError(t, repo.SaveOrganisatie(org))
+	require.NoError(t, repo.SaveGitOrganisatie(ctx, &models.GitOrganisatie{Id: "git-1", OrganisationID: &org.Uri, Url: "https://github.com/Org-1"}))
+
+	found, err := repo.GetGitOrganisationByID(ctx, "git-1")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	require.NotNil(t, found.Organisation)
+	assert.Equal(t, "Org 1", found.Organisation.Label)
+
+	for id, url := range map[string]string{
+		"repo-a":       "https://github.com/org-1/a",
+		"repo-b":       "https://github.com/ORG-1/b.git",
+		"repo-other":   "https://github.com/org-10/c",
+		"repo-gitlab":  "https://gitlab.com/org-1/d",
+		"repo-deleted": "https://github.com/org-1/deleted",
+		"repo-nested":  "https://example.org/mirror/https://github.com/org-1/e",
+	} {
+		require.NoError(t, repo.SaveRepository(ctx, &models.Repository{Id: id, Name: id, OrganisationID: &org.Uri, Url: url}))
+	}
+	require.NoError(t, repo.DeleteRepository(ctx, "repo-deleted"))
+
+	results, pagination, err := repo.GetGitOrganisationRepositories(ctx, found.Url, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, 2, pagination.TotalRecords)
+
+	results, _, err = repo.GetGitOrganisationRepositories(ctx, found.Url, 1, 10)
+	require.NoError(t, err)
+	ids := []string{results[0].Id, results[1].Id}
+	assert.ElementsMatch(t, []string{"repo-a", "repo-b"}, ids)
+
+	results, _, err = repo.GetGitOrganisationRepositories(ctx, found.Url, 3, 10)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	require.NoError(t, repo.DeleteGitOrganisation(ctx, "git-1"))
+	found, err = repo.GetGitOrganisationByID(ctx, "git-1")
+	require.NoError(t, err)
+	assert.Nil(t, found)
+}
+
+func TestRepositoriesRepository_GetGitOrganisationsAfterCursor(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	for _, id := range []string{"git-3", "git-1", "git-2"} {
+		require.NoError(t, repo.SaveGitOrganisatie(ctx, &models.GitOrganisatie{Id: id, Url: "https://github.com/" + id}))
+	}
+
+	start := ""
+	results, pagination, err := repo.GetGitOrganisations(ctx, 1, 2, nil, &start)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "git-1", results[0].Id)
+	assert.Equal(t, "git-2", results[1].Id)
+	assert.Equal(t, 3, pagination.TotalRecords)
+	assert.True(t, pagination.HasMore)
+	assert.Nil(t, pagination.Next)
+
+	after := "git-2"
+	results, pagination, err = repo.GetGitOrganisations(ctx, 1, 2, nil, &after)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "git-3", results[0].Id)
+	assert.False(t, pagination.HasMore)
 }
 
 func TestRepositoriesRepository_GetRepositoryFilterCountsAppliesCrossFilters(t *testing.T) {
@@ -649,3 +1109,282 @@ func TestRepositoriesRepository_GetRepositoryFilterCountsAppliesCrossFilters(t *
 	}
 	assert.Equal(t, map[string]int{"org-1": 1}, orgCounts)
 }
+
+func TestRepositoriesRepository_DeleteRepositoryKeepsTombstone(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	org := &models.Organisation{Uri: "org-1", Label: "Org 1"}
+	require.NoError(t, repo.SaveOrganisatie(org))
+	require.NoError(t, repo.SaveRepository(ctx, &models.Repository{
+		Id:             "repo-deleted",
+		Name:           "Deleted Repo",
+		OrganisationID: &org.Uri,
+		Url:            "https://example.org/repos/deleted",
+		PublicCodeUrl:  "https://example.org/repos/deleted/publiccode.yml",
+		Active:         true,
+	}))
+	require.NoError(t, repo.SaveRepository(ctx, &models.Repository{
+		Id:             "repo-kept",
+		Name:           "Kept Repo",
+		OrganisationID: &org.Uri,
+		Url:            "https://example.org/repos/kept",
+		PublicCodeUrl:  "https://example.org/repos/kept/publiccode.yml",
+		Active:         true,
+	}))
+
+	require.NoError(t, repo.DeleteRepository(ctx, "repo-deleted"))
+
+	got, err := repo.GetRepositoryByID(ctx, "repo-deleted")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	require.NotNil(t, got.DeletedAt)
+	assert.False(t, got.Active)
+
+	list, pagination, err := repo.GetRepositorys(ctx, 1, 10, nil)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "repo-kept", list[0].Id)
+	assert.Equal(t, 1, pagination.TotalRecords)
+
+	search, _, err := repo.SearchRepositorys(ctx, 1, 10, nil, "Repo")
+	require.NoError(t, err)
+	require.Len(t, search, 1)
+	assert.Equal(t, "repo-kept", search[0].Id)
+
+	all, err := repo.AllRepositorys(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+
+	counts, err := repo.GetRepositoryFilterCounts(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, counts.PublicCode)
+
+	require.NoError(t, repo.SaveRepository(ctx, &models.Repository{
+		Name:   "Restored Repo",
+		Url:    "https://example.org/repos/deleted",
+		Active: true,
+	}))
+	restored, err := repo.GetRepositoryByID(ctx, "repo-deleted")
+	require.NoError(t, err)
+	require.NotNil(t, restored)
+	assert.Nil(t, restored.DeletedAt)
+}
+
+func TestRepositoriesRepository_SaveRepositoryDetectsVersionConflict(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	require.NoError(t, repo.SaveRepository(ctx, &models.Repository{
+		Id:     "repo-1",
+		Name:   "Original",
+		Url:    "https://example.org/repos/repo-1",
+		Active: true,
+	}))
+
+	first, err := repo.GetRepositoryByID(ctx, "repo-1")
+	require.NoError(t, err)
+	require.Equal(t, 1, first.Version)
+	second, err := repo.GetRepositoryByID(ctx, "repo-1")
+	require.NoError(t, err)
+
+	first.Name = "First writer"
+	require.NoError(t, repo.SaveRepository(ctx, first))
+	assert.Equal(t, 2, first.Version)
+
+	second.Name = "Second writer"
+	err = repo.SaveRepository(ctx, second)
+	require.ErrorIs(t, err, repositories.ErrVersionConflict)
+	assert.Equal(t, 1, second.Version)
+
+	got, err := repo.GetRepositoryByID(ctx, "repo-1")
+	require.NoError(t, err)
+	assert.Equal(t, "First writer", got.Name)
+	assert.Equal(t, 2, got.Version)
+
+	require.NoError(t, repo.SaveRepository(ctx, &models.Repository{
+		Name: "Unconditional",
+		Url:  "https://example.org/repos/repo-1",
+	}))
+	got, err = repo.GetRepositoryByID(ctx, "repo-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Unconditional", got.Name)
+	assert.Equal(t, 3, got.Version)
+}
+
+func TestRepositoriesRepository_SaveRepositoryRecordsRevisions(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	require.NoError(t, repo.SaveRepository(ctx, &models.Repository{
+		Id:   "repo-1",
+		Name: "Repo One",
+		Url:  "https://example.org/repos/repo-1",
+		PublicCode: &models.PublicCode{
+			DevelopmentStatus: "beta",
+			Legal:             &models.PublicCodeLegal{License: "EUPL-1.2"},
+		},
+		Active: true,
+	}))
+
+	stored, err := repo.GetRepositoryByID(ctx, "repo-1")
+	require.NoError(t, err)
+	stored.LastCrawledAt = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.SaveRepository(ctx, stored))
+
+	stored, err = repo.GetRepositoryByID(ctx, "repo-1")
+	require.NoError(t, err)
+	stored.PublicCode.DevelopmentStatus = "stable"
+	stored.PublicCode.Legal.License = "MIT"
+	require.NoError(t, repo.SaveRepository(util.WithActor(ctx, "editor"), stored))
+
+	revisions, pagination, err := repo.GetRepositoryRevisions(ctx, "repo-1", 1, 10)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, 2, pagination.TotalRecords)
+
+	latest := revisions[0]
+	assert.Equal(t, "editor", latest.Actor)
+	assert.Equal(t, 3, latest.Version)
+	assert.Equal(t, []models.RepositoryFieldChange{
+		{Field: "publicCode.developmentStatus", OldValue: "beta", NewValue: "stable"},
+		{Field: "publicCode.legal.license", OldValue: "EUPL-1.2", NewValue: "MIT"},
+	}, latest.Changes)
+
+	created := revisions[1]
+	assert.Equal(t, util.ActorSystem, created.Actor)
+	assert.Equal(t, 1, created.Version)
+	fields := make([]string, 0, len(created.Changes))
+	for _, change := range created.Changes {
+		assert.Nil(t, change.OldValue)
+		fields = append(fields, change.Field)
+	}
+	assert.Contains(t, fields, "name")
+	assert.Contains(t, fields, "publicCode.legal.license")
+
+	require.NoError(t, repo.DeleteRepository(ctx, "repo-1"))
+	revisions, _, err = repo.GetRepositoryRevisions(ctx, "repo-1", 1, 1)
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	fields = fields[:0]
+	for _, change := range revisions[0].Changes {
+		fields = append(fields, change.Field)
+	}
+	assert.Equal(t, []string{"active", "deletedAt"}, fields)
+}
+
+func TestRepositoriesRepository_GetAuditEventsFiltersAndOrders(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i, event := range []models.AuditEvent{
+		{Id: "a", Actor: "gemeente-a", ResourceType: "repositories", ResourceId: "repo-1", Status: 201},
+		{Id: "b", Actor: "gemeente-a", ResourceType: "repositories", ResourceId: "repo-1", Status: 200},
+		{Id: "c", Actor: "beheer", ResourceType: "organisations", ResourceId: "org-1", Status: 204},
+	} {
+		event.OccurredAt = base.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, repo.SaveAuditEvent(ctx, &event))
+	}
+
+	events, pagination, err := repo.GetAuditEvents(ctx, 1, 10, models.AuditEventFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, pagination.TotalRecords)
+	require.Len(t, events, 3)
+	assert.Equal(t, []string{"c", "b", "a"}, []string{events[0].Id, events[1].Id, events[2].Id})
+
+	events, _, err = repo.GetAuditEvents(ctx, 1, 10, models.AuditEventFilter{Actor: "gemeente-a", ResourceId: "repo-1"})
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	from, to := base.Add(time.Hour), base.Add(2*time.Hour)
+	events, _, err = repo.GetAuditEvents(ctx, 1, 10, models.AuditEventFilter{From: &from, To: &to})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "b", events[0].Id)
+
+	events, _, err = repo.GetAuditEvents(ctx, 1, 10, models.AuditEventFilter{ResourceType: "organisations"})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "c", events[0].Id)
+}
+
+func TestRepositoriesRepository_TransactionRollsBackOnError(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	org := &models.Organisation{Uri: "org-1", Label: "Org 1"}
+	require.NoError(t, repo.SaveOrganisatie(org))
+
+	errAbort := errors.New("abort")
+	err := repo.Transaction(ctx, func(tx repositories.RepositoriesRepository) error {
+		require.NoError(t, tx.SaveRepository(ctx, &models.Repository{
+			Id:             "repo-rolled-back",
+			OrganisationID: &org.Uri,
+			Url:            "https://example.org/repos/rolled-back",
+		}))
+		return errAbort
+	})
+	require.ErrorIs(t, err, errAbort)
+
+	got, err := repo.FindRepositoryByURL(ctx, "https://example.org/repos/rolled-back")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	require.NoError(t, repo.Transaction(ctx, func(tx repositories.RepositoriesRepository) error {
+		return tx.SaveRepository(ctx, &models.Repository{
+			Id:             "repo-committed",
+			OrganisationID: &org.Uri,
+			Url:            "https://example.org/repos/committed",
+		})
+	}))
+
+	got, err = repo.FindRepositoryByURL(ctx, "https://example.org/repos/committed")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "repo-committed", got.Id)
+	require.NotNil(t, got.Organisation)
+	assert.Equal(t, "Org 1", got.Organisation.Label)
+}
+
+func TestRepositoriesRepository_CountOrganisationReferencesAndDelete(t *testing.T) {
+	db := setupDB(t)
+	repo := repositories.NewRepositoriesRepository(db)
+	ctx := context.Background()
+
+	org := &models.Organisation{Uri: "https://example.org/org", Label: "Org"}
+	empty := &models.Organisation{Uri: "https://example.org/empty", Label: "Empty"}
+	require.NoError(t, repo.SaveOrganisatie(org))
+	require.NoError(t, repo.SaveOrganisatie(empty))
+	for _, id := range []string{"repo-1", "repo-2"} {
+		require.NoError(t, repo.SaveRepository(ctx, &models.Repository{
+			Id:             id,
+			OrganisationID: &org.Uri,
+			Url:            "https://example.org/repos/" + id,
+		}))
+	}
+	require.NoError(t, repo.DeleteRepository(ctx, "repo-2"))
+	require.NoError(t, repo.SaveGitOrganisatie(ctx, &models.GitOrganisatie{
+		Id:             "git-1",
+		OrganisationID: &org.Uri,
+		Url:            "https://github.com/example",
+	}))
+
+	refs, err := repo.CountOrganisationReferences(ctx, org.Uri)
+	require.NoError(t, err)
+	assert.Equal(t, &models.OrganisationReferences{Repositories: 1, DeletedRepositories: 1, GitOrganisations: 1}, refs)
+
+	refs, err = repo.CountOrganisationReferences(ctx, empty.Uri)
+	require.NoError(t, err)
+	assert.Equal(t, &models.OrganisationReferences{}, refs)
+
+	require.NoError(t, repo.DeleteOrganisation(ctx, empty.Uri))
+	found, err := repo.FindOrganisationByURI(ctx, empty.Uri)
+	require.NoError(t, err)
+	assert.Nil(t, found)
+}
@@ -9,71 +9,104 @@ import (
 	"strings"
 	"time"
 
+	util "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/util"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
-	commonpagination "github.com/developer-overheid-nl/don-register-common/pagination"
 	commonquery "github.com/developer-overheid-nl/don-register-common/query"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrVersionConflict is returned when a repository was modified by another
+// writer between reading and saving it.
+var ErrVersionConflict = errors.New("repository version conflict")
+
 type RepositoriesRepository interface {
 	GetRepositorys(ctx context.Context, page, perPage int, p *models.RepositoryFiltersParams) ([]models.Repository, models.Pagination, error)
+	GetRepositoriesByIDs(ctx context.Context, ids []string) ([]models.Repository, error)
 	GetRepositoryByID(ctx context.Context, oasUrl string) (*models.Repository, error)
+	FindRepositoryByURL(ctx context.Context, url string) (*models.Repository, error)
+	FindRepositoryByNormalizedURL(ctx context.Context, url string) (*models.Repository, error)
 	SaveRepository(ctx context.Context, repository *models.Repository) error
+	DeleteRepository(ctx context.Context, id string) error
+	GetRepositoryRevisions(ctx context.Context, repositoryID string, page, perPage int) ([]models.RepositoryRevision, models.Pagination, error)
 	SearchRepositorys(ctx context.Context, page, perPage int, organisation *string, query string) ([]models.Repository, models.Pagination, error)
 	SaveOrganisatie(organisation *models.Organisation) error
 	AllRepositorys(ctx context.Context) ([]models.Repository, error)
-	GetOrganisations(ctx context.Context, page, perPage int) ([]models.Organisation, models.Pagination, error)
+	GetOrganisations(ctx context.Context, page, perPage int, p *models.OrganisationFilters) ([]models.Organisation, models.Pagination, error)
 	FindOrganisationByURI(ctx context.Context, uri string) (*models.Organisation, error)
-	GetGitOrganisations(ctx context.Context, page, perPage int, organisation *string) ([]models.GitOrganisatie, models.Pagination, error)
+	CountOrganisationReferences(ctx context.Context, uri string) (*models.OrganisationReferences, error)
+	DeleteOrganisation(ctx context.Context, uri string) error
+	GetGitOrganisations(ctx context.Context, page, perPage int, organisation, after *string) ([]models.GitOrganisatie, models.Pagination, error)
 	FindGitOrganisationByURL(ctx context.Context, url string) (*models.GitOrganisatie, error)
+	GetGitOrganisationsByHost(ctx context.Context, host string) ([]models.GitOrganisatie, error)
+	GetGitOrganisationByID(ctx context.Context, id string) (*models.GitOrganisatie, error)
+	DeleteGitOrganisation(ctx context.Context, id string) error
+	GetGitOrganisationRepositories(ctx context.Context, gitOrganisationURL string, page, perPage int) ([]models.Repository, models.Pagination, error)
 	SaveGitOrganisatie(ctx context.Context, gitOrg *models.GitOrganisatie) error
 	GetRepositoryFilterCounts(ctx context.Context, p *models.RepositoryFiltersParams) (*models.RepositoryFilterCounts, error)
+	SaveAuditEvent(ctx context.Context, event *models.AuditEvent) error
+	ClaimDueSearchIndexOperations(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]models.SearchIndexOperation, error)
+	SaveSearchIndexOperation(ctx context.Context, operation *models.SearchIndexOperation) error
+	CompleteSearchIndexOperation(ctx context.Context, operation *models.SearchIndexOperation) error
+	GetSearchIndexOperations(ctx context.Context, page, perPage int, status string) ([]models.SearchIndexOperation, models.Pagination, error)
+	EnqueueOrganisationSearchIndexOperations(ctx context.Context, uri string) (int, error)
+	GetAuditEvents(ctx context.Context, page, perPage int, filter models.AuditEventFilter) ([]models.AuditEvent, models.Pagination, error)
+	SaveJobRun(ctx context.Context, run *models.JobRun) error
+	GetLatestJobRun(ctx context.Context, jobName string) (*models.JobRun, error)
+	FailRunningJobRuns(ctx context.Context, jobName string, finishedAt time.Time, reason string) (int64, error)
+	TryLock(ctx context.Context, name string) (unlock func(), ok bool, err error)
+	Transaction(ctx context.Context, fn func(repo RepositoriesRepository) error) error
 }
 
 type repositoriesRepository struct {
 	db *gorm.DB
 }
 
-type repositoryFilterMatcher struct {
-	params            *models.RepositoryFiltersParams
-	organisation      string
-	query             string
-	lastActivityAfter *time.Time
-}
-
 func NewRepositoriesRepository(db *gorm.DB) RepositoriesRepository {
 	return &repositoriesRepository{db: db}
 }
 
+// SaveRepository creates or updates a repository and records a revision with
+// the changed fields and a search index operation in the same transaction.
 func (r *repositoriesRepository) SaveRepository(ctx context.Context, repository *models.Repository) error {
-	var existing models.Repository
-	found := false
-	if repository.Id != "" {
-		if err := r.db.WithContext(ctx).Where("id = ?", repository.Id).First(&existing).Error; err != nil {
-			if !errors.Is(err, gorm.ErrRecordNotFound) {
-				return err
+	expected := repository.Version
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.Repository
+		found := false
+		if repository.Id != "" {
+			if err := tx.Where("id = ?", repository.Id).First(&existing).Error; err != nil {
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return err
+				}
+			} else {
+				found = true
 			}
-		} else {
-			found = true
 		}
-	}
 
-	if !found && repository.Url != "" {
-		err := r.db.WithContext(ctx).Where("repository_url = ?", repository.Url).First(&existing).Error
-		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return err
-		}
-		if err == nil {
-			log.Printf("SaveRepository: found existing repository for url %q with id %s", repository.Url, existing.Id)
-			found = true
+		if !found && repository.Url != "" {
+			err := tx.Where("repository_url = ?", repository.Url).First(&existing).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if err == nil {
+				log.Printf("SaveRepository: found existing repository for url %q with id %s", repository.Url, existing.Id)
+				found = true
+			}
 		}
-	}
 
-	// if found && repository.Id != existing.Id {
-	// 	return problem.NewBadRequest("Repository already exists; use PUT instead of POST")
-	// }
+		if !found {
+			if repository.Version == 0 {
+				repository.Version = 1
+			}
+			if err := tx.Create(repository).Error; err != nil {
+				return err
+			}
+			if err := enqueueSearchIndexOperation(tx, repository.Id); err != nil {
+				return err
+			}
+			return recordRepositoryRevision(ctx, tx, nil, repository)
+		}
 
-	if found {
 		repository.Id = existing.Id
 		if repository.CreatedAt.IsZero() {
 			repository.CreatedAt = existing.CreatedAt
@@ -82,12 +115,69 @@ func (r *repositoriesRepository) SaveRepository(ctx context.Context, repository
 			repository.OrganisationID = existing.OrganisationID
 		}
 
-		return r.db.WithContext(ctx).Save(repository).Error
+		// Version 0 means the caller did not load the repository first; the
+		// write is then applied on top of whatever version is stored.
+		current := expected
+		if current == 0 {
+			current = existing.Version
+		}
+		repository.Version = current + 1
+
+		result := tx.Model(&models.Repository{}).
+			Where("id = ? AND version = ?", repository.Id, current).
+			Select("*").
+			Updates(repository)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		if err := enqueueSearchIndexOperation(tx, repository.Id); err != nil {
+			return err
+		}
+		return recordRepositoryRevision(ctx, tx, &existing, repository)
+	})
+	if err != nil {
+		repository.Version = expected
+		return err
 	}
+	return nil
+}
+
+// DeleteRepository soft-deletes a repository. The row is kept as a tombstone so
+// that lookups by id can report the repository as gone.
+func (r *repositoriesRepository) DeleteRepository(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.Repository
+		if err := tx.Where("id = ?", id).First(&existing).Error; err != nil {
+			return err
+		}
+
+		deleted := existing
+		deletedAt := time.Now().UTC()
+		deleted.DeletedAt = &deletedAt
+		deleted.Active = false
+		deleted.Version = existing.Version + 1
 
-	return r.db.WithContext(ctx).Create(repository).Error
+		if err := tx.Model(&models.Repository{}).
+			Where("id = ?", id).
+			Updates(map[string]any{
+				"deleted_at": deletedAt,
+				"active":     false,
+				"version":    gorm.Expr("version + 1"),
+			}).Error; err != nil {
+			return err
+		}
+		if err := enqueueSearchIndexOperation(tx, id); err != nil {
+			return err
+		}
+		return recordRepositoryRevision(ctx, tx, &existing, &deleted)
+	})
 }
 
+// GetRepositorys returns a page of the active repositories that match p. All
+// filters are evaluated by the database.
 func (r *repositoriesRepository) GetRepositorys(ctx context.Context, page, perPage int, p *models.RepositoryFiltersParams) ([]models.Repository, models.Pagination, error) {
 	if page < 1 {
 		page = 1
@@ -95,42 +185,40 @@ func (r *repositoriesRepository) GetRepositorys(ctx context.Context, page, perPa
 	if perPage <= 0 {
 		perPage = 20
 	}
-	matcher, err := compileRepositoryFilters(p, true)
+	filter, err := compileRepositoryFilters(r.db, p)
 	if err != nil {
 		return nil, models.Pagination{}, err
 	}
 
-	query := applyArchivedRepositoryFilter(r.db.WithContext(ctx).Where("(active IS NULL OR active = ?)", true), p)
-
-	var repositories []models.Repository
-	if err := applyRepositoryOrdering(query).Preload("Organisation").Find(&repositories).Error; err != nil {
+	totalRecords, err := countRepositories(filter.apply(r.db.WithContext(ctx).Model(&models.Repository{}), ""))
+	if err != nil {
 		return nil, models.Pagination{}, err
 	}
-
-	filtered := make([]models.Repository, 0, len(repositories))
-	for _, repo := range repositories {
-		if repoMatchesCompiledFilters(repo, matcher, "") {
-			filtered = append(filtered, repo)
+	if filter.params.After != nil {
+		repositories := []models.Repository{}
+		if err := whereAfter(filter.apply(r.db.WithContext(ctx), ""), "repositories.id", *filter.params.After).
+			Preload("Organisation").
+			Limit(perPage + 1).
+			Find(&repositories).Error; err != nil {
+			return nil, models.Pagination{}, err
 		}
+		return cursorPage(repositories, perPage, totalRecords)
 	}
+	pagination := models.NewPagination(page, perPage, totalRecords)
 
-	totalRecords := len(filtered)
-	pagination := commonpagination.New(page, perPage, totalRecords)
-
-	offset := (page - 1) * perPage
-	if offset >= totalRecords {
-		return []models.Repository{}, pagination, nil
-	}
-
-	end := offset + perPage
-	if end > totalRecords {
-		end = totalRecords
+	repositories := []models.Repository{}
+	if err := orderRepositories(filter.apply(r.db.WithContext(ctx), ""), filter.params.Sort, filter.search).
+		Preload("Organisation").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&repositories).Error; err != nil {
+		return nil, models.Pagination{}, err
 	}
 
-	return filtered[offset:end], pagination, nil
+	return repositories, pagination, nil
 }
 
-func (r *repositoriesRepository) GetGitOrganisations(ctx context.Context, page, perPage int, organisation *string) ([]models.GitOrganisatie, models.Pagination, error) {
+func (r *repositoriesRepository) GetGitOrganisations(ctx context.Context, page, perPage int, organisation, after *string) ([]models.GitOrganisatie, models.Pagination, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -149,16 +237,100 @@ func (r *repositoriesRepository) GetGitOrganisations(ctx context.Context, page,
 		return nil, models.Pagination{}, err
 	}
 
+	if after != nil {
+		var gitOrganisations []models.GitOrganisatie
+		if err := whereAfter(db, "id", *after).Limit(perPage + 1).Preload("Organisation").Find(&gitOrganisations).Error; err != nil {
+			return nil, models.Pagination{}, err
+		}
+		return cursorPage(gitOrganisations, perPage, int(totalRecords))
+	}
+
 	var gitOrganisations []models.GitOrganisatie
 	if err := db.Limit(perPage).Preload("Organisation").Offset(offset).Find(&gitOrganisations).Error; err != nil {
 		return nil, models.Pagination{}, err
 	}
 
-	pagination := commonpagination.New(page, perPage, int(totalRecords))
+	pagination := models.NewPagination(page, perPage, int(totalRecords))
 
 	return gitOrganisations, pagination, nil
 }
 
+// whereAfter orders db on the unique column and keeps the rows after the
+// value after, or all rows when it is empty. Unlike an offset, the position
+// of a row does not move when rows are added or updated while a client pages
+// through the results.
+func whereAfter(db *gorm.DB, column, after string) *gorm.DB {
+	db = db.Order(column)
+	if after != "" {
+		db = db.Where(column+" > ?", after)
+	}
+	return db
+}
+
+// cursorPage trims items, fetched with a limit of perPage+1, to one page.
+// HasMore is set when items follow the page; page numbers do not apply.
+func cursorPage[T any](items []T, perPage, totalRecords int) ([]T, models.Pagination, error) {
+	pagination := models.NewPagination(1, perPage, totalRecords)
+	pagination.Next = nil
+	pagination.HasMore = len(items) > perPage
+	if pagination.HasMore {
+		items = items[:perPage]
+	}
+	return items, pagination, nil
+}
+
+func (r *repositoriesRepository) GetGitOrganisationByID(ctx context.Context, id string) (*models.GitOrganisatie, error) {
+	var gitOrg models.GitOrganisatie
+	if err := r.db.WithContext(ctx).Preload("Organisation").First(&gitOrg, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &gitOrg, nil
+}
+
+func (r *repositoriesRepository) DeleteGitOrganisation(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&models.GitOrganisatie{}).Error
+}
+
+// GetGitOrganisationRepositories returns a page of the repositories, inactive
+// ones included, whose http(s) url lies below the git organisation url,
+// compared case-insensitively.
+func (r *repositoriesRepository) GetGitOrganisationRepositories(ctx context.Context, gitOrganisationURL string, page, perPage int) ([]models.Repository, models.Pagination, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage <= 0 {
+		perPage = 20
+	}
+
+	prefixes := util.RepositoryURLPrefixes(gitOrganisationURL)
+	if prefixes == nil {
+		return []models.Repository{}, models.NewPagination(page, perPage, 0), nil
+	}
+	db := r.db.WithContext(ctx).
+		Model(&models.Repository{}).
+		Where("deleted_at IS NULL").
+		Where("(LOWER(repository_url) LIKE ? ESCAPE '\\' OR LOWER(repository_url) LIKE ? ESCAPE '\\')",
+			commonquery.EscapeSQLLike(prefixes[0])+"%", commonquery.EscapeSQLLike(prefixes[1])+"%")
+
+	var totalRecords int64
+	if err := db.Count(&totalRecords).Error; err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	var repos []models.Repository
+	if err := applyRepositoryOrdering(db).
+		Preload("Organisation").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&repos).Error; err != nil {
+		return nil, models.Pagination{}, err
+	}
+	return repos, models.NewPagination(page, perPage, int(totalRecords)), nil
+}
+
 func (r *repositoriesRepository) GetRepositoryByID(ctx context.Context, id string) (*models.Repository, error) {
 	var api models.Repository
 	if err := r.db.WithContext(ctx).Preload("Organisation").First(&api, "id = ?", id).Error; err != nil {
@@ -170,6 +342,88 @@ func (r *repositoriesRepository) GetRepositoryByID(ctx context.Context, id strin
 	return &api, nil
 }
 
+// GetRepositoriesByIDs returns the active, not deleted repositories with the
+// given ids in the order of ids. Unknown ids are skipped.
+func (r *repositoriesRepository) GetRepositoriesByIDs(ctx context.Context, ids []string) ([]models.Repository, error) {
+	if len(ids) == 0 {
+		return []models.Repository{}, nil
+	}
+	var found []models.Repository
+	if err := r.db.WithContext(ctx).
+		Where("repositories.id IN ?", ids).
+		Where("repositories.deleted_at IS NULL").
+		Where("(repositories.active IS NULL OR repositories.active = ?)", true).
+		Preload("Organisation").
+		Find(&found).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[string]models.Repository, len(found))
+	for _, repository := range found {
+		byID[repository.Id] = repository
+	}
+	repositories := make([]models.Repository, 0, len(found))
+	for _, id := range ids {
+		if repository, ok := byID[id]; ok {
+			repositories = append(repositories, repository)
+			delete(byID, id)
+		}
+	}
+	return repositories, nil
+}
+
+// FindRepositoryByURL returns the repository stored for url, including tombstones.
+func (r *repositoriesRepository) FindRepositoryByURL(ctx context.Context, url string) (*models.Repository, error) {
+	var repository models.Repository
+	if err := r.db.WithContext(ctx).Preload("Organisation").First(&repository, "repository_url = ?", url).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &repository, nil
+}
+
+// FindRepositoryByNormalizedURL returns the repository, including tombstones,
+// whose url matches url when scheme, case, a trailing slash and .git are
+// ignored. An exact match is preferred.
+func (r *repositoriesRepository) FindRepositoryByNormalizedURL(ctx context.Context, url string) (*models.Repository, error) {
+	exact, err := r.FindRepositoryByURL(ctx, url)
+	if err != nil || exact != nil {
+		return exact, err
+	}
+
+	// The path of the url, without the trailing slash GitOrganisationPathPattern
+	// adds, is a coarse LIKE pre-filter before SameRepositoryURL is applied.
+	path := strings.TrimSuffix(util.GitOrganisationPathPattern(url), "/")
+	if path == "" {
+		return nil, nil
+	}
+	pattern := fmt.Sprintf("%%%s%%", commonquery.EscapeSQLLike(path))
+	var candidates []models.Repository
+	if err := r.db.WithContext(ctx).
+		Where("LOWER(repository_url) LIKE ? ESCAPE '\\'", pattern).
+		Preload("Organisation").
+		Order("deleted_at IS NOT NULL, created_at").
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	for i := range candidates {
+		if util.SameRepositoryURL(candidates[i].Url, url) {
+			return &candidates[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Transaction runs fn against a repository bound to a single database
+// transaction. Saves inside fn use savepoints, so a failing item can be rolled
+// back without aborting the whole transaction.
+func (r *repositoriesRepository) Transaction(ctx context.Context, fn func(repo RepositoriesRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&repositoriesRepository{db: tx})
+	})
+}
+
 func (r *repositoriesRepository) SearchRepositorys(ctx context.Context, page, perPage int, organisation *string, query string) ([]models.Repository, models.Pagination, error) {
 	trimmed := strings.TrimSpace(query)
 	if page < 1 {
@@ -179,19 +433,16 @@ func (r *repositoriesRepository) SearchRepositorys(ctx context.Context, page, pe
 		perPage = 20
 	}
 	if trimmed == "" {
-		return []models.Repository{}, models.Pagination{
-			CurrentPage:    page,
-			RecordsPerPage: perPage,
-		}, nil
+		return []models.Repository{}, models.NewPagination(page, perPage, 0), nil
 	}
 
-	pattern := fmt.Sprintf("%%%s%%", commonquery.EscapeSQLLike(strings.ToLower(trimmed)))
+	search := newTextSearch(r.db, trimmed)
 	applySearchFilters := func(db *gorm.DB) *gorm.DB {
-		db = db.Where("(active IS NULL OR active = ?)", true)
+		db = db.Where("deleted_at IS NULL").Where("(active IS NULL OR active = ?)", true)
 		if organisation != nil && strings.TrimSpace(*organisation) != "" {
 			db = db.Where("organisation_id = ?", strings.TrimSpace(*organisation))
 		}
-		return db.Where("(LOWER(name) LIKE ? ESCAPE '\\' OR LOWER(short_description) LIKE ? ESCAPE '\\' OR LOWER(long_description) LIKE ? ESCAPE '\\')", pattern, pattern, pattern)
+		return search.where(db)
 	}
 
 	var totalRecords int64
@@ -200,7 +451,7 @@ func (r *repositoriesRepository) SearchRepositorys(ctx context.Context, page, pe
 	}
 
 	var repositories []models.Repository
-	if err := applyRepositoryOrdering(applySearchFilters(r.db.WithContext(ctx))).
+	if err := search.order(applySearchFilters(r.db.WithContext(ctx))).
 		Preload("Organisation").
 		Offset((page - 1) * perPage).
 		Limit(perPage).
@@ -208,7 +459,7 @@ func (r *repositoriesRepository) SearchRepositorys(ctx context.Context, page, pe
 		return nil, models.Pagination{}, err
 	}
 
-	pagination := commonpagination.New(page, perPage, int(totalRecords))
+	pagination := models.NewPagination(page, perPage, int(totalRecords))
 
 	return repositories, pagination, nil
 }
@@ -219,32 +470,75 @@ func (r *repositoriesRepository) SaveOrganisatie(organisation *models.Organisati
 
 func (r *repositoriesRepository) AllRepositorys(ctx context.Context) ([]models.Repository, error) {
 	var repositories []models.Repository
-	if err := r.db.WithContext(ctx).Preload("Organisation").Find(&repositories).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("deleted_at IS NULL").Preload("Organisation").Find(&repositories).Error; err != nil {
 		return nil, err
 	}
 	return repositories, nil
 }
 
-func (r *repositoriesRepository) GetOrganisations(ctx context.Context, page, perPage int) ([]models.Organisation, models.Pagination, error) {
+// activeRepositoryCountSQL counts the active, not deleted repositories of the
+// organisation in the outer query.
+const activeRepositoryCountSQL = "(SELECT COUNT(*) FROM repositories WHERE repositories.organisation_id = organisations.uri " +
+	"AND repositories.deleted_at IS NULL AND (repositories.active IS NULL OR repositories.active = ?))"
+
+func (r *repositoriesRepository) GetOrganisations(ctx context.Context, page, perPage int, p *models.OrganisationFilters) ([]models.Organisation, models.Pagination, error) {
 	if page < 1 {
 		page = 1
 	}
 	if perPage <= 0 {
 		perPage = 20
 	}
+	if p == nil {
+		p = &models.OrganisationFilters{}
+	}
 	offset := (page - 1) * perPage
 
-	var organisations []models.Organisation
-	if err := r.db.WithContext(ctx).Order("label asc").Offset(offset).Limit(perPage).Find(&organisations).Error; err != nil {
-		return nil, models.Pagination{}, err
+	applyFilters := func(db *gorm.DB) *gorm.DB {
+		if p.Organisation != nil && strings.TrimSpace(*p.Organisation) != "" {
+			db = db.Where("uri = ?", strings.TrimSpace(*p.Organisation))
+		}
+		if trimmed := strings.TrimSpace(p.Query); trimmed != "" {
+			pattern := fmt.Sprintf("%%%s%%", commonquery.EscapeSQLLike(strings.ToLower(trimmed)))
+			db = db.Where("(LOWER(label) LIKE ? ESCAPE '\\' OR LOWER(uri) LIKE ? ESCAPE '\\')", pattern, pattern)
+		}
+		if p.HasActiveRepositories != nil {
+			if *p.HasActiveRepositories {
+				db = db.Where(activeRepositoryCountSQL+" > 0", true)
+			} else {
+				db = db.Where(activeRepositoryCountSQL+" = 0", true)
+			}
+		}
+		return db
 	}
 
 	var totalRecords int64
-	if err := r.db.Model(&models.Organisation{}).Count(&totalRecords).Error; err != nil {
+	if err := applyFilters(r.db.WithContext(ctx).Model(&models.Organisation{})).Count(&totalRecords).Error; err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	query := applyFilters(r.db.WithContext(ctx))
+	sortField, descending := strings.CutPrefix(p.Sort, "-")
+	direction := "ASC"
+	if descending {
+		direction = "DESC"
+	}
+	if sortField == models.OrganisationSortRepositoryCount {
+		// An expression ORDER BY is dropped when merged with later Order calls,
+		// so the tie-breakers are part of the same expression.
+		query = query.Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:  activeRepositoryCountSQL + " " + direction + ", label ASC, uri ASC",
+			Vars: []any{true},
+		}})
+	} else {
+		query = query.Order("label " + direction).Order("uri asc")
+	}
+
+	var organisations []models.Organisation
+	if err := query.Offset(offset).Limit(perPage).Find(&organisations).Error; err != nil {
 		return nil, models.Pagination{}, err
 	}
 
-	pagination := commonpagination.New(page, perPage, int(totalRecords))
+	pagination := models.NewPagination(page, perPage, int(totalRecords))
 
 	return organisations, pagination, nil
 }
@@ -260,6 +554,39 @@ func (r *repositoriesRepository) FindOrganisationByURI(ctx context.Context, uri
 	return &org, nil
 }
 
+// CountOrganisationReferences counts the repositories, tombstones included,
+// and git organisations that reference the organisation.
+func (r *repositoriesRepository) CountOrganisationReferences(ctx context.Context, uri string) (*models.OrganisationReferences, error) {
+	db := r.db.WithContext(ctx)
+
+	var repositoryCounts struct {
+		Active  int64
+		Deleted int64
+	}
+	if err := db.Model(&models.Repository{}).
+		Select("COALESCE(SUM(CASE WHEN deleted_at IS NULL THEN 1 ELSE 0 END), 0) AS active, "+
+			"COALESCE(SUM(CASE WHEN deleted_at IS NOT NULL THEN 1 ELSE 0 END), 0) AS deleted").
+		Where("organisation_id = ?", uri).
+		Scan(&repositoryCounts).Error; err != nil {
+		return nil, err
+	}
+
+	var gitOrganisations int64
+	if err := db.Model(&models.GitOrganisatie{}).Where("organisation_id = ?", uri).Count(&gitOrganisations).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.OrganisationReferences{
+		Repositories:        int(repositoryCounts.Active),
+		DeletedRepositories: int(repositoryCounts.Deleted),
+		GitOrganisations:    int(gitOrganisations),
+	}, nil
+}
+
+func (r *repositoriesRepository) DeleteOrganisation(ctx context.Context, uri string) error {
+	return r.db.WithContext(ctx).Where("uri = ?", uri).Delete(&models.Organisation{}).Error
+}
+
 func (r *repositoriesRepository) SaveGitOrganisatie(ctx context.Context, gitOrg *models.GitOrganisatie) error {
 	return r.db.WithContext(ctx).Save(gitOrg).Error
 }
@@ -279,342 +606,170 @@ func (r *repositoriesRepository) FindGitOrganisationByURL(ctx context.Context, u
 	return &gitOrg, nil
 }
 
-func applyRepositoryOrdering(db *gorm.DB) *gorm.DB {
-	return db.Order("(public_code_url IS NOT NULL AND public_code_url <> '') DESC").
-		Order("last_activity_at DESC").
-		Order("name")
-}
-
-func (r *repositoriesRepository) GetRepositoryFilterCounts(ctx context.Context, p *models.RepositoryFiltersParams) (*models.RepositoryFilterCounts, error) {
-	matcher, err := compileRepositoryFilters(p, true)
-	if err != nil {
-		return nil, err
+// GetGitOrganisationsByHost returns the git organisations whose http(s) url
+// starts with host, compared case-insensitively. The match is coarse: callers
+// check with RepositoryURLUnder which ones a repository lies below.
+func (r *repositoriesRepository) GetGitOrganisationsByHost(ctx context.Context, host string) ([]models.GitOrganisatie, error) {
+	if host == "" {
+		return nil, nil
 	}
-	query := applyArchivedRepositoryFilter(r.db.WithContext(ctx).Where("(active IS NULL OR active = ?)", true), p)
-
-	var allRepos []models.Repository
-	if err := query.Preload("Organisation").Find(&allRepos).Error; err != nil {
-		return nil, err
-	}
-	var archivedRepos []models.Repository
+	pattern := commonquery.EscapeSQLLike(strings.ToLower(host)) + "%"
+	var gitOrgs []models.GitOrganisatie
 	if err := r.db.WithContext(ctx).
-		Where("(active IS NULL OR active = ?)", true).
-		Where("archived = ?", true).
-		Preload("Organisation").
-		Find(&archivedRepos).Error; err != nil {
+		Where("(LOWER(url) LIKE ? ESCAPE '\\' OR LOWER(url) LIKE ? ESCAPE '\\')", "https://"+pattern, "http://"+pattern).
+		Order("id").
+		Find(&gitOrgs).Error; err != nil {
 		return nil, err
 	}
-
-	result := &models.RepositoryFilterCounts{}
-
-	result.PublicCode = countReposWithFilters(allRepos, matcher, "publiccode", func(repo models.Repository) bool {
-		return repo.PublicCodeUrl != ""
-	})
-	result.Archived = countReposWithFilters(archivedRepos, matcher, "archived", func(repo models.Repository) bool {
-		return repo.Archived
-	})
-
-	if matcher.lastActivityAfter != nil {
-		n := countReposWithFilters(allRepos, matcher, "lastActivityAfter", func(repo models.Repository) bool {
-			return !repo.LastActivityAt.Before(*matcher.lastActivityAfter)
-		})
-		result.LastActivityAfter = &n
-	}
-
-	result.SoftwareType = countByFieldWithFilters(allRepos, matcher, "softwareType", func(repo models.Repository) string {
-		if repo.PublicCode == nil {
-			return ""
-		}
-		return repo.PublicCode.SoftwareType
-	})
-
-	result.DevelopmentStatus = countByFieldWithFilters(allRepos, matcher, "developmentStatus", func(repo models.Repository) string {
-		if repo.PublicCode == nil {
-			return ""
-		}
-		return repo.PublicCode.DevelopmentStatus
-	})
-
-	result.MaintenanceType = countByFieldWithFilters(allRepos, matcher, "maintenanceType", func(repo models.Repository) string {
-		if repo.PublicCode == nil || repo.PublicCode.Maintenance == nil {
-			return ""
-		}
-		return repo.PublicCode.Maintenance.Type
-	})
-
-	result.License = countByFieldWithFilters(allRepos, matcher, "license", func(repo models.Repository) string {
-		if repo.PublicCode == nil || repo.PublicCode.Legal == nil {
-			return ""
-		}
-		return repo.PublicCode.Legal.License
-	})
-
-	result.Platforms = countByArrayFieldWithFilters(allRepos, matcher, "platforms", func(repo models.Repository) []string {
-		if repo.PublicCode == nil {
-			return nil
-		}
-		return repo.PublicCode.Platforms
-	})
-
-	result.AvailableLanguages = countByArrayFieldWithFilters(allRepos, matcher, "availableLanguages", func(repo models.Repository) []string {
-		if repo.PublicCode == nil || repo.PublicCode.Localisation == nil {
-			return nil
-		}
-		return repo.PublicCode.Localisation.AvailableLanguages
-	})
-
-	orgCounts := make(map[string]*models.OrgFilterCount)
-	for _, repo := range allRepos {
-		if !repoMatchesCompiledFilters(repo, matcher, "organisation") {
-			continue
-		}
-		if repo.OrganisationID == nil || *repo.OrganisationID == "" {
-			continue
-		}
-		orgID := *repo.OrganisationID
-		if _, ok := orgCounts[orgID]; !ok {
-			label := orgID
-			if repo.Organisation != nil {
-				label = repo.Organisation.Label
-			}
-			orgCounts[orgID] = &models.OrgFilterCount{Value: orgID, Label: label}
-		}
-		orgCounts[orgID].Count++
-	}
-	for _, fc := range orgCounts {
-		result.Organisation = append(result.Organisation, *fc)
-	}
-	sort.Slice(result.Organisation, func(i, j int) bool {
-		left := strings.ToLower(result.Organisation[i].Label)
-		right := strings.ToLower(result.Organisation[j].Label)
-		if left == right {
-			return result.Organisation[i].Value < result.Organisation[j].Value
-		}
-		return left < right
-	})
-
-	return result, nil
+	return gitOrgs, nil
 }
 
-func countRepos(repos []models.Repository, p *models.RepositoryFiltersParams, exclude string, match func(models.Repository) bool) int {
-	matcher, _ := compileRepositoryFilters(p, false)
-	return countReposWithFilters(repos, matcher, exclude, match)
+// repositoryOrdering lists repositories with a publiccode.yml first, then the
+// most recently active ones.
+var repositoryOrdering = []string{
+	"(public_code_url IS NOT NULL AND public_code_url <> '') DESC",
+	"last_activity_at DESC",
+	"name",
 }
 
-func countReposWithFilters(repos []models.Repository, matcher *repositoryFilterMatcher, exclude string, match func(models.Repository) bool) int {
-	count := 0
-	for _, repo := range repos {
-		if repoMatchesCompiledFilters(repo, matcher, exclude) && match(repo) {
-			count++
-		}
+func applyRepositoryOrdering(db *gorm.DB) *gorm.DB {
+	for _, order := range repositoryOrdering {
+		db = db.Order(order)
 	}
-	return count
+	return db
 }
 
-func countByField(repos []models.Repository, p *models.RepositoryFiltersParams, exclude string, getValue func(models.Repository) string) []models.FilterCount {
-	matcher, _ := compileRepositoryFilters(p, false)
-	return countByFieldWithFilters(repos, matcher, exclude, getValue)
+// repositorySortColumns are the values sorted on for the sort parameter of
+// the repository list.
+var repositorySortColumns = map[string]string{
+	models.RepositorySortName:           "repositories.name",
+	models.RepositorySortLastActivityAt: "repositories.last_activity_at",
+	models.RepositorySortCreatedAt:      "repositories.created_at",
+	models.RepositorySortLastCrawledAt:  "repositories.last_crawled_at",
+	models.RepositorySortOrganisation:   "(SELECT organisations.label FROM organisations WHERE organisations.uri = repositories.organisation_id)",
 }
 
-func countByFieldWithFilters(repos []models.Repository, matcher *repositoryFilterMatcher, exclude string, getValue func(models.Repository) string) []models.FilterCount {
-	counts := make(map[string]int)
-	for _, repo := range repos {
-		if !repoMatchesCompiledFilters(repo, matcher, exclude) {
-			continue
-		}
-		if val := getValue(repo); val != "" {
-			counts[val]++
-		}
-	}
-	result := make([]models.FilterCount, 0, len(counts))
-	for val, count := range counts {
-		result = append(result, models.FilterCount{Value: val, Count: count})
-	}
-	sort.Slice(result, func(i, j int) bool {
-		return strings.ToLower(result[i].Value) < strings.ToLower(result[j].Value)
-	})
-	return result
-}
-
-func countByArrayField(repos []models.Repository, p *models.RepositoryFiltersParams, exclude string, getValues func(models.Repository) []string) []models.FilterCount {
-	matcher, _ := compileRepositoryFilters(p, false)
-	return countByArrayFieldWithFilters(repos, matcher, exclude, getValues)
+// orderRepositories sorts the repositories selected by db on sort. Without a
+// sort column, and for relevance, search decides the order.
+func orderRepositories(db *gorm.DB, sort string, search *textSearch) *gorm.DB {
+	field, descending := strings.CutPrefix(sort, "-")
+	column, ok := repositorySortColumns[field]
+	if !ok {
+		return search.order(db)
+	}
+	direction := "ASC"
+	if descending {
+		direction = "DESC"
+	}
+	// Missing values come last in both directions and name and id break ties,
+	// so pages stay stable. One expression, as an expression ORDER BY is
+	// dropped when merged with later Order calls.
+	return db.Order(clause.OrderBy{Expression: clause.Expr{
+		SQL:                fmt.Sprintf("%[1]s IS NULL, %[1]s %[2]s, repositories.name ASC, repositories.id ASC", column, direction),
+		WithoutParentheses: true,
+	}})
 }
 
-func countByArrayFieldWithFilters(repos []models.Repository, matcher *repositoryFilterMatcher, exclude string, getValues func(models.Repository) []string) []models.FilterCount {
-	counts := make(map[string]int)
-	for _, repo := range repos {
-		if !repoMatchesCompiledFilters(repo, matcher, exclude) {
-			continue
-		}
-		for _, val := range getValues(repo) {
-			if val != "" {
-				counts[val]++
-			}
-		}
+// GetRepositoryFilterCounts counts the repositories per filter option. Each
+// facet is counted with grouped queries over the repositories that match all
+// other active filters.
+func (r *repositoriesRepository) GetRepositoryFilterCounts(ctx context.Context, p *models.RepositoryFiltersParams) (*models.RepositoryFilterCounts, error) {
+	filter, err := compileRepositoryFilters(r.db, p)
+	if err != nil {
+		return nil, err
 	}
-	result := make([]models.FilterCount, 0, len(counts))
-	for val, count := range counts {
-		result = append(result, models.FilterCount{Value: val, Count: count})
+	matching := func(exclude string) *gorm.DB {
+		return filter.apply(r.db.WithContext(ctx).Model(&models.Repository{}), exclude)
 	}
-	sort.Slice(result, func(i, j int) bool {
-		return strings.ToLower(result[i].Value) < strings.ToLower(result[j].Value)
-	})
-	return result
-}
 
-func repoMatchesFilters(repo models.Repository, p *models.RepositoryFiltersParams, exclude string) bool {
-	matcher, _ := compileRepositoryFilters(p, false)
-	return repoMatchesCompiledFilters(repo, matcher, exclude)
-}
+	result := &models.RepositoryFilterCounts{}
 
-func compileRepositoryFilters(p *models.RepositoryFiltersParams, validate bool) (*repositoryFilterMatcher, error) {
-	if p == nil {
-		p = &models.RepositoryFiltersParams{}
+	if result.PublicCode, err = countRepositories(matching("publiccode").Where(hasPublicCodeSQL)); err != nil {
+		return nil, err
 	}
-
-	matcher := &repositoryFilterMatcher{params: p}
-	if p.Organisation != nil {
-		matcher.organisation = strings.TrimSpace(*p.Organisation)
+	if result.Archived, err = countRepositories(matching("archived").Where("repositories.archived = ?", true)); err != nil {
+		return nil, err
 	}
-	matcher.query = strings.ToLower(strings.TrimSpace(p.Query))
-	if p.LastActivityAfter != nil {
-		trimmed := strings.TrimSpace(*p.LastActivityAfter)
-		if trimmed != "" {
-			date, err := time.Parse("2006-01-02", trimmed)
-			if err != nil {
-				if validate {
-					return nil, fmt.Errorf("invalid lastActivityAfter format, expected YYYY-MM-DD: %w", err)
-				}
-			} else {
-				matcher.lastActivityAfter = &date
-			}
+	if filter.lastActivityAfter != nil {
+		n, err := countRepositories(matching(""))
+		if err != nil {
+			return nil, err
 		}
+		result.LastActivityAfter = &n
 	}
 
-	return matcher, nil
-}
-
-func repoMatchesCompiledFilters(repo models.Repository, matcher *repositoryFilterMatcher, exclude string) bool {
-	if matcher == nil || matcher.params == nil {
-		return true
-	}
-	p := matcher.params
-	if exclude != "organisation" && matcher.organisation != "" {
-		if repo.OrganisationID == nil || *repo.OrganisationID != matcher.organisation {
-			return false
-		}
-	}
-	if matcher.query != "" && !repoMatchesQuery(repo, matcher.query) {
-		return false
-	}
-	if exclude != "publiccode" {
-		hasPublicCode := repo.PublicCodeUrl != ""
-		if publicCodeFilterValue(p) != hasPublicCode {
-			return false
+	for _, facet := range []struct {
+		key    string
+		counts *[]models.FilterCount
+	}{
+		{"softwareType", &result.SoftwareType},
+		{"developmentStatus", &result.DevelopmentStatus},
+		{"maintenanceType", &result.MaintenanceType},
+		{"license", &result.License},
+	} {
+		if *facet.counts, err = filter.countByField(matching(facet.key), publicCodeFields[facet.key]); err != nil {
+			return nil, err
 		}
 	}
-	if exclude != "lastActivityAfter" && matcher.lastActivityAfter != nil {
-		if repo.LastActivityAt.Before(*matcher.lastActivityAfter) {
-			return false
+	for _, facet := range []struct {
+		key    string
+		counts *[]models.FilterCount
+	}{
+		{"platforms", &result.Platforms},
+		{"availableLanguages", &result.AvailableLanguages},
+	} {
+		if *facet.counts, err = filter.countByArrayField(matching(facet.key), publicCodeFields[facet.key]); err != nil {
+			return nil, err
 		}
 	}
-	if exclude != "softwareType" && len(p.SoftwareType) > 0 {
-		st := ""
-		if repo.PublicCode != nil {
-			st = repo.PublicCode.SoftwareType
-		}
-		if !containsStr(p.SoftwareType, st) {
-			return false
-		}
-	}
-	if exclude != "developmentStatus" && len(p.DevelopmentStatus) > 0 {
-		ds := ""
-		if repo.PublicCode != nil {
-			ds = repo.PublicCode.DevelopmentStatus
-		}
-		if !containsStr(p.DevelopmentStatus, ds) {
-			return false
-		}
-	}
-	if exclude != "maintenanceType" && len(p.MaintenanceType) > 0 {
-		mt := ""
-		if repo.PublicCode != nil && repo.PublicCode.Maintenance != nil {
-			mt = repo.PublicCode.Maintenance.Type
-		}
-		if !containsStr(p.MaintenanceType, mt) {
-			return false
-		}
-	}
-	if exclude != "license" && len(p.License) > 0 {
-		lic := ""
-		if repo.PublicCode != nil && repo.PublicCode.Legal != nil {
-			lic = repo.PublicCode.Legal.License
-		}
-		if !containsStr(p.License, lic) {
-			return false
-		}
-	}
-	if exclude != "platforms" && len(p.Platforms) > 0 {
-		var repoPlatforms []string
-		if repo.PublicCode != nil {
-			repoPlatforms = repo.PublicCode.Platforms
-		}
-		for _, platform := range p.Platforms {
-			if !containsStr(repoPlatforms, platform) {
-				return false
-			}
-		}
-	}
-	if exclude != "availableLanguages" && len(p.AvailableLanguages) > 0 {
-		var repoLangs []string
-		if repo.PublicCode != nil && repo.PublicCode.Localisation != nil {
-			repoLangs = repo.PublicCode.Localisation.AvailableLanguages
-		}
-		for _, lang := range p.AvailableLanguages {
-			if !containsStr(repoLangs, lang) {
-				return false
-			}
-		}
-	}
-	return true
-}
 
-func publicCodeFilterValue(p *models.RepositoryFiltersParams) bool {
-	if p == nil || p.PublicCode == nil {
-		return true
+	if result.Organisation, err = r.countByOrganisation(ctx, matching("organisation")); err != nil {
+		return nil, err
 	}
-	return *p.PublicCode
+	return result, nil
 }
 
-func applyArchivedRepositoryFilter(db *gorm.DB, p *models.RepositoryFiltersParams) *gorm.DB {
-	if p != nil && p.Archived != nil && *p.Archived {
-		return db.Where("archived = ?", true)
+// countByOrganisation counts the repositories selected by db per organisation,
+// labelled with the organisation label (or its URI when the organisation is
+// not stored) and sorted by label.
+func (r *repositoriesRepository) countByOrganisation(ctx context.Context, db *gorm.DB) ([]models.OrgFilterCount, error) {
+	var counts []models.OrgFilterCount
+	if err := db.Select("repositories.organisation_id AS value, COUNT(*) AS count").
+		Where("repositories.organisation_id IS NOT NULL AND repositories.organisation_id <> ''").
+		Group("repositories.organisation_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	if len(counts) == 0 {
+		return nil, nil
 	}
-	return db.Where("(archived IS NULL OR archived = ?)", false)
-}
 
-func repoMatchesQuery(repo models.Repository, query string) bool {
-	if query == "" {
-		return true
+	uris := make([]string, len(counts))
+	for i, fc := range counts {
+		uris[i] = fc.Value
+	}
+	var organisations []models.Organisation
+	if err := r.db.WithContext(ctx).Where("uri IN ?", uris).Find(&organisations).Error; err != nil {
+		return nil, err
 	}
-	if strings.Contains(strings.ToLower(repo.Name), query) ||
-		strings.Contains(strings.ToLower(repo.ShortDescription), query) ||
-		strings.Contains(strings.ToLower(repo.LongDescription), query) {
-		return true
+	labels := make(map[string]string, len(organisations))
+	for _, org := range organisations {
+		labels[org.Uri] = org.Label
 	}
-	if repo.PublicCode == nil {
-		return false
+	for i := range counts {
+		counts[i].Label = counts[i].Value
+		if label, ok := labels[counts[i].Value]; ok {
+			counts[i].Label = label
+		}
 	}
-	return strings.Contains(strings.ToLower(repo.PublicCode.Url), query) ||
-		strings.Contains(strings.ToLower(repo.PublicCode.LandingUrl), query)
-}
 
-func containsStr(slice []string, val string) bool {
-	for _, s := range slice {
-		if s == val {
-			return true
+	sort.Slice(counts, func(i, j int) bool {
+		left := strings.ToLower(counts[i].Label)
+		right := strings.ToLower(counts[j].Label)
+		if left == right {
+			return counts[i].Value < counts[j].Value
 		}
-	}
-	return false
+		return left < right
+	})
+	return counts, nil
 }
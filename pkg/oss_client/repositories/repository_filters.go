@@ -0,0 +1,211 @@
+package repositories
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"gorm.io/gorm"
+)
+
+// publicCodeFields maps the publiccode.yml filters to their path in the
+// public_code_data document.
+var publicCodeFields = map[string][]string{
+	"softwareType":       {"softwareType"},
+	"developmentStatus":  {"developmentStatus"},
+	"maintenanceType":    {"maintenance", "type"},
+	"license":            {"legal", "license"},
+	"platforms":          {"platforms"},
+	"availableLanguages": {"localisation", "availableLanguages"},
+}
+
+// repositoryFilter translates RepositoryFiltersParams into SQL. On PostgreSQL
+// the publiccode.yml filters use jsonb operators backed by the indexes of
+// database.migrateRepositoryFilterIndexes; other dialects (SQLite in tests)
+// use the JSON1 functions.
+type repositoryFilter struct {
+	params            *models.RepositoryFiltersParams
+	organisation      string
+	search            *textSearch
+	lastActivityAfter *time.Time
+	postgres          bool
+}
+
+func compileRepositoryFilters(db *gorm.DB, p *models.RepositoryFiltersParams) (*repositoryFilter, error) {
+	if p == nil {
+		p = &models.RepositoryFiltersParams{}
+	}
+
+	filter := &repositoryFilter{params: p, postgres: db.Dialector.Name() == "postgres"}
+	if p.Organisation != nil {
+		filter.organisation = strings.TrimSpace(*p.Organisation)
+	}
+	filter.search = newTextSearch(db, p.Query)
+	if p.LastActivityAfter != nil {
+		trimmed := strings.TrimSpace(*p.LastActivityAfter)
+		if trimmed != "" {
+			date, err := time.Parse("2006-01-02", trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("invalid lastActivityAfter format, expected YYYY-MM-DD: %w", err)
+			}
+			filter.lastActivityAfter = &date
+		}
+	}
+
+	return filter, nil
+}
+
+// apply restricts db to the active repositories that match every filter
+// except exclude, which names the filter a facet is counted for.
+func (f *repositoryFilter) apply(db *gorm.DB, exclude string) *gorm.DB {
+	p := f.params
+	db = db.Where("repositories.deleted_at IS NULL").
+		Where("(repositories.active IS NULL OR repositories.active = ?)", true)
+	if exclude != "archived" {
+		if p.Archived != nil && *p.Archived {
+			db = db.Where("repositories.archived = ?", true)
+		} else {
+			db = db.Where("(repositories.archived IS NULL OR repositories.archived = ?)", false)
+		}
+	}
+	if exclude != "organisation" && f.organisation != "" {
+		db = db.Where("repositories.organisation_id = ?", f.organisation)
+	}
+	if f.search != nil {
+		db = f.search.where(db, f.publicCodeText("url"), f.publicCodeText("landingURL"))
+	}
+	if exclude != "publiccode" {
+		if publicCodeFilterValue(p) {
+			db = db.Where(hasPublicCodeSQL)
+		} else {
+			db = db.Where("NOT " + hasPublicCodeSQL)
+		}
+	}
+	if exclude != "lastActivityAfter" && f.lastActivityAfter != nil {
+		db = f.whereLastActivityAfter(db, *f.lastActivityAfter)
+	}
+	for _, field := range []publicCodeFilter{
+		{"softwareType", p.SoftwareType},
+		{"developmentStatus", p.DevelopmentStatus},
+		{"maintenanceType", p.MaintenanceType},
+		{"license", p.License},
+	} {
+		if exclude != field.key && len(field.values) > 0 {
+			db = db.Where(f.publicCodeText(publicCodeFields[field.key]...)+" IN ?", field.values)
+		}
+	}
+	for _, field := range []publicCodeFilter{
+		{"platforms", p.Platforms},
+		{"availableLanguages", p.AvailableLanguages},
+	} {
+		if exclude != field.key && len(field.values) > 0 {
+			db = f.whereContainsAll(db, publicCodeFields[field.key], field.values)
+		}
+	}
+	return db
+}
+
+type publicCodeFilter struct {
+	key    string
+	values []string
+}
+
+const hasPublicCodeSQL = "(repositories.public_code_url IS NOT NULL AND repositories.public_code_url <> '')"
+
+func publicCodeFilterValue(p *models.RepositoryFiltersParams) bool {
+	if p == nil || p.PublicCode == nil {
+		return true
+	}
+	return *p.PublicCode
+}
+
+// publicCodeText is the text value at path in the publiccode.yml document, or
+// NULL when it is missing.
+func (f *repositoryFilter) publicCodeText(path ...string) string {
+	if f.postgres {
+		return fmt.Sprintf("(repositories.public_code_data::jsonb #>> '{%s}')", strings.Join(path, ","))
+	}
+	return fmt.Sprintf("json_extract(repositories.public_code_data, '$.%s')", strings.Join(path, "."))
+}
+
+// whereContainsAll keeps repositories whose array at path holds every value.
+func (f *repositoryFilter) whereContainsAll(db *gorm.DB, path, values []string) *gorm.DB {
+	if f.postgres {
+		// {"localisation": {"availableLanguages": [...]}} for jsonb containment,
+		// which the GIN index on public_code_data supports.
+		var document any = values
+		for i := len(path) - 1; i >= 0; i-- {
+			document = map[string]any{path[i]: document}
+		}
+		encoded, _ := json.Marshal(document)
+		return db.Where("(repositories.public_code_data::jsonb) @> ?::jsonb", string(encoded))
+	}
+	for _, value := range values {
+		db = db.Where(fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(repositories.public_code_data, '$.%s') WHERE json_each.value = ?)", strings.Join(path, ".")), value)
+	}
+	return db
+}
+
+func (f *repositoryFilter) whereLastActivityAfter(db *gorm.DB, date time.Time) *gorm.DB {
+	if f.postgres {
+		return db.Where("repositories.last_activity_at >= ?", date)
+	}
+	// SQLite stores timestamps as text with their own offset; compare instants.
+	return db.Where("julianday(repositories.last_activity_at) >= julianday(?)", date.UTC().Format("2006-01-02 15:04:05"))
+}
+
+// countRepositories counts the repositories selected by db.
+func countRepositories(db *gorm.DB) (int, error) {
+	var count int64
+	if err := db.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+// countByField counts the repositories selected by db per non-empty value at
+// path.
+func (f *repositoryFilter) countByField(db *gorm.DB, path []string) ([]models.FilterCount, error) {
+	expr := f.publicCodeText(path...)
+	var counts []models.FilterCount
+	if err := db.Select(expr + " AS value, COUNT(*) AS count").
+		Where(expr + " <> ''").
+		Group(expr).
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	return sortFilterCounts(counts), nil
+}
+
+// countByArrayField counts the repositories selected by db per value of the
+// array at path.
+func (f *repositoryFilter) countByArrayField(db *gorm.DB, path []string) ([]models.FilterCount, error) {
+	if f.postgres {
+		array := fmt.Sprintf("(repositories.public_code_data::jsonb #> '{%s}')", strings.Join(path, ","))
+		db = db.Joins(fmt.Sprintf("CROSS JOIN LATERAL jsonb_array_elements_text(CASE WHEN jsonb_typeof(%[1]s) = 'array' THEN %[1]s ELSE '[]'::jsonb END) AS facet(value)", array))
+	} else {
+		db = db.Joins(fmt.Sprintf("JOIN json_each(repositories.public_code_data, '$.%s') AS facet", strings.Join(path, "."))).
+			Where("facet.type = 'text'")
+	}
+	var counts []models.FilterCount
+	if err := db.Select("facet.value AS value, COUNT(*) AS count").
+		Where("facet.value <> ''").
+		Group("facet.value").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	return sortFilterCounts(counts), nil
+}
+
+func sortFilterCounts(counts []models.FilterCount) []models.FilterCount {
+	if counts == nil {
+		counts = []models.FilterCount{}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return strings.ToLower(counts[i].Value) < strings.ToLower(counts[j].Value)
+	})
+	return counts
+}
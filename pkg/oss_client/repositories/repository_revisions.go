@@ -0,0 +1,159 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	util "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/util"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GetRepositoryRevisions returns the revisions of a repository, newest first.
+func (r *repositoriesRepository) GetRepositoryRevisions(ctx context.Context, repositoryID string, page, perPage int) ([]models.RepositoryRevision, models.Pagination, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage <= 0 {
+		perPage = 20
+	}
+
+	db := r.db.WithContext(ctx).Model(&models.RepositoryRevision{}).Where("repository_id = ?", repositoryID)
+
+	var totalRecords int64
+	if err := db.Count(&totalRecords).Error; err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	var revisions []models.RepositoryRevision
+	if err := db.Order("created_at DESC").
+		Order("version DESC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&revisions).Error; err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	return revisions, models.NewPagination(page, perPage, int(totalRecords)), nil
+}
+
+// recordRepositoryRevision stores the difference between previous and current.
+// No row is written when none of the tracked fields changed.
+func recordRepositoryRevision(ctx context.Context, tx *gorm.DB, previous, current *models.Repository) error {
+	changes := diffRepositories(previous, current)
+	if len(changes) == 0 {
+		return nil
+	}
+	return tx.Create(&models.RepositoryRevision{
+		Id:           uuid.NewString(),
+		RepositoryID: current.Id,
+		Version:      current.Version,
+		Actor:        util.ActorFromContext(ctx),
+		CreatedAt:    time.Now().UTC(),
+		Changes:      changes,
+	}).Error
+}
+
+// diffRepositories compares the tracked fields of two repositories. A nil
+// previous repository yields a change for every non-empty field. Crawl
+// bookkeeping (lastCrawledAt, lastActivityAt) is not tracked.
+func diffRepositories(previous, current *models.Repository) []models.RepositoryFieldChange {
+	before := repositoryRevisionFields(previous)
+	after := repositoryRevisionFields(current)
+
+	keys := make(map[string]struct{}, len(before)+len(after))
+	for key := range before {
+		keys[key] = struct{}{}
+	}
+	for key := range after {
+		keys[key] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	changes := make([]models.RepositoryFieldChange, 0)
+	for _, key := range sorted {
+		oldValue, newValue := before[key], after[key]
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		changes = append(changes, models.RepositoryFieldChange{
+			Field:    key,
+			OldValue: oldValue,
+			NewValue: newValue,
+		})
+	}
+	return changes
+}
+
+func repositoryRevisionFields(repo *models.Repository) map[string]any {
+	fields := map[string]any{}
+	if repo == nil {
+		return fields
+	}
+
+	setRevisionField(fields, "name", repo.Name)
+	setRevisionField(fields, "shortDescription", repo.ShortDescription)
+	setRevisionField(fields, "longDescription", repo.LongDescription)
+	setRevisionField(fields, "url", repo.Url)
+	setRevisionField(fields, "publicCodeUrl", repo.PublicCodeUrl)
+	setRevisionField(fields, "isFork", repo.IsFork)
+	setRevisionField(fields, "archived", repo.Archived)
+	setRevisionField(fields, "active", repo.Active)
+	if repo.OrganisationID != nil {
+		setRevisionField(fields, "organisationUri", *repo.OrganisationID)
+	}
+	if len(repo.ForkBasedOnURLs) > 0 {
+		setRevisionField(fields, "forkBasedOnUrls", repo.ForkBasedOnURLs)
+	}
+	if repo.DeletedAt != nil {
+		setRevisionField(fields, "deletedAt", repo.DeletedAt.UTC().Format(time.RFC3339))
+	}
+	if repo.PublicCode != nil {
+		setRevisionField(fields, "publicCode", repo.PublicCode)
+	}
+	return fields
+}
+
+// setRevisionField normalises value through JSON so that old values read back
+// from the database compare equal to freshly built ones. Objects are flattened
+// into dotted paths; empty values are left out.
+func setRevisionField(fields map[string]any, path string, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	var normalised any
+	if err := json.Unmarshal(data, &normalised); err != nil {
+		return
+	}
+	flattenRevisionValue(fields, path, normalised)
+}
+
+func flattenRevisionValue(fields map[string]any, path string, value any) {
+	switch v := value.(type) {
+	case nil:
+		return
+	case string:
+		if v == "" {
+			return
+		}
+	case []any:
+		if len(v) == 0 {
+			return
+		}
+	case map[string]any:
+		for key, nested := range v {
+			flattenRevisionValue(fields, path+"."+key, nested)
+		}
+		return
+	}
+	fields[path] = value
+}
@@ -0,0 +1,169 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// enqueueSearchIndexOperation records in tx that repositoryID must be synced
+// to the search index. A pending operation for the repository is made due
+// again instead of adding a second one.
+func enqueueSearchIndexOperation(tx *gorm.DB, repositoryID string) error {
+	now := time.Now().UTC()
+	var pending models.SearchIndexOperation
+	err := tx.Where("repository_id = ? AND status = ?", repositoryID, models.SearchIndexOperationPending).
+		Order("created_at").
+		First(&pending).Error
+	if err == nil {
+		return tx.Model(&pending).Updates(map[string]any{
+			"next_attempt_at": now,
+			"updated_at":      now,
+		}).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return tx.Create(&models.SearchIndexOperation{
+		Id:            uuid.NewString(),
+		RepositoryID:  repositoryID,
+		Status:        models.SearchIndexOperationPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}).Error
+}
+
+// EnqueueOrganisationSearchIndexOperations records that the active, not
+// deleted repositories of the organisation must be synced to the search index,
+// for example because their documents carry its label. Call it inside
+// Transaction so the operations commit with the change. It returns the number
+// of repositories enqueued.
+func (r *repositoriesRepository) EnqueueOrganisationSearchIndexOperations(ctx context.Context, uri string) (int, error) {
+	db := r.db.WithContext(ctx)
+	var ids []string
+	if err := db.Model(&models.Repository{}).
+		Where("organisation_id = ? AND deleted_at IS NULL AND active = ?", uri, true).
+		Order("id").
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		if err := enqueueSearchIndexOperation(db, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+// ClaimDueSearchIndexOperations claims at most limit pending operations that
+// are due at now, oldest first. Claimed operations are not due again until
+// lease has passed, so a crashed worker's operations are retried; on Postgres
+// rows another worker is claiming are skipped. The returned operations carry
+// the claim in UpdatedAt.
+func (r *repositoriesRepository) ClaimDueSearchIndexOperations(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]models.SearchIndexOperation, error) {
+	// Postgres keeps microseconds; the claim must compare equal once stored.
+	claimedAt := now.UTC().Truncate(time.Microsecond)
+	var operations []models.SearchIndexOperation
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("status = ? AND next_attempt_at <= ?", models.SearchIndexOperationPending, claimedAt).
+			Order("next_attempt_at").
+			Order("created_at").
+			Limit(limit)
+		if tx.Dialector.Name() == "postgres" {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		if err := query.Find(&operations).Error; err != nil {
+			return err
+		}
+		if len(operations) == 0 {
+			return nil
+		}
+
+		ids := make([]string, len(operations))
+		for i := range operations {
+			ids[i] = operations[i].Id
+			operations[i].NextAttemptAt = claimedAt.Add(lease)
+			operations[i].UpdatedAt = claimedAt
+		}
+		return tx.Model(&models.SearchIndexOperation{}).
+			Where("id IN ?", ids).
+			Updates(map[string]any{
+				"next_attempt_at": claimedAt.Add(lease),
+				"updated_at":      claimedAt,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+// SaveSearchIndexOperation stores the outcome of a failed attempt on a claimed
+// operation. When the repository was written again since the claim, the
+// operation is left as enqueued: that write must still be synced.
+func (r *repositoriesRepository) SaveSearchIndexOperation(ctx context.Context, operation *models.SearchIndexOperation) error {
+	updatedAt := time.Now().UTC().Truncate(time.Microsecond)
+	result := r.db.WithContext(ctx).
+		Model(&models.SearchIndexOperation{}).
+		Where("id = ? AND updated_at = ?", operation.Id, operation.UpdatedAt).
+		Updates(map[string]any{
+			"status":          operation.Status,
+			"attempts":        operation.Attempts,
+			"last_error":      operation.LastError,
+			"next_attempt_at": operation.NextAttemptAt,
+			"updated_at":      updatedAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		operation.UpdatedAt = updatedAt
+	}
+	return nil
+}
+
+// CompleteSearchIndexOperation removes a handled, claimed operation from the
+// outbox, unless the repository was written again since the claim.
+func (r *repositoriesRepository) CompleteSearchIndexOperation(ctx context.Context, operation *models.SearchIndexOperation) error {
+	return r.db.WithContext(ctx).
+		Where("id = ? AND updated_at = ?", operation.Id, operation.UpdatedAt).
+		Delete(&models.SearchIndexOperation{}).Error
+}
+
+// GetSearchIndexOperations returns the operations in the outbox, optionally
+// only those with status, oldest first.
+func (r *repositoriesRepository) GetSearchIndexOperations(ctx context.Context, page, perPage int, status string) ([]models.SearchIndexOperation, models.Pagination, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage <= 0 {
+		perPage = 20
+	}
+
+	db := r.db.WithContext(ctx).Model(&models.SearchIndexOperation{})
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+
+	var totalRecords int64
+	if err := db.Count(&totalRecords).Error; err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	var operations []models.SearchIndexOperation
+	if err := db.Order("created_at").
+		Order("id").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&operations).Error; err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	return operations, models.NewPagination(page, perPage, int(totalRecords)), nil
+}
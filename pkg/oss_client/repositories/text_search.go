@@ -0,0 +1,142 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	commonquery "github.com/developer-overheid-nl/don-register-common/query"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// textSearchConfigs are the dictionaries search_vector is built with; every
+// term is looked up in each of them.
+var textSearchConfigs = []string{"dutch", "english"}
+
+// organisationSearchVectorSQL is the text search vector of the label of the
+// organisation of the repository in the outer query. It ranks below every
+// field of search_vector.
+const organisationSearchVectorSQL = "COALESCE((SELECT to_tsvector('dutch', COALESCE(organisations.label, '')) || to_tsvector('english', COALESCE(organisations.label, ''))" +
+	" FROM organisations WHERE organisations.uri = repositories.organisation_id), ''::tsvector)"
+
+// textSearch is the q parameter of the repository endpoints. On PostgreSQL it
+// is a full-text query over repositories.search_vector (see
+// database.migrateRepositorySearchVector) and the organisation label, ranked
+// with ts_rank. Other dialects (SQLite in tests) fall back to a
+// case-insensitive substring match.
+type textSearch struct {
+	raw      string
+	terms    []string
+	postgres bool
+}
+
+func newTextSearch(db *gorm.DB, q string) *textSearch {
+	raw := strings.TrimSpace(q)
+	if raw == "" {
+		return nil
+	}
+	return &textSearch{
+		raw:      raw,
+		terms:    parseTextSearchTerms(raw),
+		postgres: db.Dialector.Name() == "postgres",
+	}
+}
+
+// parseTextSearchTerms turns q into to_tsquery terms that must all match.
+// Quoted text is a phrase and a trailing * makes a word a prefix, so
+// `"open data" gemeent*` gives ["open <-> data", "gemeent:*"]. Words joined by
+// punctuation, like e-mail, are matched as a phrase as well.
+func parseTextSearchTerms(q string) []string {
+	var terms []string
+	add := func(text string) {
+		prefix := strings.HasSuffix(strings.TrimSpace(text), "*")
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) == 0 {
+			return
+		}
+		if prefix {
+			words[len(words)-1] += ":*"
+		}
+		terms = append(terms, strings.Join(words, " <-> "))
+	}
+
+	for rest := q; rest != ""; {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if strings.HasPrefix(rest, `"`) {
+			phrase, after, _ := strings.Cut(rest[1:], `"`)
+			add(phrase)
+			rest = after
+			continue
+		}
+		end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+		if end < 0 {
+			end = len(rest)
+		}
+		add(rest[:end])
+		rest = rest[end:]
+	}
+	return terms
+}
+
+// tsquery returns the query matching every term in any of the dictionaries.
+func (s *textSearch) tsquery() (string, []any) {
+	if len(s.terms) == 0 {
+		return "''::tsquery", nil
+	}
+	parts := make([]string, 0, len(s.terms))
+	vars := make([]any, 0, len(s.terms)*len(textSearchConfigs))
+	for _, term := range s.terms {
+		configs := make([]string, 0, len(textSearchConfigs))
+		for _, config := range textSearchConfigs {
+			configs = append(configs, fmt.Sprintf("to_tsquery('%s', ?)", config))
+			vars = append(vars, term)
+		}
+		parts = append(parts, "("+strings.Join(configs, " || ")+")")
+	}
+	return "(" + strings.Join(parts, " && ") + ")", vars
+}
+
+// condition is the predicate selecting the repositories that match. columns
+// are additional text columns matched as a substring.
+func (s *textSearch) condition(columns ...string) (string, []any) {
+	var conditions []string
+	var vars []any
+	if s.postgres {
+		query, queryVars := s.tsquery()
+		conditions = append(conditions, "repositories.search_vector @@ "+query, organisationSearchVectorSQL+" @@ "+query)
+		vars = append(append(vars, queryVars...), queryVars...)
+	} else {
+		columns = append([]string{"repositories.name", "repositories.short_description", "repositories.long_description"}, columns...)
+	}
+
+	pattern := fmt.Sprintf("%%%s%%", commonquery.EscapeSQLLike(strings.ToLower(s.raw)))
+	for _, column := range columns {
+		conditions = append(conditions, "LOWER(COALESCE("+column+", '')) LIKE ? ESCAPE '\\'")
+		vars = append(vars, pattern)
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", vars
+}
+
+func (s *textSearch) where(db *gorm.DB, columns ...string) *gorm.DB {
+	condition, vars := s.condition(columns...)
+	return db.Where(condition, vars...)
+}
+
+// order sorts the repositories selected by db. On PostgreSQL the best matches
+// come first, with the organisation label weighing half of the lowest
+// search_vector weight; repositoryOrdering breaks ties.
+func (s *textSearch) order(db *gorm.DB) *gorm.DB {
+	if s == nil || !s.postgres {
+		return applyRepositoryOrdering(db)
+	}
+	query, vars := s.tsquery()
+	rank := "ts_rank(repositories.search_vector, " + query + ") + 0.5 * ts_rank(" + organisationSearchVectorSQL + ", " + query + ")"
+	return db.Order(clause.OrderBy{Expression: clause.Expr{
+		SQL:                rank + " DESC, " + strings.Join(repositoryOrdering, ", "),
+		Vars:               append(append([]any{}, vars...), vars...),
+		WithoutParentheses: true,
+	}})
+}
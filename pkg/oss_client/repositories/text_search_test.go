@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTextSearchTerms(t *testing.T) {
+	cases := map[string][]string{
+		"account":                     {"account"},
+		"  Account   API ":            {"account", "api"},
+		`"open data" gemeent*`:        {"open <-> data", "gemeent:*"},
+		`"Mijn Overheid`:              {"mijn <-> overheid"},
+		"e-mail":                      {"e <-> mail"},
+		"basis-reg*":                  {"basis <-> reg:*"},
+		`zaak"systeem"`:               {"zaak", "systeem"},
+		"'; DROP TABLE repositories;": {"drop", "table", "repositories"},
+		`* "" !`:                      nil,
+	}
+	for q, expected := range cases {
+		assert.Equal(t, expected, parseTextSearchTerms(q), q)
+	}
+}
+
+func TestTextSearchTsqueryMatchesEveryTermInAnyDictionary(t *testing.T) {
+	search := &textSearch{raw: `"open data" api*`, terms: []string{"open <-> data", "api:*"}, postgres: true}
+
+	query, vars := search.tsquery()
+	assert.Equal(t, "((to_tsquery('dutch', ?) || to_tsquery('english', ?)) && (to_tsquery('dutch', ?) || to_tsquery('english', ?)))", query)
+	assert.Equal(t, []any{"open <-> data", "open <-> data", "api:*", "api:*"}, vars)
+
+	condition, vars := search.condition("url")
+	assert.Contains(t, condition, "repositories.search_vector @@ "+query)
+	assert.Contains(t, condition, organisationSearchVectorSQL+" @@ "+query)
+	assert.Contains(t, condition, "LOWER(COALESCE(url, '')) LIKE ?")
+	assert.Len(t, vars, 9)
+	assert.Equal(t, `%"open data" api*%`, vars[8])
+}
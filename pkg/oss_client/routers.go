@@ -1,7 +1,15 @@
 package oss_client
 
 import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/audit"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/auth"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/handler"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/operation"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/ratelimit"
 	commonrouter "github.com/developer-overheid-nl/don-register-common/router"
 	"github.com/gin-gonic/gin"
 	"github.com/loopfz/gadgeto/tonic"
@@ -9,6 +17,10 @@ import (
 	"github.com/wI2L/fizz/openapi"
 )
 
+// EnvTrustedProxies bevat de komma-gescheiden IP-adressen of CIDR's van de
+// proxies waarvan X-Forwarded-For en X-Real-IP worden vertrouwd.
+const EnvTrustedProxies = "TRUSTED_PROXIES"
+
 var (
 	apiVersionHeader = fizz.Header(
 		"API-Version",
@@ -17,24 +29,80 @@ var (
 	)
 )
 
-func NewRouter(apiVersion string, controller *handler.OSSController) *fizz.Fizz {
+// RouterOption configures optional parts of the router.
+type RouterOption func(*routerOptions)
+
+type routerOptions struct {
+	authenticator *auth.Authenticator
+	limiter       *ratelimit.Limiter
+	audit         audit.Config
+}
+
+// WithAuthenticator enforces the security requirements of the routes.
+func WithAuthenticator(a *auth.Authenticator) RouterOption {
+	return func(o *routerOptions) {
+		o.authenticator = a
+	}
+}
+
+// WithRateLimiter limits the request rate per client and operation.
+func WithRateLimiter(l *ratelimit.Limiter) RouterOption {
+	return func(o *routerOptions) {
+		o.limiter = l
+	}
+}
+
+// WithAuditConfig configures the audit log; without it the defaults apply.
+func WithAuditConfig(cfg audit.Config) RouterOption {
+	return func(o *routerOptions) {
+		o.audit = cfg
+	}
+}
+
+func NewRouter(apiVersion string, controller *handler.OSSController, opts ...RouterOption) *fizz.Fizz {
+	var options routerOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	//gin.SetMode(gin.ReleaseMode)
 	g := commonrouter.NewEngine(apiVersion, commonrouter.CORSOptions{
-		AllowHeaders:  []string{"Origin", "Content-Length", "Content-Type", "Authorization", "API-Version", "X-Api-Key"},
-		ExposeHeaders: []string{"API-Version", "Link", "Total-Count", "Total-Pages", "Per-Page", "Current-Page"},
+		AllowHeaders:  []string{"Origin", "Content-Length", "Content-Type", "Authorization", "API-Version", "X-Api-Key", "If-Match", "If-None-Match"},
+		ExposeHeaders: []string{"API-Version", "Link", "Total-Count", "Total-Pages", "Per-Page", "Current-Page", "Next-Cursor", "ETag", ratelimit.HeaderLimit, ratelimit.HeaderRemaining, ratelimit.HeaderReset, "Retry-After"},
 	})
+	// Zonder vertrouwde proxies telt het IP-adres van de verbinding, zodat een
+	// client de rate limiter niet ontloopt met een eigen X-Forwarded-For.
+	if err := g.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Printf("[router] ignoring %s: %v", EnvTrustedProxies, err)
+		_ = g.SetTrustedProxies(nil)
+	}
+	// Organisatie-URI's worden URL-encoded als path parameter meegestuurd.
+	g.UseRawPath = true
 	commonrouter.InstallProblemHandlers(g, apiVersion)
 	f := fizz.NewFromEngine(g)
 
 	root := f.Group("/v1", "OSS v1", "OSS Register V1 routes")
+	operations := operation.Index(f.Generator())
+	// Audit eerst, zodat ook door authenticatie geweigerde verzoeken worden vastgelegd.
+	root.Use(audit.Middleware(controller.Service, operations, options.audit))
+	if options.authenticator != nil {
+		root.Use(options.authenticator.Middleware(auth.OperationRequirements(operations)))
+	}
+	// Na authenticatie, zodat clients op hun id worden geteld in plaats van op IP-adres.
+	if options.limiter != nil {
+		root.Use(options.limiter.Middleware(operations))
+	}
 
 	root.GET("/repositories",
 		[]fizz.OperationOption{
 			fizz.ID("listRepositories"),
 			fizz.Summary("List repositories"),
-			fizz.Description("Geeft een lijst terug met OSS repositories die in het register zijn opgenomen. Ondersteunt dezelfde filterquery's als het filterendpoint en combineert deze met de optionele zoekterm q."),
+			fizz.Description("Geeft een lijst terug met OSS repositories die in het register zijn opgenomen. Ondersteunt dezelfde filterquery's als het filterendpoint en combineert deze met de optionele zoekterm q. Met q worden de beste treffers eerst teruggegeven; staat Typesense aan, dan wordt q daar gezocht, met terugval op de database."),
 			fizz.Security(&openapi.SecurityRequirement{
-				"clientCredentials": {},
+				"apiKey": {},
+			}),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {"repositories:read"},
 			}),
 			apiVersionHeader,
 		},
@@ -48,7 +116,10 @@ func NewRouter(apiVersion string, controller *handler.OSSController) *fizz.Fizz
 			fizz.Description("Deprecated. Gebruik GET /repositories met de q query parameter en filters."),
 			fizz.Deprecated(true),
 			fizz.Security(&openapi.SecurityRequirement{
-				"clientCredentials": {},
+				"apiKey": {},
+			}),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {"repositories:read"},
 			}),
 			apiVersionHeader,
 		},
@@ -61,7 +132,10 @@ func NewRouter(apiVersion string, controller *handler.OSSController) *fizz.Fizz
 			fizz.Summary("Filter opties ophalen"),
 			fizz.Description("Geeft alle beschikbare filteropties terug met counts. Counts zijn berekend op basis van de meegegeven actieve filters en de optionele zoekterm q."),
 			fizz.Security(&openapi.SecurityRequirement{
-				"clientCredentials": {},
+				"apiKey": {},
+			}),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {"repositories:read"},
 			}),
 			apiVersionHeader,
 		},
@@ -74,7 +148,9 @@ func NewRouter(apiVersion string, controller *handler.OSSController) *fizz.Fizz
 			fizz.Summary("Get repository by id"),
 			fizz.Description("Geeft één OSS repository terug op basis van het id."),
 			fizz.Security(&openapi.SecurityRequirement{
-				"apiKey":            {},
+				"apiKey": {},
+			}),
+			fizz.Security(&openapi.SecurityRequirement{
 				"clientCredentials": {"repositories:read"},
 			}),
 			apiVersionHeader,
@@ -82,6 +158,22 @@ func NewRouter(apiVersion string, controller *handler.OSSController) *fizz.Fizz
 		tonic.Handler(controller.RetrieveRepository, 200),
 	)
 
+	root.GET("/repositories/:id/history",
+		[]fizz.OperationOption{
+			fizz.ID("listRepositoryHistory"),
+			fizz.Summary("Revisiegeschiedenis van een repository"),
+			fizz.Description("Geeft de revisies van een repository terug, nieuwste eerst. Elke revisie bevat tijdstip, actor en de gewijzigde velden met oude en nieuwe waarde. Ook beschikbaar voor verwijderde repositories."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"apiKey": {},
+			}),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {"repositories:read"},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.ListRepositoryHistory, 200),
+	)
+
 	root.PUT("/repositories/:id",
 		[]fizz.OperationOption{
 			fizz.ID("updateRepository"),
@@ -95,6 +187,32 @@ func NewRouter(apiVersion string, controller *handler.OSSController) *fizz.Fizz
 		tonic.Handler(controller.UpdateRepository, 200),
 	)
 
+	root.PATCH("/repositories/:id",
+		[]fizz.OperationOption{
+			fizz.ID("patchRepository"),
+			fizz.Summary("Specifieke repository deels updaten"),
+			fizz.Description("Past alleen de meegestuurde velden van een repository aan (JSON Merge Patch, application/merge-patch+json)."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {"repositories:write"},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.PatchRepository, 200),
+	)
+
+	root.DELETE("/repositories/:id",
+		[]fizz.OperationOption{
+			fizz.ID("deleteRepository"),
+			fizz.Summary("Specifieke repository verwijderen"),
+			fizz.Description("Verwijdert een repository uit het register. De repository blijft als tombstone bewaard; opvragen geeft daarna 410 Gone."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {"repositories:write"},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.DeleteRepository, 204),
+	)
+
 	root.POST("/repositories",
 		[]fizz.OperationOption{
 			fizz.ID("createRepository"),
@@ -108,13 +226,29 @@ func NewRouter(apiVersion string, controller *handler.OSSController) *fizz.Fizz
 		tonic.Handler(controller.CreateRepository, 201),
 	)
 
+	root.POST("/repositories/_bulk",
+		[]fizz.OperationOption{
+			fizz.ID("bulkUpsertRepositories"),
+			fizz.Summary("Repositories in bulk aanmaken of bijwerken"),
+			fizz.Description("Maakt repositories aan of werkt ze bij op basis van de url, in één transactie. Accepteert een JSON array (application/json) of NDJSON (application/x-ndjson) met maximaal 1000 items en geeft per item het resultaat terug."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {"repositories:write"},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.BulkUpsertRepositories, 200),
+	)
+
 	root.GET("/git-organisations",
 		[]fizz.OperationOption{
 			fizz.ID("listGitOrganisations"),
 			fizz.Summary("List git organisations"),
 			fizz.Description("Geeft een lijst terug met git organisations die in het register zijn opgenomen."),
 			fizz.Security(&openapi.SecurityRequirement{
-				"clientCredentials": {},
+				"apiKey": {},
+			}),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {"gitOrganisations:read"},
 			}),
 			apiVersionHeader,
 		},
@@ -134,13 +268,73 @@ func NewRouter(apiVersion string, controller *handler.OSSController) *fizz.Fizz
 		tonic.Handler(controller.CreateGitOrganisation, 201),
 	)
 
+	root.GET("/git-organisations/:id",
+		[]fizz.OperationOption{
+			fizz.ID("getGitOrganisation"),
+			fizz.Summary("Git organisation ophalen"),
+			fizz.Description("Geeft één git organisatie terug op basis van het id."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"apiKey": {},
+			}),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {"gitOrganisations:read"},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.RetrieveGitOrganisation, 200),
+	)
+
+	root.GET("/git-organisations/:id/repositories",
+		[]fizz.OperationOption{
+			fizz.ID("listGitOrganisationRepositories"),
+			fizz.Summary("Repositories van een git organisation"),
+			fizz.Description("Geeft de repositories terug waarvan de url onder de url van de git organisatie valt. Urls worden genormaliseerd vergeleken (host, hoofdletters, .git-suffix)."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"apiKey": {},
+			}),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {"gitOrganisations:read"},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.ListGitOrganisationRepositories, 200),
+	)
+
+	root.PUT("/git-organisations/:id",
+		[]fizz.OperationOption{
+			fizz.ID("updateGitOrganisation"),
+			fizz.Summary("Git organisation updaten"),
+			fizz.Description("Past de url en organisatie van een git organisatie aan."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {"gitOrganisations:write"},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.UpdateGitOrganisation, 200),
+	)
+
+	root.DELETE("/git-organisations/:id",
+		[]fizz.OperationOption{
+			fizz.ID("deleteGitOrganisation"),
+			fizz.Summary("Git organisation verwijderen"),
+			fizz.Description("Verwijdert een git organisatie. Repositories onder de git organisatie blijven bestaan."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {"gitOrganisations:write"},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.DeleteGitOrganisation, 204),
+	)
+
 	root.GET("/organisations",
 		[]fizz.OperationOption{
 			fizz.ID("listOrganisations"),
 			fizz.Summary("Alle organisaties ophalen"),
 			fizz.Description("Alle organisaties ophalen"),
 			fizz.Security(&openapi.SecurityRequirement{
-				"apiKey":            {},
+				"apiKey": {},
+			}),
+			fizz.Security(&openapi.SecurityRequirement{
 				"clientCredentials": {"organisations:read"},
 			}),
 			apiVersionHeader,
@@ -160,6 +354,151 @@ func NewRouter(apiVersion string, controller *handler.OSSController) *fizz.Fizz
 		},
 		tonic.Handler(controller.CreateOrganisation, 201),
 	)
+	root.GET("/organisations/:uri",
+		[]fizz.OperationOption{
+			fizz.ID("getOrganisation"),
+			fizz.Summary("Organisatie ophalen"),
+			fizz.Description("Geeft één organisatie terug op basis van de URL-encoded URI, met het aantal repositories en git organisaties."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"apiKey": {},
+			}),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {"organisations:read"},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.RetrieveOrganisation, 200),
+	)
+
+	root.PUT("/organisations/:uri",
+		[]fizz.OperationOption{
+			fizz.ID("updateOrganisation"),
+			fizz.Summary("Organisatie updaten"),
+			fizz.Description("Past het label van een organisatie aan."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {"organisations:write"},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.UpdateOrganisation, 200),
+	)
+
+	root.DELETE("/organisations/:uri",
+		[]fizz.OperationOption{
+			fizz.ID("deleteOrganisation"),
+			fizz.Summary("Organisatie verwijderen"),
+			fizz.Description("Verwijdert een organisatie. Geeft 409 zolang er nog repositories (ook verwijderde) of git organisaties naar verwijzen."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {"organisations:write"},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.DeleteOrganisation, 204),
+	)
+
+	root.GET("/audit-events",
+		[]fizz.OperationOption{
+			fizz.ID("listAuditEvents"),
+			fizz.Summary("Audit log ophalen"),
+			fizz.Description("Geeft de audit log van alle schrijvende verzoeken terug, nieuwste eerst. Filterbaar op actor, resource en tijdvak (from inclusief, to exclusief). Alleen voor admin clients."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {auth.ScopeAdmin},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.ListAuditEvents, 200),
+	)
+
+	root.GET("/search-index-operations",
+		[]fizz.OperationOption{
+			fizz.ID("listSearchIndexOperations"),
+			fizz.Summary("Zoekindex-outbox ophalen"),
+			fizz.Description("Geeft de openstaande (pending) en opgegeven (failed) Typesense-operaties uit de outbox terug, oudste eerst, met het aantal pogingen en de laatste fout. Alleen voor admin clients."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {auth.ScopeAdmin},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.ListSearchIndexOperations, 200),
+	)
+
+	root.POST("/search-index/reindex",
+		[]fizz.OperationOption{
+			fizz.ID("reindexSearchIndex"),
+			fizz.Summary("Zoekindex opnieuw opbouwen"),
+			fizz.Description("Bouwt de Typesense-index opnieuw op in een nieuwe collectie met een vast schema, importeert alle actieve repositories in bulk en zet daarna de alias in één keer om naar de nieuwe collectie. De vorige collectie wordt verwijderd. Zoeken blijft tijdens de herindexering werken. Alleen voor admin clients; geeft 409 als Typesense uit staat of er al een herindexering loopt."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {auth.ScopeAdmin},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.ReindexSearchIndex, 200),
+	)
+
+	root.GET("/search-index/curation",
+		[]fizz.OperationOption{
+			fizz.ID("retrieveSearchCuration"),
+			fizz.Summary("Zoekcuratie ophalen"),
+			fizz.Description("Geeft de synoniemen en vastgepinde resultaten terug die naar Typesense worden gestuurd: die uit het curatiebestand (TYPESENSE_CURATION_FILE) en de Nederlands-Engelse synoniemen van de filterlabels. Alleen voor admin clients."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {auth.ScopeAdmin},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.RetrieveSearchCuration, 200),
+	)
+
+	root.POST("/search-index/curation",
+		[]fizz.OperationOption{
+			fizz.ID("applySearchCuration"),
+			fizz.Summary("Zoekcuratie toepassen"),
+			fizz.Description("Stuurt de synoniemen en vastgepinde resultaten naar de live Typesense-collectie en verwijdert eerder door het register aangemaakte synoniemen en overrides die niet meer in de curatie staan. Een herindexering past de curatie ook toe. Alleen voor admin clients; geeft 409 als Typesense uit staat."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {auth.ScopeAdmin},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.ApplySearchCuration, 200),
+	)
+
+	root.GET("/jobs",
+		[]fizz.OperationOption{
+			fizz.ID("listJobs"),
+			fizz.Summary("Achtergrondtaken ophalen"),
+			fizz.Description("Geeft de geregistreerde achtergrondtaken terug met hun cron-schema (JOB_SCHEDULE_<NAAM>), het volgende geplande tijdstip, of de taak nu draait en de laatste run met duur, uitkomst, tellers en eventuele fout. Alleen voor admin clients."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {auth.ScopeAdmin},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.ListJobs, 200),
+	)
+
+	root.POST("/jobs/:name/run",
+		[]fizz.OperationOption{
+			fizz.ID("runJob"),
+			fizz.Summary("Achtergrondtaak starten"),
+			fizz.Description("Start de taak direct, buiten het schema om. De taak draait op de achtergrond; het antwoord bevat de gestarte run, die via GET /jobs te volgen is. Alleen voor admin clients; geeft 404 voor een onbekende taak en 409 als de taak al draait."),
+			fizz.Security(&openapi.SecurityRequirement{
+				"clientCredentials": {auth.ScopeAdmin},
+			}),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.RunJob, 202),
+	)
+
+	root.POST("/webhooks/:forge",
+		[]fizz.OperationOption{
+			fizz.ID("receiveWebhook"),
+			// Webhooks worden geauthenticeerd met het secret van de git organisatie.
+			fizz.WithoutSecurity(),
+			fizz.Summary("Webhook van een forge ontvangen"),
+			fizz.Description("Ontvangt push- en repository-events van GitHub, GitLab of Gitea. De handtekening wordt gecontroleerd met het webhook secret van de git organisatie; daarna wordt de bijbehorende repository direct ververst en opnieuw naar Typesense gestuurd."),
+			apiVersionHeader,
+		},
+		tonic.Handler(controller.HandleWebhook, 200),
+	)
+
 	// 6) OpenAPI documentatie
 	g.StaticFile("/v1/openapi.json", "./api/openapi.json")
 
@@ -169,3 +508,14 @@ func NewRouter(apiVersion string, controller *handler.OSSController) *fizz.Fizz
 func APIVersionMiddleware(version string) gin.HandlerFunc {
 	return commonrouter.APIVersionMiddleware(version)
 }
+
+// trustedProxies leest EnvTrustedProxies; standaard wordt geen proxy vertrouwd.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv(EnvTrustedProxies), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/auth"
+	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/google/uuid"
+)
+
+// RecordAuditEvent slaat een audit event op. Id en tijdstip worden gezet als ze
+// nog leeg zijn.
+func (s *RepositoryService) RecordAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	if event.Id == "" {
+		event.Id = uuid.NewString()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+	if event.Outcome == "" {
+		event.Outcome = models.AuditOutcomeSuccess
+		if event.Status >= 400 {
+			event.Outcome = models.AuditOutcomeFailure
+		}
+	}
+	return s.repo.SaveAuditEvent(ctx, event)
+}
+
+// ListAuditEvents geeft de audit log terug, nieuwste eerst. Alleen admin clients
+// mogen de audit log inzien.
+func (s *RepositoryService) ListAuditEvents(ctx context.Context, p *models.ListAuditEventsParams) ([]models.AuditEvent, models.Pagination, error) {
+	if !auth.IsAdmin(ctx) {
+		return nil, models.Pagination{}, problem.NewForbidden("Admin access required")
+	}
+	if p == nil {
+		p = &models.ListAuditEventsParams{}
+	}
+
+	filter := models.AuditEventFilter{
+		Actor:        trimPtr(p.Actor),
+		ResourceType: trimPtr(p.ResourceType),
+		ResourceId:   trimPtr(p.ResourceId),
+	}
+	var details []problem.ErrorDetail
+	var err error
+	if filter.From, err = parseAuditTime(p.From); err != nil {
+		details = append(details, queryError("from", "date-time", "from must be an RFC 3339 date-time"))
+	}
+	if filter.To, err = parseAuditTime(p.To); err != nil {
+		details = append(details, queryError("to", "date-time", "to must be an RFC 3339 date-time"))
+	}
+	if len(details) == 0 && filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		details = append(details, queryError("to", "range", "to must be after from"))
+	}
+	if len(details) > 0 {
+		return nil, models.Pagination{}, problem.NewBadRequest("Invalid input", details...)
+	}
+
+	events, pagination, err := s.repo.GetAuditEvents(ctx, p.Page, p.PerPage, filter)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	if events == nil {
+		events = []models.AuditEvent{}
+	}
+	return events, pagination, nil
+}
+
+func parseAuditTime(val *string) (*time.Time, error) {
+	trimmed := trimPtr(val)
+	if trimmed == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, trimmed)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
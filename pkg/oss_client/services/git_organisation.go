@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
+	util "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/util"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+)
+
+func (s *RepositoryService) RetrieveGitOrganisation(ctx context.Context, id string) (*models.GitOrganisatie, error) {
+	return s.findGitOrganisation(ctx, id)
+}
+
+// UpdateGitOrganisation vervangt url en organisatie van een git organisatie. Een
+// url die al bij een andere git organisatie hoort geeft 409. Het webhook secret
+// blijft staan als het niet is meegestuurd; een lege string verwijdert het.
+func (s *RepositoryService) UpdateGitOrganisation(ctx context.Context, id string, requestBody models.GitOrganisationInput) (*models.GitOrganisatie, error) {
+	gitOrg, err := s.findGitOrganisation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOrganisationAccessPtr(ctx, gitOrg.OrganisationID); err != nil {
+		return nil, err
+	}
+
+	gitURL, organisation, err := s.resolveGitOrganisationInput(ctx, requestBody)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOrganisationAccess(ctx, organisation.Uri); err != nil {
+		return nil, err
+	}
+
+	existingByURL, err := s.repo.FindGitOrganisationByURL(ctx, gitURL)
+	if err != nil {
+		return nil, err
+	}
+	if existingByURL != nil && existingByURL.Id != gitOrg.Id {
+		return nil, problem.New(http.StatusConflict, "Git organisation already exists",
+			bodyError("url", "conflict", "url belongs to another git organisation"),
+		)
+	}
+
+	gitOrg.Url = gitURL
+	gitOrg.OrganisationID = &organisation.Uri
+	gitOrg.Organisation = organisation
+	if requestBody.WebhookSecret != nil {
+		gitOrg.WebhookSecret = strings.TrimSpace(*requestBody.WebhookSecret)
+	}
+	if err := s.repo.SaveGitOrganisatie(ctx, gitOrg); err != nil {
+		return nil, err
+	}
+	return gitOrg, nil
+}
+
+// DeleteGitOrganisation verwijdert een git organisatie. Repositories die eronder
+// vallen blijven bestaan.
+func (s *RepositoryService) DeleteGitOrganisation(ctx context.Context, id string) error {
+	gitOrg, err := s.findGitOrganisation(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := checkOrganisationAccessPtr(ctx, gitOrg.OrganisationID); err != nil {
+		return err
+	}
+	return s.repo.DeleteGitOrganisation(ctx, gitOrg.Id)
+}
+
+// ListGitOrganisationRepositories geeft de repositories terug waarvan de url onder
+// de url van de git organisatie valt.
+func (s *RepositoryService) ListGitOrganisationRepositories(ctx context.Context, p *models.ListGitOrganisationRepositoriesParams) ([]models.RepositorySummary, models.Pagination, error) {
+	gitOrg, err := s.findGitOrganisation(ctx, p.Id)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	repositories, pagination, err := s.repo.GetGitOrganisationRepositories(ctx, gitOrg.Url, p.Page, p.PerPage)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	dtos := make([]models.RepositorySummary, len(repositories))
+	for i := range repositories {
+		dtos[i] = util.ToRepositorySummary(&repositories[i])
+	}
+	return dtos, pagination, nil
+}
+
+func (s *RepositoryService) findGitOrganisation(ctx context.Context, id string) (*models.GitOrganisatie, error) {
+	if err := validateRepositoryID(id); err != nil {
+		return nil, err
+	}
+	gitOrg, err := s.repo.GetGitOrganisationByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if gitOrg == nil {
+		return nil, problem.NewNotFound("Resource does not exist")
+	}
+	return gitOrg, nil
+}
+
+// resolveGitOrganisationInput valideert de urls uit de input en zoekt de organisatie op.
+func (s *RepositoryService) resolveGitOrganisationInput(ctx context.Context, requestBody models.GitOrganisationInput) (string, *models.Organisation, error) {
+	gitURL := strings.TrimSpace(requestBody.Url)
+	orgURL := strings.TrimSpace(requestBody.OrganisationUri)
+
+	if _, err := url.ParseRequestURI(gitURL); err != nil {
+		return "", nil, problem.NewBadRequest("Invalid input",
+			bodyError("url", "url", "must be a valid URL"),
+		)
+	}
+	if _, err := url.ParseRequestURI(orgURL); err != nil {
+		return "", nil, problem.NewBadRequest("Invalid input",
+			bodyError("organisationUri", "url", "must be a valid URL"),
+		)
+	}
+
+	organisation, err := s.repo.FindOrganisationByURI(ctx, orgURL)
+	if err != nil {
+		return "", nil, err
+	}
+	if organisation == nil {
+		return "", nil, problem.NewNotFound("Resource does not exist")
+	}
+	return gitURL, organisation, nil
+}
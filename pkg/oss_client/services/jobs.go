@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/auth"
+	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+)
+
+var (
+	// ErrJobNotFound wordt door een JobScheduler teruggegeven voor een onbekende job.
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobRunning wordt door een JobScheduler teruggegeven als de job al draait.
+	ErrJobRunning = errors.New("job is already running")
+)
+
+// JobScheduler voert de achtergrondjobs uit; jobs.Scheduler implementeert hem.
+type JobScheduler interface {
+	Jobs(ctx context.Context) ([]models.Job, error)
+	Trigger(name string) (*models.JobRun, error)
+}
+
+// SetJobScheduler koppelt de scheduler waarmee admin clients jobs inzien en
+// starten. Zonder scheduler zijn er geen jobs.
+func (s *RepositoryService) SetJobScheduler(scheduler JobScheduler) {
+	s.jobs = scheduler
+}
+
+// ListJobs geeft de geregistreerde jobs terug met hun schema en laatste
+// uitvoering. Alleen admin clients mogen ze inzien.
+func (s *RepositoryService) ListJobs(ctx context.Context) ([]models.Job, error) {
+	if !auth.IsAdmin(ctx) {
+		return nil, problem.NewForbidden("Admin access required")
+	}
+	if s.jobs == nil {
+		return []models.Job{}, nil
+	}
+	return s.jobs.Jobs(ctx)
+}
+
+// RunJob start een job buiten zijn schema om en geeft de lopende uitvoering
+// terug; de job draait op de achtergrond verder.
+func (s *RepositoryService) RunJob(ctx context.Context, name string) (*models.JobRun, error) {
+	if !auth.IsAdmin(ctx) {
+		return nil, problem.NewForbidden("Admin access required")
+	}
+	name = strings.TrimSpace(name)
+	if s.jobs == nil {
+		return nil, problem.NewNotFound("Job not found")
+	}
+	run, err := s.jobs.Trigger(name)
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		return nil, problem.NewNotFound("Job not found")
+	case errors.Is(err, ErrJobRunning):
+		return nil, problem.New(http.StatusConflict, "Job already running")
+	case err != nil:
+		return nil, err
+	}
+	return run, nil
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/repositories"
+)
+
+// RetrieveOrganisation geeft een organisatie terug met het aantal repositories
+// en git organisaties dat ernaar verwijst.
+func (s *RepositoryService) RetrieveOrganisation(ctx context.Context, uri string) (*models.OrganisationDetail, error) {
+	org, err := s.findOrganisation(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	return s.organisationDetail(ctx, org)
+}
+
+// UpdateOrganisation past het label van een bestaande organisatie aan. Omdat het
+// label in de zoekdocumenten staat, krijgen de repositories van de organisatie
+// in dezelfde transactie een operatie in de outbox.
+func (s *RepositoryService) UpdateOrganisation(ctx context.Context, uri, label string) (*models.OrganisationDetail, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return nil, problem.NewBadRequest("Invalid input",
+			bodyError("label", "required", "label is required"),
+		)
+	}
+
+	org, err := s.findOrganisation(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := org.Label != label
+	org.Label = label
+	err = s.repo.Transaction(ctx, func(repo repositories.RepositoriesRepository) error {
+		if err := repo.SaveOrganisatie(org); err != nil {
+			return err
+		}
+		if !changed {
+			return nil
+		}
+		_, err := repo.EnqueueOrganisationSearchIndexOperations(ctx, org.Uri)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.organisationDetail(ctx, org)
+}
+
+// DeleteOrganisation verwijdert een organisatie zolang er geen repositories
+// (ook geen verwijderde) of git organisaties meer naar verwijzen.
+func (s *RepositoryService) DeleteOrganisation(ctx context.Context, uri string) error {
+	org, err := s.findOrganisation(ctx, uri)
+	if err != nil {
+		return err
+	}
+
+	refs, err := s.repo.CountOrganisationReferences(ctx, org.Uri)
+	if err != nil {
+		return err
+	}
+
+	var details []problem.ErrorDetail
+	if n := refs.Repositories + refs.DeletedRepositories; n > 0 {
+		details = append(details, pathError("uri", "referenced",
+			fmt.Sprintf("organisation is referenced by %d repositories", n)))
+	}
+	if refs.GitOrganisations > 0 {
+		details = append(details, pathError("uri", "referenced",
+			fmt.Sprintf("organisation is referenced by %d git organisations", refs.GitOrganisations)))
+	}
+	if len(details) > 0 {
+		return problem.New(http.StatusConflict, "Organisation is still in use", details...)
+	}
+
+	return s.repo.DeleteOrganisation(ctx, org.Uri)
+}
+
+func (s *RepositoryService) findOrganisation(ctx context.Context, uri string) (*models.Organisation, error) {
+	uri = strings.TrimSpace(uri)
+	if _, err := url.ParseRequestURI(uri); err != nil {
+		return nil, problem.NewBadRequest("Invalid input",
+			pathError("uri", "url", "must be a valid URL"),
+		)
+	}
+
+	org, err := s.repo.FindOrganisationByURI(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, problem.NewNotFound("Resource does not exist")
+	}
+	return org, nil
+}
+
+func (s *RepositoryService) organisationDetail(ctx context.Context, org *models.Organisation) (*models.OrganisationDetail, error) {
+	refs, err := s.repo.CountOrganisationReferences(ctx, org.Uri)
+	if err != nil {
+		return nil, err
+	}
+	return &models.OrganisationDetail{
+		Uri:                  org.Uri,
+		Label:                org.Label,
+		RepositoryCount:      refs.Repositories,
+		GitOrganisationCount: refs.GitOrganisations,
+	}, nil
+}
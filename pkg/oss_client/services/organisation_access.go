@@ -0,0 +1,29 @@
+package services
+
+import (
+	"context"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/auth"
+	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
+)
+
+// checkOrganisationAccess geeft 403 als de aanroepende client geen gegevens van
+// de organisatie mag schrijven. Admins en aanroepen zonder client (jobs,
+// webhooks, authenticatie uitgeschakeld) mogen alles.
+func checkOrganisationAccess(ctx context.Context, organisationURI string) error {
+	if auth.CanWriteOrganisation(ctx, organisationURI) {
+		return nil
+	}
+	return problem.NewForbidden("Organisation not permitted",
+		bodyError("organisationUri", "forbidden", "client may not write data of this organisation"),
+	)
+}
+
+// checkOrganisationAccessPtr doet hetzelfde voor de optionele organisatie van
+// een opgeslagen repository of git organisatie.
+func checkOrganisationAccessPtr(ctx context.Context, organisationURI *string) error {
+	if organisationURI == nil {
+		return checkOrganisationAccess(ctx, "")
+	}
+	return checkOrganisationAccess(ctx, *organisationURI)
+}
@@ -8,7 +8,6 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 	"unicode/utf8"
 
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/httpclient"
@@ -23,6 +22,7 @@ import (
 // RepositoryService implementeert RepositoriesServicer met de benodigde repository
 type RepositoryService struct {
 	repo repositories.RepositoriesRepository
+	jobs JobScheduler
 }
 
 // NewRepositoryService Constructor-functie
@@ -36,11 +36,28 @@ func (s *RepositoryService) ListRepositorys(ctx context.Context, p *models.ListR
 	if p == nil {
 		p = &models.ListRepositorysParams{}
 	}
-
-	repositories, pagination, err := s.repo.GetRepositorys(ctx, p.Page, p.PerPage, p.RepositoryFilters())
+	if err := validateRepositorySort(p.Sort, p.Query); err != nil {
+		return nil, models.Pagination{}, err
+	}
+	filters := p.RepositoryFilters()
+	after, err := cursorAfter(p.Cursor)
 	if err != nil {
 		return nil, models.Pagination{}, err
 	}
+	if after != nil && p.Sort != "" {
+		return nil, models.Pagination{}, problem.NewBadRequest("Invalid input",
+			queryError("sort", "conflict", "sort cannot be combined with cursor; cursor pages are ordered by id"),
+		)
+	}
+	filters.After = after
+
+	repositories, pagination, ok := s.searchRepositories(ctx, p.Page, p.PerPage, filters)
+	if !ok {
+		repositories, pagination, err = s.repo.GetRepositorys(ctx, p.Page, p.PerPage, filters)
+		if err != nil {
+			return nil, models.Pagination{}, err
+		}
+	}
 
 	dtos := make([]models.RepositorySummary, len(repositories))
 	for i, repository := range repositories {
@@ -50,8 +67,80 @@ func (s *RepositoryService) ListRepositorys(ctx context.Context, p *models.ListR
 	return dtos, pagination, nil
 }
 
+// searchRepositories zoekt q via Typesense wanneer dat aan staat en alle
+// filters als tags in de index staan, en haalt de gevonden repositories in de
+// volgorde van Typesense uit de database. ok is false wanneer de lijst via SQL
+// moet lopen, ook als Typesense niet bereikbaar is of een fout geeft.
+func (s *RepositoryService) searchRepositories(ctx context.Context, page, perPage int, filters *models.RepositoryFiltersParams) ([]models.Repository, models.Pagination, bool) {
+	if filters.After != nil || (filters.Sort != "" && filters.Sort != models.RepositorySortRelevance) {
+		return nil, models.Pagination{}, false
+	}
+	if !typesense.Searchable(filters) || !typesense.SearchEnabled() {
+		return nil, models.Pagination{}, false
+	}
+	if page < 1 {
+		page = 1
+	}
+	if perPage <= 0 {
+		perPage = 20
+	}
+
+	result, err := typesense.SearchRepositories(ctx, filters, page, perPage)
+	if err != nil {
+		log.Printf("[typesense] search failed, falling back to SQL: %v", err)
+		return nil, models.Pagination{}, false
+	}
+	repositories, err := s.repo.GetRepositoriesByIDs(ctx, result.IDs)
+	if err != nil {
+		log.Printf("[typesense] loading search results failed, falling back to SQL: %v", err)
+		return nil, models.Pagination{}, false
+	}
+	return repositories, models.NewPagination(page, perPage, result.Found), true
+}
+
+// cursorAfter geeft het id waarna een cursorpagina begint, of nil zonder
+// cursor (paginering met page).
+func cursorAfter(cursor string) (*string, error) {
+	if strings.TrimSpace(cursor) == "" {
+		return nil, nil
+	}
+	after, err := util.DecodeCursor(cursor)
+	if err != nil {
+		return nil, problem.NewBadRequest("Invalid input",
+			queryError("cursor", "format", "cursor must be * or a Next-Cursor value from a previous response"),
+		)
+	}
+	return &after, nil
+}
+
+// validateRepositorySort controleert de sort parameter van de repositorylijst.
+func validateRepositorySort(sort, query string) error {
+	switch strings.TrimPrefix(sort, "-") {
+	case "", models.RepositorySortName, models.RepositorySortLastActivityAt, models.RepositorySortCreatedAt,
+		models.RepositorySortLastCrawledAt, models.RepositorySortOrganisation:
+		return nil
+	case models.RepositorySortRelevance:
+		if strings.HasPrefix(sort, "-") {
+			break
+		}
+		if strings.TrimSpace(query) == "" {
+			return problem.NewBadRequest("Invalid input",
+				queryError("sort", "dependentRequired", "sort=relevance requires the q query parameter"),
+			)
+		}
+		return nil
+	}
+	return problem.NewBadRequest("Invalid input",
+		queryError("sort", "enum", "sort must be one of name, -name, lastActivityAt, -lastActivityAt, createdAt, -createdAt, lastCrawledAt, -lastCrawledAt, organisation, -organisation, relevance"),
+	)
+}
+
 func (s *RepositoryService) ListGitOrganisations(ctx context.Context, p *models.ListGitOrganisationsParams) ([]models.GitOrganisatieSummary, models.Pagination, error) {
-	gitOrganisations, pagination, err := s.repo.GetGitOrganisations(ctx, p.Page, p.PerPage, p.Organisation)
+	after, err := cursorAfter(p.Cursor)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	gitOrganisations, pagination, err := s.repo.GetGitOrganisations(ctx, p.Page, p.PerPage, p.Organisation, after)
 	if err != nil {
 		return nil, models.Pagination{}, err
 	}
@@ -64,26 +153,12 @@ func (s *RepositoryService) ListGitOrganisations(ctx context.Context, p *models.
 }
 
 func (s *RepositoryService) CreateGitOrganisatie(ctx context.Context, requestBody models.GitOrganisationInput) (*models.GitOrganisatie, error) {
-	gitURL := strings.TrimSpace(requestBody.Url)
-	orgURL := strings.TrimSpace(requestBody.OrganisationUri)
-
-	if _, err := url.ParseRequestURI(gitURL); err != nil {
-		return nil, problem.NewBadRequest("Invalid input",
-			bodyError("url", "url", "must be a valid URL"),
-		)
-	}
-	if _, err := url.ParseRequestURI(orgURL); err != nil {
-		return nil, problem.NewBadRequest("Invalid input",
-			bodyError("organisationUri", "url", "must be a valid URL"),
-		)
-	}
-
-	organisation, err := s.repo.FindOrganisationByURI(ctx, orgURL)
+	gitURL, organisation, err := s.resolveGitOrganisationInput(ctx, requestBody)
 	if err != nil {
 		return nil, err
 	}
-	if organisation == nil {
-		return nil, problem.NewNotFound("Resource does not exist")
+	if err := checkOrganisationAccess(ctx, organisation.Uri); err != nil {
+		return nil, err
 	}
 
 	existingByURL, err := s.repo.FindGitOrganisationByURL(ctx, gitURL)
@@ -100,6 +175,9 @@ func (s *RepositoryService) CreateGitOrganisatie(ctx context.Context, requestBod
 		Organisation:   organisation,
 		Url:            gitURL,
 	}
+	if requestBody.WebhookSecret != nil {
+		gitOrg.WebhookSecret = strings.TrimSpace(*requestBody.WebhookSecret)
+	}
 	if err := s.repo.SaveGitOrganisatie(ctx, gitOrg); err != nil {
 		return nil, err
 	}
@@ -115,10 +193,29 @@ func (s *RepositoryService) RetrieveRepository(ctx context.Context, id string) (
 	if err != nil || api == nil {
 		return nil, err
 	}
+	if api.DeletedAt != nil {
+		return nil, problem.NewGone("Resource has been deleted")
+	}
 	detail := util.ToRepositoryDetail(api)
 	return detail, nil
 }
 
+// ListRepositoryHistory geeft de revisies van een repository terug, nieuwste eerst.
+func (s *RepositoryService) ListRepositoryHistory(ctx context.Context, p *models.ListRepositoryHistoryParams) ([]models.RepositoryRevision, models.Pagination, error) {
+	if err := validateRepositoryID(p.Id); err != nil {
+		return nil, models.Pagination{}, err
+	}
+	existing, err := s.repo.GetRepositoryByID(ctx, p.Id)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	if existing == nil {
+		return nil, models.Pagination{}, problem.NewNotFound("Resource does not exist")
+	}
+
+	return s.repo.GetRepositoryRevisions(ctx, p.Id, p.Page, p.PerPage)
+}
+
 func (s *RepositoryService) SearchRepositorys(ctx context.Context, p *models.ListRepositorysSearchParams) ([]models.RepositorySummary, models.Pagination, error) {
 	if p == nil {
 		p = &models.ListRepositorysSearchParams{}
@@ -141,7 +238,7 @@ func (s *RepositoryService) SearchRepositorys(ctx context.Context, p *models.Lis
 }
 
 func (s *RepositoryService) CreateRepository(ctx context.Context, requestBody models.RepositoryInput) (*models.RepositoryDetail, error) {
-	repo := util.ApplyRepositoryInput(nil, &requestBody)
+	repo := util.ApplyRepositoryInputContext(ctx, nil, &requestBody)
 	repo.Active = true
 
 	repoURL := strings.TrimSpace(repo.Url)
@@ -175,6 +272,27 @@ func (s *RepositoryService) CreateRepository(ctx context.Context, requestBody mo
 	if org == nil {
 		return nil, problem.NewNotFound("Resource does not exist")
 	}
+	if err := checkOrganisationAccess(ctx, org.Uri); err != nil {
+		return nil, err
+	}
+
+	// Een POST met de url van een bestaande repository werkt die bij, zoals bulk;
+	// de client moet dan ook voor de huidige organisatie mogen schrijven. Een
+	// verwijderde repository komt niet terug, net als bij bulk en de crawler.
+	existing, err := s.repo.FindRepositoryByURL(ctx, repoURL)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.DeletedAt != nil {
+		return nil, problem.New(http.StatusGone, "Resource has been deleted",
+			bodyError("url", "gone", "repository has been deleted"),
+		)
+	}
+	if existing != nil {
+		if err := checkOrganisationAccessPtr(ctx, existing.OrganisationID); err != nil {
+			return nil, err
+		}
+	}
 
 	repo.OrganisationID = &org.Uri
 	repo.Organisation = org
@@ -182,15 +300,12 @@ func (s *RepositoryService) CreateRepository(ctx context.Context, requestBody mo
 		return nil, err
 	}
 
-	if typesense.Enabled() {
-		repoCopy := *repo
-		go s.publishToTypesense(repoCopy)
-	}
-
 	return util.ToRepositoryDetail(repo), nil
 }
 
-func (s *RepositoryService) UpdateRepository(ctx context.Context, id string, requestBody models.RepositoryInput) (*models.RepositoryDetail, error) {
+// UpdateRepository vervangt een repository. Een niet-lege ifMatch wordt vergeleken
+// met de ETag van de opgeslagen versie.
+func (s *RepositoryService) UpdateRepository(ctx context.Context, id, ifMatch string, requestBody models.RepositoryInput) (*models.RepositoryDetail, error) {
 	if err := validateRepositoryID(id); err != nil {
 		return nil, err
 	}
@@ -201,8 +316,17 @@ func (s *RepositoryService) UpdateRepository(ctx context.Context, id string, req
 	if existing == nil {
 		return nil, problem.NewNotFound("Resource does not exist")
 	}
+	if existing.DeletedAt != nil {
+		return nil, problem.NewGone("Resource has been deleted")
+	}
+	if err := checkRepositoryPrecondition(existing, ifMatch); err != nil {
+		return nil, err
+	}
+	if err := checkOrganisationAccessPtr(ctx, existing.OrganisationID); err != nil {
+		return nil, err
+	}
 
-	updated := util.ApplyRepositoryInput(existing, &requestBody)
+	updated := util.ApplyRepositoryInputContext(ctx, existing, &requestBody)
 	updated.Id = id
 	updated.Active = true
 
@@ -233,24 +357,59 @@ func (s *RepositoryService) UpdateRepository(ctx context.Context, id string, req
 		if org == nil {
 			return nil, problem.NewNotFound("Resource does not exist")
 		}
+		if err := checkOrganisationAccess(ctx, org.Uri); err != nil {
+			return nil, err
+		}
 		updated.OrganisationID = &org.Uri
 		updated.Organisation = org
 	}
 
 	if err := s.repo.SaveRepository(ctx, updated); err != nil {
-		return nil, err
+		return nil, repositorySaveError(err)
 	}
 
-	if typesense.Enabled() {
-		updatedCopy := *updated
-		go s.publishToTypesense(updatedCopy)
+	return util.ToRepositoryDetail(updated), nil
+}
+
+// DeleteRepository soft-deletes a repository and removes it from the search index.
+func (s *RepositoryService) DeleteRepository(ctx context.Context, id string) error {
+	if err := validateRepositoryID(id); err != nil {
+		return err
+	}
+	existing, err := s.repo.GetRepositoryByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return problem.NewNotFound("Resource does not exist")
+	}
+	if existing.DeletedAt != nil {
+		return problem.NewGone("Resource has been deleted")
+	}
+	if err := checkOrganisationAccessPtr(ctx, existing.OrganisationID); err != nil {
+		return err
 	}
 
-	return util.ToRepositoryDetail(updated), nil
+	if err := s.repo.DeleteRepository(ctx, id); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func (s *RepositoryService) ListOrganisations(ctx context.Context, p *models.ListOrganisationsParams) ([]models.OrganisationSummary, models.Pagination, error) {
-	organisations, pagination, err := s.repo.GetOrganisations(ctx, p.Page, p.PerPage)
+	if p == nil {
+		p = &models.ListOrganisationsParams{}
+	}
+	switch strings.TrimPrefix(p.Sort, "-") {
+	case "", models.OrganisationSortLabel, models.OrganisationSortRepositoryCount:
+	default:
+		return nil, models.Pagination{}, problem.NewBadRequest("Invalid input",
+			queryError("sort", "enum", "sort must be one of label, -label, repositoryCount, -repositoryCount"),
+		)
+	}
+
+	organisations, pagination, err := s.repo.GetOrganisations(ctx, p.Page, p.PerPage, p.OrganisationFilters())
 	if err != nil {
 		return nil, models.Pagination{}, err
 	}
@@ -298,53 +457,6 @@ func (s *RepositoryService) CreateOrganisation(ctx context.Context, org *models.
 	return org, nil
 }
 
-func (s *RepositoryService) publishToTypesense(repository models.Repository) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := typesense.PublishRepository(ctx, &repository); err != nil {
-		if errors.Is(err, typesense.ErrDisabled) {
-			return
-		}
-		log.Printf("[typesense] indexing failed for repository=%s: %v", repository.Id, err)
-	}
-}
-
-// PublishAllRepositoriesToTypesense pushes every active stored repository to Typesense.
-func (s *RepositoryService) PublishAllRepositoriesToTypesense(ctx context.Context) error {
-	if !typesense.Enabled() {
-		log.Printf("[typesense] indexing disabled; skip bulk publish")
-		return nil
-	}
-
-	repos, err := s.repo.AllRepositorys(ctx)
-	if err != nil {
-		return err
-	}
-
-	for _, repository := range repos {
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-		if !repository.Active {
-			continue
-		}
-
-		repoCopy := repository
-		itemCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-		err := typesense.PublishRepository(itemCtx, &repoCopy)
-		cancel()
-		if err != nil {
-			if errors.Is(err, typesense.ErrDisabled) {
-				log.Printf("[typesense] indexing disabled tijdens bulk run; stop")
-				return nil
-			}
-			log.Printf("[typesense] bulk indexing failed for repository=%s: %v", repoCopy.Id, err)
-		}
-	}
-	return nil
-}
-
 func (s *RepositoryService) GetRepositoryFilters(ctx context.Context, p *models.RepositoryFiltersParams) ([]models.FilterGroup, error) {
 	counts, err := s.repo.GetRepositoryFilterCounts(ctx, p)
 	if err != nil {
@@ -398,6 +510,15 @@ func pathError(field, code, detail string) problem.ErrorDetail {
 	}
 }
 
+func headerError(field, code, detail string) problem.ErrorDetail {
+	return problem.ErrorDetail{
+		In:       "header",
+		Location: fmt.Sprintf("#/%s", field),
+		Code:     code,
+		Detail:   detail,
+	}
+}
+
 func queryError(field, code, detail string) problem.ErrorDetail {
 	return problem.ErrorDetail{
 		In:       "query",
@@ -414,6 +535,23 @@ func trimPtr(val *string) string {
 	return strings.TrimSpace(*val)
 }
 
+func checkRepositoryPrecondition(repository *models.Repository, ifMatch string) error {
+	if strings.TrimSpace(ifMatch) == "" {
+		return nil
+	}
+	if !util.ETagMatchesStrong(ifMatch, util.RepositoryETag(repository.Version)) {
+		return problem.NewPreconditionFailed("Repository has been modified")
+	}
+	return nil
+}
+
+func repositorySaveError(err error) error {
+	if errors.Is(err, repositories.ErrVersionConflict) {
+		return problem.NewPreconditionFailed("Repository has been modified")
+	}
+	return err
+}
+
 func validateRepositoryID(id string) error {
 	if id == "" {
 		return problem.NewBadRequest("Invalid input",
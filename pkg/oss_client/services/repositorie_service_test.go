@@ -1,20 +1,29 @@
 package services_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/auth"
 	httpclient "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/httpclient"
 	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
 	util "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/util"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/repositories"
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/services"
+	commonpagination "github.com/developer-overheid-nl/don-register-common/pagination"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
@@ -23,16 +32,31 @@ import (
 type stubRepo struct {
 	listFunc            func(ctx context.Context, page, perPage int, p *models.RepositoryFiltersParams) ([]models.Repository, models.Pagination, error)
 	retrieveFunc        func(ctx context.Context, id string) (*models.Repository, error)
+	byIDsFunc           func(ctx context.Context, ids []string) ([]models.Repository, error)
+	indexOpsFunc        func(ctx context.Context, page, perPage int, status string) ([]models.SearchIndexOperation, models.Pagination, error)
 	searchFunc          func(ctx context.Context, page, perPage int, organisation *string, query string) ([]models.Repository, models.Pagination, error)
 	saveRepositoryFunc  func(ctx context.Context, repository *models.Repository) error
+	deleteRepoFunc      func(ctx context.Context, id string) error
+	findRepoByURLFunc   func(ctx context.Context, url string) (*models.Repository, error)
+	findRepoByNormFunc  func(ctx context.Context, url string) (*models.Repository, error)
+	revisionsFunc       func(ctx context.Context, repositoryID string, page, perPage int) ([]models.RepositoryRevision, models.Pagination, error)
 	allRepositoriesFunc func(ctx context.Context) ([]models.Repository, error)
 	saveOrgFunc         func(org *models.Organisation) error
-	getOrgFunc          func(ctx context.Context, page, perPage int) ([]models.Organisation, models.Pagination, error)
-	gitOrgListFunc      func(ctx context.Context, page, perPage int, organisation *string) ([]models.GitOrganisatie, models.Pagination, error)
+	getOrgFunc          func(ctx context.Context, page, perPage int, p *models.OrganisationFilters) ([]models.Organisation, models.Pagination, error)
+	gitOrgListFunc      func(ctx context.Context, page, perPage int, organisation, after *string) ([]models.GitOrganisatie, models.Pagination, error)
 	findOrgByURIF       func(ctx context.Context, uri string) (*models.Organisation, error)
 	findGitOrgByURLFunc func(ctx context.Context, url string) (*models.GitOrganisatie, error)
 	saveGitOrgFunc      func(ctx context.Context, gitOrg *models.GitOrganisatie) error
 	filterCountsFunc    func(ctx context.Context, p *models.RepositoryFiltersParams) (*models.RepositoryFilterCounts, error)
+	saveAuditEventFunc  func(ctx context.Context, event *models.AuditEvent) error
+	auditEventsFunc     func(ctx context.Context, page, perPage int, filter models.AuditEventFilter) ([]models.AuditEvent, models.Pagination, error)
+	orgRefsFunc         func(ctx context.Context, uri string) (*models.OrganisationReferences, error)
+	deleteOrgFunc       func(ctx context.Context, uri string) error
+	getGitOrgFunc       func(ctx context.Context, id string) (*models.GitOrganisatie, error)
+	deleteGitOrgFunc    func(ctx context.Context, id string) error
+	gitOrgReposFunc     func(ctx context.Context, gitOrganisationURL string, page, perPage int) ([]models.Repository, models.Pagination, error)
+	tryLockFunc         func(ctx context.Context, name string) (func(), bool, error)
+	enqueueOrgFunc      func(ctx context.Context, uri string) (int, error)
 }
 
 type fakePublicCodeValidator struct{}
@@ -48,6 +72,32 @@ func (s *stubRepo) GetRepositorys(ctx context.Context, page, perPage int, p *mod
 	return nil, models.Pagination{}, nil
 }
 
+func (s *stubRepo) ClaimDueSearchIndexOperations(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]models.SearchIndexOperation, error) {
+	return nil, nil
+}
+
+func (s *stubRepo) SaveSearchIndexOperation(ctx context.Context, operation *models.SearchIndexOperation) error {
+	return nil
+}
+
+func (s *stubRepo) CompleteSearchIndexOperation(ctx context.Context, operation *models.SearchIndexOperation) error {
+	return nil
+}
+
+func (s *stubRepo) GetSearchIndexOperations(ctx context.Context, page, perPage int, status string) ([]models.SearchIndexOperation, models.Pagination, error) {
+	if s.indexOpsFunc != nil {
+		return s.indexOpsFunc(ctx, page, perPage, status)
+	}
+	return nil, models.Pagination{}, nil
+}
+
+func (s *stubRepo) GetRepositoriesByIDs(ctx context.Context, ids []string) ([]models.Repository, error) {
+	if s.byIDsFunc != nil {
+		return s.byIDsFunc(ctx, ids)
+	}
+	return nil, nil
+}
+
 func (s *stubRepo) GetRepositoryByID(ctx context.Context, id string) (*models.Repository, error) {
 	if s.retrieveFunc != nil {
 		return s.retrieveFunc(ctx, id)
@@ -55,6 +105,20 @@ func (s *stubRepo) GetRepositoryByID(ctx context.Context, id string) (*models.Re
 	return nil, nil
 }
 
+func (s *stubRepo) FindRepositoryByURL(ctx context.Context, url string) (*models.Repository, error) {
+	if s.findRepoByURLFunc != nil {
+		return s.findRepoByURLFunc(ctx, url)
+	}
+	return nil, nil
+}
+
+func (s *stubRepo) FindRepositoryByNormalizedURL(ctx context.Context, url string) (*models.Repository, error) {
+	if s.findRepoByNormFunc != nil {
+		return s.findRepoByNormFunc(ctx, url)
+	}
+	return s.FindRepositoryByURL(ctx, url)
+}
+
 func (s *stubRepo) SaveRepository(ctx context.Context, repository *models.Repository) error {
 	if s.saveRepositoryFunc != nil {
 		return s.saveRepositoryFunc(ctx, repository)
@@ -62,6 +126,20 @@ func (s *stubRepo) SaveRepository(ctx context.Context, repository *models.Reposi
 	return nil
 }
 
+func (s *stubRepo) DeleteRepository(ctx context.Context, id string) error {
+	if s.deleteRepoFunc != nil {
+		return s.deleteRepoFunc(ctx, id)
+	}
+	return nil
+}
+
+func (s *stubRepo) GetRepositoryRevisions(ctx context.Context, repositoryID string, page, perPage int) ([]models.RepositoryRevision, models.Pagination, error) {
+	if s.revisionsFunc != nil {
+		return s.revisionsFunc(ctx, repositoryID, page, perPage)
+	}
+	return nil, models.Pagination{}, nil
+}
+
 func (s *stubRepo) SearchRepositorys(ctx context.Context, page, perPage int, organisation *string, query string) ([]models.Repository, models.Pagination, error) {
 	if s.searchFunc != nil {
 		return s.searchFunc(ctx, page, perPage, organisation, query)
@@ -83,16 +161,16 @@ func (s *stubRepo) AllRepositorys(ctx context.Context) ([]models.Repository, err
 	return nil, nil
 }
 
-func (s *stubRepo) GetOrganisations(ctx context.Context, page, perPage int) ([]models.Organisation, models.Pagination, error) {
+func (s *stubRepo) GetOrganisations(ctx context.Context, page, perPage int, p *models.OrganisationFilters) ([]models.Organisation, models.Pagination, error) {
 	if s.getOrgFunc != nil {
-		return s.getOrgFunc(ctx, page, perPage)
+		return s.getOrgFunc(ctx, page, perPage, p)
 	}
 	return nil, models.Pagination{}, nil
 }
 
-func (s *stubRepo) GetGitOrganisations(ctx context.Context, page, perPage int, organisation *string) ([]models.GitOrganisatie, models.Pagination, error) {
+func (s *stubRepo) GetGitOrganisations(ctx context.Context, page, perPage int, organisation, after *string) ([]models.GitOrganisatie, models.Pagination, error) {
 	if s.gitOrgListFunc != nil {
-		return s.gitOrgListFunc(ctx, page, perPage, organisation)
+		return s.gitOrgListFunc(ctx, page, perPage, organisation, after)
 	}
 	return nil, models.Pagination{}, nil
 }
@@ -104,6 +182,20 @@ func (s *stubRepo) FindOrganisationByURI(ctx context.Context, uri string) (*mode
 	return nil, nil
 }
 
+func (s *stubRepo) CountOrganisationReferences(ctx context.Context, uri string) (*models.OrganisationReferences, error) {
+	if s.orgRefsFunc != nil {
+		return s.orgRefsFunc(ctx, uri)
+	}
+	return &models.OrganisationReferences{}, nil
+}
+
+func (s *stubRepo) DeleteOrganisation(ctx context.Context, uri string) error {
+	if s.deleteOrgFunc != nil {
+		return s.deleteOrgFunc(ctx, uri)
+	}
+	return nil
+}
+
 func (s *stubRepo) FindGitOrganisationByURL(ctx context.Context, url string) (*models.GitOrganisatie, error) {
 	if s.findGitOrgByURLFunc != nil {
 		return s.findGitOrgByURLFunc(ctx, url)
@@ -111,6 +203,27 @@ func (s *stubRepo) FindGitOrganisationByURL(ctx context.Context, url string) (*m
 	return nil, nil
 }
 
+func (s *stubRepo) GetGitOrganisationByID(ctx context.Context, id string) (*models.GitOrganisatie, error) {
+	if s.getGitOrgFunc != nil {
+		return s.getGitOrgFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (s *stubRepo) DeleteGitOrganisation(ctx context.Context, id string) error {
+	if s.deleteGitOrgFunc != nil {
+		return s.deleteGitOrgFunc(ctx, id)
+	}
+	return nil
+}
+
+func (s *stubRepo) GetGitOrganisationRepositories(ctx context.Context, gitOrganisationURL string, page, perPage int) ([]models.Repository, models.Pagination, error) {
+	if s.gitOrgReposFunc != nil {
+		return s.gitOrgReposFunc(ctx, gitOrganisationURL, page, perPage)
+	}
+	return nil, models.Pagination{}, nil
+}
+
 func (s *stubRepo) SaveGitOrganisatie(ctx context.Context, gitOrg *models.GitOrganisatie) error {
 	if s.saveGitOrgFunc != nil {
 		return s.saveGitOrgFunc(ctx, gitOrg)
@@ -125,6 +238,64 @@ func (s *stubRepo) GetRepositoryFilterCounts(ctx context.Context, p *models.Repo
 	return &models.RepositoryFilterCounts{}, nil
 }
 
+func (s *stubRepo) SaveAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	if s.saveAuditEventFunc != nil {
+		return s.saveAuditEventFunc(ctx, event)
+	}
+	return nil
+}
+
+func (s *stubRepo) GetAuditEvents(ctx context.Context, page, perPage int, filter models.AuditEventFilter) ([]models.AuditEvent, models.Pagination, error) {
+	if s.auditEventsFunc != nil {
+		return s.auditEventsFunc(ctx, page, perPage, filter)
+	}
+	return nil, models.Pagination{}, nil
+}
+
+func (s *stubRepo) SaveJobRun(_ context.Context, _ *models.JobRun) error {
+	return nil
+}
+
+func (s *stubRepo) FailRunningJobRuns(_ context.Context, _ string, _ time.Time, _ string) (int64, error) {
+	return 0, nil
+}
+
+func (s *stubRepo) GetGitOrganisationsByHost(ctx context.Context, host string) ([]models.GitOrganisatie, error) {
+	if s.gitOrgListFunc == nil {
+		return nil, nil
+	}
+	gitOrgs, _, err := s.gitOrgListFunc(ctx, 1, 0, nil, nil)
+	var onHost []models.GitOrganisatie
+	for _, gitOrg := range gitOrgs {
+		if util.RepositoryURLHost(gitOrg.Url) == host {
+			onHost = append(onHost, gitOrg)
+		}
+	}
+	return onHost, err
+}
+
+func (s *stubRepo) EnqueueOrganisationSearchIndexOperations(ctx context.Context, uri string) (int, error) {
+	if s.enqueueOrgFunc != nil {
+		return s.enqueueOrgFunc(ctx, uri)
+	}
+	return 0, nil
+}
+
+func (s *stubRepo) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	if s.tryLockFunc != nil {
+		return s.tryLockFunc(ctx, name)
+	}
+	return func() {}, true, nil
+}
+
+func (s *stubRepo) GetLatestJobRun(_ context.Context, _ string) (*models.JobRun, error) {
+	return nil, nil
+}
+
+func (s *stubRepo) Transaction(ctx context.Context, fn func(repo repositories.RepositoriesRepository) error) error {
+	return fn(s)
+}
+
 func TestListRepositories_ReturnsSummaries(t *testing.T) {
 	org := &models.Organisation{Uri: "org-1", Label: "Org 1"}
 	lastActivity := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
@@ -139,7 +310,7 @@ func TestListRepositories_ReturnsSummaries(t *testing.T) {
 					Organisation:     org,
 					LastActivityAt:   lastActivity,
 				},
-			}, models.Pagination{TotalRecords: 1, CurrentPage: 1, RecordsPerPage: 10}, nil
+			}, models.Pagination{Pagination: commonpagination.Pagination{TotalRecords: 1, CurrentPage: 1, RecordsPerPage: 10}}, nil
 		},
 	}
 	svc := services.NewRepositoryService(repo)
@@ -230,7 +401,7 @@ func TestSearchRepositories_ReturnsSummaries(t *testing.T) {
 				Id:           "repo-1",
 				Name:         "Account API",
 				Organisation: &models.Organisation{Uri: orgURI, Label: "Org"},
-			}}, models.Pagination{CurrentPage: 3, RecordsPerPage: 7, TotalRecords: 1}, nil
+			}}, models.Pagination{Pagination: commonpagination.Pagination{CurrentPage: 3, RecordsPerPage: 7, TotalRecords: 1}}, nil
 		},
 	}
 	svc := services.NewRepositoryService(repo)
@@ -306,13 +477,13 @@ func TestRetrieveRepository_NotFoundPassesThrough(t *testing.T) {
 func TestListGitOrganisations_ReturnsSummaries(t *testing.T) {
 	orgURI := "https://example.org/org"
 	repo := &stubRepo{
-		gitOrgListFunc: func(ctx context.Context, page, perPage int, organisation *string) ([]models.GitOrganisatie, models.Pagination, error) {
+		gitOrgListFunc: func(ctx context.Context, page, perPage int, organisation, after *string) ([]models.GitOrganisatie, models.Pagination, error) {
 			require.Equal(t, 2, page)
 			require.Equal(t, 5, perPage)
 			require.Equal(t, &orgURI, organisation)
 			return []models.GitOrganisatie{
 				{Id: "git-1", Url: "https://github.com/example", Organisation: &models.Organisation{Uri: orgURI, Label: "Example"}},
-			}, models.Pagination{CurrentPage: 2, RecordsPerPage: 5, TotalRecords: 1}, nil
+			}, models.Pagination{Pagination: commonpagination.Pagination{CurrentPage: 2, RecordsPerPage: 5, TotalRecords: 1}}, nil
 		},
 	}
 	svc := services.NewRepositoryService(repo)
@@ -399,6 +570,98 @@ func TestCreateGitOrganisatie_SavesNewOrganisation(t *testing.T) {
 	assert.Equal(t, &org.Uri, got.OrganisationID)
 }
 
+func TestUpdateGitOrganisation_ValidatesAndSaves(t *testing.T) {
+	org := &models.Organisation{Uri: "https://example.org/org", Label: "Org"}
+	var saved *models.GitOrganisatie
+	repo := &stubRepo{
+		getGitOrgFunc: func(ctx context.Context, id string) (*models.GitOrganisatie, error) {
+			if id != "git-1" {
+				return nil, nil
+			}
+			return &models.GitOrganisatie{Id: id, Url: "https://github.com/old", WebhookSecret: "current"}, nil
+		},
+		findOrgByURIF: func(ctx context.Context, uri string) (*models.Organisation, error) {
+			return org, nil
+		},
+		findGitOrgByURLFunc: func(ctx context.Context, url string) (*models.GitOrganisatie, error) {
+			if url == "https://github.com/taken" {
+				return &models.GitOrganisatie{Id: "git-2", Url: url}, nil
+			}
+			return nil, nil
+		},
+		saveGitOrgFunc: func(ctx context.Context, gitOrg *models.GitOrganisatie) error {
+			saved = gitOrg
+			return nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	updated, err := svc.UpdateGitOrganisation(context.Background(), "git-1", models.GitOrganisationInput{
+		Url:             " https://github.com/new ",
+		OrganisationUri: org.Uri,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/new", updated.Url)
+	require.NotNil(t, saved)
+	assert.Equal(t, &org.Uri, saved.OrganisationID)
+	assert.Equal(t, "current", saved.WebhookSecret, "omitted secret is kept")
+
+	cleared := ""
+	_, err = svc.UpdateGitOrganisation(context.Background(), "git-1", models.GitOrganisationInput{
+		Url:             "https://github.com/new",
+		OrganisationUri: org.Uri,
+		WebhookSecret:   &cleared,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, saved.WebhookSecret)
+
+	var apiErr problem.ProblemJSON
+	_, err = svc.UpdateGitOrganisation(context.Background(), "git-1", models.GitOrganisationInput{
+		Url:             "https://github.com/taken",
+		OrganisationUri: org.Uri,
+	})
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusConflict, apiErr.Status)
+
+	_, err = svc.UpdateGitOrganisation(context.Background(), "missing", models.GitOrganisationInput{
+		Url:             "https://github.com/new",
+		OrganisationUri: org.Uri,
+	})
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.Status)
+}
+
+func TestGitOrganisationRepositoriesAndDelete(t *testing.T) {
+	var deleted string
+	var gotURL string
+	repo := &stubRepo{
+		getGitOrgFunc: func(ctx context.Context, id string) (*models.GitOrganisatie, error) {
+			return &models.GitOrganisatie{Id: id, Url: "https://github.com/org"}, nil
+		},
+		gitOrgReposFunc: func(ctx context.Context, gitOrganisationURL string, page, perPage int) ([]models.Repository, models.Pagination, error) {
+			gotURL = gitOrganisationURL
+			return []models.Repository{{Id: "repo-1", Url: "https://github.com/org/repo"}}, models.Pagination{Pagination: commonpagination.Pagination{TotalRecords: 1}}, nil
+		},
+		deleteGitOrgFunc: func(ctx context.Context, id string) error {
+			deleted = id
+			return nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	results, pagination, err := svc.ListGitOrganisationRepositories(context.Background(), &models.ListGitOrganisationRepositoriesParams{
+		GitOrganisationParams: models.GitOrganisationParams{Id: "git-1"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "repo-1", results[0].Id)
+	assert.Equal(t, 1, pagination.TotalRecords)
+	assert.Equal(t, "https://github.com/org", gotURL)
+
+	require.NoError(t, svc.DeleteGitOrganisation(context.Background(), "git-1"))
+	assert.Equal(t, "git-1", deleted)
+}
+
 func TestCreateOrganisation_ValidatesInput(t *testing.T) {
 	repo := &stubRepo{}
 	svc := services.NewRepositoryService(repo)
@@ -529,126 +792,905 @@ func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error)
 	return t.base.RoundTrip(req)
 }
 
-func TestListOrganisations_ReturnsSummaries(t *testing.T) {
+func TestRetrieveOrganisation_ReturnsCounts(t *testing.T) {
 	repo := &stubRepo{
-		getOrgFunc: func(ctx context.Context, page, perPage int) ([]models.Organisation, models.Pagination, error) {
-			require.Equal(t, 1, page)
-			require.Equal(t, 100, perPage)
-			return []models.Organisation{{Uri: "https://example.org", Label: "Example"}}, models.Pagination{TotalRecords: 1}, nil
+		findOrgByURIF: func(ctx context.Context, uri string) (*models.Organisation, error) {
+			if uri != "https://example.org/org" {
+				return nil, nil
+			}
+			return &models.Organisation{Uri: uri, Label: "Org"}, nil
+		},
+		orgRefsFunc: func(ctx context.Context, uri string) (*models.OrganisationReferences, error) {
+			return &models.OrganisationReferences{Repositories: 3, DeletedRepositories: 1, GitOrganisations: 2}, nil
 		},
 	}
 	svc := services.NewRepositoryService(repo)
 
-	results, pagination, err := svc.ListOrganisations(context.Background(), &models.ListOrganisationsParams{Page: 1, PerPage: 100})
+	detail, err := svc.RetrieveOrganisation(context.Background(), "https://example.org/org")
 	require.NoError(t, err)
-	require.Len(t, results, 1)
-	assert.Equal(t, "https://example.org", results[0].Uri)
-	assert.Equal(t, "Example", results[0].Label)
-	assert.Equal(t, 1, pagination.TotalRecords)
+	assert.Equal(t, &models.OrganisationDetail{
+		Uri:                  "https://example.org/org",
+		Label:                "Org",
+		RepositoryCount:      3,
+		GitOrganisationCount: 2,
+	}, detail)
+
+	var apiErr problem.ProblemJSON
+	_, err = svc.RetrieveOrganisation(context.Background(), "https://example.org/missing")
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.Status)
+
+	_, err = svc.RetrieveOrganisation(context.Background(), "not-a-uri")
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.Status)
 }
 
-func TestUpdateRepository_ValidatesAndUpdatesExistingRepository(t *testing.T) {
-	t.Setenv("ENABLE_TYPESENSE", "false")
-	org := &models.Organisation{Uri: "https://example.org/new-org", Label: "New Org"}
-	existing := &models.Repository{Id: "repo-1", Name: "Old", Url: "https://example.org/old", Active: false}
-	var saved *models.Repository
+func TestUpdateOrganisation_SavesTrimmedLabel(t *testing.T) {
+	var saved *models.Organisation
+	var enqueued []string
 	repo := &stubRepo{
-		retrieveFunc: func(ctx context.Context, id string) (*models.Repository, error) {
-			assert.Equal(t, "repo-1", id)
-			return existing, nil
-		},
 		findOrgByURIF: func(ctx context.Context, uri string) (*models.Organisation, error) {
-			assert.Equal(t, org.Uri, uri)
-			return org, nil
+			return &models.Organisation{Uri: uri, Label: "Old"}, nil
 		},
-		saveRepositoryFunc: func(ctx context.Context, repository *models.Repository) error {
-			saved = repository
+		saveOrgFunc: func(org *models.Organisation) error {
+			saved = org
 			return nil
 		},
+		enqueueOrgFunc: func(ctx context.Context, uri string) (int, error) {
+			enqueued = append(enqueued, uri)
+			return 2, nil
+		},
 	}
 	svc := services.NewRepositoryService(repo)
 
-	newURL := "https://example.org/new"
-	newName := "New"
-	detail, err := svc.UpdateRepository(context.Background(), "repo-1", models.RepositoryInput{
-		Url:             &newURL,
-		OrganisationUri: &org.Uri,
-		Name:            &newName,
-	})
+	detail, err := svc.UpdateOrganisation(context.Background(), "https://example.org/org", "  New label ")
 	require.NoError(t, err)
-	require.NotNil(t, detail)
+	assert.Equal(t, "New label", detail.Label)
 	require.NotNil(t, saved)
-	assert.Equal(t, "repo-1", saved.Id)
-	assert.Equal(t, "New", saved.Name)
-	assert.Equal(t, "https://example.org/new", saved.Url)
-	assert.Equal(t, &org.Uri, saved.OrganisationID)
-	assert.True(t, saved.Active)
-	assert.Equal(t, "New", detail.Name)
-}
+	assert.Equal(t, "New label", saved.Label)
+	assert.Equal(t, []string{"https://example.org/org"}, enqueued, "a relabel enqueues the repositories of the organisation")
 
-func TestUpdateRepository_NotFoundAndInvalidInput(t *testing.T) {
-	svc := services.NewRepositoryService(&stubRepo{})
+	_, err = svc.UpdateOrganisation(context.Background(), "https://example.org/org", "Old")
+	require.NoError(t, err)
+	assert.Len(t, enqueued, 1, "an unchanged label enqueues nothing")
 
-	_, err := svc.UpdateRepository(context.Background(), "", models.RepositoryInput{})
-	require.Error(t, err)
+	_, err = svc.UpdateOrganisation(context.Background(), "https://example.org/org", " ")
+	var apiErr problem.ProblemJSON
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.Status)
+	assert.Equal(t, "#/label", apiErr.Errors[0].Location)
+}
 
+func TestDeleteOrganisation_RefusesWhileReferenced(t *testing.T) {
+	deleted := ""
+	refs := &models.OrganisationReferences{DeletedRepositories: 1, GitOrganisations: 2}
 	repo := &stubRepo{
-		retrieveFunc: func(ctx context.Context, id string) (*models.Repository, error) {
-			return nil, nil
+		findOrgByURIF: func(ctx context.Context, uri string) (*models.Organisation, error) {
+			return &models.Organisation{Uri: uri, Label: "Org"}, nil
+		},
+		orgRefsFunc: func(ctx context.Context, uri string) (*models.OrganisationReferences, error) {
+			return refs, nil
+		},
+		deleteOrgFunc: func(ctx context.Context, uri string) error {
+			deleted = uri
+			return nil
 		},
 	}
-	svc = services.NewRepositoryService(repo)
-	urlValue := "https://example.org/repo"
-	_, err = svc.UpdateRepository(context.Background(), "missing", models.RepositoryInput{Url: &urlValue})
-	require.Error(t, err)
+	svc := services.NewRepositoryService(repo)
+
+	err := svc.DeleteOrganisation(context.Background(), "https://example.org/org")
 	var apiErr problem.ProblemJSON
 	require.ErrorAs(t, err, &apiErr)
-	assert.Equal(t, http.StatusNotFound, apiErr.Status)
+	assert.Equal(t, http.StatusConflict, apiErr.Status)
+	require.Len(t, apiErr.Errors, 2)
+	assert.Equal(t, "organisation is referenced by 1 repositories", apiErr.Errors[0].Detail)
+	assert.Empty(t, deleted)
 
-	repo = &stubRepo{
-		retrieveFunc: func(ctx context.Context, id string) (*models.Repository, error) {
-			return &models.Repository{Id: id}, nil
+	refs = &models.OrganisationReferences{}
+	require.NoError(t, svc.DeleteOrganisation(context.Background(), "https://example.org/org"))
+	assert.Equal(t, "https://example.org/org", deleted)
+}
+
+func TestListOrganisations_ReturnsSummaries(t *testing.T) {
+	repo := &stubRepo{
+		getOrgFunc: func(ctx context.Context, page, perPage int, p *models.OrganisationFilters) ([]models.Organisation, models.Pagination, error) {
+			require.Equal(t, 1, page)
+			require.Equal(t, 100, perPage)
+			return []models.Organisation{{Uri: "https://example.org", Label: "Example"}}, models.Pagination{Pagination: commonpagination.Pagination{TotalRecords: 1}}, nil
 		},
 	}
-	svc = services.NewRepositoryService(repo)
-	badURL := "notaurl"
-	_, err = svc.UpdateRepository(context.Background(), "repo-1", models.RepositoryInput{Url: &badURL})
-	require.Error(t, err)
+	svc := services.NewRepositoryService(repo)
+
+	results, pagination, err := svc.ListOrganisations(context.Background(), &models.ListOrganisationsParams{Page: 1, PerPage: 100})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "https://example.org", results[0].Uri)
+	assert.Equal(t, "Example", results[0].Label)
+	assert.Equal(t, 1, pagination.TotalRecords)
 }
 
-func TestGetRepositoryFilters_ReturnsAllGroups(t *testing.T) {
-	repo := &stubRepo{}
+func TestListOrganisations_ForwardsFiltersAndValidatesSort(t *testing.T) {
+	var got *models.OrganisationFilters
+	repo := &stubRepo{
+		getOrgFunc: func(ctx context.Context, page, perPage int, p *models.OrganisationFilters) ([]models.Organisation, models.Pagination, error) {
+			got = p
+			return nil, models.Pagination{}, nil
+		},
+	}
 	svc := services.NewRepositoryService(repo)
 
-	groups, err := svc.GetRepositoryFilters(context.Background(), &models.RepositoryFiltersParams{})
+	active := true
+	_, _, err := svc.ListOrganisations(context.Background(), &models.ListOrganisationsParams{
+		Query:                 "gemeente",
+		HasActiveRepositories: &active,
+		Sort:                  "-repositoryCount",
+	})
 	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "gemeente", got.Query)
+	assert.Equal(t, &active, got.HasActiveRepositories)
+	assert.Equal(t, "-repositoryCount", got.Sort)
 
-	keys := make([]string, len(groups))
-	for i, g := range groups {
-		keys[i] = g.Key
-	}
-	assert.Contains(t, keys, "publiccode")
-	assert.Contains(t, keys, "archived")
-	assert.Contains(t, keys, "lastActivityAfter")
-	assert.Contains(t, keys, "softwareType")
-	assert.Contains(t, keys, "developmentStatus")
-	assert.Contains(t, keys, "maintenanceType")
-	assert.Contains(t, keys, "platforms")
-	assert.Contains(t, keys, "availableLanguages")
-	assert.Contains(t, keys, "license")
-	assert.Contains(t, keys, "organisation")
+	got = nil
+	_, _, err = svc.ListOrganisations(context.Background(), &models.ListOrganisationsParams{Sort: "uri"})
+	var apiErr problem.ProblemJSON
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.Status)
+	assert.Equal(t, "#/sort", apiErr.Errors[0].Location)
+	assert.Nil(t, got)
 }
 
-func TestGetRepositoryFilters_ToggleGroupHasCount(t *testing.T) {
-	n := 42
+func TestListRepositorys_ForwardsAndValidatesSort(t *testing.T) {
+	var got *models.RepositoryFiltersParams
 	repo := &stubRepo{
-		filterCountsFunc: func(ctx context.Context, p *models.RepositoryFiltersParams) (*models.RepositoryFilterCounts, error) {
-			return &models.RepositoryFilterCounts{PublicCode: n, Archived: n}, nil
+		listFunc: func(ctx context.Context, page, perPage int, p *models.RepositoryFiltersParams) ([]models.Repository, models.Pagination, error) {
+			got = p
+			return nil, models.Pagination{}, nil
 		},
 	}
 	svc := services.NewRepositoryService(repo)
 
-	groups, err := svc.GetRepositoryFilters(context.Background(), &models.RepositoryFiltersParams{})
+	_, _, err := svc.ListRepositorys(context.Background(), &models.ListRepositorysParams{Sort: "-lastActivityAt"})
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "-lastActivityAt", got.Sort)
+
+	_, _, err = svc.ListRepositorys(context.Background(), &models.ListRepositorysParams{Query: "zaak", Sort: "relevance"})
+	require.NoError(t, err)
+
+	for _, sort := range []string{"stars", "-relevance", "relevance"} {
+		got = nil
+		_, _, err = svc.ListRepositorys(context.Background(), &models.ListRepositorysParams{Sort: sort})
+		var apiErr problem.ProblemJSON
+		require.ErrorAs(t, err, &apiErr, sort)
+		assert.Equal(t, http.StatusBadRequest, apiErr.Status)
+		assert.Equal(t, "#/sort", apiErr.Errors[0].Location)
+		assert.Nil(t, got)
+	}
+}
+
+func useTypesenseSearch(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	t.Setenv("TYPESENSE_ENDPOINT", server.URL)
+	t.Setenv("TYPESENSE_API_KEY", "secret")
+	t.Setenv("TYPESENSE_COLLECTION", "oss-register")
+	prevClient := httpclient.HTTPClient
+	httpclient.HTTPClient = server.Client()
+	t.Cleanup(func() { httpclient.HTTPClient = prevClient })
+}
+
+func TestListRepositorys_SearchesTypesenseAndHydratesInOrder(t *testing.T) {
+	var filter string
+	useTypesenseSearch(t, func(w http.ResponseWriter, r *http.Request) {
+		filter = r.URL.Query().Get("filter_by")
+		_, _ = w.Write([]byte(`{"found":12,"hits":[{"document":{"id":"repo-2"}},{"document":{"id":"repo-1"}}]}`))
+	})
+	repo := &stubRepo{
+		listFunc: func(ctx context.Context, page, perPage int, p *models.RepositoryFiltersParams) ([]models.Repository, models.Pagination, error) {
+			t.Fatalf("expected GetRepositorys not to be called")
+			return nil, models.Pagination{}, nil
+		},
+		byIDsFunc: func(ctx context.Context, ids []string) ([]models.Repository, error) {
+			require.Equal(t, []string{"repo-2", "repo-1"}, ids)
+			return []models.Repository{{Id: "repo-2", Name: "Twee"}, {Id: "repo-1", Name: "Een"}}, nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	results, pagination, err := svc.ListRepositorys(context.Background(), &models.ListRepositorysParams{
+		Query:   "zaak",
+		Page:    2,
+		PerPage: 2,
+		License: []string{"EUPL-1.2"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "repo-2", results[0].Id)
+	assert.Equal(t, "repo-1", results[1].Id)
+	assert.Equal(t, 12, pagination.TotalRecords)
+	assert.Equal(t, 2, pagination.CurrentPage)
+	assert.Contains(t, filter, "tags:=[`license:EUPL-1.2`]")
+}
+
+func TestListRepositorys_FallsBackToSQLWhenTypesenseFails(t *testing.T) {
+	useTypesenseSearch(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	listed := 0
+	repo := &stubRepo{
+		listFunc: func(ctx context.Context, page, perPage int, p *models.RepositoryFiltersParams) ([]models.Repository, models.Pagination, error) {
+			listed++
+			return []models.Repository{{Id: "repo-1"}}, models.Pagination{Pagination: commonpagination.Pagination{TotalRecords: 1}}, nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	results, _, err := svc.ListRepositorys(context.Background(), &models.ListRepositorysParams{Query: "zaak"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, 1, listed)
+
+	// Een onbereikbare Typesense valt ook terug op SQL.
+	t.Setenv("TYPESENSE_ENDPOINT", "http://127.0.0.1:1")
+	_, _, err = svc.ListRepositorys(context.Background(), &models.ListRepositorysParams{Query: "zaak"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, listed)
+}
+
+func TestListRepositorys_UsesSQLForFiltersTypesenseCannotApply(t *testing.T) {
+	useTypesenseSearch(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("expected no Typesense request for %s", r.URL.RawQuery)
+	})
+	listed := 0
+	repo := &stubRepo{
+		listFunc: func(ctx context.Context, page, perPage int, p *models.RepositoryFiltersParams) ([]models.Repository, models.Pagination, error) {
+			listed++
+			return nil, models.Pagination{}, nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	date := "2024-01-01"
+	for _, params := range []*models.ListRepositorysParams{
+		{},
+		{Query: "zaak", Sort: "name"},
+		{Query: "zaak", Cursor: "*"},
+		{Query: "zaak", LastActivityAfter: &date},
+		{Query: "zaak", MaintenanceType: []string{"internal"}},
+	} {
+		_, _, err := svc.ListRepositorys(context.Background(), params)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 5, listed)
+}
+
+func TestUpdateRepository_ValidatesAndUpdatesExistingRepository(t *testing.T) {
+	t.Setenv("ENABLE_TYPESENSE", "false")
+	org := &models.Organisation{Uri: "https://example.org/new-org", Label: "New Org"}
+	existing := &models.Repository{Id: "repo-1", Name: "Old", Url: "https://example.org/old", Active: false}
+	var saved *models.Repository
+	repo := &stubRepo{
+		retrieveFunc: func(ctx context.Context, id string) (*models.Repository, error) {
+			assert.Equal(t, "repo-1", id)
+			return existing, nil
+		},
+		findOrgByURIF: func(ctx context.Context, uri string) (*models.Organisation, error) {
+			assert.Equal(t, org.Uri, uri)
+			return org, nil
+		},
+		saveRepositoryFunc: func(ctx context.Context, repository *models.Repository) error {
+			saved = repository
+			return nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	newURL := "https://example.org/new"
+	newName := "New"
+	detail, err := svc.UpdateRepository(context.Background(), "repo-1", "", models.RepositoryInput{
+		Url:             &newURL,
+		OrganisationUri: &org.Uri,
+		Name:            &newName,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, detail)
+	require.NotNil(t, saved)
+	assert.Equal(t, "repo-1", saved.Id)
+	assert.Equal(t, "New", saved.Name)
+	assert.Equal(t, "https://example.org/new", saved.Url)
+	assert.Equal(t, &org.Uri, saved.OrganisationID)
+	assert.True(t, saved.Active)
+	assert.Equal(t, "New", detail.Name)
+}
+
+func TestUpdateRepository_NotFoundAndInvalidInput(t *testing.T) {
+	svc := services.NewRepositoryService(&stubRepo{})
+
+	_, err := svc.UpdateRepository(context.Background(), "", "", models.RepositoryInput{})
+	require.Error(t, err)
+
+	repo := &stubRepo{
+		retrieveFunc: func(ctx context.Context, id string) (*models.Repository, error) {
+			return nil, nil
+		},
+	}
+	svc = services.NewRepositoryService(repo)
+	urlValue := "https://example.org/repo"
+	_, err = svc.UpdateRepository(context.Background(), "missing", "", models.RepositoryInput{Url: &urlValue})
+	require.Error(t, err)
+	var apiErr problem.ProblemJSON
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.Status)
+
+	repo = &stubRepo{
+		retrieveFunc: func(ctx context.Context, id string) (*models.Repository, error) {
+			return &models.Repository{Id: id}, nil
+		},
+	}
+	svc = services.NewRepositoryService(repo)
+	badURL := "notaurl"
+	_, err = svc.UpdateRepository(context.Background(), "repo-1", "", models.RepositoryInput{Url: &badURL})
+	require.Error(t, err)
+}
+
+func TestDeleteRepository_SoftDeletesExistingRepository(t *testing.T) {
+	t.Setenv("ENABLE_TYPESENSE", "false")
+
+	var deletedID string
+	repo := &stubRepo{
+		retrieveFunc: func(ctx context.Context, id string) (*models.Repository, error) {
+			return &models.Repository{Id: id, Active: true}, nil
+		},
+		deleteRepoFunc: func(ctx context.Context, id string) error {
+			deletedID = id
+			return nil
+		},
+	}
+
+	svc := services.NewRepositoryService(repo)
+	require.NoError(t, svc.DeleteRepository(context.Background(), "repo-1"))
+	assert.Equal(t, "repo-1", deletedID)
+}
+
+func TestDeleteRepository_NotFoundAndGone(t *testing.T) {
+	repo := &stubRepo{
+		retrieveFunc: func(ctx context.Context, id string) (*models.Repository, error) {
+			return nil, nil
+		},
+		deleteRepoFunc: func(ctx context.Context, id string) error {
+			t.Fatalf("DeleteRepository should not be called for missing repositories")
+			return nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+	err := svc.DeleteRepository(context.Background(), "missing")
+	var apiErr problem.ProblemJSON
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.Status)
+
+	deletedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	repo.retrieveFunc = func(ctx context.Context, id string) (*models.Repository, error) {
+		return &models.Repository{Id: id, DeletedAt: &deletedAt}, nil
+	}
+	err = svc.DeleteRepository(context.Background(), "repo-1")
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusGone, apiErr.Status)
+
+	_, err = svc.RetrieveRepository(context.Background(), "repo-1")
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusGone, apiErr.Status)
+
+	urlValue := "https://example.org/repo"
+	_, err = svc.UpdateRepository(context.Background(), "repo-1", "", models.RepositoryInput{Url: &urlValue})
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusGone, apiErr.Status)
+}
+
+func TestBulkUpsertRepositories_ReportsResultPerItem(t *testing.T) {
+	t.Setenv("ENABLE_TYPESENSE", "false")
+
+	orgURI := "https://example.org/org"
+	deletedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	orgLookups := 0
+	var saved []string
+	transactions := 0
+
+	repo := &stubRepo{
+		findOrgByURIF: func(ctx context.Context, uri string) (*models.Organisation, error) {
+			orgLookups++
+			if uri != orgURI {
+				return nil, nil
+			}
+			return &models.Organisation{Uri: orgURI, Label: "Org"}, nil
+		},
+		findRepoByURLFunc: func(ctx context.Context, url string) (*models.Repository, error) {
+			switch url {
+			case "https://example.org/existing":
+				return &models.Repository{Id: "existing-id", Url: url, Version: 3}, nil
+			case "https://example.org/deleted":
+				return &models.Repository{Id: "deleted-id", Url: url, DeletedAt: &deletedAt}, nil
+			}
+			return nil, nil
+		},
+		saveRepositoryFunc: func(ctx context.Context, repository *models.Repository) error {
+			saved = append(saved, repository.Url)
+			return nil
+		},
+	}
+	svc := services.NewRepositoryService(&transactionCountingRepo{stubRepo: repo, count: &transactions})
+
+	body := []byte(`[
+		{"url": "https://example.org/new", "organisationUri": "https://example.org/org"},
+		{"url": "https://example.org/existing", "organisationUri": "https://example.org/org", "name": "Existing"},
+		{"url": "not a url", "organisationUri": "https://example.org/other"},
+		{"url": "https://example.org/deleted", "organisationUri": "https://example.org/org"},
+		{"url": "https://example.org/new", "organisationUri": "https://example.org/org"},
+		{"url": "https://example.org/unknown-org", "organisationUri": "https://example.org/other"}
+	]`)
+
+	resp, err := svc.BulkUpsertRepositories(context.Background(), bytes.NewReader(body), false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Created)
+	assert.Equal(t, 1, resp.Updated)
+	assert.Equal(t, 4, resp.Failed)
+	require.Len(t, resp.Results, 6)
+
+	assert.Equal(t, models.BulkStatusCreated, resp.Results[0].Status)
+	assert.NotEmpty(t, resp.Results[0].Id)
+	assert.Equal(t, models.BulkStatusUpdated, resp.Results[1].Status)
+	assert.Equal(t, "existing-id", resp.Results[1].Id)
+
+	assert.Equal(t, models.BulkStatusFailed, resp.Results[2].Status)
+	assert.Empty(t, resp.Results[2].Id)
+	require.Len(t, resp.Results[2].Errors, 1)
+	assert.Equal(t, "#/url", resp.Results[2].Errors[0].Location)
+
+	require.Len(t, resp.Results[3].Errors, 1)
+	assert.Equal(t, "gone", resp.Results[3].Errors[0].Code)
+	require.Len(t, resp.Results[4].Errors, 1)
+	assert.Equal(t, "duplicate", resp.Results[4].Errors[0].Code)
+	require.Len(t, resp.Results[5].Errors, 1)
+	assert.Equal(t, "#/organisationUri", resp.Results[5].Errors[0].Location)
+	assert.Equal(t, 5, resp.Results[5].Index)
+
+	assert.Equal(t, []string{"https://example.org/new", "https://example.org/existing"}, saved)
+	assert.Equal(t, 1, transactions)
+	assert.Equal(t, 2, orgLookups, "organisations are looked up once per request")
+}
+
+func TestBulkUpsertRepositories_FailsItemsOfOtherOrganisations(t *testing.T) {
+	t.Setenv("ENABLE_TYPESENSE", "false")
+
+	own := "https://example.org/own"
+	other := "https://example.org/other"
+	var saved []string
+	repo := &stubRepo{
+		findOrgByURIF: func(ctx context.Context, uri string) (*models.Organisation, error) {
+			return &models.Organisation{Uri: uri}, nil
+		},
+		findRepoByURLFunc: func(ctx context.Context, url string) (*models.Repository, error) {
+			if url == "https://example.org/repos/taken-over" {
+				return &models.Repository{Id: "taken-over", Url: url, OrganisationID: &other}, nil
+			}
+			return nil, nil
+		},
+		saveRepositoryFunc: func(ctx context.Context, repository *models.Repository) error {
+			saved = append(saved, repository.Url)
+			return nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	body := []byte(`[
+		{"url": "https://example.org/repos/own", "organisationUri": "https://example.org/own"},
+		{"url": "https://example.org/repos/other", "organisationUri": "https://example.org/other"},
+		{"url": "https://example.org/repos/taken-over", "organisationUri": "https://example.org/own"}
+	]`)
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{ClientID: "gemeente", Organisations: []string{own}})
+
+	resp, err := svc.BulkUpsertRepositories(ctx, bytes.NewReader(body), false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Created)
+	assert.Equal(t, 2, resp.Failed)
+	for _, i := range []int{1, 2} {
+		require.Len(t, resp.Results[i].Errors, 1)
+		assert.Equal(t, "forbidden", resp.Results[i].Errors[0].Code)
+	}
+	assert.Equal(t, []string{"https://example.org/repos/own"}, saved)
+
+	// Without a caller (crawler, jobs) and for admins every organisation is allowed.
+	for _, ctx := range []context.Context{
+		context.Background(),
+		auth.WithPrincipal(context.Background(), &auth.Principal{ClientID: "beheer", Admin: true}),
+	} {
+		saved = nil
+		resp, err = svc.BulkUpsertRepositories(ctx, bytes.NewReader(body), false)
+		require.NoError(t, err)
+		assert.Equal(t, 0, resp.Failed)
+		assert.Len(t, saved, 3)
+	}
+}
+
+func TestBulkUpsertRepositories_FetchesPublicCodeConcurrently(t *testing.T) {
+	t.Setenv("ENABLE_TYPESENSE", "false")
+	util.SetPublicCodeValidatorForTest(t, fakePublicCodeValidator{})
+
+	// Every request waits until all three are in flight; fetched one by one
+	// the bulk request would only finish after the fetch timeouts.
+	const items = 3
+	var arrived sync.WaitGroup
+	arrived.Add(items)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived.Done()
+		arrived.Wait()
+		_, _ = w.Write([]byte("name: Fetched\n"))
+	}))
+	defer srv.Close()
+
+	orgURI := "https://example.org/org"
+	repo := &stubRepo{
+		findOrgByURIF: func(ctx context.Context, uri string) (*models.Organisation, error) {
+			return &models.Organisation{Uri: orgURI, Label: "Org"}, nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	var body bytes.Buffer
+	for i := 0; i < items; i++ {
+		fmt.Fprintf(&body, `{"url": "https://example.org/repo-%d", "organisationUri": %q, "publicCodeUrl": "%s/repo-%d/publiccode.yml"}`+"\n", i, orgURI, srv.URL, i)
+	}
+
+	started := time.Now()
+	resp, err := svc.BulkUpsertRepositories(context.Background(), &body, true)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(started), 5*time.Second)
+	assert.Equal(t, items, resp.Created)
+}
+
+func TestBulkUpsertRepositories_AcceptsNDJSON(t *testing.T) {
+	t.Setenv("ENABLE_TYPESENSE", "false")
+
+	repo := &stubRepo{
+		findOrgByURIF: func(ctx context.Context, uri string) (*models.Organisation, error) {
+			return &models.Organisation{Uri: uri, Label: "Org"}, nil
+		},
+		saveRepositoryFunc: func(ctx context.Context, repository *models.Repository) error {
+			if repository.Url == "https://example.org/conflict" {
+				return repositories.ErrVersionConflict
+			}
+			return nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	body := []byte("{\"url\": \"https://example.org/a\", \"organisationUri\": \"https://example.org/org\"}\n\n" +
+		"{not json}\n" +
+		"{\"url\": \"https://example.org/conflict\", \"organisationUri\": \"https://example.org/org\"}\n")
+
+	resp, err := svc.BulkUpsertRepositories(context.Background(), bytes.NewReader(body), true)
+	require.NoError(t, err)
+	require.Len(t, resp.Results, 3)
+	assert.Equal(t, models.BulkStatusCreated, resp.Results[0].Status)
+	assert.Equal(t, models.BulkStatusFailed, resp.Results[1].Status)
+	assert.Equal(t, "invalid", resp.Results[1].Errors[0].Code)
+	assert.Equal(t, models.BulkStatusFailed, resp.Results[2].Status)
+	assert.Equal(t, "Repository has been modified", resp.Results[2].Errors[0].Detail)
+	assert.Equal(t, 2, resp.Failed)
+}
+
+func TestBulkUpsertRepositories_RejectsInvalidBody(t *testing.T) {
+	svc := services.NewRepositoryService(&stubRepo{})
+
+	for name, body := range map[string]string{
+		"not an array":   `{"url": "https://example.org/a"}`,
+		"empty array":    `[]`,
+		"trailing value": `[{}] {}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := svc.BulkUpsertRepositories(context.Background(), strings.NewReader(body), false)
+			var apiErr problem.ProblemJSON
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, http.StatusBadRequest, apiErr.Status)
+		})
+	}
+
+	items := make([]json.RawMessage, 1001)
+	for i := range items {
+		items[i] = json.RawMessage(`{}`)
+	}
+	body, err := json.Marshal(items)
+	require.NoError(t, err)
+	_, err = svc.BulkUpsertRepositories(context.Background(), bytes.NewReader(body), false)
+	var apiErr problem.ProblemJSON
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "max", apiErr.Errors[0].Code)
+
+	ndjson := strings.Repeat("{}\n", 1001)
+	_, err = svc.BulkUpsertRepositories(context.Background(), strings.NewReader(ndjson), true)
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "max", apiErr.Errors[0].Code)
+
+	longLine := `{"name":"` + strings.Repeat("a", 1<<20) + `"}`
+	_, err = svc.BulkUpsertRepositories(context.Background(), strings.NewReader(longLine), true)
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "max", apiErr.Errors[0].Code)
+}
+
+func TestBulkUpsertRepositories_TransactionErrorFailsRequest(t *testing.T) {
+	repo := &stubRepo{
+		findOrgByURIF: func(ctx context.Context, uri string) (*models.Organisation, error) {
+			return &models.Organisation{Uri: uri, Label: "Org"}, nil
+		},
+	}
+	svc := services.NewRepositoryService(&failingTransactionRepo{stubRepo: repo})
+
+	_, err := svc.BulkUpsertRepositories(context.Background(),
+		strings.NewReader(`[{"url": "https://example.org/a", "organisationUri": "https://example.org/org"}]`), false)
+	require.ErrorIs(t, err, gorm.ErrInvalidTransaction)
+}
+
+type transactionCountingRepo struct {
+	*stubRepo
+	count *int
+}
+
+func (r *transactionCountingRepo) Transaction(ctx context.Context, fn func(repo repositories.RepositoriesRepository) error) error {
+	*r.count++
+	return fn(r)
+}
+
+type failingTransactionRepo struct {
+	*stubRepo
+}
+
+func (r *failingTransactionRepo) Transaction(ctx context.Context, fn func(repo repositories.RepositoriesRepository) error) error {
+	return gorm.ErrInvalidTransaction
+}
+
+func TestPatchRepository_AppliesOnlySuppliedFields(t *testing.T) {
+	t.Setenv("ENABLE_TYPESENSE", "false")
+
+	orgURI := "https://example.org/org"
+	publicCode := &models.PublicCode{Name: "Existing"}
+	var saved *models.Repository
+	repo := &stubRepo{
+		retrieveFunc: func(ctx context.Context, id string) (*models.Repository, error) {
+			return &models.Repository{
+				Id:               id,
+				Name:             "Old name",
+				ShortDescription: "Keep me",
+				LongDescription:  "Keep me",
+				Url:              "https://example.org/repo",
+				OrganisationID:   &orgURI,
+				PublicCodeUrl:    "https://example.org/repo/publiccode.yml",
+				PublicCode:       publicCode,
+				Active:           false,
+			}, nil
+		},
+		saveRepositoryFunc: func(ctx context.Context, repository *models.Repository) error {
+			saved = repository
+			return nil
+		},
+	}
+
+	svc := services.NewRepositoryService(repo)
+	detail, err := svc.PatchRepository(context.Background(), "repo-1", "", []byte(`{"name":" New name ","archived":true}`))
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	assert.Equal(t, "New name", saved.Name)
+	assert.True(t, saved.Archived)
+	assert.False(t, saved.Active)
+	assert.Equal(t, "Keep me", saved.ShortDescription)
+	assert.Equal(t, "https://example.org/repo/publiccode.yml", saved.PublicCodeUrl)
+	assert.Same(t, publicCode, saved.PublicCode)
+	assert.Equal(t, &orgURI, saved.OrganisationID)
+	assert.Equal(t, "New name", detail.Name)
+}
+
+func TestPatchRepository_NullClearsPublicCode(t *testing.T) {
+	t.Setenv("ENABLE_TYPESENSE", "false")
+
+	var saved *models.Repository
+	repo := &stubRepo{
+		retrieveFunc: func(ctx context.Context, id string) (*models.Repository, error) {
+			return &models.Repository{
+				Id:               id,
+				Name:             "Repo",
+				ShortDescription: "Description",
+				Url:              "https://example.org/repo",
+				PublicCodeUrl:    "https://example.org/repo/publiccode.yml",
+				PublicCode:       &models.PublicCode{Name: "Repo"},
+				ForkBasedOnURLs:  []string{"https://example.org/upstream"},
+			}, nil
+		},
+		saveRepositoryFunc: func(ctx context.Context, repository *models.Repository) error {
+			saved = repository
+			return nil
+		},
+	}
+
+	svc := services.NewRepositoryService(repo)
+	_, err := svc.PatchRepository(context.Background(), "repo-1", "", []byte(`{"publicCodeUrl":null,"shortDescription":null}`))
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	assert.Empty(t, saved.PublicCodeUrl)
+	assert.Nil(t, saved.PublicCode)
+	assert.Nil(t, saved.ForkBasedOnURLs)
+	assert.Empty(t, saved.ShortDescription)
+	assert.Equal(t, "Repo", saved.Name)
+}
+
+func TestPatchRepository_ReportsAllInvalidFields(t *testing.T) {
+	repo := &stubRepo{
+		retrieveFunc: func(ctx context.Context, id string) (*models.Repository, error) {
+			t.Fatalf("repository should not be loaded for an invalid patch")
+			return nil, nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	_, err := svc.PatchRepository(context.Background(), "repo-1", "", []byte(`{"url":"notaurl","archived":"yes","id":"other","name":null}`))
+	var apiErr problem.ProblemJSON
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.Status)
+
+	locations := make(map[string]string, len(apiErr.Errors))
+	for _, detail := range apiErr.Errors {
+		assert.Equal(t, "body", detail.In)
+		locations[detail.Location] = detail.Code
+	}
+	assert.Equal(t, map[string]string{
+		"#/archived": "type",
+		"#/id":       "unknown",
+		"#/name":     "required",
+		"#/url":      "url",
+	}, locations)
+
+	_, err = svc.PatchRepository(context.Background(), "repo-1", "", []byte(`[]`))
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.Status)
+}
+
+func TestPatchRepository_NotFoundGoneAndUnknownOrganisation(t *testing.T) {
+	repo := &stubRepo{}
+	svc := services.NewRepositoryService(repo)
+
+	_, err := svc.PatchRepository(context.Background(), "missing", "", []byte(`{"archived":true}`))
+	var apiErr problem.ProblemJSON
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.Status)
+
+	deletedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	repo.retrieveFunc = func(ctx context.Context, id string) (*models.Repository, error) {
+		return &models.Repository{Id: id, DeletedAt: &deletedAt}, nil
+	}
+	_, err = svc.PatchRepository(context.Background(), "repo-1", "", []byte(`{"archived":true}`))
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusGone, apiErr.Status)
+
+	repo.retrieveFunc = func(ctx context.Context, id string) (*models.Repository, error) {
+		return &models.Repository{Id: id, Url: "https://example.org/repo"}, nil
+	}
+	_, err = svc.PatchRepository(context.Background(), "repo-1", "", []byte(`{"organisationUri":"https://example.org/unknown"}`))
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.Status)
+	require.Len(t, apiErr.Errors, 1)
+	assert.Equal(t, "#/organisationUri", apiErr.Errors[0].Location)
+	assert.Equal(t, "not_found", apiErr.Errors[0].Code)
+}
+
+func TestUpdateRepository_IfMatchMismatchReturnsPreconditionFailed(t *testing.T) {
+	repo := &stubRepo{
+		retrieveFunc: func(ctx context.Context, id string) (*models.Repository, error) {
+			return &models.Repository{Id: id, Url: "https://example.org/repo", Version: 4}, nil
+		},
+		saveRepositoryFunc: func(ctx context.Context, repository *models.Repository) error {
+			t.Fatalf("SaveRepository should not be called on a failed precondition")
+			return nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	urlValue := "https://example.org/repo"
+	_, err := svc.UpdateRepository(context.Background(), "repo-1", `"3"`, models.RepositoryInput{Url: &urlValue})
+	var apiErr problem.ProblemJSON
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusPreconditionFailed, apiErr.Status)
+
+	_, err = svc.PatchRepository(context.Background(), "repo-1", `"3"`, []byte(`{"archived":true}`))
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusPreconditionFailed, apiErr.Status)
+}
+
+func TestUpdateRepository_VersionConflictOnSaveReturnsPreconditionFailed(t *testing.T) {
+	t.Setenv("ENABLE_TYPESENSE", "false")
+
+	repo := &stubRepo{
+		retrieveFunc: func(ctx context.Context, id string) (*models.Repository, error) {
+			return &models.Repository{Id: id, Url: "https://example.org/repo", Version: 4}, nil
+		},
+		saveRepositoryFunc: func(ctx context.Context, repository *models.Repository) error {
+			return repositories.ErrVersionConflict
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	urlValue := "https://example.org/repo"
+	_, err := svc.UpdateRepository(context.Background(), "repo-1", `"4"`, models.RepositoryInput{Url: &urlValue})
+	var apiErr problem.ProblemJSON
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusPreconditionFailed, apiErr.Status)
+}
+
+func TestListRepositoryHistory_ReturnsRevisions(t *testing.T) {
+	repo := &stubRepo{
+		retrieveFunc: func(ctx context.Context, id string) (*models.Repository, error) {
+			if id == "missing" {
+				return nil, nil
+			}
+			return &models.Repository{Id: id}, nil
+		},
+		revisionsFunc: func(ctx context.Context, repositoryID string, page, perPage int) ([]models.RepositoryRevision, models.Pagination, error) {
+			assert.Equal(t, "repo-1", repositoryID)
+			assert.Equal(t, 2, page)
+			assert.Equal(t, 5, perPage)
+			return []models.RepositoryRevision{{Id: "rev-1", RepositoryID: repositoryID}}, models.Pagination{Pagination: commonpagination.Pagination{TotalRecords: 6}}, nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	revisions, pagination, err := svc.ListRepositoryHistory(context.Background(), &models.ListRepositoryHistoryParams{
+		RepositoryParams: models.RepositoryParams{Id: "repo-1"},
+		Page:             2,
+		PerPage:          5,
+	})
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	assert.Equal(t, 6, pagination.TotalRecords)
+
+	_, _, err = svc.ListRepositoryHistory(context.Background(), &models.ListRepositoryHistoryParams{
+		RepositoryParams: models.RepositoryParams{Id: "missing"},
+	})
+	var apiErr problem.ProblemJSON
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.Status)
+}
+
+func TestGetRepositoryFilters_ReturnsAllGroups(t *testing.T) {
+	repo := &stubRepo{}
+	svc := services.NewRepositoryService(repo)
+
+	groups, err := svc.GetRepositoryFilters(context.Background(), &models.RepositoryFiltersParams{})
+	require.NoError(t, err)
+
+	keys := make([]string, len(groups))
+	for i, g := range groups {
+		keys[i] = g.Key
+	}
+	assert.Contains(t, keys, "publiccode")
+	assert.Contains(t, keys, "archived")
+	assert.Contains(t, keys, "lastActivityAfter")
+	assert.Contains(t, keys, "softwareType")
+	assert.Contains(t, keys, "developmentStatus")
+	assert.Contains(t, keys, "maintenanceType")
+	assert.Contains(t, keys, "platforms")
+	assert.Contains(t, keys, "availableLanguages")
+	assert.Contains(t, keys, "license")
+	assert.Contains(t, keys, "organisation")
+}
+
+func TestGetRepositoryFilters_ToggleGroupHasCount(t *testing.T) {
+	n := 42
+	repo := &stubRepo{
+		filterCountsFunc: func(ctx context.Context, p *models.RepositoryFiltersParams) (*models.RepositoryFilterCounts, error) {
+			return &models.RepositoryFilterCounts{PublicCode: n, Archived: n}, nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	groups, err := svc.GetRepositoryFilters(context.Background(), &models.RepositoryFiltersParams{})
 	require.NoError(t, err)
 
 	var publiccodeGroup models.FilterGroup
@@ -898,7 +1940,64 @@ localisation:
 	assert.Equal(t, "https://git.example.org/upstream/digitale-balie", created.PublicCode.Url)
 }
 
-func TestPublishAllRepositoriesToTypesense_Disabled(t *testing.T) {
+func TestCreateRepository_DeletedRepositoryIsGone(t *testing.T) {
+	org := &models.Organisation{Uri: "https://example.org/organisations/test", Label: "Test Org"}
+	deletedAt := time.Now()
+	repo := &stubRepo{
+		findOrgByURIF: func(ctx context.Context, uri string) (*models.Organisation, error) {
+			return org, nil
+		},
+		findRepoByURLFunc: func(ctx context.Context, url string) (*models.Repository, error) {
+			return &models.Repository{Id: "repo-deleted", Url: url, OrganisationID: &org.Uri, DeletedAt: &deletedAt}, nil
+		},
+		saveRepositoryFunc: func(ctx context.Context, repository *models.Repository) error {
+			t.Fatalf("SaveRepository should not be called for a deleted repository")
+			return nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	inputURL := "https://git.example.org/test/deleted"
+	_, err := svc.CreateRepository(context.Background(), models.RepositoryInput{
+		Url:             &inputURL,
+		OrganisationUri: &org.Uri,
+	})
+	var p problem.ProblemJSON
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusGone, p.Status)
+	require.Len(t, p.Errors, 1)
+	assert.Equal(t, "gone", p.Errors[0].Code)
+}
+
+func TestCreateRepository_RejectsTakeOverOfOtherOrganisation(t *testing.T) {
+	own := "https://example.org/own"
+	other := "https://example.org/other"
+	repo := &stubRepo{
+		findOrgByURIF: func(ctx context.Context, uri string) (*models.Organisation, error) {
+			return &models.Organisation{Uri: uri}, nil
+		},
+		findRepoByURLFunc: func(ctx context.Context, url string) (*models.Repository, error) {
+			return &models.Repository{Id: "repo-other", Url: url, OrganisationID: &other}, nil
+		},
+		saveRepositoryFunc: func(ctx context.Context, repository *models.Repository) error {
+			t.Fatalf("SaveRepository should not be called for a repository of another organisation")
+			return nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{ClientID: "gemeente", Organisations: []string{own}})
+
+	inputURL := "https://git.example.org/other/app"
+	_, err := svc.CreateRepository(ctx, models.RepositoryInput{
+		Url:             &inputURL,
+		OrganisationUri: &own,
+	})
+	var p problem.ProblemJSON
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusForbidden, p.Status)
+}
+
+func TestReindexTypesense_Disabled(t *testing.T) {
 	t.Setenv("ENABLE_TYPESENSE", "false")
 
 	repo := &stubRepo{
@@ -909,25 +2008,163 @@ func TestPublishAllRepositoriesToTypesense_Disabled(t *testing.T) {
 	}
 
 	service := services.NewRepositoryService(repo)
-	err := service.PublishAllRepositoriesToTypesense(context.Background())
-	assert.NoError(t, err)
+	_, err := service.ReindexTypesense(adminContext())
+	var p problem.ProblemJSON
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusConflict, p.Status)
+
+	gemeente := auth.WithPrincipal(context.Background(), &auth.Principal{ClientID: "gemeente-a"})
+	_, err = service.ReindexTypesense(gemeente)
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusForbidden, p.Status)
 }
 
-func TestPublishAllRepositoriesToTypesense_SendsDocumentsForActiveRepositories(t *testing.T) {
+func TestReindexTypesense_SwapsAliasAndResyncsChangedRepositories(t *testing.T) {
 	var mu sync.Mutex
-	sentIDs := map[string]int{}
+	var requests []string
+	imported := map[string][]string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/aliases/oss-register":
+			_, _ = w.Write([]byte(`{"name":"oss-register","collection_name":"oss-register_old"}`))
+		case strings.HasSuffix(r.URL.Path, "/documents/import"):
+			collection := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/collections/"), "/documents/import")
+			decoder := json.NewDecoder(r.Body)
+			for decoder.More() {
+				var document struct {
+					ID string `json:"id"`
+				}
+				require.NoError(t, decoder.Decode(&document))
+				imported[collection] = append(imported[collection], document.ID)
+				_, _ = w.Write([]byte(`{"success":true}` + "\n"))
+			}
+		case strings.HasSuffix(r.URL.Path, "/documents/export"):
+			_, _ = w.Write([]byte(`{"id":"repo-gone","tags":["oss-register","repository","repository-id:repo-gone"]}` + "\n" +
+				`{"id":"repo-5","tags":["oss-register","repository","repository-id:repo-5"]}` + "\n"))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("TYPESENSE_ENDPOINT", server.URL)
+	t.Setenv("TYPESENSE_API_KEY", "secret")
+	t.Setenv("TYPESENSE_COLLECTION", "oss-register")
+	t.Setenv("TYPESENSE_DEFAULT_TAGS", "oss-register,repository")
+	t.Setenv("ENABLE_TYPESENSE", "true")
+
+	prevClient := httpclient.HTTPClient
+	httpclient.HTTPClient = server.Client()
+	t.Cleanup(func() { httpclient.HTTPClient = prevClient })
+
+	calls := 0
+	repo := &stubRepo{
+		allRepositoriesFunc: func(ctx context.Context) ([]models.Repository, error) {
+			calls++
+			if calls == 1 {
+				return []models.Repository{
+					{Id: "repo-1", Active: true, Version: 1},
+					{Id: "repo-2", Active: false, Version: 1},
+					{Id: "repo-3", Active: true, Version: 1},
+				}, nil
+			}
+			// Tijdens de herindexering is repo-3 gewijzigd en repo-4 aangemaakt.
+			repos := []models.Repository{
+				{Id: "repo-1", Active: true, Version: 1},
+				{Id: "repo-2", Active: false, Version: 1},
+				{Id: "repo-3", Active: true, Version: 2},
+				{Id: "repo-4", Active: true, Version: 1},
+			}
+			if calls > 2 {
+				// repo-5 is na de inhaalslag aangemaakt en via de outbox
+				// geïndexeerd.
+				repos = append(repos, models.Repository{Id: "repo-5", Active: true, Version: 1})
+			}
+			return repos, nil
+		},
+	}
+
+	service := services.NewRepositoryService(repo)
+	result, err := service.ReindexTypesense(adminContext())
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(result.Collection, "oss-register_"))
+	assert.Equal(t, "oss-register_old", result.PreviousCollection)
+	assert.Equal(t, 2, result.Documents)
+	assert.Equal(t, 2, result.Resynced)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"repo-1", "repo-3"}, imported[result.Collection])
+	assert.Equal(t, []string{"repo-3", "repo-4"}, imported["oss-register"])
+	assert.Contains(t, requests, "PUT /aliases/oss-register")
+	assert.Contains(t, requests, "DELETE /collections/oss-register_old")
+	assert.Contains(t, requests, "DELETE /collections/oss-register/documents/repo-gone")
+	assert.NotContains(t, requests, "DELETE /collections/oss-register/documents/repo-5")
+}
+
+func TestReindexTypesense_RefusesWhileLockIsHeld(t *testing.T) {
+	t.Setenv("TYPESENSE_ENDPOINT", "http://typesense.invalid")
+	t.Setenv("TYPESENSE_API_KEY", "secret")
+	t.Setenv("ENABLE_TYPESENSE", "true")
+
+	repo := &stubRepo{
+		tryLockFunc: func(ctx context.Context, name string) (func(), bool, error) {
+			assert.Equal(t, "search-index-reindex", name)
+			return nil, false, nil
+		},
+		allRepositoriesFunc: func(ctx context.Context) ([]models.Repository, error) {
+			t.Fatalf("AllRepositorys should not be called while another reindex runs")
+			return nil, nil
+		},
+	}
+
+	service := services.NewRepositoryService(repo)
+	_, err := service.ReindexTypesense(adminContext())
+	var p problem.ProblemJSON
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusConflict, p.Status)
+	assert.Equal(t, "Reindex already running", p.Title)
+
+	require.NoError(t, service.EnsureSearchIndex(context.Background()))
+}
 
+func TestEnsureSearchIndex_CreatesMissingAlias(t *testing.T) {
+	var mu sync.Mutex
+	aliases := map[string]string{}
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodPost && r.URL.Path == "/collections/oss-register/documents" && r.URL.Query().Get("action") == "upsert" {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/aliases/oss-register" && r.Method == http.MethodGet:
+			if aliases["oss-register"] == "" {
+				http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"name":"oss-register","collection_name":%q}`, aliases["oss-register"])
+		case r.URL.Path == "/aliases/oss-register" && r.Method == http.MethodPut:
 			var body struct {
-				ID string `json:"id"`
+				CollectionName string `json:"collection_name"`
 			}
-			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
-			mu.Lock()
-			sentIDs[body.ID]++
-			mu.Unlock()
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			aliases["oss-register"] = body.CollectionName
+			_, _ = w.Write([]byte(`{}`))
+		case r.URL.Path == "/collections/oss-register" && r.Method == http.MethodGet:
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+		case strings.HasSuffix(r.URL.Path, "/documents/import"):
+			decoder := json.NewDecoder(r.Body)
+			for decoder.More() {
+				var document map[string]any
+				require.NoError(t, decoder.Decode(&document))
+				_, _ = w.Write([]byte(`{"success":true}` + "\n"))
+			}
+		default:
+			_, _ = w.Write([]byte(`{}`))
 		}
-		w.WriteHeader(http.StatusCreated)
 	}))
 	defer server.Close()
 
@@ -940,24 +2177,384 @@ func TestPublishAllRepositoriesToTypesense_SendsDocumentsForActiveRepositories(t
 	httpclient.HTTPClient = server.Client()
 	t.Cleanup(func() { httpclient.HTTPClient = prevClient })
 
+	reindexes := 0
 	repo := &stubRepo{
 		allRepositoriesFunc: func(ctx context.Context) ([]models.Repository, error) {
-			return []models.Repository{
-				{Id: "repo-1", Name: "Active repo", Active: true},
-				{Id: "repo-2", Name: "Inactive repo", Active: false},
-				{Id: "repo-3", Name: "Also active", Active: true},
-			}, nil
+			reindexes++
+			return []models.Repository{{Id: "repo-1", Active: true, Version: 1}}, nil
 		},
 	}
 
 	service := services.NewRepositoryService(repo)
-	err := service.PublishAllRepositoriesToTypesense(context.Background())
-	assert.NoError(t, err)
+	require.NoError(t, service.EnsureSearchIndex(context.Background()))
+	mu.Lock()
+	assert.True(t, strings.HasPrefix(aliases["oss-register"], "oss-register_"))
+	mu.Unlock()
+	assert.Equal(t, 3, reindexes, "the index is built, caught up and reconciled once")
+
+	require.NoError(t, service.EnsureSearchIndex(context.Background()))
+	assert.Equal(t, 3, reindexes, "an existing alias is left alone")
+}
+
+func TestApplySearchCuration_PushesToLiveCollection(t *testing.T) {
+	var mu sync.Mutex
+	var upserts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			mu.Lock()
+			upserts = append(upserts, r.URL.Path)
+			mu.Unlock()
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("TYPESENSE_ENDPOINT", server.URL)
+	t.Setenv("TYPESENSE_API_KEY", "secret")
+	t.Setenv("TYPESENSE_COLLECTION", "oss-register")
+	t.Setenv("ENABLE_TYPESENSE", "true")
+
+	prevClient := httpclient.HTTPClient
+	httpclient.HTTPClient = server.Client()
+	t.Cleanup(func() { httpclient.HTTPClient = prevClient })
+
+	service := services.NewRepositoryService(&stubRepo{})
+	curation, err := service.ApplySearchCuration(adminContext())
+	require.NoError(t, err)
 
 	mu.Lock()
 	defer mu.Unlock()
-	assert.Equal(t, map[string]int{
-		"repo-1": 1,
-		"repo-3": 1,
-	}, sentIDs)
+	assert.Len(t, upserts, len(curation.Synonyms)+len(curation.Pins))
+	assert.Contains(t, upserts, "/collections/oss-register/synonyms/register-gemeente")
+
+	var p problem.ProblemJSON
+	gemeente := auth.WithPrincipal(context.Background(), &auth.Principal{ClientID: "gemeente-a"})
+	_, err = service.ApplySearchCuration(gemeente)
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusForbidden, p.Status)
+
+	t.Setenv("TYPESENSE_CURATION_FILE", "does-not-exist.json")
+	_, err = service.SearchCuration(adminContext())
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusInternalServerError, p.Status)
+}
+
+// adminContext returns the context of an admin client, as set by the auth
+// middleware.
+func adminContext() context.Context {
+	return auth.WithPrincipal(context.Background(), &auth.Principal{ClientID: "beheer", Admin: true})
+}
+
+func signGitHub(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookStubRepo(t *testing.T, existing *models.Repository, saved **models.Repository) *stubRepo {
+	t.Helper()
+	orgURI := "https://example.org/org"
+	return &stubRepo{
+		gitOrgListFunc: func(ctx context.Context, page, perPage int, organisation, after *string) ([]models.GitOrganisatie, models.Pagination, error) {
+			return []models.GitOrganisatie{
+				{Id: "git-1", Url: "https://github.com", OrganisationID: &orgURI, WebhookSecret: "too-broad"},
+				{Id: "git-2", Url: "https://github.com/acme", OrganisationID: &orgURI, WebhookSecret: "s3cret"},
+				{Id: "git-3", Url: "https://gitlab.com/acme", OrganisationID: &orgURI, WebhookSecret: "gl-token"},
+				{Id: "git-4", Url: "https://codeberg.org/acme", OrganisationID: &orgURI, WebhookSecret: "gitea-secret"},
+				{Id: "git-5", Url: "https://codeberg.org/nosecret", OrganisationID: &orgURI},
+			}, models.Pagination{Pagination: commonpagination.Pagination{TotalPages: 1}}, nil
+		},
+		findRepoByNormFunc: func(ctx context.Context, url string) (*models.Repository, error) {
+			if existing != nil && util.SameRepositoryURL(existing.Url, url) {
+				copied := *existing
+				return &copied, nil
+			}
+			return nil, nil
+		},
+		saveRepositoryFunc: func(ctx context.Context, repository *models.Repository) error {
+			assert.Equal(t, util.ActorWebhook, util.ActorFromContext(ctx))
+			*saved = repository
+			return nil
+		},
+	}
+}
+
+func TestHandleWebhook_GitHubPushRefreshesRepository(t *testing.T) {
+	util.SetPublicCodeValidatorForTest(t, fakePublicCodeValidator{})
+	publicCode := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("publiccodeYmlVersion: \"0.4\"\nname: Refreshed name\n"))
+	}))
+	defer publicCode.Close()
+
+	existing := &models.Repository{Id: "repo-1", Name: "Old name", Url: "https://github.com/acme/app", PublicCodeUrl: publicCode.URL}
+	var saved *models.Repository
+	svc := services.NewRepositoryService(webhookStubRepo(t, existing, &saved))
+
+	body := []byte(`{"ref":"refs/heads/main","repository":{"html_url":"https://github.com/acme/app","archived":true}}`)
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "push")
+	header.Set("X-Hub-Signature-256", signGitHub("s3cret", body))
+
+	result, err := svc.HandleWebhook(context.Background(), models.WebhookForgeGitHub, header, body)
+	require.NoError(t, err)
+	assert.Equal(t, &models.WebhookResult{Status: models.WebhookStatusRefreshed, Event: "push", RepositoryId: "repo-1"}, result)
+	require.NotNil(t, saved)
+	assert.Equal(t, "Refreshed name", saved.Name)
+	assert.True(t, saved.Archived)
+	assert.True(t, saved.Active)
+	assert.WithinDuration(t, time.Now(), saved.LastActivityAt, time.Minute)
+	assert.WithinDuration(t, time.Now(), saved.LastCrawledAt, time.Minute)
+}
+
+func TestHandleWebhook_VerifiesSignature(t *testing.T) {
+	existing := &models.Repository{Id: "repo-1", Url: "https://github.com/acme/app"}
+	var saved *models.Repository
+	svc := services.NewRepositoryService(webhookStubRepo(t, existing, &saved))
+	body := []byte(`{"repository":{"html_url":"https://github.com/acme/app"}}`)
+
+	cases := map[string]struct {
+		forge  string
+		header map[string]string
+		body   []byte
+	}{
+		"github wrong secret": {models.WebhookForgeGitHub, map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": signGitHub("too-broad", body)}, body},
+		"github missing":      {models.WebhookForgeGitHub, map[string]string{"X-GitHub-Event": "push"}, body},
+		"gitlab wrong token": {models.WebhookForgeGitLab, map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "nope"},
+			[]byte(`{"project":{"web_url":"https://gitlab.com/acme/app"}}`)},
+		"gitea without secret": {models.WebhookForgeGitea, map[string]string{"X-Gitea-Event": "push", "X-Gitea-Signature": "00"},
+			[]byte(`{"repository":{"html_url":"https://codeberg.org/nosecret/app"}}`)},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tc.header {
+				header.Set(k, v)
+			}
+			_, err := svc.HandleWebhook(context.Background(), tc.forge, header, tc.body)
+			var apiErr problem.ProblemJSON
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, http.StatusUnauthorized, apiErr.Status)
+		})
+	}
+	assert.Nil(t, saved)
+}
+
+func TestHandleWebhook_GitLabAndGitea(t *testing.T) {
+	existing := &models.Repository{Id: "repo-1", Url: "https://gitlab.com/acme/app"}
+	var saved *models.Repository
+	svc := services.NewRepositoryService(webhookStubRepo(t, existing, &saved))
+
+	header := http.Header{}
+	header.Set("X-Gitlab-Event", "Push Hook")
+	header.Set("X-Gitlab-Token", "gl-token")
+	result, err := svc.HandleWebhook(context.Background(), models.WebhookForgeGitLab, header,
+		[]byte(`{"project":{"web_url":"https://gitlab.com/acme/app"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookStatusRefreshed, result.Status)
+	require.NotNil(t, saved)
+
+	// Gitea ondertekent met een hex HMAC zonder prefix.
+	existing = &models.Repository{Id: "repo-2", Url: "https://codeberg.org/acme/app", Archived: true}
+	saved = nil
+	svc = services.NewRepositoryService(webhookStubRepo(t, existing, &saved))
+	body := []byte(`{"action":"edited","repository":{"html_url":"https://codeberg.org/acme/app","archived":false}}`)
+	header = http.Header{}
+	header.Set("X-Gitea-Event", "repository")
+	header.Set("X-Gitea-Signature", strings.TrimPrefix(signGitHub("gitea-secret", body), "sha256="))
+	result, err = svc.HandleWebhook(context.Background(), models.WebhookForgeGitea, header, body)
+	require.NoError(t, err)
+	assert.Equal(t, "repo-2", result.RepositoryId)
+	require.NotNil(t, saved)
+	assert.False(t, saved.Archived)
+	assert.True(t, saved.LastActivityAt.IsZero(), "repository events do not count as activity")
+}
+
+func TestHandleWebhook_IgnoresAndRejects(t *testing.T) {
+	var saved *models.Repository
+	svc := services.NewRepositoryService(webhookStubRepo(t, nil, &saved))
+	body := []byte(`{"repository":{"html_url":"https://github.com/acme/unknown"}}`)
+
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "ping")
+	result, err := svc.HandleWebhook(context.Background(), models.WebhookForgeGitHub, header, []byte(`{"zen":"hi"}`))
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookStatusIgnored, result.Status)
+
+	header.Set("X-GitHub-Event", "push")
+	header.Set("X-Hub-Signature-256", signGitHub("s3cret", body))
+	result, err = svc.HandleWebhook(context.Background(), models.WebhookForgeGitHub, header, body)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookStatusIgnored, result.Status)
+	assert.Equal(t, "repository is not registered", result.Reason)
+	assert.Nil(t, saved)
+
+	var apiErr problem.ProblemJSON
+	_, err = svc.HandleWebhook(context.Background(), "bitbucket", header, body)
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.Status)
+
+	_, err = svc.HandleWebhook(context.Background(), models.WebhookForgeGitHub, http.Header{}, body)
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.Status)
+
+	_, err = svc.HandleWebhook(context.Background(), models.WebhookForgeGitHub, header, []byte(`not json`))
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.Status)
+
+	// Een repository buiten de geregistreerde git organisaties krijgt hetzelfde
+	// antwoord als een foute handtekening.
+	_, err = svc.HandleWebhook(context.Background(), models.WebhookForgeGitHub, header, []byte(`{"repository":{"html_url":"https://bitbucket.org/x/y"}}`))
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.Status)
+	assert.Equal(t, "Invalid webhook signature", apiErr.Title)
+}
+
+func TestHandleWebhook_MatchesRepositoryURLNormalized(t *testing.T) {
+	existing := &models.Repository{Id: "repo-1", Url: "https://github.com/acme/app"}
+	var saved *models.Repository
+	svc := services.NewRepositoryService(webhookStubRepo(t, existing, &saved))
+
+	body := []byte(`{"repository":{"html_url":"https://github.com/Acme/App.git/"}}`)
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "push")
+	header.Set("X-Hub-Signature-256", signGitHub("s3cret", body))
+
+	result, err := svc.HandleWebhook(context.Background(), models.WebhookForgeGitHub, header, body)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookStatusRefreshed, result.Status)
+	assert.Equal(t, "repo-1", result.RepositoryId)
+	require.NotNil(t, saved)
+	assert.Equal(t, "https://github.com/acme/app", saved.Url)
+}
+
+func TestListAuditEvents_ParsesFilters(t *testing.T) {
+	var got models.AuditEventFilter
+	repo := &stubRepo{
+		auditEventsFunc: func(ctx context.Context, page, perPage int, filter models.AuditEventFilter) ([]models.AuditEvent, models.Pagination, error) {
+			got = filter
+			return nil, models.Pagination{}, nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	actor := " gemeente-a "
+	from := "2026-01-01T00:00:00Z"
+	to := "2026-02-01T00:00:00+01:00"
+	events, _, err := svc.ListAuditEvents(adminContext(), &models.ListAuditEventsParams{Actor: &actor, From: &from, To: &to})
+	require.NoError(t, err)
+	require.NotNil(t, events)
+	require.Equal(t, "gemeente-a", got.Actor)
+	require.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), got.From.UTC())
+	require.Equal(t, time.Date(2026, 1, 31, 23, 0, 0, 0, time.UTC), got.To.UTC())
+
+	_, _, err = svc.ListAuditEvents(adminContext(), &models.ListAuditEventsParams{From: &to, To: &from})
+	var p problem.ProblemJSON
+	require.ErrorAs(t, err, &p)
+	require.Equal(t, http.StatusBadRequest, p.Status)
+
+	gemeente := auth.WithPrincipal(context.Background(), &auth.Principal{ClientID: "gemeente-a"})
+	_, _, err = svc.ListAuditEvents(gemeente, &models.ListAuditEventsParams{})
+	require.ErrorAs(t, err, &p)
+	require.Equal(t, http.StatusForbidden, p.Status)
+}
+
+func TestListSearchIndexOperations_FiltersOnStatus(t *testing.T) {
+	var gotStatus string
+	repo := &stubRepo{
+		indexOpsFunc: func(ctx context.Context, page, perPage int, status string) ([]models.SearchIndexOperation, models.Pagination, error) {
+			gotStatus = status
+			return nil, models.Pagination{}, nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	status := " failed "
+	operations, _, err := svc.ListSearchIndexOperations(adminContext(), &models.ListSearchIndexOperationsParams{Status: &status})
+	require.NoError(t, err)
+	require.NotNil(t, operations)
+	require.Equal(t, models.SearchIndexOperationFailed, gotStatus)
+
+	status = "done"
+	_, _, err = svc.ListSearchIndexOperations(adminContext(), &models.ListSearchIndexOperationsParams{Status: &status})
+	var p problem.ProblemJSON
+	require.ErrorAs(t, err, &p)
+	require.Equal(t, http.StatusBadRequest, p.Status)
+	require.Equal(t, "#/status", p.Errors[0].Location)
+
+	gemeente := auth.WithPrincipal(context.Background(), &auth.Principal{ClientID: "gemeente-a"})
+	_, _, err = svc.ListSearchIndexOperations(gemeente, nil)
+	require.ErrorAs(t, err, &p)
+	require.Equal(t, http.StatusForbidden, p.Status)
+}
+
+func TestRecordAuditEvent_SetsOutcome(t *testing.T) {
+	var saved []models.AuditEvent
+	repo := &stubRepo{
+		saveAuditEventFunc: func(ctx context.Context, event *models.AuditEvent) error {
+			saved = append(saved, *event)
+			return nil
+		},
+	}
+	svc := services.NewRepositoryService(repo)
+
+	require.NoError(t, svc.RecordAuditEvent(context.Background(), &models.AuditEvent{Status: http.StatusCreated}))
+	require.NoError(t, svc.RecordAuditEvent(context.Background(), &models.AuditEvent{Status: http.StatusConflict}))
+
+	require.Len(t, saved, 2)
+	require.NotEmpty(t, saved[0].Id)
+	require.False(t, saved[0].OccurredAt.IsZero())
+	require.Equal(t, models.AuditOutcomeSuccess, saved[0].Outcome)
+	require.Equal(t, models.AuditOutcomeFailure, saved[1].Outcome)
+}
+
+type jobSchedulerStub struct {
+	jobs       []models.Job
+	triggerErr error
+	triggered  []string
+}
+
+func (s *jobSchedulerStub) Jobs(context.Context) ([]models.Job, error) {
+	return s.jobs, nil
+}
+
+func (s *jobSchedulerStub) Trigger(name string) (*models.JobRun, error) {
+	s.triggered = append(s.triggered, name)
+	if s.triggerErr != nil {
+		return nil, s.triggerErr
+	}
+	return &models.JobRun{Id: "run-1", JobName: name, Status: models.JobRunRunning}, nil
+}
+
+func TestRunJob_MapsSchedulerErrors(t *testing.T) {
+	scheduler := &jobSchedulerStub{jobs: []models.Job{{Name: "repository-active"}}}
+	service := services.NewRepositoryService(&stubRepo{})
+	service.SetJobScheduler(scheduler)
+
+	jobs, err := service.ListJobs(adminContext())
+	require.NoError(t, err)
+	assert.Equal(t, scheduler.jobs, jobs)
+
+	run, err := service.RunJob(adminContext(), " repository-active ")
+	require.NoError(t, err)
+	assert.Equal(t, "repository-active", run.JobName)
+	assert.Equal(t, []string{"repository-active"}, scheduler.triggered)
+
+	var p problem.ProblemJSON
+	scheduler.triggerErr = services.ErrJobRunning
+	_, err = service.RunJob(adminContext(), "repository-active")
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusConflict, p.Status)
+
+	scheduler.triggerErr = services.ErrJobNotFound
+	_, err = service.RunJob(adminContext(), "unknown")
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusNotFound, p.Status)
+
+	gemeente := auth.WithPrincipal(context.Background(), &auth.Principal{ClientID: "gemeente-a"})
+	_, err = service.ListJobs(gemeente)
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusForbidden, p.Status)
+	_, err = service.RunJob(gemeente, "repository-active")
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusForbidden, p.Status)
 }
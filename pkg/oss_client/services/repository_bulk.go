@@ -0,0 +1,358 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
+	util "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/util"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/repositories"
+)
+
+const (
+	// maxBulkRepositories begrenst het aantal items per bulk request.
+	maxBulkRepositories = 1000
+	// maxBulkLineSize begrenst de lengte van één NDJSON-regel.
+	maxBulkLineSize = 1 << 20
+	// bulkPublicCodeWorkers begrenst hoeveel publiccode-bestanden tegelijk
+	// worden opgehaald en gevalideerd.
+	bulkPublicCodeWorkers = 8
+)
+
+// bulkItem houdt per item de invoer, de bestaande repository en het resultaat
+// bij; repository wordt gevuld zodra de invoer is toegepast.
+type bulkItem struct {
+	input      *models.RepositoryInput
+	existing   *models.Repository
+	org        *models.Organisation
+	repository *models.Repository
+	result     models.BulkRepositoryResult
+}
+
+// bulkLookup onthoudt binnen één request welke organisaties al zijn opgezocht
+// en welke urls al voorkwamen.
+type bulkLookup struct {
+	organisations map[string]*models.Organisation
+	urls          map[string]int
+}
+
+func newBulkLookup() *bulkLookup {
+	return &bulkLookup{
+		organisations: map[string]*models.Organisation{},
+		urls:          map[string]int{},
+	}
+}
+
+// BulkUpsertRepositories maakt of werkt een reeks repositories bij in één
+// database-transactie. Het body is een JSON array of, met ndjson, één
+// RepositoryInput per regel, met hoogstens maxBulkRepositories items. Het body
+// wordt gelezen tot dat maximum; leesfouten, zoals een te groot body, worden
+// ongewijzigd teruggegeven. Fouten per item komen in het resultaat van dat item
+// terecht; de overige items worden gewoon opgeslagen. Geslaagde items komen via
+// de outbox in Typesense.
+func (s *RepositoryService) BulkUpsertRepositories(ctx context.Context, body io.Reader, ndjson bool) (*models.BulkRepositoryResponse, error) {
+	raws, err := decodeBulkRepositories(body, ndjson)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*bulkItem, len(raws))
+	lookup := newBulkLookup()
+	for i, raw := range raws {
+		item := &bulkItem{result: models.BulkRepositoryResult{Index: i}}
+		items[i] = item
+
+		var input models.RepositoryInput
+		if err := json.Unmarshal(raw, &input); err != nil {
+			failBulkItem(item, problem.NewBadRequest("Invalid input",
+				bodyError("body", "invalid", "item must be a repository object"),
+			))
+			continue
+		}
+		if err := s.prepareBulkItem(ctx, item, &input, lookup); err != nil {
+			if !failBulkItem(item, err) {
+				return nil, err
+			}
+		}
+	}
+
+	applyBulkItems(ctx, items)
+	return s.saveBulkItems(ctx, items)
+}
+
+// UpsertRepositories maakt of werkt repositories bij zoals BulkUpsertRepositories,
+// maar dan voor al gedecodeerde input en zonder maximum aantal. Bedoeld voor
+// interne aanroepers zoals de crawler.
+func (s *RepositoryService) UpsertRepositories(ctx context.Context, inputs []models.RepositoryInput) (*models.BulkRepositoryResponse, error) {
+	items := make([]*bulkItem, len(inputs))
+	lookup := newBulkLookup()
+	for i := range inputs {
+		item := &bulkItem{result: models.BulkRepositoryResult{Index: i}}
+		items[i] = item
+		if err := s.prepareBulkItem(ctx, item, &inputs[i], lookup); err != nil {
+			if !failBulkItem(item, err) {
+				return nil, err
+			}
+		}
+	}
+
+	applyBulkItems(ctx, items)
+	return s.saveBulkItems(ctx, items)
+}
+
+// applyBulkItems past de invoer van de voorbereide items toe. Het ophalen en
+// valideren van publiccode.yml gebeurt met bulkPublicCodeWorkers tegelijk en
+// is per item in tijd begrensd, zodat één trage forge de rest niet ophoudt.
+func applyBulkItems(ctx context.Context, items []*bulkItem) {
+	pending := make(chan *bulkItem)
+	var wg sync.WaitGroup
+	for w := 0; w < bulkPublicCodeWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range pending {
+				repo := util.ApplyRepositoryInputContext(ctx, item.existing, item.input)
+				repo.Active = true
+				repo.OrganisationID = &item.org.Uri
+				repo.Organisation = item.org
+				item.repository = repo
+			}
+		}()
+	}
+	for _, item := range items {
+		if item.input != nil {
+			pending <- item
+		}
+	}
+	close(pending)
+	wg.Wait()
+}
+
+// saveBulkItems slaat de voorbereide items in één transactie op.
+func (s *RepositoryService) saveBulkItems(ctx context.Context, items []*bulkItem) (*models.BulkRepositoryResponse, error) {
+	err := s.repo.Transaction(ctx, func(repo repositories.RepositoriesRepository) error {
+		for _, item := range items {
+			if item.repository == nil {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := repo.SaveRepository(ctx, item.repository); err != nil {
+				if !failBulkItem(item, repositorySaveError(err)) {
+					log.Printf("[bulk] saving repository=%s failed: %v", item.repository.Id, err)
+					failBulkItem(item, problem.NewInternalServerError("Repository could not be saved"))
+				}
+				continue
+			}
+			item.result.Id = item.repository.Id
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.BulkRepositoryResponse{Results: make([]models.BulkRepositoryResult, len(items))}
+	for i, item := range items {
+		switch item.result.Status {
+		case models.BulkStatusCreated:
+			response.Created++
+		case models.BulkStatusUpdated:
+			response.Updated++
+		default:
+			response.Failed++
+		}
+		response.Results[i] = item.result
+	}
+
+	return response, nil
+}
+
+// prepareBulkItem valideert één item en zoekt de bestaande repository met
+// dezelfde url op; applyBulkItems past de invoer daarna buiten de transactie toe.
+func (s *RepositoryService) prepareBulkItem(ctx context.Context, item *bulkItem, input *models.RepositoryInput, lookup *bulkLookup) error {
+	item.result.Url = trimPtr(input.Url)
+
+	if details := repositoryInputErrors(input); len(details) > 0 {
+		return problem.NewBadRequest("Invalid input", details...)
+	}
+	if first, ok := lookup.urls[item.result.Url]; ok {
+		return problem.NewBadRequest("Invalid input",
+			bodyError("url", "duplicate", fmt.Sprintf("url already occurs at index %d", first)),
+		)
+	}
+	lookup.urls[item.result.Url] = item.result.Index
+
+	orgURL := trimPtr(input.OrganisationUri)
+	org, ok := lookup.organisations[orgURL]
+	if !ok {
+		found, err := s.repo.FindOrganisationByURI(ctx, orgURL)
+		if err != nil {
+			return err
+		}
+		org = found
+		lookup.organisations[orgURL] = org
+	}
+	if org == nil {
+		return problem.New(http.StatusNotFound, "Resource does not exist",
+			bodyError("organisationUri", "not_found", "organisation does not exist"),
+		)
+	}
+	if err := checkOrganisationAccess(ctx, org.Uri); err != nil {
+		return err
+	}
+
+	existing, err := s.repo.FindRepositoryByURL(ctx, item.result.Url)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.DeletedAt != nil {
+		return problem.New(http.StatusGone, "Resource has been deleted",
+			bodyError("url", "gone", "repository has been deleted"),
+		)
+	}
+
+	item.result.Status = models.BulkStatusCreated
+	if existing != nil {
+		if err := checkOrganisationAccessPtr(ctx, existing.OrganisationID); err != nil {
+			return err
+		}
+		item.result.Status = models.BulkStatusUpdated
+	}
+
+	item.input = input
+	item.existing = existing
+	item.org = org
+	return nil
+}
+
+// repositoryInputErrors controleert de verplichte velden van een RepositoryInput
+// zoals de binding-tags dat voor POST /repositories doen.
+func repositoryInputErrors(input *models.RepositoryInput) []problem.ErrorDetail {
+	var details []problem.ErrorDetail
+
+	repoURL := trimPtr(input.Url)
+	if repoURL == "" {
+		details = append(details, bodyError("url", "required", "url is required"))
+	} else if _, err := url.ParseRequestURI(repoURL); err != nil {
+		details = append(details, bodyError("url", "url", "must be a valid URL"))
+	}
+
+	orgURL := trimPtr(input.OrganisationUri)
+	if orgURL == "" {
+		details = append(details, bodyError("organisationUri", "required", "organisationUri is required"))
+	} else if _, err := url.ParseRequestURI(orgURL); err != nil {
+		details = append(details, bodyError("organisationUri", "url", "must be a valid URL"))
+	}
+
+	if publicCodeURL := trimPtr(input.PublicCodeUrl); publicCodeURL != "" {
+		if _, err := url.ParseRequestURI(publicCodeURL); err != nil {
+			details = append(details, bodyError("publicCodeUrl", "url", "must be a valid URL"))
+		}
+	}
+
+	return details
+}
+
+// failBulkItem markeert een item als mislukt. Alleen problem-fouten horen bij
+// een item; voor andere fouten geeft het false terug.
+func failBulkItem(item *bulkItem, err error) bool {
+	var p problem.ProblemJSON
+	if !errors.As(err, &p) {
+		return false
+	}
+	details := p.Errors
+	if len(details) == 0 {
+		details = []problem.ErrorDetail{{
+			In:       "body",
+			Location: "#",
+			Code:     strings.ToLower(strings.ReplaceAll(p.Title, " ", "_")),
+			Detail:   p.Title,
+		}}
+	}
+	item.input = nil
+	item.repository = nil
+	item.result.Id = ""
+	item.result.Status = models.BulkStatusFailed
+	item.result.Errors = details
+	return true
+}
+
+func decodeBulkRepositories(body io.Reader, ndjson bool) ([]json.RawMessage, error) {
+	tooMany := problem.NewBadRequest("Invalid input",
+		bodyError("body", "max", fmt.Sprintf("at most %d repositories per request", maxBulkRepositories)),
+	)
+	var raws []json.RawMessage
+	if ndjson {
+		// Ongeldige regels worden als mislukt item gerapporteerd.
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(nil, maxBulkLineSize)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			if len(raws) == maxBulkRepositories {
+				return nil, tooMany
+			}
+			raws = append(raws, json.RawMessage(bytes.Clone(line)))
+		}
+		if err := scanner.Err(); err != nil {
+			if errors.Is(err, bufio.ErrTooLong) {
+				return nil, problem.NewBadRequest("Invalid input",
+					bodyError("body", "max", fmt.Sprintf("a line must not exceed %d bytes", maxBulkLineSize)),
+				)
+			}
+			return nil, err
+		}
+	} else {
+		decoder := json.NewDecoder(body)
+		invalid := func(err error) error {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return err
+			}
+			return problem.NewBadRequest("Invalid input",
+				bodyError("body", "invalid", "body must be a JSON array"),
+			)
+		}
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			return nil, invalid(err)
+		}
+		for decoder.More() {
+			if len(raws) == maxBulkRepositories {
+				return nil, tooMany
+			}
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err != nil {
+				return nil, invalid(err)
+			}
+			raws = append(raws, raw)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, invalid(err)
+		}
+		if _, err := decoder.Token(); err != io.EOF {
+			return nil, invalid(err)
+		}
+	}
+
+	if len(raws) == 0 {
+		return nil, problem.NewBadRequest("Invalid input",
+			bodyError("body", "required", "at least one repository is required"),
+		)
+	}
+	return raws, nil
+}
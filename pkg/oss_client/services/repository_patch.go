@@ -0,0 +1,274 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
+	util "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/util"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+)
+
+// repositoryPatch bevat de velden uit een JSON Merge Patch (RFC 7396). Een nil
+// pointer betekent dat het veld niet is meegestuurd; clear-velden geven aan dat
+// het veld expliciet op null is gezet.
+type repositoryPatch struct {
+	Url              *string
+	OrganisationUri  *string
+	PublicCodeUrl    *string
+	IsFork           *bool
+	Archived         *bool
+	Name             *string
+	ShortDescription *string
+	LastCrawledAt    *time.Time
+	LastActivityAt   *time.Time
+
+	clearPublicCode       bool
+	clearShortDescription bool
+}
+
+// PatchRepository past een JSON Merge Patch toe op een bestaande repository.
+// Alleen meegestuurde velden worden aangepast; publiccode wordt alleen opnieuw
+// opgehaald wanneer publicCodeUrl in de patch staat. Een niet-lege ifMatch wordt
+// vergeleken met de ETag van de opgeslagen versie.
+func (s *RepositoryService) PatchRepository(ctx context.Context, id, ifMatch string, body []byte) (*models.RepositoryDetail, error) {
+	if err := validateRepositoryID(id); err != nil {
+		return nil, err
+	}
+
+	patch, err := decodeRepositoryPatch(body)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetRepositoryByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, problem.NewNotFound("Resource does not exist")
+	}
+	if existing.DeletedAt != nil {
+		return nil, problem.NewGone("Resource has been deleted")
+	}
+	if err := checkRepositoryPrecondition(existing, ifMatch); err != nil {
+		return nil, err
+	}
+	if err := checkOrganisationAccessPtr(ctx, existing.OrganisationID); err != nil {
+		return nil, err
+	}
+
+	updated := existing
+	if patch.Url != nil {
+		updated.Url = *patch.Url
+	}
+	if patch.clearPublicCode {
+		updated.PublicCodeUrl = ""
+		updated.PublicCode = nil
+		updated.ForkBasedOnURLs = nil
+	} else if patch.PublicCodeUrl != nil {
+		updated = util.ApplyRepositoryInputContext(ctx, updated, &models.RepositoryInput{PublicCodeUrl: patch.PublicCodeUrl})
+	}
+	if patch.IsFork != nil {
+		updated.IsFork = *patch.IsFork
+	}
+	if patch.Archived != nil {
+		updated.Archived = *patch.Archived
+	}
+	if patch.Name != nil {
+		updated.Name = *patch.Name
+	}
+	if patch.clearShortDescription {
+		updated.ShortDescription = ""
+		updated.LongDescription = ""
+	} else if patch.ShortDescription != nil {
+		updated.ShortDescription = *patch.ShortDescription
+		updated.LongDescription = *patch.ShortDescription
+	}
+	if patch.LastCrawledAt != nil {
+		updated.LastCrawledAt = *patch.LastCrawledAt
+	}
+	if patch.LastActivityAt != nil {
+		updated.LastActivityAt = *patch.LastActivityAt
+	}
+
+	if patch.OrganisationUri != nil {
+		org, err := s.repo.FindOrganisationByURI(ctx, *patch.OrganisationUri)
+		if err != nil {
+			return nil, err
+		}
+		if org == nil {
+			return nil, problem.NewBadRequest("Invalid input",
+				bodyError("organisationUri", "not_found", "organisation does not exist"),
+			)
+		}
+		if err := checkOrganisationAccess(ctx, org.Uri); err != nil {
+			return nil, err
+		}
+		updated.OrganisationID = &org.Uri
+		updated.Organisation = org
+	}
+
+	if err := s.repo.SaveRepository(ctx, updated); err != nil {
+		return nil, repositorySaveError(err)
+	}
+
+	return util.ToRepositoryDetail(updated), nil
+}
+
+// decodeRepositoryPatch leest een merge patch document en valideert alle velden.
+// Alle fouten worden verzameld zodat de client ze in één response terugkrijgt.
+func decodeRepositoryPatch(body []byte) (*repositoryPatch, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return nil, problem.NewBadRequest("Invalid input",
+			bodyError("body", "invalid", "body must be a JSON object"),
+		)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	patch := &repositoryPatch{}
+	var details []problem.ErrorDetail
+	for _, key := range keys {
+		raw := fields[key]
+		null := isJSONNull(raw)
+
+		switch key {
+		case "url":
+			value, detail := decodePatchURL(key, raw, null)
+			if detail != nil {
+				details = append(details, *detail)
+				continue
+			}
+			patch.Url = &value
+		case "organisationUri":
+			value, detail := decodePatchURL(key, raw, null)
+			if detail != nil {
+				details = append(details, *detail)
+				continue
+			}
+			patch.OrganisationUri = &value
+		case "publicCodeUrl":
+			if null {
+				patch.clearPublicCode = true
+				continue
+			}
+			value, detail := decodePatchString(key, raw)
+			if detail != nil {
+				details = append(details, *detail)
+				continue
+			}
+			if value == "" {
+				patch.clearPublicCode = true
+				continue
+			}
+			if _, err := url.ParseRequestURI(value); err != nil {
+				details = append(details, bodyError(key, "url", "must be a valid URL"))
+				continue
+			}
+			patch.PublicCodeUrl = &value
+		case "isFork", "archived":
+			if null {
+				details = append(details, bodyError(key, "required", "must not be null"))
+				continue
+			}
+			var value bool
+			if err := json.Unmarshal(raw, &value); err != nil {
+				details = append(details, bodyError(key, "type", "must be a boolean"))
+				continue
+			}
+			if key == "isFork" {
+				patch.IsFork = &value
+			} else {
+				patch.Archived = &value
+			}
+		case "name":
+			if null {
+				details = append(details, bodyError(key, "required", "must not be null"))
+				continue
+			}
+			value, detail := decodePatchString(key, raw)
+			if detail != nil {
+				details = append(details, *detail)
+				continue
+			}
+			if value == "" {
+				details = append(details, bodyError(key, "required", "must not be empty"))
+				continue
+			}
+			patch.Name = &value
+		case "shortDescription":
+			if null {
+				patch.clearShortDescription = true
+				continue
+			}
+			value, detail := decodePatchString(key, raw)
+			if detail != nil {
+				details = append(details, *detail)
+				continue
+			}
+			patch.ShortDescription = &value
+		case "lastCrawledAt", "lastActivityAt":
+			if null {
+				details = append(details, bodyError(key, "required", "must not be null"))
+				continue
+			}
+			var value time.Time
+			if err := json.Unmarshal(raw, &value); err != nil {
+				details = append(details, bodyError(key, "format", "must be an RFC 3339 date-time"))
+				continue
+			}
+			if key == "lastCrawledAt" {
+				patch.LastCrawledAt = &value
+			} else {
+				patch.LastActivityAt = &value
+			}
+		default:
+			details = append(details, bodyError(key, "unknown", "field cannot be patched"))
+		}
+	}
+
+	if len(details) > 0 {
+		return nil, problem.NewBadRequest("Invalid input", details...)
+	}
+	return patch, nil
+}
+
+func decodePatchURL(field string, raw json.RawMessage, null bool) (string, *problem.ErrorDetail) {
+	if null {
+		detail := bodyError(field, "required", "must not be null")
+		return "", &detail
+	}
+	value, detail := decodePatchString(field, raw)
+	if detail != nil {
+		return "", detail
+	}
+	if _, err := url.ParseRequestURI(value); err != nil {
+		d := bodyError(field, "url", "must be a valid URL")
+		return "", &d
+	}
+	return value, nil
+}
+
+func decodePatchString(field string, raw json.RawMessage) (string, *problem.ErrorDetail) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		detail := bodyError(field, "type", "must be a string")
+		return "", &detail
+	}
+	return strings.TrimSpace(value), nil
+}
+
+func isJSONNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/auth"
+	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+)
+
+// ListSearchIndexOperations geeft de openstaande en mislukte Typesense-operaties
+// uit de outbox terug, oudste eerst. Alleen admin clients mogen ze inzien.
+func (s *RepositoryService) ListSearchIndexOperations(ctx context.Context, p *models.ListSearchIndexOperationsParams) ([]models.SearchIndexOperation, models.Pagination, error) {
+	if !auth.IsAdmin(ctx) {
+		return nil, models.Pagination{}, problem.NewForbidden("Admin access required")
+	}
+	if p == nil {
+		p = &models.ListSearchIndexOperationsParams{}
+	}
+
+	status := trimPtr(p.Status)
+	switch status {
+	case "", models.SearchIndexOperationPending, models.SearchIndexOperationFailed:
+	default:
+		return nil, models.Pagination{}, problem.NewBadRequest("Invalid input",
+			queryError("status", "enum", "status must be one of pending, failed"),
+		)
+	}
+
+	operations, pagination, err := s.repo.GetSearchIndexOperations(ctx, p.Page, p.PerPage, status)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	if operations == nil {
+		operations = []models.SearchIndexOperation{}
+	}
+	return operations, pagination, nil
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/auth"
+	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	typesense "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/services/typesense"
+)
+
+// searchIndexReindexLock is de databaselock die een herindexering vasthoudt,
+// zodat twee herindexeringen, ook vanuit verschillende processen, niet elkaars
+// collectie weggooien.
+const searchIndexReindexLock = "search-index-reindex"
+
+// ReindexTypesense bouwt de Typesense-index opnieuw op in een nieuwe collectie
+// en zet de alias daarna in één keer om, zodat zoeken blijft werken. Alleen
+// admin clients mogen een herindexering starten; vanaf de CLI draait die met
+// een interne context.
+func (s *RepositoryService) ReindexTypesense(ctx context.Context) (*models.SearchIndexReindex, error) {
+	if !auth.IsAdmin(ctx) {
+		return nil, problem.NewForbidden("Admin access required")
+	}
+	if !typesense.Enabled() {
+		return nil, problem.New(http.StatusConflict, "Typesense indexing is disabled")
+	}
+	unlock, ok, err := s.repo.TryLock(ctx, searchIndexReindexLock)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, problem.New(http.StatusConflict, "Reindex already running")
+	}
+	defer unlock()
+	return s.reindexTypesense(ctx)
+}
+
+// EnsureSearchIndex bouwt bij de eerste start de Typesense-index op als de
+// alias nog niet bestaat; zonder alias mislukt elke outbox-operatie. Loopt er
+// elders al een herindexering, dan maakt die de alias aan.
+func (s *RepositoryService) EnsureSearchIndex(ctx context.Context) error {
+	if !typesense.Enabled() {
+		return nil
+	}
+	unlock, ok, err := s.repo.TryLock(ctx, searchIndexReindexLock)
+	if err != nil || !ok {
+		return err
+	}
+	defer unlock()
+
+	exists, err := typesense.IndexExists(ctx)
+	if err != nil || exists {
+		return err
+	}
+	result, err := s.reindexTypesense(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("[typesense] created search index %s with %d repositories", result.Collection, result.Documents)
+	return nil
+}
+
+func (s *RepositoryService) reindexTypesense(ctx context.Context) (*models.SearchIndexReindex, error) {
+	startedAt := time.Now().UTC()
+	repos, err := s.repo.AllRepositorys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := typesense.Reindex(ctx, repos)
+	if result == nil {
+		if errors.Is(err, typesense.ErrDisabled) {
+			return nil, problem.New(http.StatusConflict, "Typesense indexing is disabled")
+		}
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("[typesense] reindex: %v", err)
+	}
+
+	resynced, err := s.catchUpReindex(ctx, repos)
+	if err != nil {
+		log.Printf("[typesense] reindex catch-up failed: %v", err)
+	}
+
+	log.Printf("[typesense] reindexed %d repositories into %s", result.Documents, result.Collection)
+	return &models.SearchIndexReindex{
+		Collection:         result.Collection,
+		PreviousCollection: result.PreviousCollection,
+		Documents:          result.Documents,
+		Resynced:           resynced,
+		StartedAt:          startedAt,
+		FinishedAt:         time.Now().UTC(),
+	}, nil
+}
+
+// catchUpReindex synchroniseert de repositories die tijdens de herindexering
+// zijn gewijzigd. Die wijzigingen kwamen via de outbox in de oude collectie
+// terecht en zijn met die collectie verdwenen. snapshot is de stand waarmee
+// de nieuwe collectie is gevuld.
+func (s *RepositoryService) catchUpReindex(ctx context.Context, snapshot []models.Repository) (int, error) {
+	repos, err := s.repo.AllRepositorys(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	before := make(map[string]models.Repository, len(snapshot))
+	for _, repository := range snapshot {
+		before[repository.Id] = repository
+	}
+	var changed []models.Repository
+	for _, repository := range repos {
+		previous, ok := before[repository.Id]
+		if ok && previous.Version == repository.Version && typesense.Indexed(&previous) == typesense.Indexed(&repository) {
+			continue
+		}
+		changed = append(changed, repository)
+	}
+
+	var errs []error
+	if len(changed) > 0 {
+		errs = append(errs, typesense.SyncRepositories(ctx, changed))
+	}
+	// Reconcile laadt de repositories opnieuw na het exporteren van de
+	// documenten, zodat een repository die intussen is aangemaakt niet als
+	// verouderd document wordt verwijderd. Hard verwijderde repositories
+	// ontbreken dan en hun documenten verdwijnen.
+	if _, err := typesense.Reconcile(ctx, s.repo.AllRepositorys); err != nil {
+		errs = append(errs, err)
+	}
+	return len(changed), errors.Join(errs...)
+}
+
+// SearchCuration geeft de synoniemen en vastgepinde resultaten terug zoals ze
+// naar Typesense worden gestuurd. Alleen admin clients mogen ze inzien.
+func (s *RepositoryService) SearchCuration(ctx context.Context) (*models.SearchCuration, error) {
+	if !auth.IsAdmin(ctx) {
+		return nil, problem.NewForbidden("Admin access required")
+	}
+	return loadSearchCuration()
+}
+
+// ApplySearchCuration stuurt de synoniemen en vastgepinde resultaten naar de
+// live collectie, zodat een gewijzigd curatiebestand zonder herindexering
+// actief wordt.
+func (s *RepositoryService) ApplySearchCuration(ctx context.Context) (*models.SearchCuration, error) {
+	if !auth.IsAdmin(ctx) {
+		return nil, problem.NewForbidden("Admin access required")
+	}
+	if !typesense.Enabled() {
+		return nil, problem.New(http.StatusConflict, "Typesense indexing is disabled")
+	}
+	curation, err := loadSearchCuration()
+	if err != nil {
+		return nil, err
+	}
+	if err := typesense.ApplyCuration(ctx, curation); err != nil {
+		return nil, err
+	}
+	log.Printf("[typesense] applied %d synonyms and %d pins", len(curation.Synonyms), len(curation.Pins))
+	return curation, nil
+}
+
+func loadSearchCuration() (*models.SearchCuration, error) {
+	curation, err := typesense.LoadCuration()
+	if err != nil {
+		log.Printf("[typesense] %v", err)
+		return nil, problem.NewInternalServerError("Invalid search curation")
+	}
+	return curation, nil
+}
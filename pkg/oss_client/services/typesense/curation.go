@@ -0,0 +1,254 @@
+package typesense
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+)
+
+const (
+	// EnvCurationFile points at a JSON file with synonyms and pins that
+	// replaces the built-in curation.json.
+	EnvCurationFile = "TYPESENSE_CURATION_FILE"
+
+	// curationIDPrefix prefixes the ids of the synonyms and overrides the
+	// register manages, so that others in a shared collection are left alone.
+	curationIDPrefix = "register-"
+)
+
+//go:embed curation.json
+var defaultCuration []byte
+
+// labelSynonyms lists the English equivalents of the Dutch labels in
+// models.SoftwareTypeLabels, DevelopmentStatusLabels and MaintenanceTypeLabels,
+// keyed by the publiccode.yml value.
+var labelSynonyms = map[string]map[string][]string{
+	"softwaretype": {
+		"standalone/web":     {"web application", "web app", "webapplicatie"},
+		"standalone/desktop": {"desktop application", "desktop app"},
+		"standalone/mobile":  {"mobile application", "mobile app"},
+		"standalone/backend": {"back-end", "server"},
+		"standalone/iot":     {"internet of things"},
+		"standalone/other":   {"other standalone"},
+		"addon":              {"add-on", "extension", "extensie"},
+		"library":            {"bibliotheek"},
+		"configurationFiles": {"configuration files", "configuration", "configuratie"},
+	},
+	"developmentstatus": {
+		"concept":     {"proof of concept"},
+		"development": {"in development", "development"},
+		"beta":        {"bèta"},
+		"stable":      {"stable"},
+		"obsolete":    {"obsolete", "deprecated"},
+	},
+	"maintenancetype": {
+		"none":      {"no maintenance", "unmaintained"},
+		"internal":  {"internal"},
+		"contract":  {"contracted"},
+		"community": {"gemeenschap"},
+	},
+}
+
+// LoadCuration returns the curation from EnvCurationFile, or the built-in
+// curation.json when it is unset, followed by the synonyms of the filter
+// labels.
+func LoadCuration() (*models.SearchCuration, error) {
+	data := defaultCuration
+	if path := strings.TrimSpace(os.Getenv(EnvCurationFile)); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("typesense: read curation: %w", err)
+		}
+	}
+
+	var curation models.SearchCuration
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&curation); err != nil {
+		return nil, fmt.Errorf("typesense: decode curation: %w", err)
+	}
+	if err := validateCuration(&curation); err != nil {
+		return nil, err
+	}
+	curation.Synonyms = append(curation.Synonyms, buildLabelSynonyms()...)
+	if curation.Pins == nil {
+		curation.Pins = []models.SearchPin{}
+	}
+	return &curation, nil
+}
+
+func validateCuration(curation *models.SearchCuration) error {
+	var errs []error
+	ids := map[string]bool{}
+	for i, synonym := range curation.Synonyms {
+		if !validCurationID(synonym.Id) {
+			errs = append(errs, fmt.Errorf("synonyms[%d]: id %q must be lowercase letters, digits and dashes", i, synonym.Id))
+		} else if ids["synonym:"+synonym.Id] {
+			errs = append(errs, fmt.Errorf("synonyms[%d]: duplicate id %q", i, synonym.Id))
+		}
+		ids["synonym:"+synonym.Id] = true
+		if len(synonym.Synonyms) < 2 && (synonym.Root == "" || len(synonym.Synonyms) == 0) {
+			errs = append(errs, fmt.Errorf("synonyms[%d]: needs at least two terms, or a root and one term", i))
+		}
+	}
+	for i, pin := range curation.Pins {
+		if !validCurationID(pin.Id) {
+			errs = append(errs, fmt.Errorf("pins[%d]: id %q must be lowercase letters, digits and dashes", i, pin.Id))
+		} else if ids["pin:"+pin.Id] {
+			errs = append(errs, fmt.Errorf("pins[%d]: duplicate id %q", i, pin.Id))
+		}
+		ids["pin:"+pin.Id] = true
+		if strings.TrimSpace(pin.Query) == "" {
+			errs = append(errs, fmt.Errorf("pins[%d]: query is required", i))
+		}
+		if len(pin.Repositories) == 0 {
+			errs = append(errs, fmt.Errorf("pins[%d]: at least one repository is required", i))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("typesense: invalid curation: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func validCurationID(id string) bool {
+	if id == "" || strings.HasPrefix(id, "label-") {
+		return false
+	}
+	for _, r := range id {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// buildLabelSynonyms turns every filter label into a synonym group of the
+// Dutch label, the publiccode.yml value and its English equivalents.
+func buildLabelSynonyms() []models.SearchSynonym {
+	labels := map[string]map[string][2]string{
+		"softwaretype":      models.SoftwareTypeLabels,
+		"developmentstatus": models.DevelopmentStatusLabels,
+		"maintenancetype":   models.MaintenanceTypeLabels,
+	}
+
+	var synonyms []models.SearchSynonym
+	for _, group := range []string{"softwaretype", "developmentstatus", "maintenancetype"} {
+		codes := make([]string, 0, len(labels[group]))
+		for code := range labels[group] {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+
+		for _, code := range codes {
+			var terms []string
+			add := func(term string) {
+				term = strings.ToLower(strings.TrimSpace(term))
+				if term != "" && !slices.Contains(terms, term) {
+					terms = append(terms, term)
+				}
+			}
+			for _, part := range strings.Split(labels[group][code][0], " / ") {
+				add(part)
+			}
+			if !strings.Contains(code, "/") {
+				add(code)
+			}
+			for _, term := range labelSynonyms[group][code] {
+				add(term)
+			}
+			if len(terms) < 2 {
+				continue
+			}
+			id := "label-" + group + "-" + strings.ToLower(strings.ReplaceAll(code, "/", "-"))
+			synonyms = append(synonyms, models.SearchSynonym{Id: id, Synonyms: terms})
+		}
+	}
+	return synonyms
+}
+
+// ApplyCuration pushes curation to the configured collection, see
+// applyCuration.
+func ApplyCuration(ctx context.Context, curation *models.SearchCuration) error {
+	cfg := loadConfigFromEnv()
+	if !cfg.Enabled() {
+		return ErrDisabled
+	}
+	return applyCuration(ctx, cfg, cfg.Collection, curation)
+}
+
+// applyCuration upserts the synonyms and pins of curation into collection and
+// removes the synonyms and overrides the register created before but that are
+// no longer in curation. Pins become Typesense overrides on the exact query.
+func applyCuration(ctx context.Context, cfg config, collection string, curation *models.SearchCuration) error {
+	base := "/collections/" + url.PathEscape(collection)
+
+	synonymIDs := map[string]bool{}
+	for _, synonym := range curation.Synonyms {
+		id := curationIDPrefix + synonym.Id
+		synonymIDs[id] = true
+		body := map[string]any{"synonyms": synonym.Synonyms}
+		if synonym.Root != "" {
+			body["root"] = synonym.Root
+		}
+		if _, err := doRequest(ctx, cfg, http.MethodPut, base+"/synonyms/"+url.PathEscape(id), body, nil); err != nil {
+			return fmt.Errorf("typesense: upsert synonym %s: %w", synonym.Id, err)
+		}
+	}
+
+	overrideIDs := map[string]bool{}
+	for _, pin := range curation.Pins {
+		id := curationIDPrefix + pin.Id
+		overrideIDs[id] = true
+		includes := make([]map[string]any, len(pin.Repositories))
+		for i, repositoryID := range pin.Repositories {
+			includes[i] = map[string]any{"id": repositoryID, "position": i + 1}
+		}
+		body := map[string]any{
+			"rule":     map[string]string{"query": strings.TrimSpace(pin.Query), "match": "exact"},
+			"includes": includes,
+		}
+		if _, err := doRequest(ctx, cfg, http.MethodPut, base+"/overrides/"+url.PathEscape(id), body, nil); err != nil {
+			return fmt.Errorf("typesense: upsert pin %s: %w", pin.Id, err)
+		}
+	}
+
+	return errors.Join(
+		removeStaleCuration(ctx, cfg, base+"/synonyms", "synonyms", synonymIDs),
+		removeStaleCuration(ctx, cfg, base+"/overrides", "overrides", overrideIDs),
+	)
+}
+
+// removeStaleCuration deletes the register's entries under path whose id is
+// not in keep. key is the field of the list response that holds the entries.
+func removeStaleCuration(ctx context.Context, cfg config, path, key string, keep map[string]bool) error {
+	var list map[string][]struct {
+		ID string `json:"id"`
+	}
+	if _, err := doRequest(ctx, cfg, http.MethodGet, path, nil, &list); err != nil {
+		return fmt.Errorf("typesense: list %s: %w", key, err)
+	}
+
+	var errs []error
+	for _, entry := range list[key] {
+		if !strings.HasPrefix(entry.ID, curationIDPrefix) || keep[entry.ID] {
+			continue
+		}
+		status, err := doRequest(ctx, cfg, http.MethodDelete, path+"/"+url.PathEscape(entry.ID), nil, nil)
+		if err != nil && status != http.StatusNotFound {
+			errs = append(errs, fmt.Errorf("typesense: delete %s %s: %w", key, entry.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,142 @@
+package typesense_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/services/typesense"
+)
+
+func TestLoadCuration_IncludesLabelSynonyms(t *testing.T) {
+	curation, err := typesense.LoadCuration()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	synonyms := map[string][]string{}
+	for _, synonym := range curation.Synonyms {
+		synonyms[synonym.Id] = synonym.Synonyms
+	}
+	if !slices.Contains(synonyms["gemeente"], "municipality") {
+		t.Fatalf("missing gemeente synonyms: %v", synonyms["gemeente"])
+	}
+	if !slices.Contains(synonyms["zaakgericht-werken"], "zaaksysteem") {
+		t.Fatalf("missing zaaksysteem synonyms: %v", synonyms["zaakgericht-werken"])
+	}
+	if got := synonyms["label-softwaretype-standalone-web"]; !slices.Contains(got, "web applicatie") || !slices.Contains(got, "web application") {
+		t.Fatalf("unexpected web application synonyms: %v", got)
+	}
+
+	for group, labels := range map[string]map[string][2]string{
+		"softwaretype":      models.SoftwareTypeLabels,
+		"developmentstatus": models.DevelopmentStatusLabels,
+		"maintenancetype":   models.MaintenanceTypeLabels,
+	} {
+		for code := range labels {
+			id := "label-" + group + "-" + strings.ToLower(strings.ReplaceAll(code, "/", "-"))
+			if len(synonyms[id]) < 2 {
+				t.Errorf("no synonyms for %s %s", group, code)
+			}
+		}
+	}
+}
+
+func TestLoadCuration_ReadsFileFromEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "curation.json")
+	data := `{"synonyms":[{"id":"wmo","root":"wmo","synonyms":["wet maatschappelijke ondersteuning"]}],"pins":[{"id":"zaak","query":"zaak","repositories":["repo-1","repo-2"]}]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(typesense.EnvCurationFile, path)
+
+	curation, err := typesense.LoadCuration()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if curation.Synonyms[0].Id != "wmo" || curation.Synonyms[0].Root != "wmo" {
+		t.Fatalf("unexpected synonyms: %+v", curation.Synonyms[0])
+	}
+	if len(curation.Pins) != 1 || curation.Pins[0].Repositories[1] != "repo-2" {
+		t.Fatalf("unexpected pins: %+v", curation.Pins)
+	}
+}
+
+func TestLoadCuration_RejectsInvalidFile(t *testing.T) {
+	cases := map[string]string{
+		"unknown field":  `{"synonym":[]}`,
+		"single term":    `{"synonyms":[{"id":"a","synonyms":["gemeente"]}]}`,
+		"invalid id":     `{"synonyms":[{"id":"Gemeente","synonyms":["a","b"]}]}`,
+		"reserved id":    `{"synonyms":[{"id":"label-a","synonyms":["a","b"]}]}`,
+		"duplicate id":   `{"synonyms":[{"id":"a","synonyms":["a","b"]},{"id":"a","synonyms":["c","d"]}]}`,
+		"pin no query":   `{"pins":[{"id":"a","repositories":["repo-1"]}]}`,
+		"pin no results": `{"pins":[{"id":"a","query":"zaak"}]}`,
+	}
+	for name, data := range cases {
+		path := filepath.Join(t.TempDir(), "curation.json")
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(typesense.EnvCurationFile, path)
+		if _, err := typesense.LoadCuration(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestApplyCuration_UpsertsAndRemovesStaleEntries(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	bodies := map[string]map[string]any{}
+	useSearchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodPut:
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			bodies[r.URL.Path] = body
+			_, _ = w.Write([]byte(`{}`))
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/synonyms"):
+			_, _ = w.Write([]byte(`{"synonyms":[{"id":"register-gemeente"},{"id":"register-old"},{"id":"handmatig"}]}`))
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/overrides"):
+			_, _ = w.Write([]byte(`{"overrides":[{"id":"register-zaak"},{"id":"register-vervallen"}]}`))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	})
+
+	err := typesense.ApplyCuration(context.Background(), &models.SearchCuration{
+		Synonyms: []models.SearchSynonym{{Id: "gemeente", Synonyms: []string{"gemeente", "municipality"}}},
+		Pins:     []models.SearchPin{{Id: "zaak", Query: " zaak ", Repositories: []string{"repo-2", "repo-1"}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"PUT /collections/oss-register/synonyms/register-gemeente",
+		"PUT /collections/oss-register/overrides/register-zaak",
+		"GET /collections/oss-register/synonyms",
+		"DELETE /collections/oss-register/synonyms/register-old",
+		"GET /collections/oss-register/overrides",
+		"DELETE /collections/oss-register/overrides/register-vervallen",
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected requests:\nwant %v\ngot  %v", want, requests)
+	}
+
+	override, _ := json.Marshal(bodies["/collections/oss-register/overrides/register-zaak"])
+	wantOverride := `{"includes":[{"id":"repo-2","position":1},{"id":"repo-1","position":2}],"rule":{"match":"exact","query":"zaak"}}`
+	if string(override) != wantOverride {
+		t.Fatalf("unexpected override:\nwant %s\ngot  %s", wantOverride, override)
+	}
+}
@@ -1,8 +1,15 @@
 package typesense
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"strings"
 
 	httpclient "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/httpclient"
@@ -16,6 +23,9 @@ const (
 	defaultDetailBaseURL = "https://oss.developer.overheid.nl/repositories"
 	defaultLanguage      = "nl"
 	defaultItemPriority  = 1
+
+	// importBatchSize limits the number of documents per import request.
+	importBatchSize = 100
 )
 
 // ErrDisabled is returned when Typesense configuration is missing.
@@ -52,6 +62,142 @@ func PublishRepository(ctx context.Context, repository *models.Repository) (err
 	return commontypesense.UpsertDocument(ctx, httpclient.HTTPClient, cfg, buildDocument(cfg, repository))
 }
 
+// PublishRepositories pushes repositories to Typesense in batches using the
+// JSONL import endpoint instead of one request per repository.
+func PublishRepositories(ctx context.Context, repositories []models.Repository) error {
+	cfg := loadConfigFromEnv()
+	if !cfg.Enabled() {
+		return ErrDisabled
+	}
+
+	var errs []error
+	for start := 0; start < len(repositories); start += importBatchSize {
+		end := min(start+importBatchSize, len(repositories))
+		if err := importDocuments(ctx, cfg, repositories[start:end]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func importDocuments(ctx context.Context, cfg config, repositories []models.Repository) (err error) {
+	var payload bytes.Buffer
+	encoder := json.NewEncoder(&payload)
+	for i := range repositories {
+		if err := encoder.Encode(buildDocument(cfg, &repositories[i])); err != nil {
+			return fmt.Errorf("typesense: marshal payload: %w", err)
+		}
+	}
+
+	client := httpclient.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	base := strings.TrimRight(cfg.Endpoint, "/")
+	target := fmt.Sprintf("%s/collections/%s/documents/import?action=upsert", base, url.PathEscape(cfg.Collection))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, &payload)
+	if err != nil {
+		return fmt.Errorf("typesense: create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("X-TYPESENSE-API-KEY", cfg.APIKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("typesense: request failed: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("typesense: close response body: %w", closeErr)
+		}
+	}()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		body, readErr := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if readErr != nil {
+			return fmt.Errorf("typesense: read error response: %w", readErr)
+		}
+		return fmt.Errorf("typesense: import failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	// Typesense answers with one JSON line per document, in request order.
+	var failures []string
+	scanner := bufio.NewScanner(resp.Body)
+	for line := 0; scanner.Scan(); line++ {
+		var result struct {
+			Success bool   `json:"success"`
+			Error   string `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil || result.Success {
+			continue
+		}
+		id := ""
+		if line < len(repositories) {
+			id = repositories[line].Id
+		}
+		failures = append(failures, fmt.Sprintf("%s: %s", id, result.Error))
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("typesense: read import response: %w", err)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("typesense: import failed for %d document(s): %s", len(failures), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// DeleteRepository removes the document for the given repository id from Typesense.
+// A document that is already absent is not treated as an error.
+func DeleteRepository(ctx context.Context, id string) (err error) {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("typesense: repository id is empty")
+	}
+
+	cfg := loadConfigFromEnv()
+	if !cfg.Enabled() {
+		return ErrDisabled
+	}
+
+	client := httpclient.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	base := strings.TrimRight(cfg.Endpoint, "/")
+	target := fmt.Sprintf("%s/collections/%s/documents/%s", base, url.PathEscape(cfg.Collection), url.PathEscape(id))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, target, nil)
+	if err != nil {
+		return fmt.Errorf("typesense: create request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", cfg.APIKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("typesense: request failed: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("typesense: close response body: %w", closeErr)
+		}
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		body, readErr := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if readErr != nil {
+			return fmt.Errorf("typesense: read error response: %w", readErr)
+		}
+		return fmt.Errorf("typesense: delete failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
 func buildDocument(cfg config, repository *models.Repository) map[string]any {
 	doc := commontypesense.BaseDocument(cfg, repository.Id)
 
@@ -90,6 +236,8 @@ func buildDocument(cfg config, repository *models.Repository) map[string]any {
 		doc["tags"] = tags
 	}
 
+	addStructuredFields(doc, repository)
+
 	return doc
 }
 
@@ -227,7 +375,7 @@ func buildTags(cfg config, repository *models.Repository) []string {
 	}
 
 	if repositoryID := strings.TrimSpace(repository.Id); repositoryID != "" {
-		out = appendUnique(out, fmt.Sprintf("repository-id:%s", repositoryID), seen)
+		out = appendUnique(out, repositoryIDTagPrefix+repositoryID, seen)
 	}
 
 	if repository.Organisation != nil {
@@ -241,6 +389,9 @@ func buildTags(cfg config, repository *models.Repository) []string {
 	if forkType != "" {
 		out = appendUnique(out, fmt.Sprintf("forkType:%s", forkType), seen)
 	}
+	if repository.Archived {
+		out = appendUnique(out, "archived", seen)
+	}
 
 	pc := repository.PublicCode
 	if pc == nil {
@@ -2,6 +2,7 @@ package typesense
 
 import (
 	"testing"
+	"time"
 
 	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
 	"github.com/stretchr/testify/assert"
@@ -48,3 +49,53 @@ func TestLabelAndForkTypeFallbacks(t *testing.T) {
 	assert.Equal(t, "URL komt niet overeen met publiccode.yml", forkTypeLabel(models.RepositoryForkTypeURLMistake))
 	assert.Equal(t, "UNKNOWN", forkTypeLabel(models.RepositoryForkType("UNKNOWN")))
 }
+
+func TestBuildTagsMarksArchivedRepositories(t *testing.T) {
+	cfg := config{DefaultTags: []string{"oss-register"}}
+	assert.Contains(t, buildTags(cfg, &models.Repository{Id: "repo-1", Archived: true}), "archived")
+	assert.NotContains(t, buildTags(cfg, &models.Repository{Id: "repo-1"}), "archived")
+}
+
+func TestCollectionSchemaCoversDocumentFields(t *testing.T) {
+	cfg := loadConfigFromEnv()
+	doc := buildDocument(cfg, &models.Repository{
+		Id:             "repo-1",
+		Name:           "Mijn Repository",
+		Url:            "https://github.com/example/my-repo",
+		Organisation:   &models.Organisation{Label: "Ministerie van Test", Uri: "https://example.org/min-test"},
+		IsFork:         true,
+		LastActivityAt: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		PublicCode: &models.PublicCode{
+			SoftwareType:      "library",
+			DevelopmentStatus: "stable",
+			Platforms:         []string{"web"},
+			Legal:             &models.PublicCodeLegal{License: "EUPL-1.2"},
+			Localisation:      &models.PublicCodeLocalisation{AvailableLanguages: []string{"nl"}},
+		},
+	})
+
+	fields := map[string]string{}
+	for _, field := range collectionSchema("oss-register_test")["fields"].([]map[string]any) {
+		fields[field["name"].(string)] = field["type"].(string)
+	}
+	for name := range doc {
+		if name != "id" {
+			assert.Contains(t, fields, name)
+		}
+	}
+
+	assert.Equal(t, "library", doc["softwareType"])
+	assert.Equal(t, "EUPL-1.2", doc["license"])
+	assert.Equal(t, []string{"web"}, doc["platforms"])
+	assert.Equal(t, []string{"nl"}, doc["languages"])
+	assert.Equal(t, "Ministerie van Test", doc["organisation"])
+	assert.Equal(t, int64(1790812800), doc["lastActivityAt"])
+	assert.Equal(t, false, doc["archived"])
+	assert.Equal(t, "int64", fields["lastActivityAt"])
+}
+
+func TestAddStructuredFieldsLeavesOutEmptyValues(t *testing.T) {
+	doc := map[string]any{}
+	addStructuredFields(doc, &models.Repository{Archived: true, PublicCode: &models.PublicCode{Platforms: []string{" "}}})
+	assert.Equal(t, map[string]any{"archived": true}, doc)
+}
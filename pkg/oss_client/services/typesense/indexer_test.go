@@ -301,3 +301,134 @@ func TestPublishRepository_UsesReadableFallbackForRepositoryWithoutPublicCode(t
 		t.Fatalf("content missing readable fork type: %v", content)
 	}
 }
+
+func TestDeleteRepository_Disabled(t *testing.T) {
+	t.Setenv("TYPESENSE_ENDPOINT", "")
+	t.Setenv("TYPESENSE_API_KEY", "")
+	t.Setenv("TYPESENSE_COLLECTION", "")
+
+	err := typesense.DeleteRepository(context.Background(), "repo-1")
+	if !errors.Is(err, typesense.ErrDisabled) {
+		t.Fatalf("expected ErrDisabled, got %v", err)
+	}
+}
+
+func TestDeleteRepository_SendsDeleteAndIgnoresMissingDocument(t *testing.T) {
+	var capturedMethod, capturedPath, capturedKey string
+	status := http.StatusOK
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedMethod = r.Method
+		capturedPath = r.URL.Path
+		capturedKey = r.Header.Get("X-TYPESENSE-API-KEY")
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	t.Setenv("TYPESENSE_ENDPOINT", server.URL)
+	t.Setenv("TYPESENSE_API_KEY", "secret")
+	t.Setenv("TYPESENSE_COLLECTION", "oss-register")
+
+	prevClient := httpclient.HTTPClient
+	httpclient.HTTPClient = server.Client()
+	t.Cleanup(func() {
+		httpclient.HTTPClient = prevClient
+	})
+
+	if err := typesense.DeleteRepository(context.Background(), "repo-1"); err != nil {
+		t.Fatalf("DeleteRepository returned error: %v", err)
+	}
+	if capturedMethod != http.MethodDelete {
+		t.Fatalf("expected DELETE, got %s", capturedMethod)
+	}
+	if capturedPath != "/collections/oss-register/documents/repo-1" {
+		t.Fatalf("unexpected path %q", capturedPath)
+	}
+	if capturedKey != "secret" {
+		t.Fatalf("expected API key header, got %q", capturedKey)
+	}
+
+	status = http.StatusNotFound
+	if err := typesense.DeleteRepository(context.Background(), "repo-1"); err != nil {
+		t.Fatalf("expected missing document to be ignored, got %v", err)
+	}
+
+	status = http.StatusInternalServerError
+	if err := typesense.DeleteRepository(context.Background(), "repo-1"); err == nil {
+		t.Fatalf("expected error for failing Typesense response")
+	}
+}
+
+func TestPublishRepositories_Disabled(t *testing.T) {
+	t.Setenv("TYPESENSE_ENDPOINT", "")
+	t.Setenv("TYPESENSE_API_KEY", "")
+	t.Setenv("TYPESENSE_COLLECTION", "")
+
+	err := typesense.PublishRepositories(context.Background(), []models.Repository{{Id: "repo-1"}})
+	if !errors.Is(err, typesense.ErrDisabled) {
+		t.Fatalf("expected ErrDisabled, got %v", err)
+	}
+}
+
+func TestPublishRepositories_ImportsInBatches(t *testing.T) {
+	var requests int
+	var documents []map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Method != http.MethodPost {
+			t.Fatalf("expected POST, got %s", r.Method)
+		}
+		if r.URL.Path != "/collections/oss-register/documents/import" || r.URL.Query().Get("action") != "upsert" {
+			t.Fatalf("unexpected target %s", r.URL.String())
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("read body: %v", err)
+		}
+		var results []string
+		for _, line := range strings.Split(strings.TrimSpace(string(body)), "\n") {
+			var doc map[string]any
+			if err := json.Unmarshal([]byte(line), &doc); err != nil {
+				t.Fatalf("invalid JSONL line %q: %v", line, err)
+			}
+			documents = append(documents, doc)
+			if doc["id"] == "repo-broken" {
+				results = append(results, `{"success":false,"error":"Bad document"}`)
+				continue
+			}
+			results = append(results, `{"success":true}`)
+		}
+		_, _ = w.Write([]byte(strings.Join(results, "\n")))
+	}))
+	defer server.Close()
+
+	t.Setenv("TYPESENSE_ENDPOINT", server.URL)
+	t.Setenv("TYPESENSE_API_KEY", "secret")
+	t.Setenv("TYPESENSE_COLLECTION", "oss-register")
+
+	prevClient := httpclient.HTTPClient
+	httpclient.HTTPClient = server.Client()
+	t.Cleanup(func() {
+		httpclient.HTTPClient = prevClient
+	})
+
+	repos := make([]models.Repository, 150)
+	for i := range repos {
+		repos[i] = models.Repository{Id: "repo", Name: "Repo", Url: "https://example.org/repo"}
+	}
+	if err := typesense.PublishRepositories(context.Background(), repos); err != nil {
+		t.Fatalf("PublishRepositories returned error: %v", err)
+	}
+	if requests != 2 {
+		t.Fatalf("expected 2 import requests, got %d", requests)
+	}
+	if len(documents) != len(repos) {
+		t.Fatalf("expected %d documents, got %d", len(repos), len(documents))
+	}
+
+	err := typesense.PublishRepositories(context.Background(), []models.Repository{{Id: "repo-ok"}, {Id: "repo-broken"}})
+	if err == nil || !strings.Contains(err.Error(), "repo-broken: Bad document") {
+		t.Fatalf("expected per-document failure, got %v", err)
+	}
+}
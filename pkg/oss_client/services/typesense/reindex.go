@@ -0,0 +1,228 @@
+package typesense
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	httpclient "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/httpclient"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+)
+
+// collectionSchema is the schema of a versioned collection. It lists every
+// field buildDocument writes.
+func collectionSchema(name string) map[string]any {
+	optionalString := func(field string) map[string]any {
+		return map[string]any{"name": field, "type": "string", "optional": true}
+	}
+	unindexed := func(field string) map[string]any {
+		return map[string]any{"name": field, "type": "string", "optional": true, "index": false}
+	}
+	fields := []map[string]any{
+		optionalString("hierarchy.lvl0"),
+		optionalString("hierarchy.lvl1"),
+		optionalString("hierarchy.lvl2"),
+		optionalString("hierarchy.lvl3"),
+		optionalString("hierarchy.lvl4"),
+		optionalString("content"),
+		{"name": "tags", "type": "string[]", "facet": true, "optional": true},
+		{"name": "type", "type": "string", "facet": true},
+		{"name": "language", "type": "string", "facet": true},
+		{"name": "item_priority", "type": "int64"},
+		unindexed("url"),
+		unindexed("url_without_anchor"),
+		unindexed("anchor"),
+	}
+	return map[string]any{
+		"name":                  name,
+		"fields":                append(fields, structuredSchemaFields()...),
+		"default_sorting_field": "item_priority",
+	}
+}
+
+// ReindexResult describes a completed reindex.
+type ReindexResult struct {
+	Collection         string
+	PreviousCollection string
+	Documents          int
+}
+
+// Reindex builds a new versioned collection named after the configured one
+// (oss-register_20261018001500) from the indexed repositories and the
+// curation of LoadCuration, points the
+// alias with the configured name at it and drops the collection the alias
+// pointed at before. Searches keep hitting the old collection until the swap.
+//
+// When the configured name is still a plain collection, from before aliases
+// were used, it is dropped right before the alias is created; only that first
+// reindex has a short window without results.
+func Reindex(ctx context.Context, repositories []models.Repository) (*ReindexResult, error) {
+	cfg := loadConfigFromEnv()
+	if !cfg.Enabled() {
+		return nil, ErrDisabled
+	}
+
+	curation, err := LoadCuration()
+	if err != nil {
+		return nil, err
+	}
+
+	alias := cfg.Collection
+	collection := fmt.Sprintf("%s_%s", alias, time.Now().UTC().Format("20060102150405"))
+	if _, err := doRequest(ctx, cfg, http.MethodPost, "/collections", collectionSchema(collection), nil); err != nil {
+		return nil, fmt.Errorf("typesense: create collection %s: %w", collection, err)
+	}
+
+	target := cfg
+	target.Collection = collection
+	var indexed []models.Repository
+	for i := range repositories {
+		if Indexed(&repositories[i]) {
+			indexed = append(indexed, repositories[i])
+		}
+	}
+	for start := 0; start < len(indexed); start += importBatchSize {
+		end := min(start+importBatchSize, len(indexed))
+		if err := importDocuments(ctx, target, indexed[start:end]); err != nil {
+			return nil, errors.Join(err, dropCollection(ctx, cfg, collection))
+		}
+	}
+	if err := applyCuration(ctx, cfg, collection, curation); err != nil {
+		return nil, errors.Join(err, dropCollection(ctx, cfg, collection))
+	}
+
+	previous, err := aliasedCollection(ctx, cfg, alias)
+	if err != nil {
+		return nil, errors.Join(err, dropCollection(ctx, cfg, collection))
+	}
+	if previous == "" {
+		// A plain collection with the alias name would shadow the alias.
+		status, err := doRequest(ctx, cfg, http.MethodGet, "/collections/"+url.PathEscape(alias), nil, nil)
+		if err != nil && status != http.StatusNotFound {
+			return nil, errors.Join(err, dropCollection(ctx, cfg, collection))
+		}
+		if status == http.StatusOK {
+			if err := dropCollection(ctx, cfg, alias); err != nil {
+				return nil, errors.Join(err, dropCollection(ctx, cfg, collection))
+			}
+		}
+	}
+
+	if _, err := doRequest(ctx, cfg, http.MethodPut, "/aliases/"+url.PathEscape(alias), map[string]string{"collection_name": collection}, nil); err != nil {
+		return nil, errors.Join(fmt.Errorf("typesense: point alias %s at %s: %w", alias, collection, err), dropCollection(ctx, cfg, collection))
+	}
+
+	result := &ReindexResult{Collection: collection, PreviousCollection: previous, Documents: len(indexed)}
+	if previous != "" && previous != collection {
+		if err := dropCollection(ctx, cfg, previous); err != nil {
+			// The new collection is live; the old one only takes up space.
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// IndexExists reports whether the configured alias exists, or a plain
+// collection with its name from before aliases were used. Without either,
+// every sync fails until a reindex creates them.
+func IndexExists(ctx context.Context) (bool, error) {
+	cfg := loadConfigFromEnv()
+	if !cfg.Enabled() {
+		return false, ErrDisabled
+	}
+	collection, err := aliasedCollection(ctx, cfg, cfg.Collection)
+	if err != nil || collection != "" {
+		return collection != "", err
+	}
+	status, err := doRequest(ctx, cfg, http.MethodGet, "/collections/"+url.PathEscape(cfg.Collection), nil, nil)
+	if status == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("typesense: read collection %s: %w", cfg.Collection, err)
+	}
+	return true, nil
+}
+
+// aliasedCollection returns the collection alias points at, or "" when there
+// is no such alias.
+func aliasedCollection(ctx context.Context, cfg config, alias string) (string, error) {
+	var body struct {
+		CollectionName string `json:"collection_name"`
+	}
+	status, err := doRequest(ctx, cfg, http.MethodGet, "/aliases/"+url.PathEscape(alias), nil, &body)
+	if status == http.StatusNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("typesense: read alias %s: %w", alias, err)
+	}
+	return body.CollectionName, nil
+}
+
+func dropCollection(ctx context.Context, cfg config, name string) error {
+	status, err := doRequest(ctx, cfg, http.MethodDelete, "/collections/"+url.PathEscape(name), nil, nil)
+	if err != nil && status != http.StatusNotFound {
+		return fmt.Errorf("typesense: drop collection %s: %w", name, err)
+	}
+	return nil
+}
+
+// doRequest sends body as JSON to path and decodes the response into out. It
+// returns the status code, also when it is an error status.
+func doRequest(ctx context.Context, cfg config, method, path string, body, out any) (status int, err error) {
+	client := httpclient.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var payload io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("typesense: marshal payload: %w", err)
+		}
+		payload = bytes.NewReader(encoded)
+	}
+
+	target := strings.TrimRight(cfg.Endpoint, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, target, payload)
+	if err != nil {
+		return 0, fmt.Errorf("typesense: create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", cfg.APIKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("typesense: request failed: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("typesense: close response body: %w", closeErr)
+		}
+	}()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		errBody, readErr := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if readErr != nil {
+			return resp.StatusCode, fmt.Errorf("typesense: read error response: %w", readErr)
+		}
+		return resp.StatusCode, fmt.Errorf("typesense: %s %s failed with status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(errBody)))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("typesense: decode response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,208 @@
+package typesense_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/services/typesense"
+)
+
+// fakeCollections is a Typesense server that keeps collections and aliases.
+type fakeCollections struct {
+	mu          sync.Mutex
+	collections map[string]int
+	aliases     map[string]string
+	failImport  bool
+	requests    []string
+	// curated counts the synonym and override upserts per collection.
+	curated map[string]int
+}
+
+func useFakeCollections(t *testing.T, fake *fakeCollections) {
+	t.Helper()
+	if fake.collections == nil {
+		fake.collections = map[string]int{}
+	}
+	if fake.aliases == nil {
+		fake.aliases = map[string]string{}
+	}
+	fake.curated = map[string]int{}
+	useSearchServer(t, fake.serve)
+}
+
+func (f *fakeCollections) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if name, rest, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/collections/"), "/"); ok && (strings.HasPrefix(rest, "synonyms") || strings.HasPrefix(rest, "overrides")) {
+		if r.Method == http.MethodPut {
+			f.curated[name]++
+		}
+		_, _ = w.Write([]byte(`{}`))
+		return
+	}
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/collections":
+		var schema struct {
+			Name string `json:"name"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&schema)
+		f.collections[schema.Name] = 0
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/documents/import"):
+		if f.failImport {
+			http.Error(w, `{"message":"Bad JSON"}`, http.StatusBadRequest)
+			return
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/collections/"), "/documents/import")
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			f.collections[name]++
+			_, _ = w.Write([]byte(`{"success":true}` + "\n"))
+		}
+	case r.URL.Path == "/aliases/oss-register" && r.Method == http.MethodGet:
+		name, ok := f.aliases["oss-register"]
+		if !ok {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"name": "oss-register", "collection_name": name})
+	case r.URL.Path == "/aliases/oss-register" && r.Method == http.MethodPut:
+		var body struct {
+			CollectionName string `json:"collection_name"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.aliases["oss-register"] = body.CollectionName
+		_ = json.NewEncoder(w).Encode(map[string]string{"name": "oss-register", "collection_name": body.CollectionName})
+	case strings.HasPrefix(r.URL.Path, "/collections/"):
+		name := strings.TrimPrefix(r.URL.Path, "/collections/")
+		if _, ok := f.collections[name]; !ok {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.collections, name)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	default:
+		http.Error(w, `{"message":"unexpected request"}`, http.StatusTeapot)
+	}
+}
+
+func TestReindex_ImportsIntoNewCollectionAndSwapsAlias(t *testing.T) {
+	fake := &fakeCollections{
+		collections: map[string]int{"oss-register_20260101000000": 3},
+		aliases:     map[string]string{"oss-register": "oss-register_20260101000000"},
+	}
+	useFakeCollections(t, fake)
+
+	result, err := typesense.Reindex(context.Background(), []models.Repository{
+		{Id: "repo-1", Active: true},
+		{Id: "repo-2", Active: false},
+		{Id: "repo-3", Active: true, Archived: true},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.HasPrefix(result.Collection, "oss-register_") || result.Collection == "oss-register_20260101000000" {
+		t.Fatalf("unexpected collection: %s", result.Collection)
+	}
+	if result.PreviousCollection != "oss-register_20260101000000" || result.Documents != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if fake.aliases["oss-register"] != result.Collection {
+		t.Fatalf("alias points at %s, want %s", fake.aliases["oss-register"], result.Collection)
+	}
+	if len(fake.collections) != 1 || fake.collections[result.Collection] != 2 {
+		t.Fatalf("unexpected collections: %v", fake.collections)
+	}
+	if fake.curated[result.Collection] == 0 {
+		t.Fatalf("curation was not applied to %s", result.Collection)
+	}
+
+	want := []string{
+		"POST /collections",
+		"POST /collections/" + result.Collection + "/documents/import",
+		"GET /aliases/oss-register",
+		"PUT /aliases/oss-register",
+		"DELETE /collections/oss-register_20260101000000",
+	}
+	if strings.Join(fake.requests, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected requests:\nwant %v\ngot  %v", want, fake.requests)
+	}
+}
+
+func TestReindex_ReplacesPlainCollectionOnFirstRun(t *testing.T) {
+	fake := &fakeCollections{collections: map[string]int{"oss-register": 5}}
+	useFakeCollections(t, fake)
+
+	result, err := typesense.Reindex(context.Background(), []models.Repository{{Id: "repo-1", Active: true}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.PreviousCollection != "" {
+		t.Fatalf("unexpected previous collection: %s", result.PreviousCollection)
+	}
+	if _, ok := fake.collections["oss-register"]; ok {
+		t.Fatalf("plain collection was not dropped: %v", fake.collections)
+	}
+	if fake.aliases["oss-register"] != result.Collection {
+		t.Fatalf("alias points at %s, want %s", fake.aliases["oss-register"], result.Collection)
+	}
+}
+
+func TestReindex_DropsNewCollectionWhenImportFails(t *testing.T) {
+	fake := &fakeCollections{
+		collections: map[string]int{"oss-register_20260101000000": 3},
+		aliases:     map[string]string{"oss-register": "oss-register_20260101000000"},
+		failImport:  true,
+	}
+	useFakeCollections(t, fake)
+
+	_, err := typesense.Reindex(context.Background(), []models.Repository{{Id: "repo-1", Active: true}})
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("expected import error, got %v", err)
+	}
+	if fake.aliases["oss-register"] != "oss-register_20260101000000" {
+		t.Fatalf("alias moved to %s", fake.aliases["oss-register"])
+	}
+	if len(fake.collections) != 1 || fake.collections["oss-register_20260101000000"] != 3 {
+		t.Fatalf("unexpected collections: %v", fake.collections)
+	}
+}
+
+func TestIndexExists(t *testing.T) {
+	cases := map[string]*fakeCollections{
+		"alias":            {aliases: map[string]string{"oss-register": "oss-register_20260101000000"}},
+		"plain collection": {collections: map[string]int{"oss-register": 5}},
+		"nothing":          {},
+	}
+	for name, fake := range cases {
+		t.Run(name, func(t *testing.T) {
+			useFakeCollections(t, fake)
+			exists, err := typesense.IndexExists(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if exists != (name != "nothing") {
+				t.Fatalf("exists = %v", exists)
+			}
+		})
+	}
+}
+
+func TestReindex_Disabled(t *testing.T) {
+	t.Setenv("ENABLE_TYPESENSE", "false")
+
+	if _, err := typesense.Reindex(context.Background(), nil); err != typesense.ErrDisabled {
+		t.Fatalf("expected ErrDisabled, got %v", err)
+	}
+}
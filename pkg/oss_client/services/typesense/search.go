@@ -0,0 +1,175 @@
+package typesense
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	httpclient "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/httpclient"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+)
+
+// EnvEnableSearch turns the Typesense search mode of the repository list off
+// when set to 0, false, no or off, while indexing stays on.
+const EnvEnableSearch = "ENABLE_TYPESENSE_SEARCH"
+
+// searchQueryBy are the document fields q is matched against, in order of
+// weight: the repository name, the content and the organisation label.
+const searchQueryBy = "hierarchy.lvl0,content,hierarchy.lvl1"
+
+// SearchEnabled reports whether the repository list searches Typesense for q.
+// It requires indexing to be enabled.
+func SearchEnabled() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(EnvEnableSearch))) {
+	case "0", "false", "no", "off":
+		return false
+	}
+	return Enabled()
+}
+
+// Searchable reports whether every filter in p is stored as a tag on the
+// documents, so that SearchRepositories can apply it. lastActivityAfter and
+// maintenanceType are not.
+func Searchable(p *models.RepositoryFiltersParams) bool {
+	if p == nil || strings.TrimSpace(p.Query) == "" {
+		return false
+	}
+	if p.LastActivityAfter != nil && strings.TrimSpace(*p.LastActivityAfter) != "" {
+		return false
+	}
+	return len(p.MaintenanceType) == 0
+}
+
+// SearchResult is a page of repository ids, best match first.
+type SearchResult struct {
+	IDs   []string
+	Found int
+}
+
+// SearchRepositories searches the repository documents for p.Query, filtered
+// on the tags written by buildTags.
+func SearchRepositories(ctx context.Context, p *models.RepositoryFiltersParams, page, perPage int) (result *SearchResult, err error) {
+	cfg := loadConfigFromEnv()
+	if !cfg.Enabled() {
+		return nil, ErrDisabled
+	}
+
+	client := httpclient.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	params := url.Values{}
+	params.Set("q", strings.TrimSpace(p.Query))
+	params.Set("query_by", searchQueryBy)
+	params.Set("filter_by", buildFilter(cfg, p))
+	params.Set("include_fields", "id")
+	params.Set("page", strconv.Itoa(page))
+	params.Set("per_page", strconv.Itoa(perPage))
+
+	base := strings.TrimRight(cfg.Endpoint, "/")
+	target := fmt.Sprintf("%s/collections/%s/documents/search?%s", base, url.PathEscape(cfg.Collection), params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("typesense: create request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", cfg.APIKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("typesense: request failed: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("typesense: close response body: %w", closeErr)
+		}
+	}()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		body, readErr := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if readErr != nil {
+			return nil, fmt.Errorf("typesense: read error response: %w", readErr)
+		}
+		return nil, fmt.Errorf("typesense: search failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var body struct {
+		Found int `json:"found"`
+		Hits  []struct {
+			Document struct {
+				ID string `json:"id"`
+			} `json:"document"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("typesense: decode search response: %w", err)
+	}
+
+	result = &SearchResult{IDs: make([]string, 0, len(body.Hits)), Found: body.Found}
+	for _, hit := range body.Hits {
+		if hit.Document.ID != "" {
+			result.IDs = append(result.IDs, hit.Document.ID)
+		}
+	}
+	return result, nil
+}
+
+// buildFilter translates p into a filter_by expression on tags. Values of one
+// filter match any of them, except platforms and languages, which must all be
+// present, as in the SQL filters.
+func buildFilter(cfg config, p *models.RepositoryFiltersParams) string {
+	var clauses []string
+	for _, tag := range cfg.DefaultTags {
+		clauses = append(clauses, "tags:="+quoteFilterValue(tag))
+	}
+	if p.Organisation != nil && strings.TrimSpace(*p.Organisation) != "" {
+		clauses = append(clauses, "tags:="+quoteFilterValue(strings.TrimSpace(*p.Organisation)))
+	}
+	if p.PublicCode == nil || *p.PublicCode {
+		clauses = append(clauses, "tags:=publiccode")
+	} else {
+		clauses = append(clauses, "tags:!=publiccode")
+	}
+	if p.Archived != nil && *p.Archived {
+		clauses = append(clauses, "tags:=archived")
+	} else {
+		clauses = append(clauses, "tags:!=archived")
+	}
+	for _, filter := range []struct {
+		prefix string
+		values []string
+	}{
+		{"softwareType:", p.SoftwareType},
+		{"developmentStatus:", p.DevelopmentStatus},
+		{"license:", p.License},
+	} {
+		if len(filter.values) == 0 {
+			continue
+		}
+		values := make([]string, len(filter.values))
+		for i, value := range filter.values {
+			values[i] = quoteFilterValue(filter.prefix + value)
+		}
+		clauses = append(clauses, "tags:=["+strings.Join(values, ",")+"]")
+	}
+	for _, value := range p.Platforms {
+		clauses = append(clauses, "tags:="+quoteFilterValue("platform:"+value))
+	}
+	for _, value := range p.AvailableLanguages {
+		clauses = append(clauses, "tags:="+quoteFilterValue("language:"+value))
+	}
+	return strings.Join(clauses, " && ")
+}
+
+// quoteFilterValue quotes a value in backticks, so that commas and other
+// filter syntax in it are taken literally.
+func quoteFilterValue(value string) string {
+	return "`" + strings.ReplaceAll(value, "`", "") + "`"
+}
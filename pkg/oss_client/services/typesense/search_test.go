@@ -0,0 +1,146 @@
+package typesense_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	httpclient "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/httpclient"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/services/typesense"
+)
+
+func useSearchServer(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	t.Setenv("TYPESENSE_ENDPOINT", server.URL)
+	t.Setenv("TYPESENSE_API_KEY", "secret")
+	t.Setenv("TYPESENSE_COLLECTION", "oss-register")
+	t.Setenv("TYPESENSE_DEFAULT_TAGS", "oss-register,repository")
+
+	prevClient := httpclient.HTTPClient
+	httpclient.HTTPClient = server.Client()
+	t.Cleanup(func() {
+		httpclient.HTTPClient = prevClient
+	})
+}
+
+func TestSearchRepositories_SendsQueryAndTagFilters(t *testing.T) {
+	var captured url.Values
+	var capturedPath, capturedKey string
+	useSearchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		captured = r.URL.Query()
+		capturedPath = r.URL.Path
+		capturedKey = r.Header.Get("X-TYPESENSE-API-KEY")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"found":3,"hits":[{"document":{"id":"repo-2"}},{"document":{"id":"repo-1"}}]}`))
+	})
+
+	organisation := "https://organisaties.example.com/min-test"
+	result, err := typesense.SearchRepositories(context.Background(), &models.RepositoryFiltersParams{
+		Query:              "  zaak ",
+		Organisation:       &organisation,
+		SoftwareType:       []string{"standalone/web", "library"},
+		License:            []string{"EUPL-1.2"},
+		Platforms:          []string{"web", "linux"},
+		AvailableLanguages: []string{"nl"},
+	}, 2, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if capturedPath != "/collections/oss-register/documents/search" {
+		t.Fatalf("unexpected path: %s", capturedPath)
+	}
+	if capturedKey != "secret" {
+		t.Fatalf("unexpected api key: %q", capturedKey)
+	}
+	if captured.Get("q") != "zaak" || captured.Get("page") != "2" || captured.Get("per_page") != "2" {
+		t.Fatalf("unexpected query parameters: %v", captured)
+	}
+	wantFilter := strings.Join([]string{
+		"tags:=`oss-register`",
+		"tags:=`repository`",
+		"tags:=`https://organisaties.example.com/min-test`",
+		"tags:=publiccode",
+		"tags:!=archived",
+		"tags:=[`softwareType:standalone/web`,`softwareType:library`]",
+		"tags:=[`license:EUPL-1.2`]",
+		"tags:=`platform:web`",
+		"tags:=`platform:linux`",
+		"tags:=`language:nl`",
+	}, " && ")
+	if got := captured.Get("filter_by"); got != wantFilter {
+		t.Fatalf("unexpected filter_by:\nwant %s\ngot  %s", wantFilter, got)
+	}
+
+	if result.Found != 3 || len(result.IDs) != 2 || result.IDs[0] != "repo-2" || result.IDs[1] != "repo-1" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestSearchRepositories_InvertsPublicCodeAndArchived(t *testing.T) {
+	var filter string
+	useSearchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		filter = r.URL.Query().Get("filter_by")
+		_, _ = w.Write([]byte(`{"found":0,"hits":[]}`))
+	})
+
+	no, yes := false, true
+	if _, err := typesense.SearchRepositories(context.Background(), &models.RepositoryFiltersParams{
+		Query:      "zaak",
+		PublicCode: &no,
+		Archived:   &yes,
+	}, 1, 20); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(filter, "tags:!=publiccode") || !strings.Contains(filter, "tags:=archived") {
+		t.Fatalf("unexpected filter_by: %s", filter)
+	}
+}
+
+func TestSearchRepositories_ReturnsErrorOnFailure(t *testing.T) {
+	useSearchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Not Ready or Lagging"}`, http.StatusServiceUnavailable)
+	})
+
+	_, err := typesense.SearchRepositories(context.Background(), &models.RepositoryFiltersParams{Query: "zaak"}, 1, 20)
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("expected status error, got %v", err)
+	}
+}
+
+func TestSearchEnabled(t *testing.T) {
+	useSearchServer(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	if !typesense.SearchEnabled() {
+		t.Fatalf("expected search to be enabled")
+	}
+	t.Setenv(typesense.EnvEnableSearch, "off")
+	if typesense.SearchEnabled() {
+		t.Fatalf("expected search to be disabled by %s", typesense.EnvEnableSearch)
+	}
+}
+
+func TestSearchable(t *testing.T) {
+	date := "2024-01-01"
+	cases := map[string]struct {
+		params *models.RepositoryFiltersParams
+		want   bool
+	}{
+		"query":             {&models.RepositoryFiltersParams{Query: "zaak", License: []string{"MIT"}}, true},
+		"no query":          {&models.RepositoryFiltersParams{License: []string{"MIT"}}, false},
+		"lastActivityAfter": {&models.RepositoryFiltersParams{Query: "zaak", LastActivityAfter: &date}, false},
+		"maintenanceType":   {&models.RepositoryFiltersParams{Query: "zaak", MaintenanceType: []string{"internal"}}, false},
+	}
+	for name, tc := range cases {
+		if got := typesense.Searchable(tc.params); got != tc.want {
+			t.Errorf("%s: want %v, got %v", name, tc.want, got)
+		}
+	}
+}
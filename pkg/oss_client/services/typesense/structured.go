@@ -0,0 +1,79 @@
+package typesense
+
+import (
+	"strings"
+
+	util "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/util"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+)
+
+// structuredSchemaFields defines the typed fields addStructuredFields writes.
+// Unlike the DocSearch fields and tags they hold one value each, so the
+// frontend can facet on them and filter lastActivityAt by range.
+func structuredSchemaFields() []map[string]any {
+	facet := func(field, fieldType string) map[string]any {
+		return map[string]any{"name": field, "type": fieldType, "facet": true, "optional": true}
+	}
+	return []map[string]any{
+		facet("softwareType", "string"),
+		facet("developmentStatus", "string"),
+		facet("license", "string"),
+		facet("platforms", "string[]"),
+		facet("languages", "string[]"),
+		facet("organisation", "string"),
+		facet("forkType", "string"),
+		{"name": "lastActivityAt", "type": "int64", "optional": true},
+		facet("archived", "bool"),
+	}
+}
+
+// addStructuredFields adds the typed fields of repository to doc. Fields
+// without a value are left out.
+func addStructuredFields(doc map[string]any, repository *models.Repository) {
+	doc["archived"] = repository.Archived
+
+	if organisation := repositoryOrganisationLabel(repository); organisation != "" {
+		doc["organisation"] = organisation
+	}
+	if forkType := util.DetectRepositoryForkType(repository); forkType != "" {
+		doc["forkType"] = string(forkType)
+	}
+	if !repository.LastActivityAt.IsZero() {
+		doc["lastActivityAt"] = repository.LastActivityAt.Unix()
+	}
+
+	pc := repository.PublicCode
+	if pc == nil {
+		return
+	}
+	if softwareType := strings.TrimSpace(pc.SoftwareType); softwareType != "" {
+		doc["softwareType"] = softwareType
+	}
+	if developmentStatus := strings.TrimSpace(pc.DevelopmentStatus); developmentStatus != "" {
+		doc["developmentStatus"] = developmentStatus
+	}
+	if pc.Legal != nil {
+		if license := strings.TrimSpace(pc.Legal.License); license != "" {
+			doc["license"] = license
+		}
+	}
+	if platforms := nonEmpty(pc.Platforms); len(platforms) > 0 {
+		doc["platforms"] = platforms
+	}
+	if pc.Localisation != nil {
+		if languages := nonEmpty(pc.Localisation.AvailableLanguages); len(languages) > 0 {
+			doc["languages"] = languages
+		}
+	}
+}
+
+// nonEmpty returns the trimmed, non-empty values in order.
+func nonEmpty(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			out = append(out, value)
+		}
+	}
+	return out
+}
@@ -0,0 +1,192 @@
+package typesense
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	httpclient "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/httpclient"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+)
+
+// repositoryIDTagPrefix prefixes the tag buildTags writes with the repository id.
+const repositoryIDTagPrefix = "repository-id:"
+
+// Indexed reports whether repository belongs in the index: it is active and
+// not deleted. Archived repositories stay indexed with the archived tag.
+func Indexed(repository *models.Repository) bool {
+	return repository != nil && repository.Active && repository.DeletedAt == nil
+}
+
+// SyncRepository publishes repository when it is Indexed and removes its
+// document otherwise, so that deactivated and deleted repositories drop out of
+// search.
+func SyncRepository(ctx context.Context, repository *models.Repository) error {
+	if repository == nil {
+		return fmt.Errorf("typesense: repository is nil")
+	}
+	if Indexed(repository) {
+		return PublishRepository(ctx, repository)
+	}
+	return DeleteRepository(ctx, repository.Id)
+}
+
+// SyncRepositories is SyncRepository for many repositories: indexed ones are
+// imported in batches, the documents of the others are removed.
+func SyncRepositories(ctx context.Context, repositories []models.Repository) error {
+	if !Enabled() {
+		return ErrDisabled
+	}
+
+	var publish []models.Repository
+	var errs []error
+	for i := range repositories {
+		if Indexed(&repositories[i]) {
+			publish = append(publish, repositories[i])
+			continue
+		}
+		if err := DeleteRepository(ctx, repositories[i].Id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(publish) > 0 {
+		if err := PublishRepositories(ctx, publish); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Reconcile removes the documents whose repository-id tag does not belong to
+// an indexed repository returned by load; repositories that are missing, such
+// as deleted ones, count as not indexed. load is called after the documents
+// are exported, so a repository indexed in the meantime is either in its
+// result or has no exported document. Only documents carrying the default
+// tags are considered, since the collection may be shared. It returns the
+// number of removed documents.
+func Reconcile(ctx context.Context, load func(context.Context) ([]models.Repository, error)) (int, error) {
+	cfg := loadConfigFromEnv()
+	if !cfg.Enabled() {
+		return 0, ErrDisabled
+	}
+
+	documents, err := exportDocuments(ctx, cfg)
+	if err != nil {
+		return 0, err
+	}
+	repositories, err := load(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	indexed := make(map[string]struct{}, len(repositories))
+	for i := range repositories {
+		if Indexed(&repositories[i]) {
+			indexed[repositories[i].Id] = struct{}{}
+		}
+	}
+
+	removed := 0
+	var errs []error
+	for _, document := range documents {
+		repositoryID := document.repositoryID()
+		if _, ok := indexed[repositoryID]; ok {
+			continue
+		}
+		if err := DeleteRepository(ctx, document.ID); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
+
+type exportedDocument struct {
+	ID   string   `json:"id"`
+	Tags []string `json:"tags"`
+}
+
+// repositoryID is the id in the repository-id tag, or the document id for
+// documents indexed before that tag existed.
+func (d exportedDocument) repositoryID() string {
+	for _, tag := range d.Tags {
+		if id, ok := strings.CutPrefix(tag, repositoryIDTagPrefix); ok {
+			return id
+		}
+	}
+	return d.ID
+}
+
+// exportDocuments streams the id and tags of every document with the default
+// tags from the JSONL export endpoint.
+func exportDocuments(ctx context.Context, cfg config) (documents []exportedDocument, err error) {
+	client := httpclient.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	params := url.Values{}
+	params.Set("include_fields", "id,tags")
+	if len(cfg.DefaultTags) > 0 {
+		clauses := make([]string, len(cfg.DefaultTags))
+		for i, tag := range cfg.DefaultTags {
+			clauses[i] = "tags:=" + quoteFilterValue(tag)
+		}
+		params.Set("filter_by", strings.Join(clauses, " && "))
+	}
+
+	base := strings.TrimRight(cfg.Endpoint, "/")
+	target := fmt.Sprintf("%s/collections/%s/documents/export?%s", base, url.PathEscape(cfg.Collection), params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("typesense: create request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", cfg.APIKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("typesense: request failed: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("typesense: close response body: %w", closeErr)
+		}
+	}()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		body, readErr := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if readErr != nil {
+			return nil, fmt.Errorf("typesense: read error response: %w", readErr)
+		}
+		return nil, fmt.Errorf("typesense: export failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var document exportedDocument
+		if err := json.Unmarshal(line, &document); err != nil {
+			return nil, fmt.Errorf("typesense: decode exported document: %w", err)
+		}
+		if document.ID != "" {
+			documents = append(documents, document)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("typesense: read export response: %w", err)
+	}
+	return documents, nil
+}
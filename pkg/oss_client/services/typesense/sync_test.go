@@ -0,0 +1,122 @@
+package typesense_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/services/typesense"
+)
+
+type recordedRequest struct {
+	method, path, filter string
+}
+
+func useRecordingServer(t *testing.T, export string) func() []recordedRequest {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []recordedRequest
+	useSearchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, recordedRequest{r.Method, r.URL.Path, r.URL.Query().Get("filter_by")})
+		mu.Unlock()
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(export))
+			return
+		}
+		if r.Method == http.MethodDelete && r.URL.Path == "/collections/oss-register/documents/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	return func() []recordedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recordedRequest(nil), requests...)
+	}
+}
+
+func TestSyncRepository_PublishesIndexedAndDeletesOthers(t *testing.T) {
+	requests := useRecordingServer(t, "")
+	deletedAt := time.Now()
+
+	for _, repository := range []*models.Repository{
+		{Id: "active", Active: true},
+		{Id: "archived", Active: true, Archived: true},
+		{Id: "inactive", Active: false},
+		{Id: "deleted", Active: true, DeletedAt: &deletedAt},
+	} {
+		if err := typesense.SyncRepository(context.Background(), repository); err != nil {
+			t.Fatalf("sync %s: %v", repository.Id, err)
+		}
+	}
+
+	want := []recordedRequest{
+		{method: http.MethodPost, path: "/collections/oss-register/documents"},
+		{method: http.MethodPost, path: "/collections/oss-register/documents"},
+		{method: http.MethodDelete, path: "/collections/oss-register/documents/inactive"},
+		{method: http.MethodDelete, path: "/collections/oss-register/documents/deleted"},
+	}
+	got := requests()
+	if len(got) != len(want) {
+		t.Fatalf("unexpected requests: %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("unexpected request %d: want %+v got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestReconcile_RemovesDocumentsWithoutIndexedRepository(t *testing.T) {
+	requests := useRecordingServer(t, `{"id":"repo-1","tags":["oss-register","repository","repository-id:repo-1"]}
+{"id":"repo-2","tags":["oss-register","repository","repository-id:repo-2"]}
+
+{"id":"legacy","tags":["oss-register","repository"]}
+{"id":"missing","tags":["oss-register","repository","repository-id:missing"]}
+`)
+
+	removed, err := typesense.Reconcile(context.Background(), func(context.Context) ([]models.Repository, error) {
+		return []models.Repository{
+			{Id: "repo-1", Active: true},
+			{Id: "repo-2", Active: false},
+		}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if removed != 3 {
+		t.Fatalf("expected 3 removed documents, got %d", removed)
+	}
+
+	got := requests()
+	if len(got) != 4 {
+		t.Fatalf("unexpected requests: %+v", got)
+	}
+	if got[0].path != "/collections/oss-register/documents/export" || got[0].filter != "tags:=`oss-register` && tags:=`repository`" {
+		t.Fatalf("unexpected export request: %+v", got[0])
+	}
+	for i, id := range []string{"repo-2", "legacy", "missing"} {
+		if want := "/collections/oss-register/documents/" + id; got[i+1].method != http.MethodDelete || got[i+1].path != want {
+			t.Fatalf("unexpected request %d: want DELETE %s got %+v", i+1, want, got[i+1])
+		}
+	}
+}
+
+func TestReconcile_ReturnsExportErrors(t *testing.T) {
+	useSearchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	load := func(context.Context) ([]models.Repository, error) {
+		t.Fatalf("repositories should not be loaded when the export fails")
+		return nil, nil
+	}
+	if _, err := typesense.Reconcile(context.Background(), load); err == nil {
+		t.Fatalf("expected export error")
+	}
+}
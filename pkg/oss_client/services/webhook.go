@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	problem "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/problem"
+	util "github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/helpers/util"
+	"github.com/developer-overheid-nl/don-oss-register/pkg/oss_client/models"
+	"github.com/google/uuid"
+)
+
+// webhookForge beschrijft per forge waar event en handtekening staan en welke
+// events een repository verversen.
+type webhookForge struct {
+	eventHeader string
+	pushEvents  map[string]bool
+	repoEvents  map[string]bool
+	verify      func(header http.Header, body []byte, secret string) bool
+}
+
+var webhookForges = map[string]webhookForge{
+	models.WebhookForgeGitHub: {
+		eventHeader: "X-GitHub-Event",
+		pushEvents:  map[string]bool{"push": true},
+		repoEvents:  map[string]bool{"repository": true},
+		verify: func(header http.Header, body []byte, secret string) bool {
+			signature, ok := strings.CutPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+			return ok && validHMAC(body, secret, signature)
+		},
+	},
+	models.WebhookForgeGitLab: {
+		eventHeader: "X-Gitlab-Event",
+		pushEvents:  map[string]bool{"Push Hook": true},
+		repoEvents:  map[string]bool{},
+		verify: func(header http.Header, _ []byte, secret string) bool {
+			token := header.Get("X-Gitlab-Token")
+			return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+		},
+	},
+	models.WebhookForgeGitea: {
+		eventHeader: "X-Gitea-Event",
+		pushEvents:  map[string]bool{"push": true},
+		repoEvents:  map[string]bool{"repository": true},
+		verify: func(header http.Header, body []byte, secret string) bool {
+			return validHMAC(body, secret, header.Get("X-Gitea-Signature"))
+		},
+	},
+}
+
+// webhookUnknownSecret wordt gecontroleerd voor repositories zonder git
+// organisatie of secret, zodat die even lang duren als een foute handtekening.
+var webhookUnknownSecret = uuid.NewString()
+
+// webhookPayload bevat de velden uit GitHub-, GitLab- en Gitea-payloads die
+// nodig zijn om de repository te vinden.
+type webhookPayload struct {
+	Repository *struct {
+		HTMLURL  string `json:"html_url"`
+		Archived *bool  `json:"archived"`
+	} `json:"repository"`
+	Project *struct {
+		WebURL string `json:"web_url"`
+	} `json:"project"`
+}
+
+func (p *webhookPayload) repositoryURL() string {
+	if p.Repository != nil && p.Repository.HTMLURL != "" {
+		return strings.TrimSpace(p.Repository.HTMLURL)
+	}
+	if p.Project != nil {
+		return strings.TrimSpace(p.Project.WebURL)
+	}
+	return ""
+}
+
+// HandleWebhook verwerkt een webhook van GitHub, GitLab of Gitea. De
+// handtekening wordt gecontroleerd met het secret van de git organisatie
+// waaronder de repository valt. Push- en repository-events verversen de
+// bestaande repository: het publiccode-bestand wordt opnieuw opgehaald en de
+// repository via de outbox opnieuw naar Typesense gestuurd. Overige events
+// worden genegeerd.
+func (s *RepositoryService) HandleWebhook(ctx context.Context, forge string, header http.Header, body []byte) (*models.WebhookResult, error) {
+	config, ok := webhookForges[forge]
+	if !ok {
+		return nil, problem.NewNotFound("Unknown forge")
+	}
+
+	event := strings.TrimSpace(header.Get(config.eventHeader))
+	if event == "" {
+		return nil, problem.NewBadRequest("Invalid input",
+			headerError(config.eventHeader, "required", "event header is required"),
+		)
+	}
+	result := &models.WebhookResult{Event: event, Status: models.WebhookStatusIgnored}
+	push := config.pushEvents[event]
+	if !push && !config.repoEvents[event] {
+		result.Reason = "event is not supported"
+		return result, nil
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, problem.NewBadRequest("Invalid input",
+			bodyError("body", "invalid", "body must be a JSON object"),
+		)
+	}
+	repoURL := payload.repositoryURL()
+	if repoURL == "" {
+		return nil, problem.NewBadRequest("Invalid input",
+			bodyError("repository", "required", "payload does not contain a repository url"),
+		)
+	}
+
+	gitOrg, err := s.gitOrganisationForRepository(ctx, repoURL)
+	if err != nil {
+		return nil, err
+	}
+	// Zonder git organisatie of secret volgt dezelfde controle en hetzelfde
+	// antwoord als bij een foute handtekening, zodat een ongeauthenticeerde
+	// aanroeper niet kan afleiden welke organisaties geregistreerd zijn.
+	secret, known := webhookUnknownSecret, false
+	if gitOrg != nil && gitOrg.WebhookSecret != "" {
+		secret, known = gitOrg.WebhookSecret, true
+	}
+	if !config.verify(header, body, secret) || !known {
+		return nil, problem.New(http.StatusUnauthorized, "Invalid webhook signature")
+	}
+
+	existing, err := s.repo.FindRepositoryByNormalizedURL(ctx, repoURL)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil || existing.DeletedAt != nil {
+		result.Reason = "repository is not registered"
+		return result, nil
+	}
+
+	now := time.Now().UTC()
+	input := models.RepositoryInput{LastCrawledAt: now}
+	if existing.PublicCodeUrl != "" {
+		publicCodeURL := existing.PublicCodeUrl
+		input.PublicCodeUrl = &publicCodeURL
+	}
+	if push {
+		input.LastActivityAt = now
+	}
+	if payload.Repository != nil && payload.Repository.Archived != nil {
+		input.Archived = payload.Repository.Archived
+	}
+
+	updated := util.ApplyRepositoryInputContext(ctx, existing, &input)
+	updated.Active = true
+	if err := s.repo.SaveRepository(util.WithActor(ctx, util.ActorWebhook), updated); err != nil {
+		return nil, repositorySaveError(err)
+	}
+
+	result.Status = models.WebhookStatusRefreshed
+	result.RepositoryId = updated.Id
+	return result, nil
+}
+
+// gitOrganisationForRepository zoekt de git organisatie met de langste url
+// waaronder de repository valt. Alleen de git organisaties op de host van de
+// repository worden uit de database gehaald.
+func (s *RepositoryService) gitOrganisationForRepository(ctx context.Context, repoURL string) (*models.GitOrganisatie, error) {
+	gitOrgs, err := s.repo.GetGitOrganisationsByHost(ctx, util.RepositoryURLHost(repoURL))
+	if err != nil {
+		return nil, err
+	}
+	var match *models.GitOrganisatie
+	for i := range gitOrgs {
+		if !util.RepositoryURLUnder(repoURL, gitOrgs[i].Url) {
+			continue
+		}
+		if match == nil || len(gitOrgs[i].Url) > len(match.Url) {
+			match = &gitOrgs[i]
+		}
+	}
+	return match, nil
+}
+
+func validHMAC(body []byte, secret, signature string) bool {
+	expected, err := hex.DecodeString(strings.TrimSpace(signature))
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}